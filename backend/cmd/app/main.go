@@ -1,16 +1,24 @@
 package main
 
 import (
-	"fmt"
+	"context"
+	"crypto/rand"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/joho/godotenv"
 
 	_ "ai-bot-deecogs/docs" // Import the Swagger docs
 	"ai-bot-deecogs/internal/api"
+	"ai-bot-deecogs/internal/clients"
+	"ai-bot-deecogs/internal/config"
 	"ai-bot-deecogs/internal/db"
+	"ai-bot-deecogs/internal/db/memory"
+	"ai-bot-deecogs/internal/services"
 
 	"github.com/gin-contrib/cors"
 
@@ -24,6 +32,9 @@ import (
 // @description API documentation for AI-Bot-DeeCogs
 // @host localhost:8080
 // @BasePath /
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
 func main() {
 
 	err := godotenv.Load()
@@ -31,31 +42,16 @@ func main() {
 		log.Println("No .env file found, loading environment variables from the system")
 	}
 
-	// Check if DATABASE_URL is set
-	// if os.Getenv("DATABASE_URL") == "" {
-	// 	log.Fatal("DATABASE_URL is not set")
-	// }
-	// Read DATABASE_URL
-	dbURL := os.Getenv("DATABASE_URL")
-	if dbURL == "" {
-		log.Fatal("DATABASE_URL is not set")
+	// Defaults < config file < environment < flags
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
 	}
-	fmt.Println("Using database:", dbURL)
 
 	r := gin.Default()
 
 	// Add CORS middleware before routes
-	allowedOrigins := []string{
-		"http://localhost:3000",
-		"http://localhost:3001", 
-		"https://triage-frontend-844145949029.europe-west1.run.app",
-	}
-	
-	// Add production origins from environment variable if available
-	if prodOrigin := os.Getenv("ALLOWED_ORIGIN"); prodOrigin != "" {
-		allowedOrigins = append(allowedOrigins, prodOrigin)
-	}
-	
+	allowedOrigins := cfg.CORS.AllowedOrigins
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     allowedOrigins,
 		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
@@ -71,12 +67,119 @@ func main() {
 			}
 			return false
 		},
-		MaxAge: 12 * time.Hour,
+		MaxAge: cfg.CORS.MaxAge,
 	}))
-	db.InitDB()
-	defer db.CloseDB()
+	var store *db.Store
+	if cfg.Database.Backend == "memory" {
+		log.Println("Using in-memory storage, data is lost on restart")
+		store = memory.NewStore()
+	} else {
+		pool, err := db.Connect(context.Background(), cfg.Database.URL, db.PoolConfig{
+			MaxConns:          cfg.Database.MaxConns,
+			MinConns:          cfg.Database.MinConns,
+			MaxConnLifetime:   cfg.Database.MaxConnLifetime,
+			MaxConnIdleTime:   cfg.Database.MaxConnIdleTime,
+			HealthCheckPeriod: cfg.Database.HealthCheckPeriod,
+			ConnectRetries:    cfg.Database.ConnectRetries,
+			ConnectBackoff:    cfg.Database.ConnectBackoff,
+		})
+		if err != nil {
+			log.Fatalf("Unable to connect to database: %v", err)
+		}
+		defer pool.Close()
+		log.Println("Connected to database")
 
-	db.PostgresVersion()
+		db.PostgresVersion(context.Background(), pool)
+		store = db.NewStore(pool)
+	}
+	secret := []byte(cfg.Auth.JWTSecret)
+	if len(secret) == 0 {
+		// config.Validate only lets development run without a secret
+		log.Println("Warning: JWT_SECRET not set, using a random key; tokens will not survive a restart")
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			log.Fatalf("Failed to generate JWT secret: %v", err)
+		}
+	}
+	// AI_BACKEND=fake replays a fixtures script so the flow runs without network access
+	var outbound *clients.Outbound
+	var ai clients.AIClient
+	if cfg.AI.Backend == "fake" {
+		fake, err := clients.NewFakeAIClient(cfg.AI.FixturesFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Println("Using the scripted fake AI backend")
+		ai = fake
+	} else {
+		outbound = clients.NewOutbound(clients.OutboundConfig{
+			MaxRetries:      cfg.AI.MaxRetries,
+			RetryBaseDelay:  cfg.AI.RetryBaseDelay,
+			RetryMaxDelay:   cfg.AI.RetryMaxDelay,
+			BreakerFailures: cfg.AI.BreakerFailures,
+			BreakerOpenFor:  cfg.AI.BreakerOpenFor,
+		})
+		ai = clients.NewHTTPAIClient(clients.AIEndpoints{
+			ChatURL:              cfg.AI.ChatURL,
+			QuestionnaireURL:     cfg.AI.QuestionnaireURL,
+			DashboardURL:         cfg.AI.DashboardURL,
+			ChatTimeout:          cfg.AI.TimeoutOr(cfg.AI.ChatTimeout),
+			VideoTimeout:         cfg.AI.TimeoutOr(cfg.AI.VideoTimeout),
+			QuestionnaireTimeout: cfg.AI.TimeoutOr(cfg.AI.QuestionnaireTimeout),
+			DashboardTimeout:     cfg.AI.TimeoutOr(cfg.AI.DashboardTimeout),
+		}, outbound)
+	}
+	// Finished analyses are reviewed for red flags before they are published
+	if cfg.RedFlags.Enabled {
+		if err := services.LoadRedFlagRules(cfg.RedFlags.RulesFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+	// Without notifiers nothing is queued
+	var notifiers map[string]clients.Notifier
+	if cfg.Notify.Enabled {
+		notifiers = map[string]clients.Notifier{}
+		for _, channel := range cfg.Notify.Channels {
+			switch channel {
+			case clients.ChannelEmail:
+				notifiers[channel] = clients.NewSMTPNotifier(clients.SMTPConfig{
+					Host:     cfg.Notify.SMTP.Host,
+					Port:     cfg.Notify.SMTP.Port,
+					Username: cfg.Notify.SMTP.Username,
+					Password: cfg.Notify.SMTP.Password,
+					From:     cfg.Notify.SMTP.From,
+					Timeout:  cfg.Notify.Timeout,
+				})
+			case clients.ChannelWebhook:
+				notifiers[channel] = clients.NewWebhookNotifier(clients.WebhookConfig{
+					URL:     cfg.Notify.Webhook.URL,
+					Secret:  cfg.Notify.Webhook.Secret,
+					Timeout: cfg.Notify.Timeout,
+				})
+			case clients.ChannelLog:
+				notifiers[channel] = clients.NewLogNotifier(cfg.Notify.LogFile)
+			}
+		}
+	}
+	if err := services.Init(services.Deps{
+		Store: store,
+		AI:    ai,
+		Speech: clients.NewGoogleSpeechClient(clients.GoogleSpeechConfig{
+			APIKey:      cfg.Speech.APIKey,
+			ProjectID:   cfg.Speech.ProjectID,
+			STTEndpoint: cfg.Speech.STTEndpoint,
+			TTSEndpoint: cfg.Speech.TTSEndpoint,
+			Timeout:     cfg.Speech.Timeout,
+		}),
+		Auth: services.AuthConfig{
+			Secret:     secret,
+			AccessTTL:  cfg.Auth.AccessTTL,
+			RefreshTTL: cfg.Auth.RefreshTTL,
+		},
+		Notifiers: notifiers,
+	}); err != nil {
+		log.Fatal(err)
+	}
 
 	// Swagger route
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
@@ -86,8 +189,113 @@ func main() {
 		c.JSON(200, gin.H{"message": "pong"})
 	})
 
-	api.SetupRoutes(r)
+	// Readiness probe, fails while the storage backend is unreachable. The AI breakers are
+	// reported but do not fail it, the API answers 503 itself while an AI endpoint is down.
+	r.GET("/readyz", func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 2*time.Second)
+		defer cancel()
+		body := gin.H{"status": "ready"}
+		if outbound != nil {
+			body["aiBreakers"] = outbound.BreakerStates()
+		}
+		if err := store.Ready(ctx); err != nil {
+			body["status"] = "unavailable"
+			body["error"] = err.Error()
+			c.JSON(503, body)
+			return
+		}
+		c.JSON(200, body)
+	})
+
+	api.SetupRoutes(r, api.RouteConfig{VoiceOrigins: allowedOrigins})
+
+	srv := &http.Server{
+		Addr:         cfg.Server.Addr(),
+		Handler:      r,
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		WriteTimeout: cfg.Server.WriteTimeout,
+		IdleTimeout:  cfg.Server.IdleTimeout,
+	}
 
-	log.Println("Starting server on http://localhost:8080")
-	r.Run(":8080")
+	// SIGINT/SIGTERM stop the background workers and drain in-flight requests
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// The dispatcher starts first so the sweeper and the analysis workers can queue messages
+	var notifyDone <-chan struct{}
+	if cfg.Notify.Enabled {
+		if err := services.LoadNotificationTemplates(cfg.Notify.TemplatesDir); err != nil {
+			log.Fatal(err)
+		}
+		notifyDone = services.StartNotificationDispatcher(ctx, services.NotificationConfig{
+			PollInterval:  cfg.Notify.PollInterval,
+			MaxAttempts:   cfg.Notify.MaxAttempts,
+			RetryBackoff:  cfg.Notify.RetryBackoff,
+			LeaseTimeout:  cfg.Notify.LeaseTimeout,
+			ReminderLeads: cfg.Notify.ReminderLeads,
+		})
+	}
+
+	var sweeperDone <-chan struct{}
+	if cfg.Sweeper.Enabled {
+		sweeperDone = services.StartAbandonmentSweeper(ctx, services.SweeperConfig{
+			Interval:         cfg.Sweeper.Interval,
+			InactivityWindow: cfg.Sweeper.AbandonAfter,
+			BatchSize:        cfg.Sweeper.BatchSize,
+		})
+	}
+
+	analysisDone := services.StartAnalysisWorkers(ctx, services.AnalysisJobConfig{
+		Workers:      cfg.Analysis.Workers,
+		PollInterval: cfg.Analysis.PollInterval,
+		MaxAttempts:  cfg.Analysis.MaxAttempts,
+		RetryBackoff: cfg.Analysis.RetryBackoff,
+		LeaseTimeout: cfg.Analysis.LeaseTimeout,
+		Wait:         cfg.Analysis.DashboardWait,
+	})
+
+	serverErr := make(chan error, 1)
+	go func() {
+		log.Printf("Starting server on http://localhost%s", srv.Addr)
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			serverErr <- err
+		}
+		close(serverErr)
+	}()
+
+	select {
+	case err := <-serverErr:
+		if err != nil {
+			log.Printf("Server failed: %v", err)
+		}
+		stop()
+	case <-ctx.Done():
+		log.Println("Shutting down")
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Server shutdown: %v", err)
+	}
+	if sweeperDone != nil {
+		select {
+		case <-sweeperDone:
+		case <-shutdownCtx.Done():
+			log.Println("Abandonment sweeper did not stop before the shutdown timeout")
+		}
+	}
+	select {
+	case <-analysisDone:
+	case <-shutdownCtx.Done():
+		log.Println("Analysis workers did not stop before the shutdown timeout")
+	}
+	if notifyDone != nil {
+		select {
+		case <-notifyDone:
+		case <-shutdownCtx.Done():
+			log.Println("Notification dispatcher did not stop before the shutdown timeout")
+		}
+	}
+	log.Println("Server stopped")
 }
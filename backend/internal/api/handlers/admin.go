@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"ai-bot-deecogs/internal/helpers"
+	"ai-bot-deecogs/internal/models"
+	"ai-bot-deecogs/internal/services"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListUsers handles GET /admin/users
+// @Summary List users
+// @Description Lists every user with their role (admin only)
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} services.UserProfile
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/users [get]
+func ListUsers(c *gin.Context) {
+	users, err := services.ListUsers()
+	if err != nil {
+		helpers.SendResponse(c.Writer, false, http.StatusInternalServerError, "", err)
+		return
+	}
+	helpers.SendResponse(c.Writer, true, http.StatusOK, users, nil)
+}
+
+// UpdateUserRole handles PATCH /admin/users/:id/role
+// @Summary Change a user's role
+// @Description Sets the role of a user to patient, physiotherapist or admin (admin only)
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Param role body map[string]string true "Role"
+// @Success 200 {object} services.UserProfile
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /admin/users/{id}/role [patch]
+func UpdateUserRole(c *gin.Context) {
+	userID, err := helpers.StringToUInt32(c.Param("id"))
+	if err != nil {
+		helpers.SendResponse(c.Writer, false, http.StatusBadRequest, "", errors.New("invalid user ID"))
+		return
+	}
+
+	var request struct {
+		Role string `json:"role" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		helpers.SendResponse(c.Writer, false, http.StatusBadRequest, "", err)
+		return
+	}
+
+	// Admins cannot demote themselves and lock everyone out
+	if principal := CurrentPrincipal(c); principal.UserID == userID && models.Role(request.Role) != models.RoleAdmin {
+		helpers.SendResponse(c.Writer, false, http.StatusBadRequest, "", errors.New("admins cannot change their own role"))
+		return
+	}
+
+	user, err := services.SetUserRole(userID, models.Role(request.Role))
+	if err != nil {
+		helpers.SendResponse(c.Writer, false, userErrorStatus(err), "", err)
+		return
+	}
+	helpers.SendResponse(c.Writer, true, http.StatusOK, user, nil)
+}
+
+// AssignPhysio handles POST /admin/assessments/:assessmentId/physio
+// @Summary Assign a physiotherapist
+// @Description Assigns a physiotherapist to an assessment, a null physioId unassigns it (admin only)
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param assessmentId path string true "Assessment ID"
+// @Param physio body map[string]uint32 true "Physiotherapist"
+// @Success 200 {object} services.Assessment
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /admin/assessments/{assessmentId}/physio [post]
+func AssignPhysio(c *gin.Context) {
+	assessmentID, err := helpers.StringToUInt32(c.Param("assessmentId"))
+	if err != nil {
+		helpers.SendResponse(c.Writer, false, http.StatusBadRequest, "", errors.New("invalid assessment ID"))
+		return
+	}
+
+	var request struct {
+		PhysioID *uint32 `json:"physioId"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		helpers.SendResponse(c.Writer, false, http.StatusBadRequest, "", err)
+		return
+	}
+
+	if err := services.AssignPhysio(assessmentID, request.PhysioID); err != nil {
+		switch {
+		case errors.Is(err, services.ErrAssessmentNotFound), errors.Is(err, services.ErrUserNotFound):
+			helpers.SendResponse(c.Writer, false, http.StatusNotFound, "", err)
+		case errors.Is(err, services.ErrNotPhysio):
+			helpers.SendResponse(c.Writer, false, http.StatusBadRequest, "", err)
+		default:
+			helpers.SendResponse(c.Writer, false, http.StatusInternalServerError, "", err)
+		}
+		return
+	}
+
+	assessment, err := services.GetAssessment(assessmentID)
+	if err != nil {
+		helpers.SendResponse(c.Writer, false, http.StatusInternalServerError, "", err)
+		return
+	}
+	helpers.SendResponse(c.Writer, true, http.StatusOK, assessment, nil)
+}
+
+// GetSweeperStatus handles GET /admin/sweeper
+// @Summary Abandonment sweeper status
+// @Description Shows the settings of the background abandonment sweeper, its last run and totals (admin only)
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} services.SweeperStatus
+// @Failure 403 {object} map[string]string
+// @Router /admin/sweeper [get]
+func GetSweeperStatus(c *gin.Context) {
+	helpers.SendResponse(c.Writer, true, http.StatusOK, services.GetSweeperStatus(), nil)
+}
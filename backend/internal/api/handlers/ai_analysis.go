@@ -1 +1,83 @@
-package handlers
\ No newline at end of file
+package handlers
+
+import (
+	"ai-bot-deecogs/internal/helpers"
+	"ai-bot-deecogs/internal/services"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestAnalysis handles POST /assessments/:assessmentId/analysis
+// @Summary Request the dashboard analysis
+// @Description Queues the AI analysis of the current questionnaire and ROM data. The same data is analysed once, so repeating the request returns the existing job; a failed job is queued again. Answers 202 while the job is pending and 200 once it has finished.
+// @Tags Dashboard
+// @Produce json
+// @Security BearerAuth
+// @Param assessmentId path string true "Assessment ID"
+// @Success 200 {object} services.AnalysisJob
+// @Success 202 {object} services.AnalysisJob
+// @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /assessments/{assessmentId}/analysis [post]
+func RequestAnalysis(c *gin.Context) {
+	assessmentID, err := helpers.StringToUInt32(c.Param("assessmentId"))
+	if err != nil {
+		helpers.SendResponse(c.Writer, false, http.StatusBadRequest, "", err)
+		return
+	}
+
+	job, _, err := services.EnqueueAnalysis(assessmentID)
+	if err != nil {
+		sendAnalysisError(c, err)
+		return
+	}
+	sendAnalysisJob(c, job)
+}
+
+// GetAnalysis handles GET /assessments/:assessmentId/analysis
+// @Summary Get the dashboard analysis
+// @Description Returns the latest analysis job of an assessment, with its result once it succeeded
+// @Tags Dashboard
+// @Produce json
+// @Security BearerAuth
+// @Param assessmentId path string true "Assessment ID"
+// @Success 200 {object} services.AnalysisJob
+// @Success 202 {object} services.AnalysisJob
+// @Failure 404 {object} map[string]string
+// @Router /assessments/{assessmentId}/analysis [get]
+func GetAnalysis(c *gin.Context) {
+	assessmentID, err := helpers.StringToUInt32(c.Param("assessmentId"))
+	if err != nil {
+		helpers.SendResponse(c.Writer, false, http.StatusBadRequest, "", err)
+		return
+	}
+
+	job, err := services.GetAnalysis(assessmentID)
+	if err != nil {
+		sendAnalysisError(c, err)
+		return
+	}
+	sendAnalysisJob(c, job)
+}
+
+// sendAnalysisJob answers 202 while the job is pending and 200 once it has finished
+func sendAnalysisJob(c *gin.Context, job *services.AnalysisJob) {
+	status := http.StatusOK
+	if !job.Done() {
+		status = http.StatusAccepted
+	}
+	helpers.SendResponse(c.Writer, true, status, job, nil)
+}
+
+func sendAnalysisError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrAnalysisNotFound):
+		helpers.SendResponse(c.Writer, false, http.StatusNotFound, "", err)
+	case errors.Is(err, services.ErrAnalysisInputMissing):
+		helpers.SendResponse(c.Writer, false, http.StatusConflict, "", err)
+	default:
+		helpers.SendResponse(c.Writer, false, http.StatusInternalServerError, "", err)
+	}
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"ai-bot-deecogs/internal/clients"
+	"ai-bot-deecogs/internal/helpers"
+	"ai-bot-deecogs/internal/models"
+	"ai-bot-deecogs/internal/services"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AIUnavailableCode marks the response sent while an AI service is down
+const AIUnavailableCode = "ai_unavailable"
+
+const aiUnavailableMessage = "AI service is temporarily unavailable. Please try again in a moment."
+
+// AIUnavailable is the data of a 503 answer, the frontend shows Message and may retry after RetryAfterSeconds
+type AIUnavailable struct {
+	Code              string `json:"code"`
+	Message           string `json:"message"`
+	RetryAfterSeconds int    `json:"retryAfterSeconds,omitempty"`
+}
+
+// newAIUnavailable describes err when it reports an unreachable AI service
+func newAIUnavailable(err error) (AIUnavailable, bool) {
+	if !errors.Is(err, clients.ErrAIUnavailable) {
+		return AIUnavailable{}, false
+	}
+	body := AIUnavailable{Code: AIUnavailableCode, Message: aiUnavailableMessage}
+	var unavailable *clients.UnavailableError
+	if errors.As(err, &unavailable) && unavailable.RetryAfter > 0 {
+		body.RetryAfterSeconds = int(math.Ceil(unavailable.RetryAfter.Seconds()))
+	}
+	return body, true
+}
+
+// sendAIError answers a failed AI call: 503 with AIUnavailable while the service is down,
+// 502 when it answered outside its protocol, 409 once the assessment is finished and 500 with data otherwise
+func sendAIError(c *gin.Context, err error, data interface{}) {
+	if body, ok := newAIUnavailable(err); ok {
+		if body.RetryAfterSeconds > 0 {
+			c.Header("Retry-After", strconv.Itoa(body.RetryAfterSeconds))
+		}
+		helpers.SendResponse(c.Writer, false, http.StatusServiceUnavailable, body, err)
+		return
+	}
+	var violation *models.SchemaViolationError
+	if errors.As(err, &violation) {
+		helpers.SendResponse(c.Writer, false, http.StatusBadGateway, data, err)
+		return
+	}
+	if errors.Is(err, services.ErrInvalidTransition) {
+		helpers.SendResponse(c.Writer, false, http.StatusConflict, "", err)
+		return
+	}
+	helpers.SendResponse(c.Writer, false, http.StatusInternalServerError, data, err)
+}
+
+// aiErrorEvent is the data of the error event sent when a streamed AI call fails
+func aiErrorEvent(err error, message string) interface{} {
+	if body, ok := newAIUnavailable(err); ok {
+		return gin.H{"error": body.Message, "code": body.Code, "retryAfterSeconds": body.RetryAfterSeconds}
+	}
+	if errors.Is(err, services.ErrInvalidTransition) {
+		return gin.H{"error": err.Error()}
+	}
+	return gin.H{"error": message}
+}
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"ai-bot-deecogs/internal/helpers"
+	"ai-bot-deecogs/internal/services"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AnatomyRequest is the body of the anatomy create and update endpoints
+type AnatomyRequest struct {
+	Name        string  `json:"name" binding:"required"`
+	Description *string `json:"description,omitempty"`
+	Category    *string `json:"category,omitempty"`
+	Subcategory *string `json:"subcategory,omitempty"`
+}
+
+// ListAnatomy handles GET /anatomy
+// @Summary List anatomy
+// @Description Lists the body parts an assessment can target
+// @Tags Anatomy
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} services.Anatomy
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /anatomy [get]
+func ListAnatomy(c *gin.Context) {
+	list, err := services.ListAnatomy()
+	if err != nil {
+		helpers.SendResponse(c.Writer, false, http.StatusInternalServerError, "", err)
+		return
+	}
+	helpers.SendResponse(c.Writer, true, http.StatusOK, list, nil)
+}
+
+// CreateAnatomy handles POST /admin/anatomy
+// @Summary Create anatomy
+// @Description Adds a body part to the anatomy catalogue (admin only)
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param anatomy body AnatomyRequest true "Anatomy"
+// @Success 201 {object} services.Anatomy
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /admin/anatomy [post]
+func CreateAnatomy(c *gin.Context) {
+	var request AnatomyRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		helpers.SendResponse(c.Writer, false, http.StatusBadRequest, "", err)
+		return
+	}
+
+	anatomy, err := services.CreateAnatomy(services.Anatomy{
+		Name:        request.Name,
+		Description: request.Description,
+		Category:    request.Category,
+		Subcategory: request.Subcategory,
+	})
+	if err != nil {
+		helpers.SendResponse(c.Writer, false, anatomyErrorStatus(err), "", err)
+		return
+	}
+	helpers.SendResponse(c.Writer, true, http.StatusCreated, anatomy, nil)
+}
+
+// UpdateAnatomy handles PUT /admin/anatomy/:id
+// @Summary Update anatomy
+// @Description Replaces the name and descriptions of an anatomy entry (admin only)
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Anatomy ID"
+// @Param anatomy body AnatomyRequest true "Anatomy"
+// @Success 200 {object} services.Anatomy
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /admin/anatomy/{id} [put]
+func UpdateAnatomy(c *gin.Context) {
+	anatomyID, err := helpers.StringToUInt32(c.Param("id"))
+	if err != nil {
+		helpers.SendResponse(c.Writer, false, http.StatusBadRequest, "", errors.New("invalid anatomy ID"))
+		return
+	}
+
+	var request AnatomyRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		helpers.SendResponse(c.Writer, false, http.StatusBadRequest, "", err)
+		return
+	}
+
+	anatomy, err := services.UpdateAnatomy(services.Anatomy{
+		AnatomyID:   anatomyID,
+		Name:        request.Name,
+		Description: request.Description,
+		Category:    request.Category,
+		Subcategory: request.Subcategory,
+	})
+	if err != nil {
+		helpers.SendResponse(c.Writer, false, anatomyErrorStatus(err), "", err)
+		return
+	}
+	helpers.SendResponse(c.Writer, true, http.StatusOK, anatomy, nil)
+}
+
+// DeleteAnatomy handles DELETE /admin/anatomy/:id
+// @Summary Delete anatomy
+// @Description Removes an anatomy entry that no assessment references (admin only)
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Anatomy ID"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /admin/anatomy/{id} [delete]
+func DeleteAnatomy(c *gin.Context) {
+	anatomyID, err := helpers.StringToUInt32(c.Param("id"))
+	if err != nil {
+		helpers.SendResponse(c.Writer, false, http.StatusBadRequest, "", errors.New("invalid anatomy ID"))
+		return
+	}
+
+	if err := services.DeleteAnatomy(anatomyID); err != nil {
+		helpers.SendResponse(c.Writer, false, anatomyErrorStatus(err), "", err)
+		return
+	}
+	helpers.SendResponse(c.Writer, true, http.StatusOK, gin.H{"message": "Anatomy deleted"}, nil)
+}
+
+func anatomyErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrAnatomyNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrAnatomyExists), errors.Is(err, services.ErrAnatomyInUse):
+		return http.StatusConflict
+	case err.Error() == "name is required":
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
@@ -1,10 +1,13 @@
 package handlers
 
 import (
+	"ai-bot-deecogs/internal/clients"
+	"ai-bot-deecogs/internal/db"
 	"ai-bot-deecogs/internal/helpers"
 	"ai-bot-deecogs/internal/models"
 	"ai-bot-deecogs/internal/services"
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -19,6 +22,7 @@ import (
 // @Tags Assessments
 // @Accept json
 // @Produce json
+// @Security BearerAuth
 // @Param assessment body map[string]string true "Assessment Data"
 // @Success 201 {object} services.Assessment
 // @Failure 400 {object} map[string]string
@@ -26,7 +30,7 @@ import (
 // @Router /assessments [post]
 func CreateAssessment(c *gin.Context) {
 	var request struct {
-		UserID         uint32 `json:"userId" binding:"required"`
+		UserID         uint32 `json:"userId"` // Defaults to the caller
 		AnatomyID      uint32 `json:"anatomyId" binding:"required"`
 		AssessmentType string `json:"assessmentType" binding:"required"`
 	}
@@ -36,6 +40,15 @@ func CreateAssessment(c *gin.Context) {
 		return
 	}
 
+	// Callers can only start assessments for themselves, admins for anyone
+	principal := CurrentPrincipal(c)
+	if request.UserID == 0 {
+		request.UserID = principal.UserID
+	} else if request.UserID != principal.UserID && !principal.Can(models.PermAssessmentWriteAll) {
+		helpers.SendResponse(c.Writer, false, http.StatusForbidden, "", services.ErrForbidden)
+		return
+	}
+
 	assessment, err := services.CreateAssessment(request.UserID, request.AnatomyID, request.AssessmentType)
 	if err != nil {
 		log.Println("Error creating assessment:")
@@ -47,23 +60,28 @@ func CreateAssessment(c *gin.Context) {
 }
 
 // SendChatToAIHandler handles POST /assessments/:assessmentId/chat
-// @Summary Send chat history for AI response
-// @Description Sends chat history to an AI model for physiotherapy assessment
+// @Summary Send a chat message for AI response
+// @Description Appends the new user message to the stored chat and returns the AI reply. Clients that still send the full chat_history have its last user message used.
 // @Tags Assessments
 // @Accept json
 // @Produce json
-// @Param id path string true "Assessment ID"
-// @Param chat_body body services.ChatRequest true "Chat History"
+// @Security BearerAuth
+// @Param assessmentId path string true "Assessment ID"
+// @Param chat_body body services.ChatMessageRequest true "New message"
 // @Success 200 {object} services.ChatResponse
 // @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string
 // @Failure 500 {object} map[string]string
+// @Failure 502 {object} map[string]string
+// @Failure 503 {object} handlers.AIUnavailable
 // @Router /assessments/{assessmentId}/chat [post]
 func SendChatToAIHandler(c *gin.Context) {
 	assessmentID := c.Param("assessmentId")
 
-	// Create a flexible request structure that can handle both chat_history and video
+	// Create a flexible request structure that can handle a message, the legacy chat_history and video
 	var chatRequest struct {
-		ChatHistory []services.ChatMessage `json:"chat_history"`
+		Message     string                 `json:"message"`
+		ChatHistory []services.ChatMessage `json:"chat_history"`    // deprecated, the history is stored server side
 		Video       string                 `json:"video,omitempty"` // Optional video field
 	}
 
@@ -72,38 +90,36 @@ func SendChatToAIHandler(c *gin.Context) {
 		return
 	}
 
-	log.Printf("Chat Request: %+v\n", chatRequest)
 	log.Printf("Assessment ID: %s\n", assessmentID)
 
+	assessmentIDUint, unitErr := helpers.StringToUInt32(assessmentID)
+	if unitErr != nil {
+		log.Println(`Error converting assessment ID to uint32 `, assessmentID)
+		helpers.SendResponse(c.Writer, false, http.StatusInternalServerError, "", unitErr)
+		return
+	}
+
+	_, err := services.GetAssessment(assessmentIDUint)
+	if err != nil {
+		log.Println(`Error fetching the assessment `, assessmentID)
+		helpers.SendResponse(c.Writer, false, http.StatusInternalServerError, "", err)
+		return
+	}
+
 	// Check if this is a video request
 	if chatRequest.Video != "" {
 		log.Println("Received video for body part identification")
 		// Handle video differently - don't add to chat history
-		// Create a special request for video processing
 		videoRequest := services.VideoRequest{
 			ChatHistory: chatRequest.ChatHistory,
 			Video:       chatRequest.Video,
 		}
 
-		assessmentIDUint, unitErr := helpers.StringToUInt32(assessmentID)
-		if unitErr != nil {
-			log.Println(`Error converting assessment ID to uint32 `, assessmentID)
-			helpers.SendResponse(c.Writer, false, http.StatusInternalServerError, "", unitErr)
-			return
-		}
-
-		_, err := services.GetAssessment(assessmentIDUint)
-		if err != nil {
-			log.Println(`Error fetching the assessment `, assessmentID)
-			helpers.SendResponse(c.Writer, false, http.StatusInternalServerError, "", err)
-			return
-		}
-
 		// Call the AI service with video
-		aiResponse, err := services.SendVideoToAI(assessmentIDUint, videoRequest)
+		aiResponse, err := services.SendVideoToAI(c.Request.Context(), assessmentIDUint, videoRequest)
 		if err != nil {
 			log.Println(`Error sending video to AI `, assessmentID)
-			helpers.SendResponse(c.Writer, false, http.StatusInternalServerError, "", err)
+			sendAIError(c, err, "")
 			return
 		}
 
@@ -112,29 +128,56 @@ func SendChatToAIHandler(c *gin.Context) {
 	}
 
 	// Handle regular chat (text-based)
-	assessmentIDUint, unitErr := helpers.StringToUInt32(assessmentID)
-	if unitErr != nil {
-		log.Println(`Error converting assessment ID to uint32 `, assessmentID)
-		helpers.SendResponse(c.Writer, false, http.StatusInternalServerError, "", unitErr)
+	message := newChatMessage(chatRequest.Message, chatRequest.ChatHistory)
+	if message == "" {
+		helpers.SendResponse(c.Writer, false, http.StatusBadRequest, "", errors.New("message is required"))
 		return
 	}
 
-	_, err := services.GetAssessment(assessmentIDUint)
+	// Call the AI service for regular chat
+	aiResponse, err := services.SendChatToAI(c.Request.Context(), assessmentIDUint, message)
 	if err != nil {
-		log.Println(`Error fetching the assessment `, assessmentID)
-		helpers.SendResponse(c.Writer, false, http.StatusInternalServerError, "", err)
+		log.Println(`Error sending chat to AI `, assessmentID)
+		sendAIError(c, err, "")
 		return
 	}
 
-	// Call the AI service for regular chat
-	aiResponse, err := services.SendChatToAI(assessmentIDUint, chatRequest.ChatHistory)
+	helpers.SendResponse(c.Writer, true, http.StatusOK, aiResponse.Data, nil)
+}
+
+// newChatMessage is the message sent by the client, or the last user message of a legacy full history
+func newChatMessage(message string, history []services.ChatMessage) string {
+	if message == "" && len(history) > 0 {
+		return history[len(history)-1].User
+	}
+	return message
+}
+
+// GetChatHistory handles GET /assessments/:assessmentId/chat
+// @Summary Get the stored chat
+// @Description Returns every stored turn of the body part identification chat so it can be resumed
+// @Tags Assessments
+// @Produce json
+// @Security BearerAuth
+// @Param assessmentId path string true "Assessment ID"
+// @Success 200 {object} services.ChatTranscript
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /assessments/{assessmentId}/chat [get]
+func GetChatHistory(c *gin.Context) {
+	assessmentID, err := helpers.StringToUInt32(c.Param("assessmentId"))
+	if err != nil {
+		helpers.SendResponse(c.Writer, false, http.StatusBadRequest, "", err)
+		return
+	}
+
+	transcript, err := services.GetChatTranscript(assessmentID)
 	if err != nil {
-		log.Println(`Error sending chat to AI `, assessmentID)
 		helpers.SendResponse(c.Writer, false, http.StatusInternalServerError, "", err)
 		return
 	}
 
-	helpers.SendResponse(c.Writer, true, http.StatusOK, aiResponse.Data, nil)
+	helpers.SendResponse(c.Writer, true, http.StatusOK, transcript, nil)
 }
 
 // GetAssessment handles GET /assessments/:assessmentId
@@ -165,9 +208,36 @@ func GetAssessment(c *gin.Context) {
 	helpers.SendResponse(c.Writer, true, http.StatusOK, assessment, nil)
 }
 
+// GetAssessmentTimeline handles GET /assessments/:assessmentId/timeline
+// @Summary Get assessment timeline
+// @Description Lists the status changes and completed phases of an assessment, oldest first
+// @Tags Assessments
+// @Produce json
+// @Security BearerAuth
+// @Param assessmentId path string true "Assessment ID"
+// @Success 200 {array} services.TimelineEvent
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /assessments/{assessmentId}/timeline [get]
+func GetAssessmentTimeline(c *gin.Context) {
+	assessmentID, err := helpers.StringToUInt32(c.Param("assessmentId"))
+	if err != nil {
+		helpers.SendResponse(c.Writer, false, http.StatusBadRequest, "", err)
+		return
+	}
+
+	timeline, err := services.GetAssessmentTimeline(assessmentID)
+	if err != nil {
+		helpers.SendResponse(c.Writer, false, http.StatusInternalServerError, "", err)
+		return
+	}
+
+	helpers.SendResponse(c.Writer, true, http.StatusOK, timeline, nil)
+}
+
 // UpdateAssessmentStatus handles PATCH /assessments/:id/status
 // @Summary Update assessment status
-// @Description Updates the status of an assessment
+// @Description Moves an assessment to a new status, an optional reason is recorded on its timeline
 // @Tags Assessments
 // @Accept json
 // @Produce json
@@ -176,12 +246,14 @@ func GetAssessment(c *gin.Context) {
 // @Success 200 {object} map[string]string
 // @Failure 400 {object} map[string]string
 // @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
 // @Router /assessments/{assessmentId}/status [post]
 func UpdateAssessmentStatus(c *gin.Context) {
 	assessmentID := c.Param("assessmentId")
 
 	var request struct {
 		Status string `json:"status" binding:"required"`
+		Reason string `json:"reason"`
 	}
 	if err := c.ShouldBindJSON(&request); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -189,9 +261,11 @@ func UpdateAssessmentStatus(c *gin.Context) {
 	}
 
 	status := models.AssessmentStatus(request.Status)
-	if err := services.UpdateAssessmentStatus(assessmentID, status); err != nil {
-		if err.Error() == "assessment not found" {
+	if err := services.UpdateAssessmentStatus(assessmentID, status, request.Reason); err != nil {
+		if errors.Is(err, services.ErrAssessmentNotFound) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Assessment not found"})
+		} else if errors.Is(err, services.ErrInvalidTransition) || errors.Is(err, services.ErrStatusConflict) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
 		} else {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		}
@@ -230,8 +304,29 @@ func UpdateAssessmentStatus(c *gin.Context) {
 // @Success 200 {object} services.QuestionResponse
 // @Failure 400 {object} map[string]string
 // @Failure 500 {object} map[string]string
+// @Failure 502 {object} map[string]string
+// @Failure 503 {object} handlers.AIUnavailable
 // @Router /assessments/{assessmentId}/questionnaires [post]
 func SendQuestionsToAIHandler(c *gin.Context) {
+	assessmentIDUint, questionRequest, ok := bindQuestionnaire(c)
+	if !ok {
+		return
+	}
+
+	// Call the AI service
+	aiResponse, err := services.SendQuestionsToAI(c.Request.Context(), assessmentIDUint, questionRequest)
+	if err != nil {
+		log.Printf("Error sending questions to AI: %v", err)
+		sendAIError(c, err, questionnaireErrorMessage(err))
+		return
+	}
+
+	helpers.SendResponse(c.Writer, true, http.StatusOK, aiResponse.Data, nil)
+}
+
+// bindQuestionnaire parses a questionnaire turn and checks the assessment is still open,
+// ok is false once an error response has been written
+func bindQuestionnaire(c *gin.Context) (uint32, services.QuestionRequest, bool) {
 	assessmentID := c.Param("assessmentId")
 
 	// First, try to bind as QuestionRequest
@@ -247,7 +342,7 @@ func SendQuestionsToAIHandler(c *gin.Context) {
 		if err2 := c.ShouldBindJSON(&chatRequest); err2 != nil {
 			log.Printf("Error parsing request body: %v", err2)
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
-			return
+			return 0, questionRequest, false
 		}
 
 		questionRequest.QuestionHistory = chatRequest.ChatHistory
@@ -277,7 +372,7 @@ func SendQuestionsToAIHandler(c *gin.Context) {
 	if err != nil {
 		log.Printf("Error converting assessment ID: %s", assessmentID)
 		helpers.SendResponse(c.Writer, false, http.StatusBadRequest, "", err)
-		return
+		return 0, questionRequest, false
 	}
 
 	// Verify assessment exists and is active
@@ -285,7 +380,7 @@ func SendQuestionsToAIHandler(c *gin.Context) {
 	if err != nil {
 		log.Printf("Assessment not found: %d", assessmentIDUint)
 		helpers.SendResponse(c.Writer, false, http.StatusNotFound, "", err)
-		return
+		return 0, questionRequest, false
 	}
 
 	// Check if assessment is already completed
@@ -293,45 +388,24 @@ func SendQuestionsToAIHandler(c *gin.Context) {
 		log.Printf("Assessment %d is already %s", assessmentIDUint, assessment.Status)
 		helpers.SendResponse(c.Writer, false, http.StatusBadRequest,
 			fmt.Sprintf("Assessment is already %s", assessment.Status), nil)
-		return
+		return 0, questionRequest, false
 	}
 
 	log.Printf("Processing questionnaire for assessment %d with %d messages",
 		assessmentIDUint, len(questionRequest.QuestionHistory))
+	return assessmentIDUint, questionRequest, true
+}
 
-	// Call the AI service
-	aiResponse, err := services.SendQuestionsToAI(assessmentIDUint, questionRequest)
-	if err != nil {
-		log.Printf("Error sending questions to AI: %v", err)
-
-		// Return user-friendly error message
-		errorMessage := "Error processing your question. Please try again."
-		if err.Error() == "failed to get a response from AI model" {
-			errorMessage = "AI service is temporarily unavailable. Please try again in a moment."
-		}
-
-		helpers.SendResponse(c.Writer, false, http.StatusInternalServerError, errorMessage, err)
-		return
-	}
-
-	// Update assessment status if needed
-	if aiResponse.Data != nil {
-		if dataMap, ok := aiResponse.Data.(map[string]interface{}); ok {
-			if action, exists := dataMap["action"]; exists {
-				log.Printf("AI action: %v", action)
-
-				// Update status to in_progress if it's still in started state
-				if assessment.Status == "started" {
-					updateErr := services.UpdateAssessmentStatus(assessmentID, models.StatusInProgress)
-					if updateErr != nil {
-						log.Printf("Warning: Failed to update assessment status: %v", updateErr)
-					}
-				}
-			}
-		}
+// questionnaireErrorMessage is the user-friendly message for a failed questionnaire turn
+func questionnaireErrorMessage(err error) string {
+	var violation *models.SchemaViolationError
+	switch {
+	case errors.As(err, &violation):
+		return "The AI service sent an unexpected reply. Please try again."
+	case errors.Is(err, clients.ErrAIUnavailable):
+		return aiUnavailableMessage
 	}
-
-	helpers.SendResponse(c.Writer, true, http.StatusOK, aiResponse.Data, nil)
+	return "Error processing your question. Please try again."
 }
 
 // Add this middleware to store request body for re-reading
@@ -377,14 +451,15 @@ func GetQuestionnaires(c *gin.Context) {
 
 // SubmitROMAnalysis handles POST /assessments/:assessmentId/romAnalysis
 // @Summary Submit ROM analysis data
-// @Description Submits pose model data and analysis results for an assessment
+// @Description Submits the range of motion measured for an assessment, one entry per joint, movement, side and mode. Each measurement is checked against the plausible range of its joint. The legacy single rangeOfMotion payload is still accepted.
 // @Tags ROM
 // @Accept json
 // @Produce json
 // @Param assessmentId path string true "Assessment ID"
-// @Param romAnalysis body services.ROMAnalysis true "ROM Analysis Data"
+// @Param romAnalysis body services.ROMRequest true "ROM Analysis Data"
 // @Success 201 {object} map[string]string
 // @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /assessments/{assessmentId}/romAnalysis [post]
 func SubmitROMAnalysis(c *gin.Context) {
@@ -410,8 +485,17 @@ func SubmitROMAnalysis(c *gin.Context) {
 		return
 	}
 
-	_, err := services.SubmitROMAnalysis(assessmentIDUint, request.RangeOfMotion)
+	_, err := services.SubmitROMAnalysis(assessmentIDUint, request)
 	if err != nil {
+		var measurementErr *models.ROMMeasurementError
+		if errors.Is(err, services.ErrROMDataMissing) || errors.As(err, &measurementErr) {
+			helpers.SendResponse(c.Writer, false, http.StatusBadRequest, "", err)
+			return
+		}
+		if errors.Is(err, services.ErrInvalidTransition) {
+			helpers.SendResponse(c.Writer, false, http.StatusConflict, "", err)
+			return
+		}
 		helpers.SendResponse(c.Writer, false, http.StatusInternalServerError, "", err)
 		return
 	}
@@ -456,58 +540,38 @@ func GetROMAnalysisByAssessmentId(c *gin.Context) {
 
 // GetDashboardData handles GET /assessments/:assessmentId/dashboard
 // @Summary Get dashboard data
-// @Description Retrieves dashboard data for an assessment
+// @Description Deprecated, use POST and GET /assessments/{assessmentId}/analysis. Queues the analysis of the current assessment data unless it already ran, then waits a bounded time for it. Answers the analysis result when it is ready and 202 with the job otherwise.
 // @Tags Dashboard
 // @Produce json
+// @Security BearerAuth
 // @Param assessmentId path string true "Assessment ID"
-// @Success 200 {object} services.DashboardData
+// @Success 200 {object} services.AIResult
+// @Success 202 {object} services.AnalysisJob
 // @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 502 {object} services.AnalysisJob
 // @Router /assessments/{assessmentId}/dashboard [get]
 func GetDashboardData(c *gin.Context) {
-	assessmentID := c.Param("assessmentId")
-
-	assessmentIDUint, unitErr := helpers.StringToUInt32(assessmentID)
-	if unitErr != nil {
-		log.Println(`Error converting assessment ID to uint32 `, assessmentID)
-		helpers.SendResponse(c.Writer, false, http.StatusInternalServerError, "", unitErr)
-		return
-	}
-
-	//check if assessment exists
-	_, assessmentErr := services.GetAssessment(assessmentIDUint)
-	if assessmentErr != nil {
-		helpers.SendResponse(c.Writer, false, http.StatusNotFound, "", assessmentErr)
-		return
-	}
-
-	dashboardData, err := services.FetchAssessmentData(assessmentIDUint)
+	assessmentID, err := helpers.StringToUInt32(c.Param("assessmentId"))
 	if err != nil {
-		helpers.SendResponse(c.Writer, false, http.StatusNotFound, "", err)
-		return
-	}
-
-	// Send data to AI API
-	aiResult, err := services.RequestAIAnalysisFromAI(assessmentIDUint, dashboardData)
-	if err != nil {
-		helpers.SendResponse(c.Writer, false, 500, "Failed to process AI analysis", err)
+		helpers.SendResponse(c.Writer, false, http.StatusBadRequest, "", err)
 		return
 	}
 
-	// Save AI analysis in database
-	err = services.SaveAIAnalysis(assessmentIDUint, dashboardData, aiResult)
+	job, err := services.AwaitAnalysis(c.Request.Context(), assessmentID)
 	if err != nil {
-		helpers.SendResponse(c.Writer, false, 500, "Failed to save AI analysis", err)
+		sendAnalysisError(c, err)
 		return
 	}
 
-	//mark assessment as completed
-	err = services.MarkAssessmentComplete(assessmentIDUint)
-	if err != nil {
-		helpers.SendResponse(c.Writer, false, 500, "Failed to mark assessment as complete", err)
-		return
+	switch job.Status {
+	case db.JobSucceeded:
+		helpers.SendResponse(c.Writer, true, http.StatusOK, job.Result, nil)
+	case db.JobFailed:
+		helpers.SendResponse(c.Writer, false, http.StatusBadGateway, job, errors.New("failed to process AI analysis"))
+	default:
+		helpers.SendResponse(c.Writer, true, http.StatusAccepted, job, nil)
 	}
-
-	helpers.SendResponse(c.Writer, true, http.StatusOK, aiResult, nil)
 }
 
 // GetDashboardDataByAssessmentId handles GET /assessments/:assessmentId/dashboardByAssessmentId
@@ -543,3 +607,26 @@ func GetDashboardDataByAssessmentId(c *gin.Context) {
 	}
 	helpers.SendResponse(c.Writer, true, http.StatusOK, analysisData, nil)
 }
+
+// ListAssignedAssessments handles GET /physio/assessments
+// @Summary List assigned assessments
+// @Description Lists the assessments assigned to the calling physiotherapist
+// @Tags Physiotherapists
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} services.Assessment
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /physio/assessments [get]
+func ListAssignedAssessments(c *gin.Context) {
+	principal := CurrentPrincipal(c)
+
+	assessments, err := services.ListAssignedAssessments(principal.UserID)
+	if err != nil {
+		helpers.SendResponse(c.Writer, false, http.StatusInternalServerError, "", err)
+		return
+	}
+
+	helpers.SendResponse(c.Writer, true, http.StatusOK, assessments, nil)
+}
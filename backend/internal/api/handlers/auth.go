@@ -1,7 +1,9 @@
 package handlers
 
 import (
+	"ai-bot-deecogs/internal/helpers"
 	"ai-bot-deecogs/internal/services"
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -9,7 +11,7 @@ import (
 
 // LoginUser handles POST /auth/loginuser
 // @Summary Login user
-// @Description Authenticates a user with email and password
+// @Description Authenticates a user with email and password and issues an access and refresh token
 // @Tags Auth
 // @Accept json
 // @Produce json
@@ -29,23 +31,102 @@ func LoginUser(c *gin.Context) {
 		return
 	}
 
-	// Fetch the user from the service layer
-	user, err := services.GetUserByEmail(credentials.Email)
+	// Check the password against the stored bcrypt hash
+	user, err := services.Authenticate(credentials.Email, credentials.Password)
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
 		return
 	}
 
-	// Validate the password (in a real-world app, use hashed passwords)
-	if credentials.Password != user.Password {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
+	userID, err := helpers.StringToUInt32(user.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Return user details (excluding password)
+	tokens, err := services.IssueTokens(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue tokens"})
+		return
+	}
+
+	// Return user details (excluding password) with the tokens
 	c.JSON(http.StatusOK, gin.H{
-		"user_id": user.UserID,
-		"name":    user.Name,
-		"email":   user.Email,
+		"user_id":       user.UserID,
+		"name":          user.Name,
+		"email":         user.Email,
+		"access_token":  tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"token_type":    tokens.TokenType,
+		"expires_in":    tokens.ExpiresIn,
 	})
 }
+
+// RefreshToken handles POST /auth/refresh
+// @Summary Refresh tokens
+// @Description Exchanges a refresh token for a new access and refresh token; the old refresh token is revoked
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param body body map[string]string true "Refresh Token"
+// @Success 200 {object} services.TokenPair
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Router /auth/refresh [post]
+func RefreshToken(c *gin.Context) {
+	var request struct {
+		RefreshToken string `json:"refresh_token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tokens, err := services.RefreshTokens(request.RefreshToken)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidToken) || errors.Is(err, services.ErrTokenRevoked) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// Logout handles POST /auth/logout
+// @Summary Logout user
+// @Description Revokes the bearer access token and, if given, the refresh token of the session
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer access token"
+// @Param body body map[string]string false "Refresh Token"
+// @Success 200 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Router /auth/logout [post]
+func Logout(c *gin.Context) {
+	var request struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	// The body is optional, only the bearer token is required
+	_ = c.ShouldBindJSON(&request)
+
+	accessToken := helpers.BearerToken(c.Request)
+	if accessToken == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Missing bearer token"})
+		return
+	}
+
+	if err := services.Logout(accessToken, request.RefreshToken); err != nil {
+		if errors.Is(err, services.ErrInvalidToken) || errors.Is(err, services.ErrTokenRevoked) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+}
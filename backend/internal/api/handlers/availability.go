@@ -0,0 +1,287 @@
+package handlers
+
+import (
+	"ai-bot-deecogs/internal/helpers"
+	"ai-bot-deecogs/internal/services"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultSlotRange is the slot listing range when the request gives no end
+const defaultSlotRange = 7 * 24 * time.Hour
+
+// AvailabilityExceptionRequest is the body of POST /physios/:id/availability/exceptions
+type AvailabilityExceptionRequest struct {
+	StartsAt  string  `json:"starts_at" binding:"required"` // RFC 3339
+	EndsAt    string  `json:"ends_at" binding:"required"`   // RFC 3339
+	Available bool    `json:"available"`                    // extra hours, otherwise time off
+	Reason    *string `json:"reason,omitempty"`
+}
+
+// BookSlotRequest is the body of POST /assessments/:assessmentId/physio-calls/book
+type BookSlotRequest struct {
+	Start    string  `json:"start" binding:"required"` // RFC 3339, a slot start from GET /physios/:id/slots
+	PhysioID *uint32 `json:"physio_id,omitempty"`      // defaults to the physiotherapist of the assessment
+}
+
+// GetPhysioAvailability handles GET /physios/:id/availability
+// @Summary Get the weekly availability of a physiotherapist
+// @Description Returns the weekly windows in which the physiotherapist takes calls, in their time zone
+// @Tags Availability
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Physiotherapist user ID"
+// @Success 200 {object} services.Availability
+// @Failure 404 {object} map[string]string
+// @Router /physios/{id}/availability [get]
+func GetPhysioAvailability(c *gin.Context) {
+	physioID, err := helpers.StringToUInt32(c.Param("id"))
+	if err != nil {
+		helpers.SendResponse(c.Writer, false, http.StatusBadRequest, "", errors.New("invalid user ID"))
+		return
+	}
+
+	availability, err := services.GetAvailability(physioID)
+	if err != nil {
+		helpers.SendResponse(c.Writer, false, availabilityErrorStatus(err), "", err)
+		return
+	}
+	helpers.SendResponse(c.Writer, true, http.StatusOK, availability, nil)
+}
+
+// SetPhysioAvailability handles PUT /physios/:id/availability
+// @Summary Replace the weekly availability of a physiotherapist
+// @Description Replaces the weekly windows of the physiotherapist (the physiotherapist or an admin). Windows are HH:MM on the local clock of time_zone, an IANA zone name, and are cut into slots of slot_minutes. Booked calls are kept.
+// @Tags Availability
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Physiotherapist user ID"
+// @Param availability body services.Availability true "Weekly windows"
+// @Success 200 {object} services.Availability
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /physios/{id}/availability [put]
+func SetPhysioAvailability(c *gin.Context) {
+	physioID, err := helpers.StringToUInt32(c.Param("id"))
+	if err != nil {
+		helpers.SendResponse(c.Writer, false, http.StatusBadRequest, "", errors.New("invalid user ID"))
+		return
+	}
+
+	var request services.Availability
+	if err := c.ShouldBindJSON(&request); err != nil {
+		helpers.SendResponse(c.Writer, false, http.StatusBadRequest, "", err)
+		return
+	}
+
+	availability, err := services.SetAvailability(physioID, request)
+	if err != nil {
+		helpers.SendResponse(c.Writer, false, availabilityErrorStatus(err), "", err)
+		return
+	}
+	helpers.SendResponse(c.Writer, true, http.StatusOK, availability, nil)
+}
+
+// ListAvailabilityExceptions handles GET /physios/:id/availability/exceptions
+// @Summary List availability exceptions
+// @Description Returns the time off and extra hours of the physiotherapist overlapping [from, to) (the physiotherapist or an admin)
+// @Tags Availability
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Physiotherapist user ID"
+// @Param from query string false "RFC 3339"
+// @Param to query string false "RFC 3339"
+// @Success 200 {array} services.AvailabilityException
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /physios/{id}/availability/exceptions [get]
+func ListAvailabilityExceptions(c *gin.Context) {
+	physioID, err := helpers.StringToUInt32(c.Param("id"))
+	if err != nil {
+		helpers.SendResponse(c.Writer, false, http.StatusBadRequest, "", errors.New("invalid user ID"))
+		return
+	}
+	filter, err := callFilterOf(c)
+	if err != nil {
+		helpers.SendResponse(c.Writer, false, http.StatusBadRequest, "", err)
+		return
+	}
+
+	exceptions, err := services.ListAvailabilityExceptions(physioID, filter.From, filter.To)
+	if err != nil {
+		helpers.SendResponse(c.Writer, false, availabilityErrorStatus(err), "", err)
+		return
+	}
+	helpers.SendResponse(c.Writer, true, http.StatusOK, exceptions, nil)
+}
+
+// AddAvailabilityException handles POST /physios/:id/availability/exceptions
+// @Summary Add time off or extra hours
+// @Description Blocks [starts_at, ends_at) for bookings, or opens it when available is true (the physiotherapist or an admin). Calls already booked in the time off are kept.
+// @Tags Availability
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Physiotherapist user ID"
+// @Param exception body AvailabilityExceptionRequest true "Exception"
+// @Success 201 {object} services.AvailabilityException
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /physios/{id}/availability/exceptions [post]
+func AddAvailabilityException(c *gin.Context) {
+	physioID, err := helpers.StringToUInt32(c.Param("id"))
+	if err != nil {
+		helpers.SendResponse(c.Writer, false, http.StatusBadRequest, "", errors.New("invalid user ID"))
+		return
+	}
+
+	var request AvailabilityExceptionRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		helpers.SendResponse(c.Writer, false, http.StatusBadRequest, "", err)
+		return
+	}
+	startsAt, err := time.Parse(time.RFC3339, request.StartsAt)
+	if err != nil {
+		helpers.SendResponse(c.Writer, false, http.StatusBadRequest, "", errors.New("starts_at must be an RFC 3339 time"))
+		return
+	}
+	endsAt, err := time.Parse(time.RFC3339, request.EndsAt)
+	if err != nil {
+		helpers.SendResponse(c.Writer, false, http.StatusBadRequest, "", errors.New("ends_at must be an RFC 3339 time"))
+		return
+	}
+
+	exception, err := services.AddAvailabilityException(physioID, startsAt, endsAt, request.Available, request.Reason)
+	if err != nil {
+		helpers.SendResponse(c.Writer, false, availabilityErrorStatus(err), "", err)
+		return
+	}
+	helpers.SendResponse(c.Writer, true, http.StatusCreated, exception, nil)
+}
+
+// DeleteAvailabilityException handles DELETE /physios/:id/availability/exceptions/:exceptionId
+// @Summary Delete an availability exception
+// @Description Removes time off or extra hours of the physiotherapist (the physiotherapist or an admin)
+// @Tags Availability
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Physiotherapist user ID"
+// @Param exceptionId path string true "Exception ID"
+// @Success 200 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /physios/{id}/availability/exceptions/{exceptionId} [delete]
+func DeleteAvailabilityException(c *gin.Context) {
+	physioID, err := helpers.StringToUInt32(c.Param("id"))
+	if err != nil {
+		helpers.SendResponse(c.Writer, false, http.StatusBadRequest, "", errors.New("invalid user ID"))
+		return
+	}
+	exceptionID, err := helpers.StringToUInt32(c.Param("exceptionId"))
+	if err != nil {
+		helpers.SendResponse(c.Writer, false, http.StatusBadRequest, "", errors.New("invalid exception ID"))
+		return
+	}
+
+	if err := services.DeleteAvailabilityException(physioID, exceptionID); err != nil {
+		helpers.SendResponse(c.Writer, false, availabilityErrorStatus(err), "", err)
+		return
+	}
+	helpers.SendResponse(c.Writer, true, http.StatusOK, gin.H{"message": "Availability exception deleted"}, nil)
+}
+
+// ListPhysioSlots handles GET /physios/:id/slots
+// @Summary List bookable slots of a physiotherapist
+// @Description Returns the free slots of the physiotherapist within [from, to), at most 31 days. from defaults to now and to to a week after from. Slot times are in the physiotherapist's time zone.
+// @Tags Availability
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Physiotherapist user ID"
+// @Param from query string false "RFC 3339"
+// @Param to query string false "RFC 3339"
+// @Success 200 {object} services.PhysioSlots
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /physios/{id}/slots [get]
+func ListPhysioSlots(c *gin.Context) {
+	physioID, err := helpers.StringToUInt32(c.Param("id"))
+	if err != nil {
+		helpers.SendResponse(c.Writer, false, http.StatusBadRequest, "", errors.New("invalid user ID"))
+		return
+	}
+	filter, err := callFilterOf(c)
+	if err != nil {
+		helpers.SendResponse(c.Writer, false, http.StatusBadRequest, "", err)
+		return
+	}
+	from := time.Now()
+	if filter.From != nil {
+		from = *filter.From
+	}
+	to := from.Add(defaultSlotRange)
+	if filter.To != nil {
+		to = *filter.To
+	}
+
+	slots, err := services.ListPhysioSlots(physioID, from, to)
+	if err != nil {
+		helpers.SendResponse(c.Writer, false, availabilityErrorStatus(err), "", err)
+		return
+	}
+	helpers.SendResponse(c.Writer, true, http.StatusOK, slots, nil)
+}
+
+// BookPhysioSlot handles POST /assessments/:assessmentId/physio-calls/book
+// @Summary Book a physiotherapist slot
+// @Description Books a slot from GET /physios/{id}/slots as a scheduled call of the assessment. The slot is checked and taken in one transaction, a slot taken in the meantime returns 409.
+// @Tags Physio Calls
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param assessmentId path string true "Assessment ID"
+// @Param booking body BookSlotRequest true "Slot"
+// @Success 201 {object} services.PhysioCall
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /assessments/{assessmentId}/physio-calls/book [post]
+func BookPhysioSlot(c *gin.Context) {
+	assessmentID, err := helpers.StringToUInt32(c.Param("assessmentId"))
+	if err != nil {
+		helpers.SendResponse(c.Writer, false, http.StatusBadRequest, "", errors.New("invalid assessment ID"))
+		return
+	}
+
+	var request BookSlotRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		helpers.SendResponse(c.Writer, false, http.StatusBadRequest, "", err)
+		return
+	}
+	start, err := time.Parse(time.RFC3339, request.Start)
+	if err != nil {
+		helpers.SendResponse(c.Writer, false, http.StatusBadRequest, "", errors.New("start must be an RFC 3339 time"))
+		return
+	}
+
+	call, err := services.BookPhysioSlot(assessmentID, request.PhysioID, start)
+	if err != nil {
+		helpers.SendResponse(c.Writer, false, callErrorStatus(err), "", err)
+		return
+	}
+	helpers.SendResponse(c.Writer, true, http.StatusCreated, call, nil)
+}
+
+func availabilityErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrInvalidAvailability):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrNotPhysio), errors.Is(err, services.ErrExceptionNotFound):
+		return http.StatusNotFound
+	}
+	return callErrorStatus(err)
+}
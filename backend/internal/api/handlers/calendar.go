@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"ai-bot-deecogs/internal/helpers"
+	"ai-bot-deecogs/internal/services"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// calendarContentType is the media type of iCalendar responses
+const calendarContentType = "text/calendar; charset=utf-8"
+
+// CalendarTokenRequest is the body of POST /users/:id/calendar-tokens
+type CalendarTokenRequest struct {
+	Label *string `json:"label,omitempty"` // e.g. the calendar app the feed is added to
+}
+
+// CalendarTokenResponse is a new feed token with the URL to subscribe to
+type CalendarTokenResponse struct {
+	services.NewCalendarToken
+	FeedURL string `json:"feed_url"`
+}
+
+// GetPhysioCallICS handles GET /physio-calls/:callId/ics
+// @Summary Download a physio call as an iCalendar file
+// @Description Returns the scheduled call as a single event with reminders, for whoever can read the call
+// @Tags Calendar
+// @Produce text/calendar
+// @Security BearerAuth
+// @Param callId path string true "Call ID"
+// @Success 200 {string} string "iCalendar file"
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /physio-calls/{callId}/ics [get]
+func GetPhysioCallICS(c *gin.Context) {
+	callID, err := helpers.StringToUInt32(c.Param("callId"))
+	if err != nil {
+		helpers.SendResponse(c.Writer, false, http.StatusBadRequest, "", errors.New("invalid call ID"))
+		return
+	}
+
+	ics, err := services.PhysioCallICS(callID)
+	if err != nil {
+		helpers.SendResponse(c.Writer, false, calendarErrorStatus(err), "", err)
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="physio-call-%d.ics"`, callID))
+	c.Data(http.StatusOK, calendarContentType, ics)
+}
+
+// CreateCalendarToken handles POST /users/:id/calendar-tokens
+// @Summary Create a calendar feed token
+// @Description Creates a secret feed URL listing the user's physio calls as patient and as physiotherapist. The token is shown only in this response.
+// @Tags Calendar
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Param token body CalendarTokenRequest false "Label"
+// @Success 201 {object} CalendarTokenResponse
+// @Failure 403 {object} map[string]string
+// @Router /users/{id}/calendar-tokens [post]
+func CreateCalendarToken(c *gin.Context) {
+	userID, err := helpers.StringToUInt32(c.Param("id"))
+	if err != nil {
+		helpers.SendResponse(c.Writer, false, http.StatusBadRequest, "", errors.New("invalid user ID"))
+		return
+	}
+
+	var request CalendarTokenRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			helpers.SendResponse(c.Writer, false, http.StatusBadRequest, "", err)
+			return
+		}
+	}
+
+	token, err := services.CreateCalendarToken(userID, request.Label)
+	if err != nil {
+		helpers.SendResponse(c.Writer, false, calendarErrorStatus(err), "", err)
+		return
+	}
+	helpers.SendResponse(c.Writer, true, http.StatusCreated, CalendarTokenResponse{
+		NewCalendarToken: *token,
+		FeedURL:          requestBaseURL(c) + token.FeedPath,
+	}, nil)
+}
+
+// ListCalendarTokens handles GET /users/:id/calendar-tokens
+// @Summary List calendar feed tokens
+// @Description Lists the user's feed tokens, revoked ones included, without their secrets
+// @Tags Calendar
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Success 200 {array} services.CalendarToken
+// @Failure 403 {object} map[string]string
+// @Router /users/{id}/calendar-tokens [get]
+func ListCalendarTokens(c *gin.Context) {
+	userID, err := helpers.StringToUInt32(c.Param("id"))
+	if err != nil {
+		helpers.SendResponse(c.Writer, false, http.StatusBadRequest, "", errors.New("invalid user ID"))
+		return
+	}
+
+	tokens, err := services.ListCalendarTokens(userID)
+	if err != nil {
+		helpers.SendResponse(c.Writer, false, calendarErrorStatus(err), "", err)
+		return
+	}
+	helpers.SendResponse(c.Writer, true, http.StatusOK, tokens, nil)
+}
+
+// RevokeCalendarToken handles DELETE /users/:id/calendar-tokens/:tokenId
+// @Summary Revoke a calendar feed token
+// @Description Stops the feed of the token, subscribed calendars stop updating
+// @Tags Calendar
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Param tokenId path string true "Token ID"
+// @Success 200 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /users/{id}/calendar-tokens/{tokenId} [delete]
+func RevokeCalendarToken(c *gin.Context) {
+	userID, err := helpers.StringToUInt32(c.Param("id"))
+	if err != nil {
+		helpers.SendResponse(c.Writer, false, http.StatusBadRequest, "", errors.New("invalid user ID"))
+		return
+	}
+	tokenID, err := helpers.StringToUInt32(c.Param("tokenId"))
+	if err != nil {
+		helpers.SendResponse(c.Writer, false, http.StatusBadRequest, "", errors.New("invalid token ID"))
+		return
+	}
+
+	if err := services.RevokeCalendarToken(userID, tokenID); err != nil {
+		helpers.SendResponse(c.Writer, false, calendarErrorStatus(err), "", err)
+		return
+	}
+	helpers.SendResponse(c.Writer, true, http.StatusOK, gin.H{"message": "Calendar token revoked"}, nil)
+}
+
+// GetCalendarFeed handles GET /calendar-feeds/:token
+// @Summary Calendar feed of physio calls
+// @Description Subscribable iCalendar feed of a user's physio calls. The token in the path authenticates the request, calendar apps cannot send a bearer token.
+// @Tags Calendar
+// @Produce text/calendar
+// @Param token path string true "Feed token, optionally followed by .ics"
+// @Success 200 {string} string "iCalendar feed"
+// @Failure 404 {object} map[string]string
+// @Router /calendar-feeds/{token} [get]
+func GetCalendarFeed(c *gin.Context) {
+	token := strings.TrimSuffix(c.Param("token"), ".ics")
+
+	ics, err := services.CalendarFeed(token)
+	if err != nil {
+		helpers.SendResponse(c.Writer, false, calendarErrorStatus(err), "", err)
+		return
+	}
+	c.Header("Cache-Control", "private, max-age=300")
+	c.Data(http.StatusOK, calendarContentType, ics)
+}
+
+// requestBaseURL is the scheme and host the request reached the API on
+func requestBaseURL(c *gin.Context) string {
+	scheme := "http"
+	if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + c.Request.Host
+}
+
+func calendarErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrCalendarTokenNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrNoCallSlot):
+		return http.StatusConflict
+	}
+	return callErrorStatus(err)
+}
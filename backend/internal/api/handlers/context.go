@@ -0,0 +1,25 @@
+package handlers
+
+import (
+	"ai-bot-deecogs/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// principalKey is the gin context key holding the authenticated caller
+const principalKey = "principal"
+
+// SetPrincipal stores the authenticated caller on the request context
+func SetPrincipal(c *gin.Context, principal *services.Principal) {
+	c.Set(principalKey, principal)
+}
+
+// CurrentPrincipal returns the authenticated caller, or nil on public routes
+func CurrentPrincipal(c *gin.Context) *services.Principal {
+	if value, ok := c.Get(principalKey); ok {
+		if principal, ok := value.(*services.Principal); ok {
+			return principal
+		}
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"ai-bot-deecogs/internal/db"
+	"ai-bot-deecogs/internal/helpers"
+	"ai-bot-deecogs/internal/services"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Page size of GET /admin/notifications
+const (
+	defaultNotificationLimit = 100
+	maxNotificationLimit     = 500
+)
+
+// ListNotifications handles GET /admin/notifications
+// @Summary List outbox notifications
+// @Description Lists queued, sent, failed and skipped notifications, newest first (admin only)
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Param user_id query string false "Recipient user ID"
+// @Param status query string false "pending, sending, sent, failed or skipped"
+// @Param limit query int false "At most this many, 100 by default and 500 at most"
+// @Success 200 {array} services.Notification
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /admin/notifications [get]
+func ListNotifications(c *gin.Context) {
+	var userID *uint32
+	if value := c.Query("user_id"); value != "" {
+		id, err := helpers.StringToUInt32(value)
+		if err != nil {
+			helpers.SendResponse(c.Writer, false, http.StatusBadRequest, "", errors.New("invalid user ID"))
+			return
+		}
+		userID = &id
+	}
+	var status *string
+	if value := c.Query("status"); value != "" {
+		switch value {
+		case db.NotificationPending, db.NotificationSending, db.NotificationSent, db.NotificationFailed, db.NotificationSkipped:
+		default:
+			helpers.SendResponse(c.Writer, false, http.StatusBadRequest, "", errors.New("status must be pending, sending, sent, failed or skipped"))
+			return
+		}
+		status = &value
+	}
+	limit := defaultNotificationLimit
+	if value := c.Query("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxNotificationLimit {
+			helpers.SendResponse(c.Writer, false, http.StatusBadRequest, "", errors.New("limit must be between 1 and 500"))
+			return
+		}
+		limit = n
+	}
+
+	notifications, err := services.ListNotifications(userID, status, limit)
+	if err != nil {
+		helpers.SendResponse(c.Writer, false, http.StatusInternalServerError, "", err)
+		return
+	}
+	helpers.SendResponse(c.Writer, true, http.StatusOK, notifications, nil)
+}
+
+// RetryNotification handles POST /admin/notifications/:notificationId/retry
+// @Summary Retry a failed notification
+// @Description Gives a failed notification a fresh set of delivery attempts (admin only)
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Param notificationId path string true "Notification ID"
+// @Success 200 {object} services.Notification
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /admin/notifications/{notificationId}/retry [post]
+func RetryNotification(c *gin.Context) {
+	notificationID, err := helpers.StringToUInt32(c.Param("notificationId"))
+	if err != nil {
+		helpers.SendResponse(c.Writer, false, http.StatusBadRequest, "", errors.New("invalid notification ID"))
+		return
+	}
+
+	notification, err := services.RetryNotification(notificationID)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, services.ErrNotificationNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, services.ErrNotificationNotFailed):
+			status = http.StatusConflict
+		}
+		helpers.SendResponse(c.Writer, false, status, "", err)
+		return
+	}
+	helpers.SendResponse(c.Writer, true, http.StatusOK, notification, nil)
+}
@@ -1,7 +1,10 @@
 package handlers
 
 import (
+	"ai-bot-deecogs/internal/helpers"
+	"ai-bot-deecogs/internal/models"
 	"ai-bot-deecogs/internal/services"
+	"errors"
 	"net/http"
 	"time"
 
@@ -10,29 +13,43 @@ import (
 
 // PhysioCallRequest represents the request body for scheduling a physio call
 type PhysioCallRequest struct {
-	CallType      string `json:"call_type" binding:"required"` // Immediate or Scheduled
-	ScheduledTime string `json:"scheduled_time,omitempty"`    // Optional for immediate calls
-	InitiatedBy   string `json:"initiated_by" binding:"required"`
+	CallType        string `json:"call_type" binding:"required"` // immediate or scheduled
+	ScheduledTime   string `json:"scheduled_time,omitempty"`     // RFC 3339, required for scheduled calls
+	DurationMinutes int    `json:"duration_minutes,omitempty"`   // defaults to 30
 }
 
-// SchedulePhysioCall handles POST /assessments/:id/physio-calls
+// CallStatusRequest is the body of POST /physio-calls/:callId/status
+type CallStatusRequest struct {
+	Status models.CallStatus `json:"status" binding:"required"`
+	Reason *string           `json:"reason,omitempty"` // why the call was cancelled or missed
+}
+
+// RescheduleCallRequest is the body of PUT /physio-calls/:callId/schedule
+type RescheduleCallRequest struct {
+	ScheduledTime   string `json:"scheduled_time" binding:"required"` // RFC 3339
+	DurationMinutes int    `json:"duration_minutes,omitempty"`        // keeps the current length when omitted
+}
+
+// SchedulePhysioCall handles POST /assessments/:assessmentId/physio-calls
 // @Summary Schedule a physio call
-// @Description Schedules a physio call (immediate or scheduled) for a specific assessment
+// @Description Schedules a physio call (immediate or scheduled) for a specific assessment. The call goes to the physiotherapist of the assessment, must lie within their published hours and must not overlap another open call of the patient or physiotherapist.
 // @Tags Physio Calls
 // @Accept json
 // @Produce json
-// @Param id path string true "Assessment ID"
+// @Security BearerAuth
+// @Param assessmentId path string true "Assessment ID"
 // @Param call_data body PhysioCallRequest true "Call Details"
 // @Success 201 {object} services.PhysioCall
 // @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string
 // @Failure 500 {object} map[string]string
-// @Router /assessments/{id}/physio-calls [post]
+// @Router /assessments/{assessmentId}/physio-calls [post]
 func SchedulePhysioCall(c *gin.Context) {
-	assessmentID := c.Param("id")
+	assessmentID := c.Param("assessmentId")
 
 	var request PhysioCallRequest
 	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		helpers.SendResponse(c.Writer, false, http.StatusBadRequest, "", err)
 		return
 	}
 
@@ -40,43 +57,294 @@ func SchedulePhysioCall(c *gin.Context) {
 	if request.ScheduledTime != "" {
 		parsedTime, err := time.Parse(time.RFC3339, request.ScheduledTime)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid scheduled time format"})
+			helpers.SendResponse(c.Writer, false, http.StatusBadRequest, "", errors.New("invalid scheduled time format"))
 			return
 		}
 		scheduledTime = &parsedTime
 	}
 
-	call, err := services.SchedulePhysioCall(assessmentID, request.CallType, request.InitiatedBy, scheduledTime)
+	call, err := services.SchedulePhysioCall(assessmentID, request.CallType, scheduledTime, request.DurationMinutes)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		helpers.SendResponse(c.Writer, false, callErrorStatus(err), "", err)
 		return
 	}
 
-	c.JSON(http.StatusCreated, call)
+	helpers.SendResponse(c.Writer, true, http.StatusCreated, call, nil)
 }
 
-// GetPhysioCalls handles GET /assessments/:id/physio-calls
+// GetPhysioCalls handles GET /assessments/:assessmentId/physio-calls
 // @Summary Get physio calls
-// @Description Retrieves all physio calls for a specific assessment
+// @Description Retrieves all physio calls for a specific assessment, newest first
 // @Tags Physio Calls
 // @Produce json
-// @Param id path string true "Assessment ID"
+// @Security BearerAuth
+// @Param assessmentId path string true "Assessment ID"
 // @Success 200 {array} services.PhysioCall
-// @Failure 404 {object} map[string]string
 // @Failure 500 {object} map[string]string
-// @Router /assessments/:id/physio-calls [get]
+// @Router /assessments/{assessmentId}/physio-calls [get]
 func GetPhysioCalls(c *gin.Context) {
-	assessmentID := c.Param("id")
+	calls, err := services.GetPhysioCalls(c.Param("assessmentId"))
+	if err != nil {
+		helpers.SendResponse(c.Writer, false, http.StatusInternalServerError, "", err)
+		return
+	}
+
+	helpers.SendResponse(c.Writer, true, http.StatusOK, calls, nil)
+}
+
+// GetPhysioCall handles GET /physio-calls/:callId
+// @Summary Get a physio call
+// @Description Returns a call to its patient, its physiotherapist, the physiotherapist of its assessment and admins
+// @Tags Physio Calls
+// @Produce json
+// @Security BearerAuth
+// @Param callId path string true "Call ID"
+// @Success 200 {object} services.PhysioCall
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /physio-calls/{callId} [get]
+func GetPhysioCall(c *gin.Context) {
+	callID, err := helpers.StringToUInt32(c.Param("callId"))
+	if err != nil {
+		helpers.SendResponse(c.Writer, false, http.StatusBadRequest, "", errors.New("invalid call ID"))
+		return
+	}
+
+	call, err := services.GetPhysioCall(callID)
+	if err != nil {
+		helpers.SendResponse(c.Writer, false, callErrorStatus(err), "", err)
+		return
+	}
+
+	helpers.SendResponse(c.Writer, true, http.StatusOK, call, nil)
+}
+
+// UpdatePhysioCallStatus handles POST /physio-calls/:callId/status
+// @Summary Move a physio call along its lifecycle
+// @Description Calls run scheduled -> in_progress -> completed and can end as cancelled or no_show. Patients can only cancel, the physiotherapist of the call and admins make every transition.
+// @Tags Physio Calls
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param callId path string true "Call ID"
+// @Param status body CallStatusRequest true "New status"
+// @Success 200 {object} services.PhysioCall
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /physio-calls/{callId}/status [post]
+func UpdatePhysioCallStatus(c *gin.Context) {
+	callID, err := helpers.StringToUInt32(c.Param("callId"))
+	if err != nil {
+		helpers.SendResponse(c.Writer, false, http.StatusBadRequest, "", errors.New("invalid call ID"))
+		return
+	}
+
+	var request CallStatusRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		helpers.SendResponse(c.Writer, false, http.StatusBadRequest, "", err)
+		return
+	}
+
+	call, err := services.UpdatePhysioCallStatus(CurrentPrincipal(c), callID, request.Status, request.Reason)
+	if err != nil {
+		helpers.SendResponse(c.Writer, false, callErrorStatus(err), "", err)
+		return
+	}
+
+	helpers.SendResponse(c.Writer, true, http.StatusOK, call, nil)
+}
 
-	calls, err := services.GetPhysioCalls(assessmentID)
+// ReschedulePhysioCall handles PUT /physio-calls/:callId/schedule
+// @Summary Reschedule a physio call
+// @Description Moves a scheduled call to a new slot, which must lie within the physiotherapist's published hours and not overlap another open call of the patient or physiotherapist
+// @Tags Physio Calls
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param callId path string true "Call ID"
+// @Param schedule body RescheduleCallRequest true "New slot"
+// @Success 200 {object} services.PhysioCall
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /physio-calls/{callId}/schedule [put]
+func ReschedulePhysioCall(c *gin.Context) {
+	callID, err := helpers.StringToUInt32(c.Param("callId"))
 	if err != nil {
-		if err.Error() == "no physio calls found for the given assessment ID" {
-			c.JSON(http.StatusNotFound, gin.H{"error": "No physio calls found"})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		helpers.SendResponse(c.Writer, false, http.StatusBadRequest, "", errors.New("invalid call ID"))
+		return
+	}
+
+	var request RescheduleCallRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		helpers.SendResponse(c.Writer, false, http.StatusBadRequest, "", err)
+		return
+	}
+	scheduledTime, err := time.Parse(time.RFC3339, request.ScheduledTime)
+	if err != nil {
+		helpers.SendResponse(c.Writer, false, http.StatusBadRequest, "", errors.New("invalid scheduled time format"))
+		return
+	}
+
+	call, err := services.ReschedulePhysioCall(callID, scheduledTime, request.DurationMinutes)
+	if err != nil {
+		helpers.SendResponse(c.Writer, false, callErrorStatus(err), "", err)
+		return
+	}
+
+	helpers.SendResponse(c.Writer, true, http.StatusOK, call, nil)
+}
+
+// AssignCallPhysio handles PUT /admin/physio-calls/:callId/physio
+// @Summary Assign a physiotherapist to a physio call
+// @Description Makes a physiotherapist responsible for a scheduled call, a null physioId unassigns it (admin only)
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param callId path string true "Call ID"
+// @Param physio body map[string]uint32 true "Physiotherapist"
+// @Success 200 {object} services.PhysioCall
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /admin/physio-calls/{callId}/physio [put]
+func AssignCallPhysio(c *gin.Context) {
+	callID, err := helpers.StringToUInt32(c.Param("callId"))
+	if err != nil {
+		helpers.SendResponse(c.Writer, false, http.StatusBadRequest, "", errors.New("invalid call ID"))
+		return
+	}
+
+	var request struct {
+		PhysioID *uint32 `json:"physioId"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		helpers.SendResponse(c.Writer, false, http.StatusBadRequest, "", err)
+		return
+	}
+
+	call, err := services.AssignCallPhysio(callID, request.PhysioID)
+	if err != nil {
+		helpers.SendResponse(c.Writer, false, callErrorStatus(err), "", err)
+		return
+	}
+
+	helpers.SendResponse(c.Writer, true, http.StatusOK, call, nil)
+}
+
+// ListUserPhysioCalls handles GET /users/:id/physio-calls
+// @Summary List the physio calls of a user
+// @Description Returns the calls of the user's assessments in time order, optionally within [from, to) and of one status
+// @Tags Physio Calls
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Param from query string false "Earliest scheduled time, RFC 3339"
+// @Param to query string false "Scheduled time before which calls end the list, RFC 3339"
+// @Param status query string false "Call status"
+// @Success 200 {array} services.PhysioCall
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /users/{id}/physio-calls [get]
+func ListUserPhysioCalls(c *gin.Context) {
+	userID, err := helpers.StringToUInt32(c.Param("id"))
+	if err != nil {
+		helpers.SendResponse(c.Writer, false, http.StatusBadRequest, "", errors.New("invalid user ID"))
+		return
+	}
+	filter, err := callFilterOf(c)
+	if err != nil {
+		helpers.SendResponse(c.Writer, false, http.StatusBadRequest, "", err)
+		return
+	}
+
+	calls, err := services.ListUserPhysioCalls(userID, filter)
+	if err != nil {
+		helpers.SendResponse(c.Writer, false, callErrorStatus(err), "", err)
+		return
+	}
+
+	helpers.SendResponse(c.Writer, true, http.StatusOK, calls, nil)
+}
+
+// ListPhysioCallsOfPhysio handles GET /physio/calls and GET /admin/physios/:id/calls
+// @Summary List the physio calls of a physiotherapist
+// @Description Returns the calls assigned to the calling physiotherapist, or for admins to the :id physiotherapist, in time order, optionally within [from, to) and of one status
+// @Tags Physio Calls
+// @Produce json
+// @Security BearerAuth
+// @Param from query string false "Earliest scheduled time, RFC 3339"
+// @Param to query string false "Scheduled time before which calls end the list, RFC 3339"
+// @Param status query string false "Call status"
+// @Success 200 {array} services.PhysioCall
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /physio/calls [get]
+// @Router /admin/physios/{id}/calls [get]
+func ListPhysioCallsOfPhysio(c *gin.Context) {
+	physioID := CurrentPrincipal(c).UserID
+	if c.Param("id") != "" {
+		id, err := helpers.StringToUInt32(c.Param("id"))
+		if err != nil {
+			helpers.SendResponse(c.Writer, false, http.StatusBadRequest, "", errors.New("invalid user ID"))
+			return
 		}
+		physioID = id
+	}
+	filter, err := callFilterOf(c)
+	if err != nil {
+		helpers.SendResponse(c.Writer, false, http.StatusBadRequest, "", err)
+		return
+	}
+
+	calls, err := services.ListPhysioCallsOfPhysio(physioID, filter)
+	if err != nil {
+		helpers.SendResponse(c.Writer, false, callErrorStatus(err), "", err)
 		return
 	}
 
-	c.JSON(http.StatusOK, calls)
-}
\ No newline at end of file
+	helpers.SendResponse(c.Writer, true, http.StatusOK, calls, nil)
+}
+
+// callFilterOf reads the from, to and status query parameters
+func callFilterOf(c *gin.Context) (services.CallFilter, error) {
+	var filter services.CallFilter
+	for name, target := range map[string]**time.Time{"from": &filter.From, "to": &filter.To} {
+		value := c.Query(name)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return filter, errors.New(name + " must be an RFC 3339 time")
+		}
+		*target = &t
+	}
+	if status := c.Query("status"); status != "" {
+		s := models.CallStatus(status)
+		filter.Status = &s
+	}
+	return filter, nil
+}
+
+func callErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrInvalidCall), errors.Is(err, services.ErrNotPhysio):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, services.ErrCallNotFound), errors.Is(err, services.ErrUserNotFound),
+		errors.Is(err, services.ErrAssessmentNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrCallOverlap), errors.Is(err, services.ErrSlotUnavailable),
+		errors.Is(err, services.ErrInvalidTransition), errors.Is(err, services.ErrCallStatusConflict):
+		return http.StatusConflict
+	}
+	if err.Error() == "invalid assessment ID" {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"ai-bot-deecogs/internal/helpers"
+	"ai-bot-deecogs/internal/services"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetUserProgress handles GET /users/:id/progress
+// @Summary Get the recovery progress of a user
+// @Description Aggregates ROM measurements, pain ratings and AI extracted symptoms across the assessments of each anatomy the user was assessed on, with the trend of each series
+// @Tags Progress
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Success 200 {array} services.UserProgress
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /users/{id}/progress [get]
+func GetUserProgress(c *gin.Context) {
+	userID, err := helpers.StringToUInt32(c.Param("id"))
+	if err != nil {
+		helpers.SendResponse(c.Writer, false, http.StatusBadRequest, "", errors.New("invalid user ID"))
+		return
+	}
+
+	progress, err := services.GetUserProgress(userID)
+	if err != nil {
+		helpers.SendResponse(c.Writer, false, progressErrorStatus(err), "", err)
+		return
+	}
+
+	helpers.SendResponse(c.Writer, true, http.StatusOK, progress, nil)
+}
+
+// GetUserAnatomyProgress handles GET /users/:id/progress/:anatomyId
+// @Summary Get the recovery progress of one anatomy
+// @Description Returns the ROM, pain and symptom series of one anatomy across the assessments of the user, with trend direction and rate of change per week
+// @Tags Progress
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Param anatomyId path string true "Anatomy ID"
+// @Success 200 {object} services.UserProgress
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /users/{id}/progress/{anatomyId} [get]
+func GetUserAnatomyProgress(c *gin.Context) {
+	userID, err := helpers.StringToUInt32(c.Param("id"))
+	if err != nil {
+		helpers.SendResponse(c.Writer, false, http.StatusBadRequest, "", errors.New("invalid user ID"))
+		return
+	}
+	anatomyID, err := helpers.StringToUInt32(c.Param("anatomyId"))
+	if err != nil {
+		helpers.SendResponse(c.Writer, false, http.StatusBadRequest, "", errors.New("invalid anatomy ID"))
+		return
+	}
+
+	progress, err := services.GetUserAnatomyProgress(userID, anatomyID)
+	if err != nil {
+		helpers.SendResponse(c.Writer, false, progressErrorStatus(err), "", err)
+		return
+	}
+
+	helpers.SendResponse(c.Writer, true, http.StatusOK, progress, nil)
+}
+
+func progressErrorStatus(err error) int {
+	if errors.Is(err, services.ErrUserNotFound) || errors.Is(err, services.ErrProgressNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
@@ -1,5 +1,19 @@
 package handlers
 
+import (
+	"ai-bot-deecogs/internal/db"
+	"ai-bot-deecogs/internal/helpers"
+	"ai-bot-deecogs/internal/models"
+	"ai-bot-deecogs/internal/services"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxLandmarkBody bounds a landmark upload, a 30 fps recording takes about 1MB per 10 seconds
+const maxLandmarkBody = 64 << 20
+
 // SubmitROMAnalysis handles POST /assessments/:id/rom
 // @Summary Submit ROM analysis data
 // @Description Submits pose model data and analysis results for an assessment
@@ -54,4 +68,56 @@ package handlers
 // 	}
 
 // 	c.JSON(http.StatusOK, data)
-// }
\ No newline at end of file
+// }
+
+// SubmitLandmarkROM handles POST /assessments/:assessmentId/rom/landmarks
+// @Summary Submit pose landmarks for ROM
+// @Description Computes the range of motion from MediaPipe-style pose landmarks, 33 keypoints per frame with visibility scores, one recording per movement. Frames where a needed landmark is poorly visible are rejected, outliers are filtered and the angles smoothed before the ROM of each movement is derived. The frames, the angle series and the measurements are stored.
+// @Tags ROM
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param assessmentId path string true "Assessment ID"
+// @Param landmarks body services.LandmarkROMRequest true "Landmark recordings"
+// @Success 201 {object} services.LandmarkROMResponse
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 413 {object} map[string]string
+// @Router /assessments/{assessmentId}/rom/landmarks [post]
+func SubmitLandmarkROM(c *gin.Context) {
+	assessmentID, err := helpers.StringToUInt32(c.Param("assessmentId"))
+	if err != nil {
+		helpers.SendResponse(c.Writer, false, http.StatusBadRequest, "", err)
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxLandmarkBody)
+	var request services.LandmarkROMRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			helpers.SendResponse(c.Writer, false, http.StatusRequestEntityTooLarge, "", err)
+			return
+		}
+		helpers.SendResponse(c.Writer, false, http.StatusBadRequest, "", err)
+		return
+	}
+
+	result, err := services.SubmitLandmarkROM(assessmentID, request)
+	if err != nil {
+		var measurementErr *models.ROMMeasurementError
+		switch {
+		case errors.Is(err, services.ErrInvalidLandmarks), errors.As(err, &measurementErr):
+			helpers.SendResponse(c.Writer, false, http.StatusBadRequest, "", err)
+		case errors.Is(err, db.ErrNotFound):
+			helpers.SendResponse(c.Writer, false, http.StatusNotFound, "", services.ErrAssessmentNotFound)
+		case errors.Is(err, services.ErrInvalidTransition):
+			helpers.SendResponse(c.Writer, false, http.StatusConflict, "", err)
+		default:
+			helpers.SendResponse(c.Writer, false, http.StatusInternalServerError, "", err)
+		}
+		return
+	}
+	helpers.SendResponse(c.Writer, true, http.StatusCreated, result, nil)
+}
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"ai-bot-deecogs/internal/helpers"
+	"ai-bot-deecogs/internal/services"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ROMNormsRequest is the body of PUT /admin/rom-norms
+type ROMNormsRequest struct {
+	Norms []services.ROMNorm `json:"norms" binding:"required"`
+}
+
+// ListROMNorms handles GET /rom-norms
+// @Summary List normative ROM ranges
+// @Description Lists the normal range of each movement per anatomy, age band and sex that ROM results are scored against
+// @Tags ROM
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} services.ROMNorm
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /rom-norms [get]
+func ListROMNorms(c *gin.Context) {
+	list, err := services.ListROMNorms()
+	if err != nil {
+		helpers.SendResponse(c.Writer, false, http.StatusInternalServerError, "", err)
+		return
+	}
+	helpers.SendResponse(c.Writer, true, http.StatusOK, list, nil)
+}
+
+// UpsertROMNorms handles PUT /admin/rom-norms
+// @Summary Seed normative ROM ranges
+// @Description Stores normative ranges, replacing the range of a band that already exists for the anatomy, movement, sex and ages (admin only). Loading the same set again changes nothing.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param norms body ROMNormsRequest true "Normative ranges"
+// @Success 200 {array} services.ROMNorm
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /admin/rom-norms [put]
+func UpsertROMNorms(c *gin.Context) {
+	var request ROMNormsRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		helpers.SendResponse(c.Writer, false, http.StatusBadRequest, "", err)
+		return
+	}
+
+	norms, err := services.UpsertROMNorms(request.Norms)
+	if err != nil {
+		helpers.SendResponse(c.Writer, false, romNormErrorStatus(err), "", err)
+		return
+	}
+	helpers.SendResponse(c.Writer, true, http.StatusOK, norms, nil)
+}
+
+// DeleteROMNorm handles DELETE /admin/rom-norms/:id
+// @Summary Delete a normative ROM range
+// @Description Removes a normative range (admin only)
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Norm ID"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /admin/rom-norms/{id} [delete]
+func DeleteROMNorm(c *gin.Context) {
+	normID, err := helpers.StringToUInt32(c.Param("id"))
+	if err != nil {
+		helpers.SendResponse(c.Writer, false, http.StatusBadRequest, "", errors.New("invalid norm ID"))
+		return
+	}
+
+	if err := services.DeleteROMNorm(normID); err != nil {
+		helpers.SendResponse(c.Writer, false, romNormErrorStatus(err), "", err)
+		return
+	}
+	helpers.SendResponse(c.Writer, true, http.StatusOK, gin.H{"message": "Normative range deleted"}, nil)
+}
+
+func romNormErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrROMNormNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrInvalidROMNorm):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
@@ -1,8 +1,8 @@
 package handlers
 
 import (
-	"ai-bot-deecogs/internal/clients"
 	"ai-bot-deecogs/internal/helpers"
+	"ai-bot-deecogs/internal/services"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -29,41 +29,12 @@ func SpeechToText(c *gin.Context) {
 		return
 	}
 
-	// Convert to Google API format
-	googleRequest := map[string]interface{}{
-		"config": map[string]interface{}{
-			"encoding":                   "WEBM_OPUS",
-			"sampleRateHertz":            48000,
-			"languageCode":               request.LanguageCode,
-			"enableAutomaticPunctuation": true,
-			"model":                      "latest_long",
-			"useEnhanced":                true,
-		},
-		"audio": map[string]interface{}{
-			"content": request.AudioContent,
-		},
-	}
-
-	result, err := clients.GetGoogleSpeechClient().SpeechToText(googleRequest)
+	transcript, err := services.TranscribeAudio(c.Request.Context(), request.AudioContent, request.LanguageCode)
 	if err != nil {
 		helpers.SendResponse(c.Writer, false, http.StatusInternalServerError, nil, err)
 		return
 	}
 
-	// Extract transcript from Google response
-	transcript := ""
-	if results, ok := result["results"].([]interface{}); ok && len(results) > 0 {
-		if firstResult, ok := results[0].(map[string]interface{}); ok {
-			if alternatives, ok := firstResult["alternatives"].([]interface{}); ok && len(alternatives) > 0 {
-				if alt, ok := alternatives[0].(map[string]interface{}); ok {
-					if t, ok := alt["transcript"].(string); ok {
-						transcript = t
-					}
-				}
-			}
-		}
-	}
-
 	// Return in expected format
 	helpers.SendResponse(c.Writer, true, http.StatusOK, map[string]interface{}{
 		"transcript": transcript,
@@ -78,33 +49,16 @@ func TextToSpeech(c *gin.Context) {
 		return
 	}
 
-	// Convert to Google API format
-	googleRequest := map[string]interface{}{
-		"input": map[string]interface{}{
-			"text": request.Text,
-		},
-		"voice": map[string]interface{}{
-			"languageCode": request.LanguageCode,
-			"name":         request.VoiceName,
-		},
-		"audioConfig": map[string]interface{}{
-			"audioEncoding": "LINEAR16",
-			"speakingRate":  request.SpeakingRate,
-		},
-	}
-
-	result, err := clients.GetGoogleSpeechClient().TextToSpeech(googleRequest)
+	audioContent, err := services.SynthesizeSpeech(c.Request.Context(), request.Text, services.VoiceSettings{
+		LanguageCode: request.LanguageCode,
+		VoiceName:    request.VoiceName,
+		SpeakingRate: request.SpeakingRate,
+	})
 	if err != nil {
 		helpers.SendResponse(c.Writer, false, http.StatusInternalServerError, nil, err)
 		return
 	}
 
-	// Extract audio content
-	audioContent := ""
-	if ac, ok := result["audioContent"].(string); ok {
-		audioContent = ac
-	}
-
 	// Return in expected format
 	helpers.SendResponse(c.Writer, true, http.StatusOK, map[string]interface{}{
 		"audio_content": audioContent,
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"ai-bot-deecogs/internal/helpers"
+	"ai-bot-deecogs/internal/services"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StreamChatHandler handles POST /assessments/:assessmentId/chat/stream
+// @Summary Stream a chat reply
+// @Description Server-Sent Events variant of the chat endpoint. Emits "token" events with partial reply text, then one "action" event with the structured AI payload, or an "error" event.
+// @Tags Assessments
+// @Accept json
+// @Produce text/event-stream
+// @Security BearerAuth
+// @Param assessmentId path string true "Assessment ID"
+// @Param chat_body body services.ChatMessageRequest true "New message"
+// @Success 200 {string} string "event stream"
+// @Failure 400 {object} map[string]string
+// @Router /assessments/{assessmentId}/chat/stream [post]
+func StreamChatHandler(c *gin.Context) {
+	assessmentID, err := helpers.StringToUInt32(c.Param("assessmentId"))
+	if err != nil {
+		helpers.SendResponse(c.Writer, false, http.StatusBadRequest, "", err)
+		return
+	}
+
+	var chatRequest struct {
+		Message     string                 `json:"message"`
+		ChatHistory []services.ChatMessage `json:"chat_history"` // deprecated, the history is stored server side
+	}
+	if err := c.ShouldBindJSON(&chatRequest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+	message := newChatMessage(chatRequest.Message, chatRequest.ChatHistory)
+	if message == "" {
+		helpers.SendResponse(c.Writer, false, http.StatusBadRequest, "", errors.New("message is required"))
+		return
+	}
+
+	startEventStream(c)
+	aiResponse, err := services.StreamChatToAI(c.Request.Context(), assessmentID, message, func(token string) {
+		sendEvent(c, "token", gin.H{"text": token})
+	})
+	if err != nil {
+		log.Printf("Error streaming chat for assessment %d: %v", assessmentID, err)
+		sendEvent(c, "error", aiErrorEvent(err, err.Error()))
+		return
+	}
+	sendEvent(c, "action", aiResponse.Data)
+}
+
+// StreamQuestionsHandler handles POST /assessments/:assessmentId/questionnaires/stream
+// @Summary Stream a questionnaire reply
+// @Description Server-Sent Events variant of the questionnaire endpoint. Emits "token" events with partial reply text, then one "action" event with the structured AI payload, or an "error" event.
+// @Tags Assessments
+// @Accept json
+// @Produce text/event-stream
+// @Security BearerAuth
+// @Param assessmentId path string true "Assessment ID"
+// @Param questions body services.QuestionRequest true "Questions"
+// @Success 200 {string} string "event stream"
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /assessments/{assessmentId}/questionnaires/stream [post]
+func StreamQuestionsHandler(c *gin.Context) {
+	assessmentID, questionRequest, ok := bindQuestionnaire(c)
+	if !ok {
+		return
+	}
+
+	startEventStream(c)
+	aiResponse, err := services.StreamQuestionsToAI(c.Request.Context(), assessmentID, questionRequest, func(token string) {
+		sendEvent(c, "token", gin.H{"text": token})
+	})
+	if err != nil {
+		log.Printf("Error streaming questions for assessment %d: %v", assessmentID, err)
+		sendEvent(c, "error", aiErrorEvent(err, questionnaireErrorMessage(err)))
+		return
+	}
+	sendEvent(c, "action", aiResponse.Data)
+}
+
+// startEventStream writes the SSE headers, errors after this point are sent as events
+func startEventStream(c *gin.Context) {
+	header := c.Writer.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	header.Set("X-Accel-Buffering", "no") // keep proxies from buffering the stream
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+}
+
+// sendEvent writes one event and flushes it to the client
+func sendEvent(c *gin.Context, event string, data interface{}) {
+	c.SSEvent(event, data)
+	c.Writer.Flush()
+}
@@ -1,46 +1,202 @@
 package handlers
 
 import (
+	"ai-bot-deecogs/internal/helpers"
+	"ai-bot-deecogs/internal/models"
+	"ai-bot-deecogs/internal/services"
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 )
 
+// CreateUserRequest is the body of POST /users
+type CreateUserRequest struct {
+	Name     string `json:"name" binding:"required"`
+	Email    string `json:"email" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// UpdateUserRequest is the body of PATCH /users/:id, omitted fields are unchanged
+type UpdateUserRequest struct {
+	Name        *string `json:"name,omitempty"`
+	Email       *string `json:"email,omitempty"`
+	DateOfBirth *string `json:"dateOfBirth,omitempty"` // YYYY-MM-DD, empty to clear
+	Sex         *string `json:"sex,omitempty"`         // female or male, empty to clear
+}
+
+// ChangePasswordRequest is the body of PUT /users/:id/password
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password" binding:"required"`
+}
+
 // CreateUser handles POST /users
 // @Summary Create a new user
-// @Description Creates a new user with the provided details
+// @Description Registers a new patient account
 // @Tags Users
 // @Accept json
 // @Produce json
-// @Param user body map[string]interface{} true "User Data"
-// @Success 201 {object} map[string]string
+// @Param user body CreateUserRequest true "User Data"
+// @Success 201 {object} services.UserProfile
 // @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string
 // @Router /users [post]
 func CreateUser(c *gin.Context) {
-	var user map[string]interface{}
-	if err := c.ShouldBindJSON(&user); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user data"})
+	var request CreateUserRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		helpers.SendResponse(c.Writer, false, http.StatusBadRequest, "", errors.New("invalid user data"))
+		return
+	}
+
+	// The password is stored as a bcrypt hash
+	user, err := services.CreateUser(request.Name, request.Email, request.Password)
+	if err != nil {
+		helpers.SendResponse(c.Writer, false, userErrorStatus(err), "", err)
 		return
 	}
-	// Logic to create user (e.g., insert into database)
-	c.JSON(http.StatusCreated, gin.H{"message": "User created successfully!", "user": user})
+
+	helpers.SendResponse(c.Writer, true, http.StatusCreated, user, nil)
 }
 
 // GetUser handles GET /users/:id
 // @Summary Get user details
-// @Description Retrieves details of a user by ID
+// @Description Retrieves the profile of a user, callers can only read their own unless they are admins
 // @Tags Users
 // @Produce json
+// @Security BearerAuth
 // @Param id path string true "User ID"
-// @Success 200 {object} map[string]interface{}
+// @Success 200 {object} services.UserProfile
+// @Failure 403 {object} map[string]string
 // @Failure 404 {object} map[string]string
 // @Router /users/{id} [get]
 func GetUser(c *gin.Context) {
-	userID := c.Param("id")
-	// Logic to fetch user details (e.g., query from database)
-	c.JSON(http.StatusOK, gin.H{
-		"user_id": userID,
-		"name":    "John Doe",
-		"email":   "john.doe@example.com",
-	})
+	userID, err := helpers.StringToUInt32(c.Param("id"))
+	if err != nil {
+		helpers.SendResponse(c.Writer, false, http.StatusBadRequest, "", errors.New("invalid user ID"))
+		return
+	}
+
+	user, err := services.GetUserProfile(userID)
+	if err != nil {
+		helpers.SendResponse(c.Writer, false, userErrorStatus(err), "", err)
+		return
+	}
+
+	helpers.SendResponse(c.Writer, true, http.StatusOK, user, nil)
+}
+
+// UpdateUser handles PATCH /users/:id
+// @Summary Update user profile
+// @Description Changes the name, email, date of birth and sex of a user. Age and sex select the normal ROM ranges the user is scored against.
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Param user body UpdateUserRequest true "Profile fields"
+// @Success 200 {object} services.UserProfile
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /users/{id} [patch]
+func UpdateUser(c *gin.Context) {
+	userID, err := helpers.StringToUInt32(c.Param("id"))
+	if err != nil {
+		helpers.SendResponse(c.Writer, false, http.StatusBadRequest, "", errors.New("invalid user ID"))
+		return
+	}
+
+	var request UpdateUserRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		helpers.SendResponse(c.Writer, false, http.StatusBadRequest, "", errors.New("invalid user data"))
+		return
+	}
+
+	user, err := services.UpdateUserProfile(userID, request.Name, request.Email, request.DateOfBirth, request.Sex)
+	if err != nil {
+		helpers.SendResponse(c.Writer, false, userErrorStatus(err), "", err)
+		return
+	}
+
+	helpers.SendResponse(c.Writer, true, http.StatusOK, user, nil)
+}
+
+// ChangePassword handles PUT /users/:id/password
+// @Summary Change password
+// @Description Replaces a user's password, users changing their own must send the current one
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Param passwords body ChangePasswordRequest true "Passwords"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /users/{id}/password [put]
+func ChangePassword(c *gin.Context) {
+	userID, err := helpers.StringToUInt32(c.Param("id"))
+	if err != nil {
+		helpers.SendResponse(c.Writer, false, http.StatusBadRequest, "", errors.New("invalid user ID"))
+		return
+	}
+
+	var request ChangePasswordRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		helpers.SendResponse(c.Writer, false, http.StatusBadRequest, "", errors.New("invalid password data"))
+		return
+	}
+
+	// Admins resetting someone else's password do not know the current one
+	principal := CurrentPrincipal(c)
+	verifyCurrent := principal.UserID == userID || !principal.Can(models.PermUserManage)
+
+	if err := services.ChangePassword(userID, request.CurrentPassword, request.NewPassword, verifyCurrent); err != nil {
+		helpers.SendResponse(c.Writer, false, userErrorStatus(err), "", err)
+		return
+	}
+
+	helpers.SendResponse(c.Writer, true, http.StatusOK, gin.H{"message": "Password updated, every session of the user was signed out"}, nil)
+}
+
+// DeleteUser handles DELETE /users/:id
+// @Summary Delete user
+// @Description Deactivates a user account, its assessments are kept
+// @Tags Users
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Success 200 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /users/{id} [delete]
+func DeleteUser(c *gin.Context) {
+	userID, err := helpers.StringToUInt32(c.Param("id"))
+	if err != nil {
+		helpers.SendResponse(c.Writer, false, http.StatusBadRequest, "", errors.New("invalid user ID"))
+		return
+	}
+
+	if err := services.DeleteUser(userID); err != nil {
+		helpers.SendResponse(c.Writer, false, userErrorStatus(err), "", err)
+		return
+	}
+
+	helpers.SendResponse(c.Writer, true, http.StatusOK, gin.H{"message": "User deleted"}, nil)
+}
+
+func userErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrInvalidUserData):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrPasswordMismatch):
+		return http.StatusForbidden
+	case errors.Is(err, services.ErrUserNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrEmailTaken):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
 }
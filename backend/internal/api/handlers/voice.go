@@ -0,0 +1,405 @@
+package handlers
+
+import (
+	"ai-bot-deecogs/internal/helpers"
+	"ai-bot-deecogs/internal/models"
+	"ai-bot-deecogs/internal/services"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// Voice session messages. Audio is exchanged as binary frames, everything else as JSON
+// text frames with a "type". The client sends "start", "end_of_utterance", "text",
+// "interrupt" and "stop"; the server answers with "state" (listening, thinking or
+// speaking), "transcript", "token", "reply", "audio_start", "audio_end" and "error".
+const (
+	voiceStateListening = "listening"
+	voiceStateThinking  = "thinking"
+	voiceStateSpeaking  = "speaking"
+
+	voiceMaxUtterance  = 10 << 20 // recorded audio of a single user turn
+	voiceAudioChunk    = 32 << 10 // synthesized audio is sent in chunks so barge-in stops it quickly
+	voiceWriteTimeout  = 10 * time.Second
+	voicePongTimeout   = 60 * time.Second
+	voicePingInterval  = 25 * time.Second
+	voiceStopWaitLimit = 5 * time.Second
+)
+
+// newVoiceUpgrader accepts connections from the given browser origins
+func newVoiceUpgrader(origins []string) *websocket.Upgrader {
+	return &websocket.Upgrader{
+		ReadBufferSize:  16 << 10,
+		WriteBufferSize: 16 << 10,
+		Subprotocols:    []string{"bearer"}, // echoed back when the token came as a subprotocol
+		CheckOrigin: func(r *http.Request) bool {
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				return true // not a browser
+			}
+			for _, allowed := range origins {
+				if origin == allowed {
+					return true
+				}
+			}
+			return false
+		},
+	}
+}
+
+// voiceClientMessage is a JSON control message from the client
+type voiceClientMessage struct {
+	Type     string                     `json:"type"`
+	Text     string                     `json:"text,omitempty"`     // "text": typed answer, skips recognition
+	Settings *services.VoiceSettings    `json:"settings,omitempty"` // "start"
+	History  []services.QuestionMessage `json:"history,omitempty"`  // "start": questionnaire so far, to resume
+}
+
+// VoiceSessionHandler handles GET /assessments/:assessmentId/voice, only browsers on
+// allowedOrigins may open a session
+// @Summary Voice questionnaire session
+// @Description Upgrades to a WebSocket running the questionnaire by voice. Binary frames carry WEBM_OPUS audio of the user's utterance, {"type":"end_of_utterance"} has it transcribed and sent to the questionnaire AI, and the reply is streamed back as "token" messages, a "reply" with the structured payload and LINEAR16 audio frames between "audio_start" and "audio_end". Audio or {"type":"interrupt"} while the server is thinking or speaking cancels that turn (barge-in). "state" messages report listening, thinking and speaking. Browsers pass the access token as the subprotocols "bearer, <token>".
+// @Tags Assessments
+// @Security BearerAuth
+// @Param assessmentId path string true "Assessment ID"
+// @Success 101 {string} string "Switching Protocols"
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /assessments/{assessmentId}/voice [get]
+func VoiceSessionHandler(allowedOrigins []string) gin.HandlerFunc {
+	upgrader := newVoiceUpgrader(allowedOrigins)
+	return func(c *gin.Context) {
+		assessmentID, err := helpers.StringToUInt32(c.Param("assessmentId"))
+		if err != nil {
+			helpers.SendResponse(c.Writer, false, http.StatusBadRequest, "", err)
+			return
+		}
+		assessment, err := services.GetAssessment(assessmentID)
+		if err != nil {
+			helpers.SendResponse(c.Writer, false, http.StatusNotFound, "", err)
+			return
+		}
+		if models.AssessmentStatus(assessment.Status).IsTerminal() {
+			helpers.SendResponse(c.Writer, false, http.StatusConflict, "", fmt.Errorf("assessment is already %s", assessment.Status))
+			return
+		}
+
+		conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+		if err != nil {
+			// The upgrader has already answered with an HTTP error
+			log.Printf("Voice session upgrade failed for assessment %d: %v", assessmentID, err)
+			return
+		}
+		defer conn.Close()
+
+		session := &voiceSession{
+			conn:         conn,
+			assessmentID: assessmentID,
+			settings:     services.DefaultVoiceSettings(),
+		}
+		log.Printf("Voice session opened for assessment %d", assessmentID)
+		session.run()
+		log.Printf("Voice session closed for assessment %d", assessmentID)
+	}
+}
+
+// voiceSession is one WebSocket connection. The read loop owns the audio buffer and
+// turns run one at a time in their own goroutine.
+type voiceSession struct {
+	conn         *websocket.Conn
+	assessmentID uint32
+	audio        bytes.Buffer
+
+	mu       sync.Mutex // guards settings and history
+	settings services.VoiceSettings
+	history  []services.QuestionMessage
+
+	writeMu sync.Mutex
+
+	turnMu     sync.Mutex
+	cancelTurn context.CancelFunc
+	turnDone   chan struct{}
+}
+
+func (s *voiceSession) run() {
+	s.conn.SetReadLimit(voiceMaxUtterance)
+	s.conn.SetReadDeadline(time.Now().Add(voicePongTimeout))
+	s.conn.SetPongHandler(func(string) error {
+		return s.conn.SetReadDeadline(time.Now().Add(voicePongTimeout))
+	})
+
+	stopPing := make(chan struct{})
+	defer close(stopPing)
+	go s.keepAlive(stopPing)
+	defer s.waitTurn()
+	defer s.interrupt()
+
+	s.sendState(voiceStateListening)
+	for {
+		kind, data, err := s.conn.ReadMessage()
+		if err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				log.Printf("Voice session read error for assessment %d: %v", s.assessmentID, err)
+			}
+			return
+		}
+		s.conn.SetReadDeadline(time.Now().Add(voicePongTimeout))
+
+		if kind == websocket.BinaryMessage {
+			// The user speaking over the reply cancels it
+			if s.interrupt() {
+				s.sendState(voiceStateListening)
+			}
+			if s.audio.Len()+len(data) > voiceMaxUtterance {
+				s.audio.Reset()
+				s.sendError(errors.New("utterance too long"))
+				continue
+			}
+			s.audio.Write(data)
+			continue
+		}
+
+		var msg voiceClientMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			s.sendError(errors.New("invalid message"))
+			continue
+		}
+		switch msg.Type {
+		case "start":
+			s.interrupt()
+			s.mu.Lock()
+			if msg.Settings != nil {
+				s.settings = *msg.Settings
+			}
+			if msg.History != nil {
+				s.history = msg.History
+			}
+			s.mu.Unlock()
+			s.sendState(voiceStateListening)
+		case "end_of_utterance":
+			if s.audio.Len() == 0 {
+				s.sendError(errors.New("no audio received"))
+				continue
+			}
+			audio := base64.StdEncoding.EncodeToString(s.audio.Bytes())
+			s.audio.Reset()
+			s.startTurn(func(ctx context.Context) { s.answer(ctx, audio, "") })
+		case "text":
+			if msg.Text == "" {
+				s.sendError(errors.New("text is required"))
+				continue
+			}
+			text := msg.Text
+			s.startTurn(func(ctx context.Context) { s.answer(ctx, "", text) })
+		case "interrupt":
+			s.interrupt()
+			s.audio.Reset()
+			s.sendState(voiceStateListening)
+		case "stop":
+			s.writeMu.Lock()
+			s.conn.SetWriteDeadline(time.Now().Add(voiceWriteTimeout))
+			s.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+			s.writeMu.Unlock()
+			return
+		default:
+			s.sendError(fmt.Errorf("unknown message type %q", msg.Type))
+		}
+	}
+}
+
+// startTurn cancels the running turn, waits for it to stop and runs turn in the background
+func (s *voiceSession) startTurn(turn func(ctx context.Context)) {
+	s.interrupt()
+	s.waitTurn()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	s.turnMu.Lock()
+	s.cancelTurn, s.turnDone = cancel, done
+	s.turnMu.Unlock()
+
+	go func() {
+		defer close(done)
+		defer cancel()
+		turn(ctx)
+	}()
+}
+
+// interrupt cancels the running turn without waiting, reporting whether one was still running.
+// A cancelled turn sends nothing more and leaves the history untouched.
+func (s *voiceSession) interrupt() bool {
+	s.turnMu.Lock()
+	cancel, done := s.cancelTurn, s.turnDone
+	s.turnMu.Unlock()
+	if cancel == nil {
+		return false
+	}
+	select {
+	case <-done:
+		return false
+	default:
+		cancel()
+		return true
+	}
+}
+
+// waitTurn waits for the last turn to return
+func (s *voiceSession) waitTurn() {
+	s.turnMu.Lock()
+	done := s.turnDone
+	s.turnMu.Unlock()
+	if done == nil {
+		return
+	}
+	select {
+	case <-done:
+	case <-time.After(voiceStopWaitLimit):
+		// The cancelled calls should return promptly, whatever they still produce is dropped
+		log.Printf("Voice turn for assessment %d still finishing after interrupt", s.assessmentID)
+	}
+}
+
+// answer runs one turn: recognition, the questionnaire AI and speech synthesis.
+// Every call gets ctx, so barge-in aborts the request in flight and the turn sends nothing more.
+func (s *voiceSession) answer(ctx context.Context, audio, typed string) {
+	s.mu.Lock()
+	settings := s.settings
+	history := append([]services.QuestionMessage(nil), s.history...)
+	s.mu.Unlock()
+
+	s.sendState(voiceStateThinking)
+
+	transcript := typed
+	if audio != "" {
+		var err error
+		transcript, err = services.TranscribeAudio(ctx, audio, settings.LanguageCode)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			s.sendError(err)
+			s.sendState(voiceStateListening)
+			return
+		}
+	}
+	s.send(gin.H{"type": "transcript", "text": transcript})
+	if transcript == "" {
+		// Nothing recognised, let the user try again
+		s.sendState(voiceStateListening)
+		return
+	}
+
+	history = append(history, services.QuestionMessage{User: transcript})
+	aiResponse, err := services.StreamQuestionsToAI(ctx, s.assessmentID, services.QuestionRequest{QuestionHistory: history}, func(token string) {
+		if ctx.Err() == nil {
+			s.send(gin.H{"type": "token", "text": token})
+		}
+	})
+	if ctx.Err() != nil {
+		return
+	}
+	if err != nil {
+		s.sendError(errors.New(questionnaireErrorMessage(err)))
+		s.sendState(voiceStateListening)
+		return
+	}
+
+	reply := aiResponse.Text
+	history[len(history)-1].Assistant = reply
+	s.mu.Lock()
+	if ctx.Err() == nil {
+		s.history = history
+	}
+	s.mu.Unlock()
+	s.send(gin.H{"type": "reply", "data": aiResponse.Data})
+
+	if reply != "" {
+		s.speak(ctx, reply, settings)
+		if ctx.Err() != nil {
+			return
+		}
+	}
+	s.sendState(voiceStateListening)
+}
+
+// speak synthesizes the reply and streams it in chunks until done or interrupted
+func (s *voiceSession) speak(ctx context.Context, text string, settings services.VoiceSettings) {
+	encoded, err := services.SynthesizeSpeech(ctx, text, settings)
+	if ctx.Err() != nil {
+		return
+	}
+	if err != nil {
+		// The text reply was already sent, the client can still show it
+		s.sendError(err)
+		return
+	}
+	audio, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(audio) == 0 {
+		return
+	}
+
+	s.sendState(voiceStateSpeaking)
+	s.send(gin.H{"type": "audio_start", "encoding": "LINEAR16"})
+	for start := 0; start < len(audio); start += voiceAudioChunk {
+		if ctx.Err() != nil {
+			s.send(gin.H{"type": "audio_end", "interrupted": true})
+			return
+		}
+		end := min(start+voiceAudioChunk, len(audio))
+		if err := s.write(websocket.BinaryMessage, audio[start:end]); err != nil {
+			return
+		}
+	}
+	s.send(gin.H{"type": "audio_end"})
+}
+
+func (s *voiceSession) keepAlive(stop <-chan struct{}) {
+	ticker := time.NewTicker(voicePingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if err := s.write(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (s *voiceSession) sendState(state string) {
+	s.send(gin.H{"type": "state", "state": state})
+}
+
+func (s *voiceSession) sendError(err error) {
+	s.send(gin.H{"type": "error", "error": err.Error()})
+}
+
+func (s *voiceSession) send(msg gin.H) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		log.Printf("Voice session message not sent: %v", err)
+		return
+	}
+	s.write(websocket.TextMessage, data)
+}
+
+// write serialises writes, the connection allows a single writer at a time
+func (s *voiceSession) write(kind int, data []byte) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	s.conn.SetWriteDeadline(time.Now().Add(voiceWriteTimeout))
+	return s.conn.WriteMessage(kind, data)
+}
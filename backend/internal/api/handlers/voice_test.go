@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"ai-bot-deecogs/internal/clients"
+	"ai-bot-deecogs/internal/db"
+	"ai-bot-deecogs/internal/db/memory"
+	"ai-bot-deecogs/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// voiceTestServer runs the voice handler on an open knee assessment, speech synthesis goes to tts
+func voiceTestServer(t *testing.T, tts http.HandlerFunc) (url string, assessmentID uint32) {
+	t.Helper()
+	speechAPI := httptest.NewServer(tts)
+	t.Cleanup(speechAPI.Close)
+
+	b := memory.New()
+	fake, err := clients.NewFakeAIClient("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := services.Init(services.Deps{
+		Store:  b.Store(),
+		AI:     fake,
+		Speech: clients.NewGoogleSpeechClient(clients.GoogleSpeechConfig{APIKey: "key", STTEndpoint: speechAPI.URL, TTSEndpoint: speechAPI.URL, Timeout: time.Minute}),
+		Auth:   services.AuthConfig{Secret: []byte("test-secret")},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	anatomyID, err := b.AddAnatomy(db.Anatomy{Name: "Knee"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, err := services.CreateUser("Jane", "jane@example.com", "password123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assessment, err := services.CreateAssessment(user.UserID, anatomyID, "PAIN")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/assessments/:assessmentId/voice", VoiceSessionHandler([]string{"https://app.example.com"}))
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	return "ws" + strings.TrimPrefix(server.URL, "http") + "/assessments/", assessment.AssessmentID
+}
+
+func dialVoice(t *testing.T, url string, id uint32) *websocket.Conn {
+	t.Helper()
+	conn, _, err := websocket.DefaultDialer.Dial(url+strconv.FormatUint(uint64(id), 10)+"/voice", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// readUntil reads messages until one of the given type arrives and returns the types seen
+func readUntil(t *testing.T, conn *websocket.Conn, want string) ([]string, map[string]any) {
+	t.Helper()
+	var seen []string
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		kind, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("waiting for %s after %v: %v", want, seen, err)
+		}
+		if kind == websocket.BinaryMessage {
+			seen = append(seen, "audio")
+			continue
+		}
+		var msg map[string]any
+		if err := json.Unmarshal(data, &msg); err != nil {
+			t.Fatal(err)
+		}
+		name := msg["type"].(string)
+		if name == "state" {
+			name += ":" + msg["state"].(string)
+		}
+		seen = append(seen, name)
+		if name == want {
+			return seen, msg
+		}
+	}
+}
+
+func TestVoiceTextTurn(t *testing.T) {
+	url, id := voiceTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"audioContent":"AAAAAA=="}`))
+	})
+	conn := dialVoice(t, url, id)
+	readUntil(t, conn, "state:listening")
+
+	conn.WriteJSON(gin.H{"type": "text", "text": "My knee hurts"})
+	seen, _ := readUntil(t, conn, "state:listening")
+	want := []string{"state:thinking", "transcript", "token", "reply", "state:speaking", "audio_start", "audio", "audio_end", "state:listening"}
+	var compact []string
+	for _, name := range seen {
+		if name == "token" && len(compact) > 0 && compact[len(compact)-1] == "token" {
+			continue
+		}
+		compact = append(compact, name)
+	}
+	if strings.Join(compact, " ") != strings.Join(want, " ") {
+		t.Fatalf("messages = %v, want %v", compact, want)
+	}
+}
+
+func TestVoiceBargeInCancelsSynthesis(t *testing.T) {
+	synthesizing, cancelled := make(chan struct{}), make(chan struct{})
+	url, id := voiceTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		// The server notices the dropped request only once the body is read
+		io.Copy(io.Discard, r.Body)
+		close(synthesizing)
+		select {
+		case <-r.Context().Done():
+			close(cancelled)
+		case <-time.After(10 * time.Second):
+		}
+	})
+	conn := dialVoice(t, url, id)
+	readUntil(t, conn, "state:listening")
+
+	conn.WriteJSON(gin.H{"type": "text", "text": "My knee hurts"})
+	readUntil(t, conn, "reply")
+	select {
+	case <-synthesizing:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the reply was not sent for synthesis")
+	}
+	// The user speaks while the reply is being synthesized
+	conn.WriteMessage(websocket.BinaryMessage, []byte{1, 2, 3})
+	seen, _ := readUntil(t, conn, "state:listening")
+	for _, name := range seen {
+		if name == "audio_start" || name == "error" {
+			t.Fatalf("messages after barge-in = %v, want the turn dropped silently", seen)
+		}
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the synthesis request was not cancelled by the barge-in")
+	}
+}
+
+func TestVoiceRejectsFinishedAssessment(t *testing.T) {
+	url, id := voiceTestServer(t, func(w http.ResponseWriter, r *http.Request) {})
+	if err := services.MarkAssessmentComplete(id); err != nil {
+		t.Fatal(err)
+	}
+	_, resp, err := websocket.DefaultDialer.Dial(url+strconv.FormatUint(uint64(id), 10)+"/voice", nil)
+	if err == nil || resp == nil || resp.StatusCode != http.StatusConflict {
+		t.Fatalf("dial = %v, want 409", resp)
+	}
+}
+
+func TestVoiceChecksOrigin(t *testing.T) {
+	url, id := voiceTestServer(t, func(w http.ResponseWriter, r *http.Request) {})
+	for origin, ok := range map[string]bool{"https://app.example.com": true, "https://evil.example.com": false} {
+		conn, _, err := websocket.DefaultDialer.Dial(url+strconv.FormatUint(uint64(id), 10)+"/voice", http.Header{"Origin": {origin}})
+		if (err == nil) != ok {
+			t.Errorf("origin %s: dial error %v, want allowed %v", origin, err, ok)
+		}
+		if conn != nil {
+			conn.Close()
+		}
+	}
+}
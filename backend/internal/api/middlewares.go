@@ -1 +1,179 @@
-package api
\ No newline at end of file
+package api
+
+import (
+	"ai-bot-deecogs/internal/api/handlers"
+	"ai-bot-deecogs/internal/helpers"
+	"ai-bot-deecogs/internal/models"
+	"ai-bot-deecogs/internal/services"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireAuth validates the bearer access token and loads the caller into the context
+func RequireAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := helpers.BearerToken(c.Request)
+		if token == "" {
+			token = helpers.WebSocketBearerToken(c.Request)
+		}
+		if token == "" {
+			helpers.SendResponse(c.Writer, false, http.StatusUnauthorized, "", errors.New("missing bearer token"))
+			c.Abort()
+			return
+		}
+
+		principal, err := services.AuthenticateToken(token)
+		if err != nil {
+			status := http.StatusUnauthorized
+			if !errors.Is(err, services.ErrInvalidToken) && !errors.Is(err, services.ErrTokenRevoked) {
+				status = http.StatusInternalServerError
+			}
+			helpers.SendResponse(c.Writer, false, status, "", err)
+			c.Abort()
+			return
+		}
+
+		handlers.SetPrincipal(c, principal)
+		c.Next()
+	}
+}
+
+// RequirePermission rejects callers whose role does not grant perm
+func RequirePermission(perm models.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal := handlers.CurrentPrincipal(c)
+		if principal == nil {
+			helpers.SendResponse(c.Writer, false, http.StatusUnauthorized, "", errors.New("missing bearer token"))
+			c.Abort()
+			return
+		}
+		if !principal.Can(perm) {
+			helpers.SendResponse(c.Writer, false, http.StatusForbidden, "", services.ErrForbidden)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireAssessmentAccess rejects callers without the given access to the :assessmentId in the path
+func RequireAssessmentAccess(level services.AccessLevel) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal := handlers.CurrentPrincipal(c)
+		if principal == nil {
+			helpers.SendResponse(c.Writer, false, http.StatusUnauthorized, "", errors.New("missing bearer token"))
+			c.Abort()
+			return
+		}
+
+		assessmentID, err := helpers.StringToUInt32(c.Param("assessmentId"))
+		if err != nil {
+			helpers.SendResponse(c.Writer, false, http.StatusBadRequest, "", errors.New("invalid assessment ID"))
+			c.Abort()
+			return
+		}
+
+		if err := services.AuthorizeAssessment(principal, assessmentID, level); err != nil {
+			switch {
+			case errors.Is(err, services.ErrForbidden):
+				helpers.SendResponse(c.Writer, false, http.StatusForbidden, "", err)
+			case errors.Is(err, services.ErrAssessmentNotFound):
+				helpers.SendResponse(c.Writer, false, http.StatusNotFound, "", err)
+			default:
+				helpers.SendResponse(c.Writer, false, http.StatusInternalServerError, "", err)
+			}
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequirePhysioCallAccess rejects callers without the given access to the :callId in the path
+func RequirePhysioCallAccess(level services.AccessLevel) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal := handlers.CurrentPrincipal(c)
+		if principal == nil {
+			helpers.SendResponse(c.Writer, false, http.StatusUnauthorized, "", errors.New("missing bearer token"))
+			c.Abort()
+			return
+		}
+
+		callID, err := helpers.StringToUInt32(c.Param("callId"))
+		if err != nil {
+			helpers.SendResponse(c.Writer, false, http.StatusBadRequest, "", errors.New("invalid call ID"))
+			c.Abort()
+			return
+		}
+
+		if err := services.AuthorizePhysioCall(principal, callID, level); err != nil {
+			switch {
+			case errors.Is(err, services.ErrForbidden):
+				helpers.SendResponse(c.Writer, false, http.StatusForbidden, "", err)
+			case errors.Is(err, services.ErrCallNotFound):
+				helpers.SendResponse(c.Writer, false, http.StatusNotFound, "", err)
+			default:
+				helpers.SendResponse(c.Writer, false, http.StatusInternalServerError, "", err)
+			}
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireUserAccess limits routes on the :id user to that user and to admins
+func RequireUserAccess() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal := handlers.CurrentPrincipal(c)
+		if principal == nil {
+			helpers.SendResponse(c.Writer, false, http.StatusUnauthorized, "", errors.New("missing bearer token"))
+			c.Abort()
+			return
+		}
+
+		userID, err := helpers.StringToUInt32(c.Param("id"))
+		if err != nil {
+			helpers.SendResponse(c.Writer, false, http.StatusBadRequest, "", errors.New("invalid user ID"))
+			c.Abort()
+			return
+		}
+		if userID != principal.UserID && !principal.Can(models.PermUserManage) {
+			helpers.SendResponse(c.Writer, false, http.StatusForbidden, "", services.ErrForbidden)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequirePhysioAccess limits routes on the :id physiotherapist to that physiotherapist and
+// to admins who assign physiotherapists
+func RequirePhysioAccess() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal := handlers.CurrentPrincipal(c)
+		if principal == nil {
+			helpers.SendResponse(c.Writer, false, http.StatusUnauthorized, "", errors.New("missing bearer token"))
+			c.Abort()
+			return
+		}
+
+		physioID, err := helpers.StringToUInt32(c.Param("id"))
+		if err != nil {
+			helpers.SendResponse(c.Writer, false, http.StatusBadRequest, "", errors.New("invalid user ID"))
+			c.Abort()
+			return
+		}
+		self := physioID == principal.UserID && principal.Can(models.PermPhysioCallManage)
+		if !self && !principal.Can(models.PermAssessmentAssign) {
+			helpers.SendResponse(c.Writer, false, http.StatusForbidden, "", services.ErrForbidden)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
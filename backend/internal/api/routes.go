@@ -3,6 +3,8 @@ package api
 
 import (
 	"ai-bot-deecogs/internal/api/handlers"
+	"ai-bot-deecogs/internal/models"
+	"ai-bot-deecogs/internal/services"
 	"bytes"
 	"io"
 
@@ -21,31 +23,113 @@ func StoreRequestBody() gin.HandlerFunc {
 	}
 }
 
+// RouteConfig holds the settings the handlers are built with
+type RouteConfig struct {
+	VoiceOrigins []string // browser origins allowed to open voice sessions
+}
+
 // SetupRoutes initializes API routes
-func SetupRoutes(router *gin.Engine) {
+func SetupRoutes(router *gin.Engine, cfg RouteConfig) {
 	// Add the middleware for questionnaire endpoint
 	router.Use(StoreRequestBody())
 
-	// User routes
+	// User routes, sign up is public and the rest is limited to the user and admins
 	router.POST("/users", handlers.CreateUser)
-	router.GET("/users/:id", handlers.GetUser)
+	user := router.Group("/users/:id", RequireAuth(), RequireUserAccess())
+	user.GET("", handlers.GetUser)
+	user.PATCH("", handlers.UpdateUser)
+	user.PUT("/password", handlers.ChangePassword)
+	user.DELETE("", handlers.DeleteUser)
+	user.GET("/progress", handlers.GetUserProgress)
+	user.GET("/progress/:anatomyId", handlers.GetUserAnatomyProgress)
+	user.GET("/physio-calls", handlers.ListUserPhysioCalls)
+	user.POST("/calendar-tokens", handlers.CreateCalendarToken)
+	user.GET("/calendar-tokens", handlers.ListCalendarTokens)
+	user.DELETE("/calendar-tokens/:tokenId", handlers.RevokeCalendarToken)
 
 	// Authentication routes
 	router.POST("/auth/loginuser", handlers.LoginUser)
+	router.POST("/auth/refresh", handlers.RefreshToken)
+	router.POST("/auth/logout", handlers.Logout)
+
+	// Anatomy catalogue and normative ROM ranges, readable by any signed in user
+	router.GET("/anatomy", RequireAuth(), handlers.ListAnatomy)
+	router.GET("/rom-norms", RequireAuth(), handlers.ListROMNorms)
 
-	// Assessment routes
-	router.POST("/assessments", handlers.CreateAssessment)
-	router.GET("/assessments/:assessmentId", handlers.GetAssessment)
-	router.POST("/assessments/:assessmentId/chat", handlers.SendChatToAIHandler)
-	router.POST("/assessments/:assessmentId/status", handlers.UpdateAssessmentStatus)
-	router.POST("/assessments/:assessmentId/questionnaires", handlers.SendQuestionsToAIHandler)
-	router.GET("/assessments/:assessmentId/questionnaires", handlers.GetQuestionnaires)
+	// Assessment routes, all require a bearer token
+	assessments := router.Group("/assessments", RequireAuth())
+	assessments.POST("", RequirePermission(models.PermAssessmentCreate), handlers.CreateAssessment)
+
+	// Routes on a single assessment: reads are open to the owner, the assigned
+	// physiotherapist and admins, writes only to the owner and admins
+	read := RequireAssessmentAccess(services.AccessRead)
+	write := RequireAssessmentAccess(services.AccessWrite)
+	assessment := assessments.Group("/:assessmentId")
+	assessment.GET("", read, handlers.GetAssessment)
+	assessment.POST("/chat", write, handlers.SendChatToAIHandler)
+	assessment.GET("/chat", read, handlers.GetChatHistory)
+	assessment.POST("/chat/stream", write, handlers.StreamChatHandler)
+	assessment.POST("/status", write, handlers.UpdateAssessmentStatus)
+	assessment.GET("/timeline", read, handlers.GetAssessmentTimeline)
+	assessment.POST("/questionnaires", write, handlers.SendQuestionsToAIHandler)
+	assessment.POST("/questionnaires/stream", write, handlers.StreamQuestionsHandler)
+	assessment.GET("/voice", write, handlers.VoiceSessionHandler(cfg.VoiceOrigins))
+	assessment.GET("/questionnaires", read, handlers.GetQuestionnaires)
 
 	// ROM Analysis routes
-	router.POST("/assessments/:assessmentId/rom", handlers.SubmitROMAnalysis)
-	router.GET("/assessments/:assessmentId/rom", handlers.GetROMAnalysisByAssessmentId)
-	router.GET("/assessments/:assessmentId/dashboard", handlers.GetDashboardData)
-	router.GET("/assessments/:assessmentId/dashboardByAssessmentId", handlers.GetDashboardDataByAssessmentId)
+	assessment.POST("/rom", write, handlers.SubmitROMAnalysis)
+	assessment.GET("/rom", read, handlers.GetROMAnalysisByAssessmentId)
+	assessment.POST("/rom/landmarks", write, handlers.SubmitLandmarkROM)
+	// The dashboard analysis runs as a background job and completes the assessment
+	assessment.POST("/analysis", write, handlers.RequestAnalysis)
+	assessment.GET("/analysis", read, handlers.GetAnalysis)
+	assessment.GET("/dashboard", write, handlers.GetDashboardData) // deprecated, waits for the job
+	assessment.GET("/dashboardByAssessmentId", read, handlers.GetDashboardDataByAssessmentId)
+	assessment.POST("/physio-calls", write, handlers.SchedulePhysioCall)
+	assessment.GET("/physio-calls", read, handlers.GetPhysioCalls)
+	assessment.POST("/physio-calls/book", write, handlers.BookPhysioSlot)
+
+	// Routes on a single physio call: the patient and the physiotherapist of the call
+	// run it, the physiotherapist of the assessment can read it
+	calls := router.Group("/physio-calls/:callId", RequireAuth())
+	calls.GET("", RequirePhysioCallAccess(services.AccessRead), handlers.GetPhysioCall)
+	calls.POST("/status", RequirePhysioCallAccess(services.AccessWrite), handlers.UpdatePhysioCallStatus)
+	calls.PUT("/schedule", RequirePhysioCallAccess(services.AccessWrite), handlers.ReschedulePhysioCall)
+	calls.GET("/ics", RequirePhysioCallAccess(services.AccessRead), handlers.GetPhysioCallICS)
+
+	// Calendar feeds authenticate with the secret token in the path
+	router.GET("/calendar-feeds/:token", handlers.GetCalendarFeed)
+
+	// Availability of a physiotherapist: any signed in user sees the calendar and free
+	// slots, the physiotherapist and admins change it
+	physios := router.Group("/physios/:id", RequireAuth())
+	physios.GET("/availability", handlers.GetPhysioAvailability)
+	physios.GET("/slots", handlers.ListPhysioSlots)
+	physios.PUT("/availability", RequirePhysioAccess(), handlers.SetPhysioAvailability)
+	physios.GET("/availability/exceptions", RequirePhysioAccess(), handlers.ListAvailabilityExceptions)
+	physios.POST("/availability/exceptions", RequirePhysioAccess(), handlers.AddAvailabilityException)
+	physios.DELETE("/availability/exceptions/:exceptionId", RequirePhysioAccess(), handlers.DeleteAvailabilityException)
+
+	// Physiotherapist routes
+	physio := router.Group("/physio", RequireAuth(), RequirePermission(models.PermAssessmentReadAssigned))
+	physio.GET("/assessments", handlers.ListAssignedAssessments)
+	physio.GET("/calls", handlers.ListPhysioCallsOfPhysio)
+
+	// Admin routes
+	admin := router.Group("/admin", RequireAuth())
+	admin.GET("/users", RequirePermission(models.PermUserManage), handlers.ListUsers)
+	admin.PATCH("/users/:id/role", RequirePermission(models.PermUserManage), handlers.UpdateUserRole)
+	admin.POST("/anatomy", RequirePermission(models.PermAnatomyManage), handlers.CreateAnatomy)
+	admin.PUT("/anatomy/:id", RequirePermission(models.PermAnatomyManage), handlers.UpdateAnatomy)
+	admin.DELETE("/anatomy/:id", RequirePermission(models.PermAnatomyManage), handlers.DeleteAnatomy)
+	admin.PUT("/rom-norms", RequirePermission(models.PermAnatomyManage), handlers.UpsertROMNorms)
+	admin.DELETE("/rom-norms/:id", RequirePermission(models.PermAnatomyManage), handlers.DeleteROMNorm)
+	admin.POST("/assessments/:assessmentId/physio", RequirePermission(models.PermAssessmentAssign), handlers.AssignPhysio)
+	admin.PUT("/physio-calls/:callId/physio", RequirePermission(models.PermAssessmentAssign), handlers.AssignCallPhysio)
+	admin.GET("/physios/:id/calls", RequirePermission(models.PermAssessmentAssign), handlers.ListPhysioCallsOfPhysio)
+	admin.GET("/sweeper", RequirePermission(models.PermSystemMonitor), handlers.GetSweeperStatus)
+	admin.GET("/notifications", RequirePermission(models.PermSystemMonitor), handlers.ListNotifications)
+	admin.POST("/notifications/:notificationId/retry", RequirePermission(models.PermSystemMonitor), handlers.RetryNotification)
 
 	// Google Speech API routes
 	router.POST("/api/speech-to-text", handlers.SpeechToText)
@@ -1 +1,171 @@
-package clients
\ No newline at end of file
+package clients
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+// AIClient is the contract of the AI services used by the triage flow
+type AIClient interface {
+	// Chat sends the body part identification conversation to the BPI bot
+	Chat(ctx context.Context, history []ChatMessage) (*AIResponse, error)
+	// IdentifyBodyPartFromVideo sends the conversation and a base64 video to the BPI bot
+	IdentifyBodyPartFromVideo(ctx context.Context, history []ChatMessage, video string) (*AIResponse, error)
+	// NextQuestion sends the questionnaire conversation to the XAI bot
+	NextQuestion(ctx context.Context, history []QuestionMessage, video string) (*AIResponse, error)
+	// AnalyseDashboard sends the collected assessment data to the dashboard function
+	AnalyseDashboard(ctx context.Context, content json.RawMessage) (*AIResponse, error)
+	// ChatStream is Chat relaying the reply text to onToken as it is generated
+	ChatStream(ctx context.Context, history []ChatMessage, onToken TokenFunc) (*AIResponse, error)
+	// NextQuestionStream is NextQuestion relaying the reply text to onToken as it is generated
+	NextQuestionStream(ctx context.Context, history []QuestionMessage, video string, onToken TokenFunc) (*AIResponse, error)
+}
+
+// TokenFunc receives a partial reply text while a streamed response arrives
+type TokenFunc func(token string)
+
+// ChatMessage is one turn of the body part identification chat
+type ChatMessage struct {
+	User     string `json:"user"`
+	Response string `json:"response,omitempty"`
+}
+
+// QuestionMessage is one turn of the questionnaire
+type QuestionMessage struct {
+	User      string `json:"user"`
+	Assistant string `json:"assistant"`
+}
+
+// AIResponse is the envelope every AI service answers with
+type AIResponse struct {
+	Success    bool            `json:"success"`
+	StatusCode int             `json:"statusCode"`
+	Data       json.RawMessage `json:"data"`
+}
+
+// AIEndpoints holds the URLs of the hosted AI services and the timeout of each call
+type AIEndpoints struct {
+	ChatURL              string
+	QuestionnaireURL     string
+	DashboardURL         string
+	ChatTimeout          time.Duration
+	VideoTimeout         time.Duration
+	QuestionnaireTimeout time.Duration
+	DashboardTimeout     time.Duration
+}
+
+// Outbound endpoint names, each has its own timeout and circuit breaker
+const (
+	EndpointChat          = "chat"
+	EndpointVideo         = "video"
+	EndpointQuestionnaire = "questionnaire"
+	EndpointDashboard     = "dashboard"
+)
+
+// HTTPAIClient calls the hosted AI services over HTTP
+type HTTPAIClient struct {
+	endpoints AIEndpoints
+	outbound  *Outbound
+	policies  map[string]EndpointPolicy
+}
+
+// NewHTTPAIClient builds a client for the given endpoints sending through outbound
+func NewHTTPAIClient(endpoints AIEndpoints, outbound *Outbound) *HTTPAIClient {
+	// The bots keep no state between calls, so every call is safe to repeat
+	return &HTTPAIClient{
+		endpoints: endpoints,
+		outbound:  outbound,
+		policies: map[string]EndpointPolicy{
+			EndpointChat:          {Timeout: endpoints.ChatTimeout, Idempotent: true},
+			EndpointVideo:         {Timeout: endpoints.VideoTimeout, Idempotent: true},
+			EndpointQuestionnaire: {Timeout: endpoints.QuestionnaireTimeout, Idempotent: true},
+			EndpointDashboard:     {Timeout: endpoints.DashboardTimeout, Idempotent: true},
+		},
+	}
+}
+
+func (c *HTTPAIClient) Chat(ctx context.Context, history []ChatMessage) (*AIResponse, error) {
+	payload := struct {
+		ChatHistory []ChatMessage `json:"chat_history"`
+	}{ChatHistory: history}
+	return c.post(ctx, EndpointChat, c.endpoints.ChatURL, payload)
+}
+
+func (c *HTTPAIClient) IdentifyBodyPartFromVideo(ctx context.Context, history []ChatMessage, video string) (*AIResponse, error) {
+	payload := struct {
+		ChatHistory []ChatMessage `json:"chat_history"`
+		Video       string        `json:"video"`
+	}{ChatHistory: history, Video: video}
+	return c.post(ctx, EndpointVideo, c.endpoints.ChatURL, payload)
+}
+
+func (c *HTTPAIClient) NextQuestion(ctx context.Context, history []QuestionMessage, video string) (*AIResponse, error) {
+	payload := struct {
+		ChatHistory []QuestionMessage `json:"chat_history"`
+		Video       string            `json:"video,omitempty"`
+	}{ChatHistory: history, Video: video}
+	return c.post(ctx, EndpointQuestionnaire, c.endpoints.QuestionnaireURL, payload)
+}
+
+func (c *HTTPAIClient) AnalyseDashboard(ctx context.Context, content json.RawMessage) (*AIResponse, error) {
+	payload := struct {
+		Content json.RawMessage `json:"content"`
+	}{Content: content}
+	return c.post(ctx, EndpointDashboard, c.endpoints.DashboardURL, payload)
+}
+
+// post sends a JSON payload and decodes the response envelope
+func (c *HTTPAIClient) post(ctx context.Context, endpoint, url string, payload any) (*AIResponse, error) {
+	resp, err := c.send(ctx, endpoint, url, payload, "application/json")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return decodeAIResponse(resp.Body)
+}
+
+// send posts a JSON payload through the outbound layer, any status but 200 is returned as an error
+func (c *HTTPAIClient) send(ctx context.Context, endpoint, url string, payload any, accept string) (*http.Response, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal AI request: %w", err)
+	}
+
+	log.Printf("Calling AI %s, payload size: %d bytes", url, len(body))
+	resp, err := c.outbound.Do(ctx, endpoint, c.policies[endpoint], func(ctx context.Context) (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create AI request: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", accept)
+		return req, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("AI Response Status: %v, Content-Type: %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	return resp, nil
+}
+
+// decodeAIResponse reads a whole JSON response envelope
+func decodeAIResponse(body io.Reader) (*AIResponse, error) {
+	raw, err := io.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read AI response: %w", err)
+	}
+	log.Printf("AI Response Body Size: %d bytes", len(raw))
+
+	var aiResponse AIResponse
+	if err := json.Unmarshal(raw, &aiResponse); err != nil {
+		log.Printf("Raw AI response: %s", string(raw))
+		return nil, fmt.Errorf("failed to decode AI response: %w", err)
+	}
+	return &aiResponse, nil
+}
@@ -0,0 +1,111 @@
+package clients
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+//go:embed fixtures/ai_fake.json
+var defaultAIFixtures []byte
+
+// AIFixtures is the script replayed by FakeAIClient. Chat and Questionnaire hold one
+// reply per user turn, the last reply is repeated once the script runs out.
+type AIFixtures struct {
+	Chat          []json.RawMessage `json:"chat"`
+	Video         json.RawMessage   `json:"video"`
+	Questionnaire []json.RawMessage `json:"questionnaire"`
+	Dashboard     json.RawMessage   `json:"dashboard"`
+}
+
+// FakeAIClient answers from a fixtures file without any network access. The reply
+// only depends on the length of the history sent, so a given conversation always
+// gets the same answers and concurrent assessments do not interfere.
+type FakeAIClient struct {
+	fixtures AIFixtures
+}
+
+// NewFakeAIClient loads the fixtures at path, or the bundled script when path is empty
+func NewFakeAIClient(path string) (*FakeAIClient, error) {
+	data := defaultAIFixtures
+	if path != "" {
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			return nil, fmt.Errorf("failed to read AI fixtures: %w", err)
+		}
+	}
+
+	var fixtures AIFixtures
+	if err := json.Unmarshal(data, &fixtures); err != nil {
+		return nil, fmt.Errorf("failed to parse AI fixtures: %w", err)
+	}
+	if len(fixtures.Chat) == 0 || len(fixtures.Questionnaire) == 0 || fixtures.Video == nil || fixtures.Dashboard == nil {
+		return nil, errors.New("AI fixtures must define chat, video, questionnaire and dashboard replies")
+	}
+	return &FakeAIClient{fixtures: fixtures}, nil
+}
+
+func (c *FakeAIClient) Chat(ctx context.Context, history []ChatMessage) (*AIResponse, error) {
+	return reply(ctx, step(c.fixtures.Chat, len(history)))
+}
+
+func (c *FakeAIClient) IdentifyBodyPartFromVideo(ctx context.Context, history []ChatMessage, video string) (*AIResponse, error) {
+	return reply(ctx, c.fixtures.Video)
+}
+
+func (c *FakeAIClient) NextQuestion(ctx context.Context, history []QuestionMessage, video string) (*AIResponse, error) {
+	return reply(ctx, step(c.fixtures.Questionnaire, len(history)))
+}
+
+func (c *FakeAIClient) AnalyseDashboard(ctx context.Context, content json.RawMessage) (*AIResponse, error) {
+	return reply(ctx, c.fixtures.Dashboard)
+}
+
+func (c *FakeAIClient) ChatStream(ctx context.Context, history []ChatMessage, onToken TokenFunc) (*AIResponse, error) {
+	return replyStream(ctx, step(c.fixtures.Chat, len(history)), onToken)
+}
+
+func (c *FakeAIClient) NextQuestionStream(ctx context.Context, history []QuestionMessage, video string, onToken TokenFunc) (*AIResponse, error) {
+	return replyStream(ctx, step(c.fixtures.Questionnaire, len(history)), onToken)
+}
+
+// step picks the scripted reply for the n-th turn, counting from 1
+func step(script []json.RawMessage, turns int) json.RawMessage {
+	i := turns - 1
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(script) {
+		i = len(script) - 1
+	}
+	return script[i]
+}
+
+func reply(ctx context.Context, data json.RawMessage) (*AIResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return &AIResponse{
+		Success:    true,
+		StatusCode: http.StatusOK,
+		Data:       append(json.RawMessage(nil), data...),
+	}, nil
+}
+
+// replyStream relays the scripted reply text word by word before returning it
+func replyStream(ctx context.Context, data json.RawMessage, onToken TokenFunc) (*AIResponse, error) {
+	for _, token := range strings.SplitAfter(ReplyText(data), " ") {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if token != "" {
+			onToken(token)
+		}
+	}
+	return reply(ctx, data)
+}
@@ -0,0 +1,129 @@
+package clients
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"strings"
+)
+
+// maxStreamEvent bounds a single server-sent event from the AI services
+const maxStreamEvent = 1 << 20
+
+// streamEvent is the data of one event of a streamed AI response. Partial replies
+// carry a token, the last event carries the usual response envelope.
+type streamEvent struct {
+	Token *string `json:"token"`
+	AIResponse
+}
+
+func (c *HTTPAIClient) ChatStream(ctx context.Context, history []ChatMessage, onToken TokenFunc) (*AIResponse, error) {
+	payload := struct {
+		ChatHistory []ChatMessage `json:"chat_history"`
+		Stream      bool          `json:"stream"`
+	}{ChatHistory: history, Stream: true}
+	return c.postStream(ctx, EndpointChat, c.endpoints.ChatURL, payload, onToken)
+}
+
+func (c *HTTPAIClient) NextQuestionStream(ctx context.Context, history []QuestionMessage, video string, onToken TokenFunc) (*AIResponse, error) {
+	payload := struct {
+		ChatHistory []QuestionMessage `json:"chat_history"`
+		Video       string            `json:"video,omitempty"`
+		Stream      bool              `json:"stream"`
+	}{ChatHistory: history, Video: video, Stream: true}
+	return c.postStream(ctx, EndpointQuestionnaire, c.endpoints.QuestionnaireURL, payload, onToken)
+}
+
+// postStream asks for a text/event-stream response and relays its tokens. A service
+// answering with plain JSON is relayed as a single token, so callers need no fallback.
+func (c *HTTPAIClient) postStream(ctx context.Context, endpoint, url string, payload any, onToken TokenFunc) (*AIResponse, error) {
+	resp, err := c.send(ctx, endpoint, url, payload, "text/event-stream, application/json")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType != "text/event-stream" {
+		aiResponse, err := decodeAIResponse(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		if text := ReplyText(aiResponse.Data); text != "" {
+			onToken(text)
+		}
+		return aiResponse, nil
+	}
+	return readEventStream(resp.Body, onToken)
+}
+
+// readEventStream parses server-sent events until the final envelope
+func readEventStream(body io.Reader, onToken TokenFunc) (*AIResponse, error) {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxStreamEvent)
+
+	var data bytes.Buffer
+	var final *AIResponse
+	dispatch := func() error {
+		defer data.Reset()
+		if data.Len() == 0 || data.String() == "[DONE]" {
+			return nil
+		}
+		var event streamEvent
+		if err := json.Unmarshal(data.Bytes(), &event); err != nil {
+			return fmt.Errorf("failed to decode AI stream event: %w", err)
+		}
+		if event.Token != nil {
+			onToken(*event.Token)
+			return nil
+		}
+		final = &event.AIResponse
+		return nil
+	}
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if err := dispatch(); err != nil {
+				return nil, err
+			}
+		case strings.HasPrefix(line, "data:"):
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+		// event:, id:, retry: and comments carry nothing the client needs
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read AI stream: %w", err)
+	}
+	if err := dispatch(); err != nil {
+		return nil, err
+	}
+	if final == nil {
+		return nil, errors.New("AI stream ended without a response")
+	}
+	return final, nil
+}
+
+// ReplyText returns the text the bots answer with, data.response for the chat
+// and data.question for the questionnaire
+func ReplyText(data json.RawMessage) string {
+	var fields struct {
+		Response string `json:"response"`
+		Question string `json:"question"`
+	}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return ""
+	}
+	if fields.Response != "" {
+		return fields.Response
+	}
+	return fields.Question
+}
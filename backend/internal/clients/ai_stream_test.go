@@ -0,0 +1,110 @@
+package clients
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadEventStream(t *testing.T) {
+	final := `{"success":true,"statusCode":200,"data":{"response":"Hello there","action":"continue"}}`
+	tests := []struct {
+		name       string
+		stream     string
+		wantTokens []string
+		wantErr    bool
+	}{
+		{
+			name:       "tokens then the envelope",
+			stream:     "data: {\"token\":\"Hello \"}\n\ndata: {\"token\":\"there\"}\n\ndata: " + final + "\n\n",
+			wantTokens: []string{"Hello ", "there"},
+		},
+		{
+			name:       "event names, comments and a done marker",
+			stream:     ": keep-alive\nevent: token\ndata: {\"token\":\"Hi\"}\n\nevent: done\ndata: " + final + "\n\ndata: [DONE]\n\n",
+			wantTokens: []string{"Hi"},
+		},
+		{
+			name:   "envelope split over data lines, no trailing blank line",
+			stream: "data: {\"success\":true,\ndata: \"statusCode\":200,\"data\":{\"response\":\"Hello there\"}}",
+		},
+		{
+			name:       "empty token",
+			stream:     "data: {\"token\":\"\"}\n\ndata: " + final + "\n\n",
+			wantTokens: []string{""},
+		},
+		{name: "no envelope", stream: "data: {\"token\":\"Hi\"}\n\n", wantTokens: []string{"Hi"}, wantErr: true},
+		{name: "broken event", stream: "data: {\"token\":\n\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var tokens []string
+			resp, err := readEventStream(strings.NewReader(tt.stream), func(token string) { tokens = append(tokens, token) })
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("readEventStream = %+v, want an error", resp)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Join(tokens, "|") != strings.Join(tt.wantTokens, "|") {
+				t.Errorf("tokens = %q, want %q", tokens, tt.wantTokens)
+			}
+			if !resp.Success || ReplyText(resp.Data) != "Hello there" {
+				t.Errorf("response = %+v, want the final envelope", resp)
+			}
+		})
+	}
+}
+
+func TestChatStreamFallsBackToJSON(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		wantTokens  []string
+	}{
+		{
+			name:        "event stream",
+			contentType: "text/event-stream; charset=utf-8",
+			body:        "data: {\"token\":\"Where \"}\n\ndata: {\"token\":\"does it hurt?\"}\n\ndata: {\"success\":true,\"statusCode\":200,\"data\":{\"response\":\"Where does it hurt?\",\"action\":\"continue\"}}\n\n",
+			wantTokens:  []string{"Where ", "does it hurt?"},
+		},
+		{
+			name:        "service without streaming",
+			contentType: "application/json",
+			body:        `{"success":true,"statusCode":200,"data":{"response":"Where does it hurt?","action":"continue"}}`,
+			wantTokens:  []string{"Where does it hurt?"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if accept := r.Header.Get("Accept"); !strings.Contains(accept, "text/event-stream") {
+					t.Errorf("Accept = %q, want an event stream", accept)
+				}
+				w.Header().Set("Content-Type", tt.contentType)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			client := NewHTTPAIClient(AIEndpoints{ChatURL: server.URL, ChatTimeout: time.Second}, NewOutbound(OutboundConfig{BreakerFailures: 5, BreakerOpenFor: time.Minute}))
+			var tokens []string
+			resp, err := client.ChatStream(context.Background(), []ChatMessage{{User: "Hi"}}, func(token string) { tokens = append(tokens, token) })
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Join(tokens, "|") != strings.Join(tt.wantTokens, "|") {
+				t.Errorf("tokens = %q, want %q", tokens, tt.wantTokens)
+			}
+			if ReplyText(resp.Data) != "Where does it hurt?" {
+				t.Errorf("response = %s, want the reply", resp.Data)
+			}
+		})
+	}
+}
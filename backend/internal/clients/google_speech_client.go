@@ -3,15 +3,15 @@ package clients
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
-	"os"
+	"time"
 )
 
-var googleSpeechClient *GoogleSpeechClient
-
 type GoogleSpeechClient struct {
 	apiKey      string
 	projectID   string
@@ -20,32 +20,31 @@ type GoogleSpeechClient struct {
 	httpClient  *http.Client
 }
 
-// GetGoogleSpeechClient returns a singleton instance of GoogleSpeechClient
-func GetGoogleSpeechClient() *GoogleSpeechClient {
-	if googleSpeechClient == nil {
-		apiKey := os.Getenv("GOOGLE_CLOUD_API_KEY")
-		if apiKey == "" {
-			fmt.Printf("Warning: GOOGLE_CLOUD_API_KEY not set\n")
-		}
-
-		projectID := os.Getenv("GOOGLE_CLOUD_PROJECT_ID")
-		if projectID == "" {
-			projectID = "dochq-staging"
-		}
+// GoogleSpeechConfig holds the settings of the Google speech APIs
+type GoogleSpeechConfig struct {
+	APIKey      string
+	ProjectID   string
+	STTEndpoint string
+	TTSEndpoint string
+	Timeout     time.Duration
+}
 
-		googleSpeechClient = &GoogleSpeechClient{
-			apiKey:      apiKey,
-			projectID:   projectID,
-			sttEndpoint: "https://speech.googleapis.com/v1/speech:recognize",
-			ttsEndpoint: "https://texttospeech.googleapis.com/v1/text:synthesize",
-			httpClient:  &http.Client{},
-		}
+// NewGoogleSpeechClient builds a client for the given settings
+func NewGoogleSpeechClient(cfg GoogleSpeechConfig) *GoogleSpeechClient {
+	if cfg.APIKey == "" {
+		log.Println("Warning: GOOGLE_CLOUD_API_KEY not set, speech endpoints are disabled")
+	}
+	return &GoogleSpeechClient{
+		apiKey:      cfg.APIKey,
+		projectID:   cfg.ProjectID,
+		sttEndpoint: cfg.STTEndpoint,
+		ttsEndpoint: cfg.TTSEndpoint,
+		httpClient:  &http.Client{Timeout: cfg.Timeout},
 	}
-	return googleSpeechClient
 }
 
 // SpeechToText converts audio to text using Google Speech-to-Text API
-func (c *GoogleSpeechClient) SpeechToText(request interface{}) (map[string]interface{}, error) {
+func (c *GoogleSpeechClient) SpeechToText(ctx context.Context, request interface{}) (map[string]interface{}, error) {
 	if c.apiKey == "" {
 		return nil, fmt.Errorf("GOOGLE_CLOUD_API_KEY not set")
 	}
@@ -58,7 +57,7 @@ func (c *GoogleSpeechClient) SpeechToText(request interface{}) (map[string]inter
 
 	// Create HTTP request with API key
 	url := fmt.Sprintf("%s?key=%s", c.sttEndpoint, c.apiKey)
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -99,7 +98,7 @@ func (c *GoogleSpeechClient) SpeechToText(request interface{}) (map[string]inter
 }
 
 // TextToSpeech converts text to audio using Google Text-to-Speech API
-func (c *GoogleSpeechClient) TextToSpeech(request interface{}) (map[string]interface{}, error) {
+func (c *GoogleSpeechClient) TextToSpeech(ctx context.Context, request interface{}) (map[string]interface{}, error) {
 	if c.apiKey == "" {
 		return nil, fmt.Errorf("GOOGLE_CLOUD_API_KEY not set")
 	}
@@ -112,7 +111,7 @@ func (c *GoogleSpeechClient) TextToSpeech(request interface{}) (map[string]inter
 
 	// Create HTTP request with API key
 	url := fmt.Sprintf("%s?key=%s", c.ttsEndpoint, c.apiKey)
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
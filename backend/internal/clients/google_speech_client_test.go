@@ -0,0 +1,49 @@
+package clients
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestGoogleSpeechCancelledCallReturns(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	client := NewGoogleSpeechClient(GoogleSpeechConfig{
+		APIKey:      "key",
+		STTEndpoint: server.URL,
+		TTSEndpoint: server.URL,
+		Timeout:     time.Minute,
+	})
+	calls := map[string]func(ctx context.Context) error{
+		"SpeechToText": func(ctx context.Context) error { _, err := client.SpeechToText(ctx, map[string]string{}); return err },
+		"TextToSpeech": func(ctx context.Context) error { _, err := client.TextToSpeech(ctx, map[string]string{}); return err },
+	}
+	for name, call := range calls {
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error, 1)
+		go func() { done <- call(ctx) }()
+		// Barge-in while the API is still working
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+		select {
+		case err := <-done:
+			if !errors.Is(err, context.Canceled) {
+				t.Errorf("%s error = %v, want context.Canceled", name, err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s still running after the turn was cancelled", name)
+		}
+	}
+}
@@ -0,0 +1,264 @@
+package clients
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Notification channels, one Notifier each
+const (
+	ChannelEmail   = "email"
+	ChannelWebhook = "webhook"
+	ChannelLog     = "log"
+)
+
+// Message is a rendered notification ready to be delivered
+type Message struct {
+	ID           uint32    `json:"id"`
+	Kind         string    `json:"kind"`
+	DedupeKey    string    `json:"dedupe_key"`
+	UserID       uint32    `json:"user_id"`
+	Address      string    `json:"email,omitempty"` // email address of the recipient
+	AssessmentID *uint32   `json:"assessment_id,omitempty"`
+	CallID       *uint32   `json:"call_id,omitempty"`
+	Subject      string    `json:"subject"`
+	Body         string    `json:"body"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// Notifier delivers messages over one channel
+type Notifier interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// PermanentError is a delivery failure that retrying will not fix
+type PermanentError struct {
+	Cause error
+}
+
+func (e *PermanentError) Error() string { return e.Cause.Error() }
+func (e *PermanentError) Unwrap() error { return e.Cause }
+
+// SMTPConfig holds the settings of the email channel
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string // no authentication when empty
+	Password string
+	From     string        // an address, optionally with a display name
+	Timeout  time.Duration // per message, from dial to QUIT
+}
+
+// SMTPNotifier sends plain text emails, upgrading to TLS when the server offers STARTTLS
+type SMTPNotifier struct {
+	cfg SMTPConfig
+}
+
+// NewSMTPNotifier builds the email channel
+func NewSMTPNotifier(cfg SMTPConfig) *SMTPNotifier {
+	return &SMTPNotifier{cfg: cfg}
+}
+
+// Send emails the message to its recipient
+func (n *SMTPNotifier) Send(ctx context.Context, msg Message) error {
+	if msg.Address == "" {
+		return &PermanentError{Cause: errors.New("recipient has no email address")}
+	}
+	ctx, cancel := context.WithTimeout(ctx, n.cfg.Timeout)
+	defer cancel()
+
+	addr := net.JoinHostPort(n.cfg.Host, strconv.Itoa(n.cfg.Port))
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return fmt.Errorf("smtp dial %s: %w", addr, err)
+	}
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+
+	c, err := smtp.NewClient(conn, n.cfg.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("smtp %s: %w", addr, err)
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: n.cfg.Host}); err != nil {
+			return fmt.Errorf("smtp starttls: %w", err)
+		}
+	}
+	if n.cfg.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", n.cfg.Username, n.cfg.Password, n.cfg.Host)); err != nil {
+			return &PermanentError{Cause: fmt.Errorf("smtp auth: %w", err)}
+		}
+	}
+	from, err := mail.ParseAddress(n.cfg.From)
+	if err != nil {
+		return &PermanentError{Cause: fmt.Errorf("smtp from %q: %w", n.cfg.From, err)}
+	}
+	if err := c.Mail(from.Address); err != nil {
+		return fmt.Errorf("smtp MAIL FROM: %w", err)
+	}
+	if err := c.Rcpt(msg.Address); err != nil {
+		return smtpError("smtp RCPT TO", err)
+	}
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("smtp DATA: %w", err)
+	}
+	if _, err := w.Write(n.email(msg, from)); err != nil {
+		return fmt.Errorf("smtp DATA: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return smtpError("smtp DATA", err)
+	}
+	return c.Quit()
+}
+
+// email renders the message as an RFC 5322 email with a UTF-8 text body
+func (n *SMTPNotifier) email(msg Message, from *mail.Address) []byte {
+	var b bytes.Buffer
+	header := func(name, value string) {
+		b.WriteString(name + ": " + value + "\r\n")
+	}
+	domain := from.Address[strings.LastIndex(from.Address, "@")+1:]
+	header("From", from.String())
+	header("To", msg.Address)
+	header("Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("Message-ID", "<notification-"+strconv.FormatUint(uint64(msg.ID), 10)+"."+randomHex(8)+"@"+domain+">")
+	header("MIME-Version", "1.0")
+	header("Content-Type", "text/plain; charset=utf-8")
+	header("Content-Transfer-Encoding", "8bit")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(msg.Body, "\r\n", "\n"), "\n", "\r\n"))
+	b.WriteString("\r\n")
+	return b.Bytes()
+}
+
+// smtpError marks 5xx replies, rejected for good by the server, as permanent
+func smtpError(step string, err error) error {
+	var reply *textproto.Error
+	if errors.As(err, &reply) && reply.Code >= 500 {
+		return &PermanentError{Cause: fmt.Errorf("%s: %w", step, err)}
+	}
+	return fmt.Errorf("%s: %w", step, err)
+}
+
+// WebhookConfig holds the settings of the webhook channel
+type WebhookConfig struct {
+	URL     string
+	Secret  string // signs the body in X-Signature when set
+	Timeout time.Duration
+}
+
+// WebhookNotifier posts messages as JSON to an endpoint, which fans them out to SMS,
+// push or chat. The dedupe key is sent as Idempotency-Key so retries can be dropped.
+type WebhookNotifier struct {
+	cfg        WebhookConfig
+	httpClient *http.Client
+}
+
+// NewWebhookNotifier builds the webhook channel
+func NewWebhookNotifier(cfg WebhookConfig) *WebhookNotifier {
+	return &WebhookNotifier{cfg: cfg, httpClient: &http.Client{Timeout: cfg.Timeout}}
+}
+
+// Send posts the message, any 2xx answer is a delivery
+func (n *WebhookNotifier) Send(ctx context.Context, msg Message) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return &PermanentError{Cause: err}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return &PermanentError{Cause: err}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", msg.DedupeKey)
+	if n.cfg.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(n.cfg.Secret))
+		mac.Write(body)
+		req.Header.Set("X-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := n.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return fmt.Errorf("webhook answered %d", resp.StatusCode)
+	}
+	return &PermanentError{Cause: fmt.Errorf("webhook answered %d", resp.StatusCode)}
+}
+
+// LogNotifier writes messages to a file, or to the application log when no file is set.
+// It is meant for development, where no mail server or webhook is at hand.
+type LogNotifier struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewLogNotifier builds the log channel, path is appended to and created if needed
+func NewLogNotifier(path string) *LogNotifier {
+	return &LogNotifier{path: path}
+}
+
+// Send writes the message
+func (n *LogNotifier) Send(ctx context.Context, msg Message) error {
+	to := "user " + strconv.FormatUint(uint64(msg.UserID), 10)
+	if msg.Address != "" {
+		to += " <" + msg.Address + ">"
+	}
+	if n.path == "" {
+		log.Printf("Notification %d (%s) to %s: %s\n%s", msg.ID, msg.Kind, to, msg.Subject, msg.Body)
+		return nil
+	}
+
+	entry := fmt.Sprintf("--- %s notification %d (%s)\nTo: %s\nSubject: %s\n\n%s\n\n",
+		time.Now().UTC().Format(time.RFC3339), msg.ID, msg.Kind, to, msg.Subject, msg.Body)
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	f, err := os.OpenFile(n.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(entry); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
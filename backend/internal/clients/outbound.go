@@ -0,0 +1,308 @@
+package clients
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrAIUnavailable matches every error returned while an AI service is down or its breaker is open
+var ErrAIUnavailable = errors.New("AI service is temporarily unavailable")
+
+// UnavailableError reports an endpoint that failed every attempt or whose circuit breaker is open
+type UnavailableError struct {
+	Endpoint   string
+	RetryAfter time.Duration // time until the breaker lets a call through again, zero when unknown
+	Cause      error
+}
+
+func (e *UnavailableError) Error() string {
+	if e.Cause == nil {
+		return fmt.Sprintf("%s: circuit open for %s", ErrAIUnavailable, e.Endpoint)
+	}
+	return fmt.Sprintf("%s: %s: %v", ErrAIUnavailable, e.Endpoint, e.Cause)
+}
+
+func (e *UnavailableError) Is(target error) bool { return target == ErrAIUnavailable }
+func (e *UnavailableError) Unwrap() error        { return e.Cause }
+
+// StatusError is a non-200 answer that retrying will not fix
+type StatusError struct {
+	Endpoint   string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("failed to get a response from AI model: %s answered %d", e.Endpoint, e.StatusCode)
+}
+
+// EndpointPolicy holds the outbound settings of one AI endpoint
+type EndpointPolicy struct {
+	Timeout    time.Duration // per attempt, including reading the body
+	Idempotent bool          // only idempotent calls are retried
+}
+
+// OutboundConfig holds the settings shared by every outbound endpoint
+type OutboundConfig struct {
+	MaxRetries      int           // extra attempts after the first one
+	RetryBaseDelay  time.Duration // backoff before the first retry, doubled for each one after
+	RetryMaxDelay   time.Duration
+	BreakerFailures int           // consecutive failures that open the breaker
+	BreakerOpenFor  time.Duration // time an open breaker rejects calls before letting one probe through
+}
+
+// Outbound sends requests to the AI services with per-endpoint timeouts, jittered
+// retries and one circuit breaker per endpoint
+type Outbound struct {
+	cfg        OutboundConfig
+	httpClient *http.Client
+
+	mu       sync.Mutex
+	breakers map[string]*breaker
+}
+
+// NewOutbound builds the shared outbound layer
+func NewOutbound(cfg OutboundConfig) *Outbound {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{Timeout: 10 * time.Second, KeepAlive: 30 * time.Second}).DialContext
+	transport.ResponseHeaderTimeout = 0 // bounded by the endpoint timeout
+	return &Outbound{
+		cfg: cfg,
+		// No client timeout, each attempt is bounded by its endpoint context
+		httpClient: &http.Client{Transport: transport},
+		breakers:   map[string]*breaker{},
+	}
+}
+
+// Do sends the request built by newRequest for each attempt. The body of the returned
+// response must be closed, the attempt timeout keeps running until then.
+func (o *Outbound) Do(ctx context.Context, endpoint string, policy EndpointPolicy, newRequest func(ctx context.Context) (*http.Request, error)) (*http.Response, error) {
+	b := o.breaker(endpoint)
+	attempts := 1
+	if policy.Idempotent {
+		attempts += o.cfg.MaxRetries
+	}
+
+	var lastErr error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			if err := sleep(ctx, o.backoff(attempt)); err != nil {
+				return nil, err
+			}
+		}
+		if retryAfter, ok := b.allow(); !ok {
+			return nil, &UnavailableError{Endpoint: endpoint, RetryAfter: retryAfter}
+		}
+
+		resp, err := o.attempt(ctx, policy, newRequest)
+		if ctx.Err() != nil {
+			// The caller gave up, that says nothing about the endpoint
+			if err == nil {
+				discard(resp)
+			}
+			b.release()
+			return nil, ctx.Err()
+		}
+		if err == nil && resp.StatusCode == http.StatusOK {
+			b.record(true)
+			return resp, nil
+		}
+		if err == nil {
+			raw, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+			discard(resp)
+			log.Printf("AI %s returned status %d. Response: %s", endpoint, resp.StatusCode, string(raw))
+			// Every 5xx counts against the endpoint, a 4xx is an answer to a bad request
+			b.record(resp.StatusCode < http.StatusInternalServerError)
+			if !retryableStatus(resp.StatusCode) {
+				return nil, &StatusError{Endpoint: endpoint, StatusCode: resp.StatusCode}
+			}
+			err = fmt.Errorf("status %d", resp.StatusCode)
+		} else {
+			b.record(false)
+		}
+		lastErr = err
+		log.Printf("AI %s attempt %d/%d failed: %v", endpoint, attempt+1, attempts, err)
+	}
+	return nil, &UnavailableError{Endpoint: endpoint, Cause: lastErr}
+}
+
+// attempt sends one request under the endpoint timeout
+func (o *Outbound) attempt(ctx context.Context, policy EndpointPolicy, newRequest func(ctx context.Context) (*http.Request, error)) (*http.Response, error) {
+	var attemptCtx context.Context
+	var cancel context.CancelFunc
+	if policy.Timeout > 0 {
+		attemptCtx, cancel = context.WithTimeout(ctx, policy.Timeout)
+	} else {
+		attemptCtx, cancel = context.WithCancel(ctx)
+	}
+	req, err := newRequest(attemptCtx)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp, err := o.httpClient.Do(req)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// backoff is a full jitter delay, random up to the doubled base capped at the max delay
+func (o *Outbound) backoff(retry int) time.Duration {
+	ceiling := o.cfg.RetryBaseDelay << (retry - 1)
+	if ceiling <= 0 || ceiling > o.cfg.RetryMaxDelay {
+		ceiling = o.cfg.RetryMaxDelay
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(ceiling) + 1))
+}
+
+func (o *Outbound) breaker(endpoint string) *breaker {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	b, ok := o.breakers[endpoint]
+	if !ok {
+		b = &breaker{name: endpoint, threshold: o.cfg.BreakerFailures, openFor: o.cfg.BreakerOpenFor, now: time.Now}
+		o.breakers[endpoint] = b
+	}
+	return b
+}
+
+// BreakerStates reports the state of every endpoint breaker used so far
+func (o *Outbound) BreakerStates() map[string]string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	states := make(map[string]string, len(o.breakers))
+	for name, b := range o.breakers {
+		states[name] = b.current()
+	}
+	return states
+}
+
+// discard drains what is left of a response body so the connection can be reused, then closes it
+func discard(resp *http.Response) {
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+}
+
+// retryableStatus reports statuses a later attempt may not get
+func retryableStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// cancelOnClose ends the attempt context once the caller is done with the body
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+const (
+	breakerClosed   = "closed"
+	breakerOpen     = "open"
+	breakerHalfOpen = "half_open"
+)
+
+// breaker opens after threshold consecutive failures, rejects calls for openFor and
+// then lets a single probe decide whether it closes again
+type breaker struct {
+	name      string
+	threshold int
+	openFor   time.Duration
+	now       func() time.Time
+
+	mu       sync.Mutex
+	state    string
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// allow reports whether a call may go out, or how long until one may
+func (b *breaker) allow() (time.Duration, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case breakerOpen:
+		if wait := b.openFor - b.now().Sub(b.openedAt); wait > 0 {
+			return wait, false
+		}
+		b.state = breakerHalfOpen
+		log.Printf("AI %s circuit half open, probing", b.name)
+		fallthrough
+	case breakerHalfOpen:
+		if b.probing {
+			return b.openFor, false
+		}
+		b.probing = true
+	}
+	return 0, true
+}
+
+// record counts the outcome of an allowed call
+func (b *breaker) record(success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+	if success {
+		if b.state != breakerClosed && b.state != "" {
+			log.Printf("AI %s circuit closed", b.name)
+		}
+		b.state, b.failures = breakerClosed, 0
+		return
+	}
+	b.failures++
+	if b.state == breakerHalfOpen || (b.threshold > 0 && b.failures >= b.threshold) {
+		if b.state != breakerOpen {
+			log.Printf("AI %s circuit open after %d failures", b.name, b.failures)
+		}
+		b.state, b.openedAt = breakerOpen, b.now()
+	}
+}
+
+// release frees a probe slot without counting the call
+func (b *breaker) release() {
+	b.mu.Lock()
+	b.probing = false
+	b.mu.Unlock()
+}
+
+func (b *breaker) current() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == "" {
+		return breakerClosed
+	}
+	return b.state
+}
@@ -0,0 +1,169 @@
+package clients
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBreakerTransitions(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	b := &breaker{name: "test", threshold: 3, openFor: time.Minute, now: func() time.Time { return now }}
+
+	for i := 0; i < 2; i++ {
+		if _, ok := b.allow(); !ok {
+			t.Fatalf("call %d rejected by a closed breaker", i+1)
+		}
+		b.record(false)
+	}
+	if b.current() != breakerClosed {
+		t.Fatalf("state after 2 failures = %s, want closed", b.current())
+	}
+	b.record(true)
+	b.record(false)
+	b.record(false)
+	if b.current() != breakerClosed {
+		t.Fatalf("a success must reset the failure count, state = %s", b.current())
+	}
+	b.record(false)
+	if b.current() != breakerOpen {
+		t.Fatalf("state after 3 consecutive failures = %s, want open", b.current())
+	}
+
+	now = now.Add(20 * time.Second)
+	if wait, ok := b.allow(); ok || wait != 40*time.Second {
+		t.Fatalf("allow on an open breaker = %s, %v, want 40s and rejected", wait, ok)
+	}
+
+	// Once openFor has passed a single probe goes through
+	now = now.Add(40 * time.Second)
+	if _, ok := b.allow(); !ok {
+		t.Fatal("probe rejected after openFor")
+	}
+	if b.current() != breakerHalfOpen {
+		t.Fatalf("state while probing = %s, want half_open", b.current())
+	}
+	if _, ok := b.allow(); ok {
+		t.Fatal("a second call went out while the probe is running")
+	}
+	b.record(false)
+	if b.current() != breakerOpen {
+		t.Fatalf("state after a failed probe = %s, want open", b.current())
+	}
+
+	now = now.Add(time.Minute)
+	if _, ok := b.allow(); !ok {
+		t.Fatal("probe rejected after openFor")
+	}
+	b.release()
+	if _, ok := b.allow(); !ok {
+		t.Fatal("a released probe slot was not freed")
+	}
+	b.record(true)
+	if b.current() != breakerClosed {
+		t.Fatalf("state after a successful probe = %s, want closed", b.current())
+	}
+}
+
+func TestOutboundDoStatusHandling(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		idempotent bool
+		wantCalls  int32
+		wantErr    func(error) bool
+		wantFailed int // consecutive failures counted by the breaker
+	}{
+		{"ok", http.StatusOK, true, 1, nil, 0},
+		{"bad request is an answer", http.StatusBadRequest, true, 1, isStatusError, 0},
+		{"internal error is not retried but counts as a failure", http.StatusInternalServerError, true, 1, isStatusError, 1},
+		{"bad gateway is retried", http.StatusBadGateway, true, 3, isUnavailable, 3},
+		{"non idempotent calls are not retried", http.StatusServiceUnavailable, false, 1, isUnavailable, 1},
+		{"rate limit is retried without counting as a failure", http.StatusTooManyRequests, true, 3, isUnavailable, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&calls, 1)
+				w.WriteHeader(tt.status)
+				w.Write([]byte(`{"detail":"test"}`))
+			}))
+			defer server.Close()
+
+			o := NewOutbound(OutboundConfig{MaxRetries: 2, BreakerFailures: 5, BreakerOpenFor: time.Minute})
+			resp, err := o.Do(context.Background(), "test", EndpointPolicy{Timeout: time.Second, Idempotent: tt.idempotent}, newGet(server.URL))
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatal(err)
+				}
+				resp.Body.Close()
+			} else if !tt.wantErr(err) {
+				t.Fatalf("error = %v", err)
+			}
+			if calls := atomic.LoadInt32(&calls); calls != tt.wantCalls {
+				t.Errorf("%d attempts, want %d", calls, tt.wantCalls)
+			}
+			if failures := o.breaker("test").failures; failures != tt.wantFailed {
+				t.Errorf("breaker counted %d failures, want %d", failures, tt.wantFailed)
+			}
+		})
+	}
+}
+
+func TestOutboundDoOpenBreakerRejects(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	o := NewOutbound(OutboundConfig{BreakerFailures: 2, BreakerOpenFor: time.Minute})
+	policy := EndpointPolicy{Timeout: time.Second}
+	for i := 0; i < 2; i++ {
+		o.Do(context.Background(), "test", policy, newGet(server.URL))
+	}
+	_, err := o.Do(context.Background(), "test", policy, newGet(server.URL))
+	var unavailable *UnavailableError
+	if !errors.As(err, &unavailable) || unavailable.RetryAfter <= 0 {
+		t.Fatalf("error = %v, want the open breaker to reject the call", err)
+	}
+	if calls := atomic.LoadInt32(&calls); calls != 2 {
+		t.Errorf("%d calls reached the endpoint, want 2", calls)
+	}
+}
+
+func TestOutboundDoCallerCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cancel()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	o := NewOutbound(OutboundConfig{BreakerFailures: 1, BreakerOpenFor: time.Minute})
+	if _, err := o.Do(ctx, "test", EndpointPolicy{Timeout: time.Second}, newGet(server.URL)); !errors.Is(err, context.Canceled) {
+		t.Fatalf("error = %v, want context.Canceled", err)
+	}
+	if state := o.BreakerStates()["test"]; state != breakerClosed {
+		t.Errorf("breaker %s, a cancelled caller must not count against the endpoint", state)
+	}
+}
+
+func newGet(url string) func(ctx context.Context) (*http.Request, error) {
+	return func(ctx context.Context) (*http.Request, error) {
+		return http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	}
+}
+
+func isStatusError(err error) bool {
+	var status *StatusError
+	return errors.As(err, &status)
+}
+
+func isUnavailable(err error) bool { return errors.Is(err, ErrAIUnavailable) }
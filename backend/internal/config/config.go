@@ -1 +1,638 @@
-package config
\ No newline at end of file
+// Package config loads the application settings from defaults, an optional
+// YAML file, the environment and command line flags, in that order of precedence.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/mail"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the full application configuration
+type Config struct {
+	Server   ServerConfig   `yaml:"server"`
+	Database DatabaseConfig `yaml:"database"`
+	Auth     AuthConfig     `yaml:"auth"`
+	AI       AIConfig       `yaml:"ai"`
+	Speech   SpeechConfig   `yaml:"speech"`
+	CORS     CORSConfig     `yaml:"cors"`
+	Sweeper  SweeperConfig  `yaml:"sweeper"`
+	Analysis AnalysisConfig `yaml:"analysis"`
+	Notify   NotifyConfig   `yaml:"notify"`
+	RedFlags RedFlagConfig  `yaml:"red_flags"`
+}
+
+// ServerConfig holds the HTTP listener settings
+type ServerConfig struct {
+	Env             string        `yaml:"env"` // development or production
+	Port            int           `yaml:"port"`
+	ReadTimeout     time.Duration `yaml:"read_timeout"`
+	WriteTimeout    time.Duration `yaml:"write_timeout"`
+	IdleTimeout     time.Duration `yaml:"idle_timeout"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+}
+
+// Addr is the listen address for the configured port
+func (s ServerConfig) Addr() string {
+	return ":" + strconv.Itoa(s.Port)
+}
+
+// DatabaseConfig selects the storage backend and tunes the Postgres pool
+type DatabaseConfig struct {
+	Backend           string        `yaml:"backend"` // postgres or memory
+	URL               string        `yaml:"url"`
+	MaxConns          int32         `yaml:"max_conns"`
+	MinConns          int32         `yaml:"min_conns"`
+	MaxConnLifetime   time.Duration `yaml:"max_conn_lifetime"`
+	MaxConnIdleTime   time.Duration `yaml:"max_conn_idle_time"`
+	HealthCheckPeriod time.Duration `yaml:"health_check_period"`
+	ConnectRetries    int           `yaml:"connect_retries"`
+	ConnectBackoff    time.Duration `yaml:"connect_backoff"`
+}
+
+// AuthConfig holds the token signing settings
+type AuthConfig struct {
+	JWTSecret  string        `yaml:"jwt_secret"`
+	AccessTTL  time.Duration `yaml:"access_ttl"`
+	RefreshTTL time.Duration `yaml:"refresh_ttl"`
+}
+
+// AIConfig selects the AI backend and holds the endpoints of the hosted services
+type AIConfig struct {
+	Backend          string        `yaml:"backend"`           // http or fake
+	FixturesFile     string        `yaml:"fixtures_file"`     // fake backend script, the bundled one when empty
+	ChatURL          string        `yaml:"chat_url"`          // BPI bot, body part identification
+	QuestionnaireURL string        `yaml:"questionnaire_url"` // XAI bot, questionnaire
+	DashboardURL     string        `yaml:"dashboard_url"`     // dashboard cloud function
+	Timeout          time.Duration `yaml:"timeout"`           // used by endpoints without their own timeout
+
+	// Per call timeouts, zero falls back to Timeout
+	ChatTimeout          time.Duration `yaml:"chat_timeout"`
+	VideoTimeout         time.Duration `yaml:"video_timeout"`
+	QuestionnaireTimeout time.Duration `yaml:"questionnaire_timeout"`
+	DashboardTimeout     time.Duration `yaml:"dashboard_timeout"`
+
+	MaxRetries      int           `yaml:"max_retries"` // extra attempts after a failed call
+	RetryBaseDelay  time.Duration `yaml:"retry_base_delay"`
+	RetryMaxDelay   time.Duration `yaml:"retry_max_delay"`
+	BreakerFailures int           `yaml:"breaker_failures"` // consecutive failures opening an endpoint's breaker
+	BreakerOpenFor  time.Duration `yaml:"breaker_open_for"`
+}
+
+// TimeoutOr returns d, or the shared timeout when d is unset
+func (c AIConfig) TimeoutOr(d time.Duration) time.Duration {
+	if d > 0 {
+		return d
+	}
+	return c.Timeout
+}
+
+// SpeechConfig holds the Google speech API settings
+type SpeechConfig struct {
+	APIKey      string        `yaml:"api_key"`
+	ProjectID   string        `yaml:"project_id"`
+	STTEndpoint string        `yaml:"stt_endpoint"`
+	TTSEndpoint string        `yaml:"tts_endpoint"`
+	Timeout     time.Duration `yaml:"timeout"`
+}
+
+// CORSConfig lists the browser origins allowed to call the API
+type CORSConfig struct {
+	AllowedOrigins []string      `yaml:"allowed_origins"`
+	MaxAge         time.Duration `yaml:"max_age"`
+}
+
+// SweeperConfig controls the background abandonment of stale assessments
+type SweeperConfig struct {
+	Enabled      bool          `yaml:"enabled"`
+	Interval     time.Duration `yaml:"interval"`
+	AbandonAfter time.Duration `yaml:"abandon_after"` // inactivity window
+	BatchSize    int           `yaml:"batch_size"`
+}
+
+// AnalysisConfig controls the background dashboard analysis workers
+type AnalysisConfig struct {
+	Workers       int           `yaml:"workers"`
+	PollInterval  time.Duration `yaml:"poll_interval"`
+	MaxAttempts   int           `yaml:"max_attempts"`
+	RetryBackoff  time.Duration `yaml:"retry_backoff"`  // doubled after each failed attempt
+	LeaseTimeout  time.Duration `yaml:"lease_timeout"`  // a running job older than this is claimed again
+	DashboardWait time.Duration `yaml:"dashboard_wait"` // time GET /dashboard waits for its job
+}
+
+// NotifyConfig controls the notification outbox and its delivery channels
+type NotifyConfig struct {
+	Enabled       bool            `yaml:"enabled"`
+	Channels      []string        `yaml:"channels"` // email, webhook and log
+	PollInterval  time.Duration   `yaml:"poll_interval"`
+	MaxAttempts   int             `yaml:"max_attempts"`
+	RetryBackoff  time.Duration   `yaml:"retry_backoff"`  // doubled after each failed attempt
+	LeaseTimeout  time.Duration   `yaml:"lease_timeout"`  // a message sending for longer is claimed again
+	ReminderLeads []time.Duration `yaml:"reminder_leads"` // call reminders go out this long before the call
+	TemplatesDir  string          `yaml:"templates_dir"`  // <kind>.tmpl files replacing the built-in templates
+	LogFile       string          `yaml:"log_file"`       // log channel output, the application log when empty
+	Timeout       time.Duration   `yaml:"timeout"`        // per email or webhook delivery
+	SMTP          SMTPConfig      `yaml:"smtp"`
+	Webhook       WebhookConfig   `yaml:"webhook"`
+}
+
+// SMTPConfig holds the mail server of the email channel
+type SMTPConfig struct {
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	Username string `yaml:"username"` // no authentication when empty
+	Password string `yaml:"password"`
+	From     string `yaml:"from"`
+}
+
+// WebhookConfig holds the endpoint of the webhook channel
+type WebhookConfig struct {
+	URL    string `yaml:"url"`
+	Secret string `yaml:"secret"` // HMAC-SHA256 key of the X-Signature header
+}
+
+// RedFlagConfig controls the red-flag review of finished analyses
+type RedFlagConfig struct {
+	Enabled   bool   `yaml:"enabled"`
+	RulesFile string `yaml:"rules_file"` // versioned rules, the bundled ones when empty
+}
+
+// Default returns the configuration used when nothing else is set
+func Default() Config {
+	return Config{
+		Server: ServerConfig{
+			Env:             "production",
+			Port:            8080,
+			ReadTimeout:     30 * time.Second,
+			WriteTimeout:    2 * time.Minute, // dashboard generation waits on the AI
+			IdleTimeout:     2 * time.Minute,
+			ShutdownTimeout: 15 * time.Second,
+		},
+		Database: DatabaseConfig{
+			Backend:           "postgres",
+			MaxConns:          10,
+			MinConns:          1,
+			MaxConnLifetime:   time.Hour,
+			MaxConnIdleTime:   30 * time.Minute,
+			HealthCheckPeriod: time.Minute,
+			ConnectRetries:    5,
+			ConnectBackoff:    time.Second,
+		},
+		Auth: AuthConfig{
+			AccessTTL:  15 * time.Minute,
+			RefreshTTL: 7 * 24 * time.Hour,
+		},
+		AI: AIConfig{
+			Backend:              "http",
+			ChatURL:              "https://deecogs-bpi-bot-844145949029.europe-west1.run.app/chat",
+			QuestionnaireURL:     "https://deecogs-xai-bot-844145949029.europe-west1.run.app/chat",
+			DashboardURL:         "https://europe-west2-dochq-staging.cloudfunctions.net/deecogs-dashboard",
+			Timeout:              90 * time.Second,
+			ChatTimeout:          30 * time.Second,
+			QuestionnaireTimeout: 30 * time.Second,
+			MaxRetries:           2,
+			RetryBaseDelay:       200 * time.Millisecond,
+			RetryMaxDelay:        2 * time.Second,
+			BreakerFailures:      5,
+			BreakerOpenFor:       30 * time.Second,
+		},
+		Speech: SpeechConfig{
+			STTEndpoint: "https://speech.googleapis.com/v1/speech:recognize",
+			TTSEndpoint: "https://texttospeech.googleapis.com/v1/text:synthesize",
+			Timeout:     30 * time.Second,
+		},
+		CORS: CORSConfig{
+			AllowedOrigins: []string{
+				"http://localhost:3000",
+				"http://localhost:3001",
+				"https://triage-frontend-844145949029.europe-west1.run.app",
+			},
+			MaxAge: 12 * time.Hour,
+		},
+		Sweeper: SweeperConfig{
+			Enabled:      true,
+			Interval:     10 * time.Minute,
+			AbandonAfter: 72 * time.Hour,
+			BatchSize:    100,
+		},
+		Analysis: AnalysisConfig{
+			Workers:       2,
+			PollInterval:  5 * time.Second,
+			MaxAttempts:   3,
+			RetryBackoff:  10 * time.Second,
+			LeaseTimeout:  10 * time.Minute,
+			DashboardWait: 60 * time.Second,
+		},
+		Notify: NotifyConfig{
+			Enabled:       true,
+			Channels:      []string{"log"},
+			PollInterval:  30 * time.Second,
+			MaxAttempts:   5,
+			RetryBackoff:  time.Minute,
+			LeaseTimeout:  5 * time.Minute,
+			ReminderLeads: []time.Duration{24 * time.Hour, time.Hour},
+			Timeout:       30 * time.Second,
+			SMTP:          SMTPConfig{Port: 587},
+		},
+		RedFlags: RedFlagConfig{Enabled: true},
+	}
+}
+
+// Load builds the configuration for the given command line arguments (without the program name).
+// The file named by -config or CONFIG_FILE is read first, then the environment and flags override it.
+func Load(args []string) (*Config, error) {
+	fs := flag.NewFlagSet("app", flag.ContinueOnError)
+	configFile := fs.String("config", os.Getenv("CONFIG_FILE"), "path to a YAML config file")
+	port := fs.Int("port", 0, "HTTP port to listen on")
+	backend := fs.String("storage", "", "storage backend: postgres or memory")
+	aiBackend := fs.String("ai", "", "AI backend: http or fake")
+	databaseURL := fs.String("database-url", "", "Postgres connection string")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	cfg := Default()
+	if *configFile != "" {
+		if err := loadFile(&cfg, *configFile); err != nil {
+			return nil, err
+		}
+	}
+	if err := loadEnv(&cfg); err != nil {
+		return nil, err
+	}
+
+	// Only flags given explicitly override the lower layers
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "port":
+			cfg.Server.Port = *port
+		case "storage":
+			cfg.Database.Backend = *backend
+		case "database-url":
+			cfg.Database.URL = *databaseURL
+		case "ai":
+			cfg.AI.Backend = *aiBackend
+		}
+	})
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+func loadFile(cfg *Config, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config: reading %s: %w", path, err)
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("config: parsing %s: %w", path, err)
+	}
+	return nil
+}
+
+// loadEnv applies the environment variables, keeping the names the app has always used
+func loadEnv(cfg *Config) error {
+	var errs []error
+	str := func(key string, dst *string) {
+		if v, ok := os.LookupEnv(key); ok && v != "" {
+			*dst = v
+		}
+	}
+	integer := func(key string, dst *int) {
+		if v, ok := os.LookupEnv(key); ok && v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %q is not an integer", key, v))
+				return
+			}
+			*dst = n
+		}
+	}
+	int32v := func(key string, dst *int32) {
+		n := int(*dst)
+		integer(key, &n)
+		*dst = int32(n)
+	}
+	boolean := func(key string, dst *bool) {
+		if v, ok := os.LookupEnv(key); ok && v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %q is not a boolean", key, v))
+				return
+			}
+			*dst = b
+		}
+	}
+	duration := func(key string, dst *time.Duration) {
+		if v, ok := os.LookupEnv(key); ok && v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %q is not a duration (e.g. 30s, 5m)", key, v))
+				return
+			}
+			*dst = d
+		}
+	}
+
+	str("APP_ENV", &cfg.Server.Env)
+	integer("PORT", &cfg.Server.Port)
+	duration("SERVER_READ_TIMEOUT", &cfg.Server.ReadTimeout)
+	duration("SERVER_WRITE_TIMEOUT", &cfg.Server.WriteTimeout)
+	duration("SERVER_IDLE_TIMEOUT", &cfg.Server.IdleTimeout)
+	duration("SHUTDOWN_TIMEOUT", &cfg.Server.ShutdownTimeout)
+
+	str("STORAGE_BACKEND", &cfg.Database.Backend)
+	str("DATABASE_URL", &cfg.Database.URL)
+	int32v("DB_MAX_CONNS", &cfg.Database.MaxConns)
+	int32v("DB_MIN_CONNS", &cfg.Database.MinConns)
+	duration("DB_MAX_CONN_LIFETIME", &cfg.Database.MaxConnLifetime)
+	duration("DB_MAX_CONN_IDLE_TIME", &cfg.Database.MaxConnIdleTime)
+	duration("DB_HEALTH_CHECK_PERIOD", &cfg.Database.HealthCheckPeriod)
+	integer("DB_CONNECT_RETRIES", &cfg.Database.ConnectRetries)
+	duration("DB_CONNECT_BACKOFF", &cfg.Database.ConnectBackoff)
+
+	str("JWT_SECRET", &cfg.Auth.JWTSecret)
+	duration("JWT_ACCESS_TTL", &cfg.Auth.AccessTTL)
+	duration("JWT_REFRESH_TTL", &cfg.Auth.RefreshTTL)
+
+	str("AI_BACKEND", &cfg.AI.Backend)
+	str("AI_FIXTURES_FILE", &cfg.AI.FixturesFile)
+	str("AI_CHAT_URL", &cfg.AI.ChatURL)
+	str("AI_QUESTIONNAIRE_URL", &cfg.AI.QuestionnaireURL)
+	str("AI_DASHBOARD_URL", &cfg.AI.DashboardURL)
+	duration("AI_TIMEOUT", &cfg.AI.Timeout)
+	duration("AI_CHAT_TIMEOUT", &cfg.AI.ChatTimeout)
+	duration("AI_VIDEO_TIMEOUT", &cfg.AI.VideoTimeout)
+	duration("AI_QUESTIONNAIRE_TIMEOUT", &cfg.AI.QuestionnaireTimeout)
+	duration("AI_DASHBOARD_TIMEOUT", &cfg.AI.DashboardTimeout)
+	integer("AI_MAX_RETRIES", &cfg.AI.MaxRetries)
+	duration("AI_RETRY_BASE_DELAY", &cfg.AI.RetryBaseDelay)
+	duration("AI_RETRY_MAX_DELAY", &cfg.AI.RetryMaxDelay)
+	integer("AI_BREAKER_FAILURES", &cfg.AI.BreakerFailures)
+	duration("AI_BREAKER_OPEN_FOR", &cfg.AI.BreakerOpenFor)
+
+	str("GOOGLE_CLOUD_API_KEY", &cfg.Speech.APIKey)
+	str("GOOGLE_CLOUD_PROJECT_ID", &cfg.Speech.ProjectID)
+	duration("SPEECH_TIMEOUT", &cfg.Speech.Timeout)
+
+	boolean("ABANDON_SWEEP_ENABLED", &cfg.Sweeper.Enabled)
+	duration("ABANDON_SWEEP_INTERVAL", &cfg.Sweeper.Interval)
+	duration("ABANDON_AFTER", &cfg.Sweeper.AbandonAfter)
+	integer("ABANDON_SWEEP_BATCH_SIZE", &cfg.Sweeper.BatchSize)
+
+	integer("ANALYSIS_WORKERS", &cfg.Analysis.Workers)
+	duration("ANALYSIS_POLL_INTERVAL", &cfg.Analysis.PollInterval)
+	integer("ANALYSIS_MAX_ATTEMPTS", &cfg.Analysis.MaxAttempts)
+	duration("ANALYSIS_RETRY_BACKOFF", &cfg.Analysis.RetryBackoff)
+	duration("ANALYSIS_LEASE_TIMEOUT", &cfg.Analysis.LeaseTimeout)
+	duration("ANALYSIS_DASHBOARD_WAIT", &cfg.Analysis.DashboardWait)
+
+	boolean("NOTIFY_ENABLED", &cfg.Notify.Enabled)
+	duration("NOTIFY_POLL_INTERVAL", &cfg.Notify.PollInterval)
+	integer("NOTIFY_MAX_ATTEMPTS", &cfg.Notify.MaxAttempts)
+	duration("NOTIFY_RETRY_BACKOFF", &cfg.Notify.RetryBackoff)
+	duration("NOTIFY_LEASE_TIMEOUT", &cfg.Notify.LeaseTimeout)
+	str("NOTIFY_TEMPLATES_DIR", &cfg.Notify.TemplatesDir)
+	str("NOTIFY_LOG_FILE", &cfg.Notify.LogFile)
+	duration("NOTIFY_TIMEOUT", &cfg.Notify.Timeout)
+	str("SMTP_HOST", &cfg.Notify.SMTP.Host)
+	integer("SMTP_PORT", &cfg.Notify.SMTP.Port)
+	str("SMTP_USERNAME", &cfg.Notify.SMTP.Username)
+	str("SMTP_PASSWORD", &cfg.Notify.SMTP.Password)
+	str("SMTP_FROM", &cfg.Notify.SMTP.From)
+	str("NOTIFY_WEBHOOK_URL", &cfg.Notify.Webhook.URL)
+	str("NOTIFY_WEBHOOK_SECRET", &cfg.Notify.Webhook.Secret)
+	boolean("RED_FLAGS_ENABLED", &cfg.RedFlags.Enabled)
+	str("RED_FLAG_RULES_FILE", &cfg.RedFlags.RulesFile)
+	if v := os.Getenv("NOTIFY_CHANNELS"); v != "" {
+		cfg.Notify.Channels = splitList(v)
+	}
+	if v := os.Getenv("NOTIFY_REMINDER_LEADS"); v != "" {
+		cfg.Notify.ReminderLeads = nil
+		for _, item := range splitList(v) {
+			d, err := time.ParseDuration(item)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("NOTIFY_REMINDER_LEADS: %q is not a duration (e.g. 24h, 1h)", item))
+				continue
+			}
+			cfg.Notify.ReminderLeads = append(cfg.Notify.ReminderLeads, d)
+		}
+	}
+
+	if v := os.Getenv("CORS_ALLOWED_ORIGINS"); v != "" {
+		cfg.CORS.AllowedOrigins = splitList(v)
+	}
+	// ALLOWED_ORIGIN predates CORS_ALLOWED_ORIGINS and adds a single origin
+	if v := os.Getenv("ALLOWED_ORIGIN"); v != "" {
+		cfg.CORS.AllowedOrigins = append(cfg.CORS.AllowedOrigins, v)
+	}
+
+	return errors.Join(errs...)
+}
+
+// Validate reports every invalid setting at once
+func (c *Config) Validate() error {
+	var errs []error
+	add := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+	positive := func(name string, d time.Duration) {
+		if d <= 0 {
+			add("%s must be a positive duration, got %s", name, d)
+		}
+	}
+
+	if c.Server.Env != "development" && c.Server.Env != "production" {
+		add("server.env (APP_ENV) must be development or production, got %q", c.Server.Env)
+	}
+	if c.Server.Port < 1 || c.Server.Port > 65535 {
+		add("server.port must be between 1 and 65535, got %d", c.Server.Port)
+	}
+	positive("server.read_timeout", c.Server.ReadTimeout)
+	positive("server.write_timeout", c.Server.WriteTimeout)
+	positive("server.idle_timeout", c.Server.IdleTimeout)
+	positive("server.shutdown_timeout", c.Server.ShutdownTimeout)
+
+	switch c.Database.Backend {
+	case "memory":
+	case "postgres":
+		if c.Database.URL == "" {
+			add("database.url (DATABASE_URL) is required for the postgres backend")
+		}
+		if c.Database.MaxConns < 1 {
+			add("database.max_conns must be at least 1, got %d", c.Database.MaxConns)
+		}
+		if c.Database.MinConns < 0 || c.Database.MinConns > c.Database.MaxConns {
+			add("database.min_conns must be between 0 and max_conns, got %d", c.Database.MinConns)
+		}
+		if c.Database.ConnectRetries < 0 {
+			add("database.connect_retries must not be negative, got %d", c.Database.ConnectRetries)
+		}
+	default:
+		add("database.backend (STORAGE_BACKEND) must be postgres or memory, got %q", c.Database.Backend)
+	}
+
+	// Only development may sign with a throwaway key
+	if c.Auth.JWTSecret == "" && c.Server.Env != "development" {
+		add("auth.jwt_secret (JWT_SECRET) is required outside development")
+	}
+	positive("auth.access_ttl", c.Auth.AccessTTL)
+	positive("auth.refresh_ttl", c.Auth.RefreshTTL)
+
+	for name, raw := range map[string]string{
+		"ai.chat_url":          c.AI.ChatURL,
+		"ai.questionnaire_url": c.AI.QuestionnaireURL,
+		"ai.dashboard_url":     c.AI.DashboardURL,
+		"speech.stt_endpoint":  c.Speech.STTEndpoint,
+		"speech.tts_endpoint":  c.Speech.TTSEndpoint,
+	} {
+		if err := checkURL(raw); err != nil {
+			add("%s: %v", name, err)
+		}
+	}
+	if c.AI.Backend != "http" && c.AI.Backend != "fake" {
+		add("ai.backend (AI_BACKEND) must be http or fake, got %q", c.AI.Backend)
+	}
+	positive("ai.timeout", c.AI.Timeout)
+	for name, d := range map[string]time.Duration{
+		"ai.chat_timeout":          c.AI.ChatTimeout,
+		"ai.video_timeout":         c.AI.VideoTimeout,
+		"ai.questionnaire_timeout": c.AI.QuestionnaireTimeout,
+		"ai.dashboard_timeout":     c.AI.DashboardTimeout,
+	} {
+		if d < 0 {
+			add("%s must not be negative, got %s", name, d)
+		}
+	}
+	if c.AI.MaxRetries < 0 {
+		add("ai.max_retries must not be negative, got %d", c.AI.MaxRetries)
+	}
+	if c.AI.MaxRetries > 0 {
+		positive("ai.retry_base_delay", c.AI.RetryBaseDelay)
+		if c.AI.RetryMaxDelay < c.AI.RetryBaseDelay {
+			add("ai.retry_max_delay must be at least retry_base_delay, got %s", c.AI.RetryMaxDelay)
+		}
+	}
+	if c.AI.BreakerFailures < 1 {
+		add("ai.breaker_failures must be at least 1, got %d", c.AI.BreakerFailures)
+	}
+	positive("ai.breaker_open_for", c.AI.BreakerOpenFor)
+	positive("speech.timeout", c.Speech.Timeout)
+
+	if c.Sweeper.Enabled {
+		positive("sweeper.interval", c.Sweeper.Interval)
+		positive("sweeper.abandon_after", c.Sweeper.AbandonAfter)
+		if c.Sweeper.BatchSize < 1 {
+			add("sweeper.batch_size must be at least 1, got %d", c.Sweeper.BatchSize)
+		}
+	}
+
+	if c.Analysis.Workers < 1 {
+		add("analysis.workers must be at least 1, got %d", c.Analysis.Workers)
+	}
+	if c.Analysis.MaxAttempts < 1 {
+		add("analysis.max_attempts must be at least 1, got %d", c.Analysis.MaxAttempts)
+	}
+	positive("analysis.poll_interval", c.Analysis.PollInterval)
+	positive("analysis.retry_backoff", c.Analysis.RetryBackoff)
+	positive("analysis.dashboard_wait", c.Analysis.DashboardWait)
+	// A job still waiting on the AI must not look abandoned to the other workers
+	if attempt := c.AI.TimeoutOr(c.AI.DashboardTimeout) * time.Duration(c.AI.MaxRetries+1); c.Analysis.LeaseTimeout <= attempt {
+		add("analysis.lease_timeout must be longer than the dashboard AI call with its retries (%s), got %s", attempt, c.Analysis.LeaseTimeout)
+	}
+
+	if c.Notify.Enabled {
+		if len(c.Notify.Channels) == 0 {
+			add("notify.channels must list at least one of email, webhook and log")
+		}
+		seen := map[string]bool{}
+		for _, channel := range c.Notify.Channels {
+			switch {
+			case seen[channel]:
+				add("notify.channels lists %s twice", channel)
+			case channel == "email":
+				if c.Notify.SMTP.Host == "" {
+					add("notify.smtp.host (SMTP_HOST) is required for the email channel")
+				}
+				if _, err := mail.ParseAddress(c.Notify.SMTP.From); err != nil {
+					add("notify.smtp.from (SMTP_FROM) must be an email address for the email channel: %v", err)
+				}
+				if c.Notify.SMTP.Port < 1 || c.Notify.SMTP.Port > 65535 {
+					add("notify.smtp.port must be between 1 and 65535, got %d", c.Notify.SMTP.Port)
+				}
+			case channel == "webhook":
+				if err := checkURL(c.Notify.Webhook.URL); err != nil {
+					add("notify.webhook.url (NOTIFY_WEBHOOK_URL) is required for the webhook channel: %v", err)
+				}
+			case channel == "log":
+			default:
+				add("notify.channels: %q is not email, webhook or log", channel)
+			}
+			seen[channel] = true
+		}
+		if c.Notify.MaxAttempts < 1 {
+			add("notify.max_attempts must be at least 1, got %d", c.Notify.MaxAttempts)
+		}
+		positive("notify.poll_interval", c.Notify.PollInterval)
+		positive("notify.retry_backoff", c.Notify.RetryBackoff)
+		positive("notify.timeout", c.Notify.Timeout)
+		for _, lead := range c.Notify.ReminderLeads {
+			positive("notify.reminder_leads", lead)
+		}
+		// A delivery still in progress must not look abandoned to the next poll
+		if c.Notify.LeaseTimeout <= c.Notify.Timeout {
+			add("notify.lease_timeout must be longer than notify.timeout (%s), got %s", c.Notify.Timeout, c.Notify.LeaseTimeout)
+		}
+	}
+
+	// Credentials are allowed, so a wildcard origin would be rejected by the CORS middleware
+	for _, origin := range c.CORS.AllowedOrigins {
+		if origin == "*" {
+			add("cors.allowed_origins cannot contain * because credentials are allowed")
+			continue
+		}
+		if err := checkURL(origin); err != nil {
+			add("cors.allowed_origins: %q: %v", origin, err)
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("invalid configuration:\n%w", errors.Join(errs...))
+}
+
+func checkURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%q is not an absolute http(s) URL", raw)
+	}
+	return nil
+}
+
+func splitList(v string) []string {
+	var out []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
@@ -0,0 +1,31 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateRequiresJWTSecretOutsideDevelopment(t *testing.T) {
+	tests := []struct {
+		env, secret string
+		wantErr     bool
+	}{
+		{"production", "", true},
+		{"production", "s3cret", false},
+		{"development", "", false},
+		{"staging", "s3cret", true},
+	}
+	for _, tt := range tests {
+		cfg := Default()
+		cfg.Database.URL = "postgres://localhost/aibot"
+		cfg.Server.Env = tt.env
+		cfg.Auth.JWTSecret = tt.secret
+		err := cfg.Validate()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Validate with APP_ENV=%s and JWT_SECRET=%q = %v, want error %v", tt.env, tt.secret, err, tt.wantErr)
+		}
+		if err != nil && tt.env == "production" && !strings.Contains(err.Error(), "JWT_SECRET") {
+			t.Errorf("Validate error %q does not name JWT_SECRET", err)
+		}
+	}
+}
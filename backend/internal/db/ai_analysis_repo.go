@@ -0,0 +1,67 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// AIAnalysis is a row of the ai_analysis table
+type AIAnalysis struct {
+	AnalysisID      uint32
+	AssessmentID    uint32
+	AssessmentData  json.RawMessage
+	AnalysedResults json.RawMessage
+	CreatedAt       time.Time
+}
+
+// AIAnalysisRepo reads and writes the ai_analysis table
+type AIAnalysisRepo struct {
+	pool *pgxpool.Pool
+}
+
+// Create stores the input and result of a dashboard analysis and returns its id
+func (r *AIAnalysisRepo) Create(ctx context.Context, assessmentID uint32, assessmentData, analysedResults json.RawMessage) (uint32, error) {
+	query := `
+		INSERT INTO ai_analysis (assessment_id, assessment_data, analysed_results, created_at)
+		VALUES ($1, $2, $3, NOW())
+		RETURNING analysis_id
+	`
+	var analysisID uint32
+	err := r.pool.QueryRow(ctx, query, assessmentID, assessmentData, analysedResults).Scan(&analysisID)
+	return analysisID, translate(err)
+}
+
+// Get returns an analysis by id
+func (r *AIAnalysisRepo) Get(ctx context.Context, analysisID uint32) (*AIAnalysis, error) {
+	query := `
+		SELECT analysis_id, assessment_id, assessment_data, analysed_results, created_at
+		FROM ai_analysis
+		WHERE analysis_id = $1
+	`
+	var a AIAnalysis
+	err := r.pool.QueryRow(ctx, query, analysisID).Scan(&a.AnalysisID, &a.AssessmentID, &a.AssessmentData, &a.AnalysedResults, &a.CreatedAt)
+	if err != nil {
+		return nil, translate(err)
+	}
+	return &a, nil
+}
+
+// Latest returns the most recent analysis of an assessment
+func (r *AIAnalysisRepo) Latest(ctx context.Context, assessmentID uint32) (*AIAnalysis, error) {
+	query := `
+		SELECT analysis_id, assessment_id, assessment_data, analysed_results, created_at
+		FROM ai_analysis
+		WHERE assessment_id = $1
+		ORDER BY created_at DESC
+		LIMIT 1
+	`
+	var a AIAnalysis
+	err := r.pool.QueryRow(ctx, query, assessmentID).Scan(&a.AnalysisID, &a.AssessmentID, &a.AssessmentData, &a.AnalysedResults, &a.CreatedAt)
+	if err != nil {
+		return nil, translate(err)
+	}
+	return &a, nil
+}
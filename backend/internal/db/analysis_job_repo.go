@@ -0,0 +1,179 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Analysis job statuses
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+)
+
+// AnalysisJob is a row of the analysis_jobs table
+type AnalysisJob struct {
+	JobID        uint32
+	AssessmentID uint32
+	Revision     string
+	Input        json.RawMessage
+	Status       string
+	Attempts     int
+	MaxAttempts  int
+	RunAfter     time.Time
+	LockedAt     *time.Time
+	LastError    *string
+	AnalysisID   *uint32
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	FinishedAt   *time.Time
+}
+
+// AnalysisJobRepo reads and writes the analysis_jobs table
+type AnalysisJobRepo struct {
+	pool *pgxpool.Pool
+}
+
+const analysisJobColumns = `job_id, assessment_id, revision, input, status, attempts, max_attempts, run_after,
+	locked_at, last_error, analysis_id, created_at, updated_at, finished_at`
+
+func scanAnalysisJob(row pgx.Row) (*AnalysisJob, error) {
+	var j AnalysisJob
+	err := row.Scan(&j.JobID, &j.AssessmentID, &j.Revision, &j.Input, &j.Status, &j.Attempts, &j.MaxAttempts, &j.RunAfter,
+		&j.LockedAt, &j.LastError, &j.AnalysisID, &j.CreatedAt, &j.UpdatedAt, &j.FinishedAt)
+	if err != nil {
+		return nil, translate(err)
+	}
+	return &j, nil
+}
+
+// Enqueue adds a queued job, or returns the existing job of the same assessment revision with created false
+func (r *AnalysisJobRepo) Enqueue(ctx context.Context, assessmentID uint32, revision string, input json.RawMessage, maxAttempts int) (*AnalysisJob, bool, error) {
+	query := `
+		INSERT INTO analysis_jobs (assessment_id, revision, input, max_attempts)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (assessment_id, revision) DO NOTHING
+		RETURNING ` + analysisJobColumns
+	job, err := scanAnalysisJob(r.pool.QueryRow(ctx, query, assessmentID, revision, input, maxAttempts))
+	if err == nil {
+		return job, true, nil
+	}
+	if !errors.Is(err, ErrNotFound) {
+		return nil, false, err
+	}
+
+	query = `SELECT ` + analysisJobColumns + ` FROM analysis_jobs WHERE assessment_id = $1 AND revision = $2`
+	job, err = scanAnalysisJob(r.pool.QueryRow(ctx, query, assessmentID, revision))
+	return job, false, err
+}
+
+// Get returns a job by id
+func (r *AnalysisJobRepo) Get(ctx context.Context, jobID uint32) (*AnalysisJob, error) {
+	query := `SELECT ` + analysisJobColumns + ` FROM analysis_jobs WHERE job_id = $1`
+	return scanAnalysisJob(r.pool.QueryRow(ctx, query, jobID))
+}
+
+// Latest returns the most recently enqueued job of an assessment
+func (r *AnalysisJobRepo) Latest(ctx context.Context, assessmentID uint32) (*AnalysisJob, error) {
+	query := `SELECT ` + analysisJobColumns + ` FROM analysis_jobs WHERE assessment_id = $1 ORDER BY job_id DESC LIMIT 1`
+	return scanAnalysisJob(r.pool.QueryRow(ctx, query, assessmentID))
+}
+
+// Claim marks the oldest due job as running and counts the attempt. A running job locked
+// longer than lease ago lost its worker and is claimed again. ErrNotFound means nothing is due.
+// Both cutoffs are taken from the database clock, the one that wrote locked_at and run_after.
+func (r *AnalysisJobRepo) Claim(ctx context.Context, lease time.Duration) (*AnalysisJob, error) {
+	query := `
+		UPDATE analysis_jobs
+		SET status = 'running', attempts = attempts + 1, locked_at = NOW(), updated_at = NOW()
+		WHERE job_id = (
+			SELECT job_id FROM analysis_jobs
+			WHERE (status = 'queued' AND run_after <= NOW()) OR (status = 'running' AND locked_at < NOW() - make_interval(secs => $1))
+			ORDER BY run_after, job_id
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + analysisJobColumns
+	return scanAnalysisJob(r.pool.QueryRow(ctx, query, lease.Seconds()))
+}
+
+// Succeed stores the analysis result and finishes the job in one transaction. lockedAt is
+// the claim being finished, ErrConflict means the job was claimed again meanwhile.
+func (r *AnalysisJobRepo) Succeed(ctx context.Context, jobID uint32, lockedAt time.Time, analysedResults json.RawMessage) (*AnalysisJob, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return nil, translate(err)
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+		INSERT INTO ai_analysis (assessment_id, assessment_data, analysed_results, created_at)
+		SELECT assessment_id, input, $2, NOW() FROM analysis_jobs
+		WHERE job_id = $1 AND status = 'running' AND locked_at = $3
+		RETURNING analysis_id
+	`
+	var analysisID uint32
+	if err := tx.QueryRow(ctx, query, jobID, analysedResults, lockedAt).Scan(&analysisID); err != nil {
+		if err = translate(err); errors.Is(err, ErrNotFound) {
+			return nil, ErrConflict
+		}
+		return nil, err
+	}
+
+	query = `
+		UPDATE analysis_jobs
+		SET status = 'succeeded', analysis_id = $2, locked_at = NULL, last_error = NULL,
+			finished_at = NOW(), updated_at = NOW()
+		WHERE job_id = $1
+		RETURNING ` + analysisJobColumns
+	job, err := scanAnalysisJob(tx.QueryRow(ctx, query, jobID, analysisID))
+	if err != nil {
+		return nil, err
+	}
+	return job, translate(tx.Commit(ctx))
+}
+
+// Fail records a failed attempt. The job is queued again retryIn from now, or failed for good
+// when retryIn is nil. ErrConflict means the job was claimed again meanwhile.
+func (r *AnalysisJobRepo) Fail(ctx context.Context, jobID uint32, lockedAt time.Time, message string, retryIn *time.Duration) error {
+	query := `
+		UPDATE analysis_jobs
+		SET status = CASE WHEN $3::float8 IS NULL THEN 'failed' ELSE 'queued' END,
+			run_after = COALESCE(NOW() + make_interval(secs => $3), run_after),
+			finished_at = CASE WHEN $3::float8 IS NULL THEN NOW() END,
+			last_error = $2, locked_at = NULL, updated_at = NOW()
+		WHERE job_id = $1 AND status = 'running' AND locked_at = $4
+	`
+	tag, err := r.pool.Exec(ctx, query, jobID, message, seconds(retryIn), lockedAt)
+	if err != nil {
+		return translate(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrConflict
+	}
+	return nil
+}
+
+// Requeue gives a failed job a fresh set of attempts, ErrConflict means it has not failed
+func (r *AnalysisJobRepo) Requeue(ctx context.Context, jobID uint32) (*AnalysisJob, error) {
+	query := `
+		UPDATE analysis_jobs
+		SET status = 'queued', attempts = 0, run_after = NOW(), finished_at = NULL, updated_at = NOW()
+		WHERE job_id = $1 AND status = 'failed'
+		RETURNING ` + analysisJobColumns
+	job, err := scanAnalysisJob(r.pool.QueryRow(ctx, query, jobID))
+	if errors.Is(err, ErrNotFound) {
+		if _, getErr := r.Get(ctx, jobID); getErr != nil {
+			return nil, getErr
+		}
+		return nil, ErrConflict
+	}
+	return job, err
+}
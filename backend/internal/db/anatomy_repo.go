@@ -0,0 +1,105 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Anatomy is a row of the anatomy table
+type Anatomy struct {
+	AnatomyID   uint32
+	Name        string
+	Description *string
+	Category    *string
+	Subcategory *string
+	CreatedAt   time.Time
+}
+
+// AnatomyRepo reads and writes the anatomy table
+type AnatomyRepo struct {
+	pool *pgxpool.Pool
+}
+
+const anatomyColumns = `anatomy_id, name, description, category, subcategory, created_at`
+
+func scanAnatomy(row pgx.Row) (*Anatomy, error) {
+	var a Anatomy
+	if err := row.Scan(&a.AnatomyID, &a.Name, &a.Description, &a.Category, &a.Subcategory, &a.CreatedAt); err != nil {
+		return nil, translate(err)
+	}
+	return &a, nil
+}
+
+// List returns every anatomy row ordered by id
+func (r *AnatomyRepo) List(ctx context.Context) ([]Anatomy, error) {
+	rows, err := r.pool.Query(ctx, `SELECT `+anatomyColumns+` FROM anatomy ORDER BY anatomy_id`)
+	if err != nil {
+		return nil, translate(err)
+	}
+	defer rows.Close()
+
+	var list []Anatomy
+	for rows.Next() {
+		a, err := scanAnatomy(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *a)
+	}
+	return list, translate(rows.Err())
+}
+
+// Get returns a single anatomy row
+func (r *AnatomyRepo) Get(ctx context.Context, anatomyID uint32) (*Anatomy, error) {
+	return scanAnatomy(r.pool.QueryRow(ctx, `SELECT `+anatomyColumns+` FROM anatomy WHERE anatomy_id = $1`, anatomyID))
+}
+
+// Create inserts an anatomy row and fills in its id and creation time
+func (r *AnatomyRepo) Create(ctx context.Context, a *Anatomy) error {
+	query := `
+		INSERT INTO anatomy (name, description, category, subcategory)
+		VALUES ($1, $2, $3, $4)
+		RETURNING anatomy_id, created_at
+	`
+	err := r.pool.QueryRow(ctx, query, a.Name, a.Description, a.Category, a.Subcategory).Scan(&a.AnatomyID, &a.CreatedAt)
+	return translate(err)
+}
+
+// Update overwrites the editable columns of an anatomy row
+func (r *AnatomyRepo) Update(ctx context.Context, a *Anatomy) error {
+	query := `
+		UPDATE anatomy
+		SET name = $1, description = $2, category = $3, subcategory = $4
+		WHERE anatomy_id = $5
+	`
+	tag, err := r.pool.Exec(ctx, query, a.Name, a.Description, a.Category, a.Subcategory, a.AnatomyID)
+	if err != nil {
+		return translate(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Delete removes an anatomy row, cascading to its assessments
+func (r *AnatomyRepo) Delete(ctx context.Context, anatomyID uint32) error {
+	tag, err := r.pool.Exec(ctx, `DELETE FROM anatomy WHERE anatomy_id = $1`, anatomyID)
+	if err != nil {
+		return translate(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// InUse reports whether any assessment references the anatomy row
+func (r *AnatomyRepo) InUse(ctx context.Context, anatomyID uint32) (bool, error) {
+	var used bool
+	err := r.pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM assessments WHERE anatomy_id = $1)`, anatomyID).Scan(&used)
+	return used, translate(err)
+}
@@ -0,0 +1,85 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Assessment event types
+const (
+	EventCreated        = "created"
+	EventStatusChanged  = "status_changed"
+	EventPhaseCompleted = "phase_completed"
+)
+
+// AssessmentEvent is a row of the assessment_events table
+type AssessmentEvent struct {
+	EventID      uint32
+	AssessmentID uint32
+	EventType    string
+	FromStatus   *string
+	ToStatus     *string
+	Phase        *string
+	Details      json.RawMessage
+	CreatedAt    time.Time
+}
+
+// StatusTransition is a status change guarded by the current status
+type StatusTransition struct {
+	From       string
+	To         string
+	Ended      bool     // sets end_time
+	Completion *float64 // replaces completion_percentage when set
+	Details    json.RawMessage
+	// IdleFor only applies the change while last_activity_at is at least this long ago
+	IdleFor *time.Duration
+}
+
+// AssessmentEventRepo reads the assessment_events table
+type AssessmentEventRepo struct {
+	pool *pgxpool.Pool
+}
+
+const assessmentEventColumns = `event_id, assessment_id, event_type, from_status, to_status, phase, details, created_at`
+
+func scanAssessmentEvent(row pgx.Row) (*AssessmentEvent, error) {
+	var e AssessmentEvent
+	err := row.Scan(&e.EventID, &e.AssessmentID, &e.EventType, &e.FromStatus, &e.ToStatus, &e.Phase, &e.Details, &e.CreatedAt)
+	if err != nil {
+		return nil, translate(err)
+	}
+	return &e, nil
+}
+
+// ListByAssessment returns the events of an assessment in the order they happened
+func (r *AssessmentEventRepo) ListByAssessment(ctx context.Context, assessmentID uint32) ([]AssessmentEvent, error) {
+	query := `SELECT ` + assessmentEventColumns + ` FROM assessment_events WHERE assessment_id = $1 ORDER BY created_at, event_id`
+	rows, err := r.pool.Query(ctx, query, assessmentID)
+	if err != nil {
+		return nil, translate(err)
+	}
+	defer rows.Close()
+
+	var events []AssessmentEvent
+	for rows.Next() {
+		e, err := scanAssessmentEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, *e)
+	}
+	return events, translate(rows.Err())
+}
+
+// insertAssessmentEvent adds an event inside a transaction
+func insertAssessmentEvent(ctx context.Context, tx pgx.Tx, e AssessmentEvent) (*AssessmentEvent, error) {
+	query := `
+		INSERT INTO assessment_events (assessment_id, event_type, from_status, to_status, phase, details)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING ` + assessmentEventColumns
+	return scanAssessmentEvent(tx.QueryRow(ctx, query, e.AssessmentID, e.EventType, e.FromStatus, e.ToStatus, e.Phase, e.Details))
+}
@@ -0,0 +1,260 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Assessment is a row of the assessments table
+type Assessment struct {
+	AssessmentID         uint32
+	UserID               uint32
+	AnatomyID            uint32
+	AssessmentType       string
+	StartTime            time.Time
+	EndTime              *time.Time
+	Status               string
+	CompletionPercentage float64
+	ChatHistory          json.RawMessage
+	PhysioID             *uint32
+	LastActivityAt       time.Time
+}
+
+// AssessmentRepo reads and writes the assessments table
+type AssessmentRepo struct {
+	pool *pgxpool.Pool
+}
+
+// Create inserts a new assessment with its creation event and returns the stored row
+func (r *AssessmentRepo) Create(ctx context.Context, userID, anatomyID uint32, assessmentType, status string) (*Assessment, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return nil, translate(err)
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+		INSERT INTO assessments (user_id, anatomy_id, assessment_type, start_time, status, completion_percentage)
+		VALUES ($1, $2, $3, NOW(), $4, 0)
+		RETURNING assessment_id, start_time, last_activity_at
+	`
+	assessment := Assessment{
+		UserID:         userID,
+		AnatomyID:      anatomyID,
+		AssessmentType: assessmentType,
+		Status:         status,
+	}
+	err = tx.QueryRow(ctx, query, userID, anatomyID, assessmentType, status).Scan(&assessment.AssessmentID, &assessment.StartTime, &assessment.LastActivityAt)
+	if err != nil {
+		return nil, translate(err)
+	}
+
+	_, err = insertAssessmentEvent(ctx, tx, AssessmentEvent{
+		AssessmentID: assessment.AssessmentID,
+		EventType:    EventCreated,
+		ToStatus:     &status,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &assessment, translate(tx.Commit(ctx))
+}
+
+const assessmentColumns = `assessment_id, user_id, anatomy_id, assessment_type, start_time, end_time, status, completion_percentage, chat_history, physio_id, last_activity_at`
+
+func scanAssessment(row pgx.Row) (*Assessment, error) {
+	var a Assessment
+	err := row.Scan(
+		&a.AssessmentID,
+		&a.UserID,
+		&a.AnatomyID,
+		&a.AssessmentType,
+		&a.StartTime,
+		&a.EndTime,
+		&a.Status,
+		&a.CompletionPercentage,
+		&a.ChatHistory,
+		&a.PhysioID,
+		&a.LastActivityAt,
+	)
+	if err != nil {
+		return nil, translate(err)
+	}
+	return &a, nil
+}
+
+// Get returns a single assessment
+func (r *AssessmentRepo) Get(ctx context.Context, assessmentID uint32) (*Assessment, error) {
+	return scanAssessment(r.pool.QueryRow(ctx, `SELECT `+assessmentColumns+` FROM assessments WHERE assessment_id = $1`, assessmentID))
+}
+
+// ListByPhysio returns the assessments assigned to a physiotherapist, newest first
+func (r *AssessmentRepo) ListByPhysio(ctx context.Context, physioID uint32) ([]Assessment, error) {
+	return r.list(ctx, `physio_id = $1`, physioID)
+}
+
+// ListByUser returns the assessments of a user, newest first
+func (r *AssessmentRepo) ListByUser(ctx context.Context, userID uint32) ([]Assessment, error) {
+	return r.list(ctx, `user_id = $1`, userID)
+}
+
+func (r *AssessmentRepo) list(ctx context.Context, where string, args ...any) ([]Assessment, error) {
+	rows, err := r.pool.Query(ctx, `SELECT `+assessmentColumns+` FROM assessments WHERE `+where+` ORDER BY start_time DESC, assessment_id DESC`, args...)
+	if err != nil {
+		return nil, translate(err)
+	}
+	defer rows.Close()
+
+	var list []Assessment
+	for rows.Next() {
+		a, err := scanAssessment(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *a)
+	}
+	return list, translate(rows.Err())
+}
+
+// AssignPhysio sets the physiotherapist of an assessment, nil clears it
+func (r *AssessmentRepo) AssignPhysio(ctx context.Context, assessmentID uint32, physioID *uint32) error {
+	tag, err := r.pool.Exec(ctx, `UPDATE assessments SET physio_id = $1 WHERE assessment_id = $2`, physioID, assessmentID)
+	if err != nil {
+		return translate(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Transition changes the status of an assessment if it is still t.From and records the
+// change, ErrConflict means the status moved on (or activity happened) since it was read
+func (r *AssessmentRepo) Transition(ctx context.Context, assessmentID uint32, t StatusTransition) (*AssessmentEvent, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return nil, translate(err)
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+		UPDATE assessments
+		SET status = $1,
+			end_time = CASE WHEN $2 THEN NOW() ELSE end_time END,
+			completion_percentage = COALESCE($3, completion_percentage)
+		WHERE assessment_id = $4 AND status = $5 AND ($6::float8 IS NULL OR last_activity_at < NOW() - make_interval(secs => $6))
+	`
+	tag, err := tx.Exec(ctx, query, t.To, t.Ended, t.Completion, assessmentID, t.From, seconds(t.IdleFor))
+	if err != nil {
+		return nil, translate(err)
+	}
+	if tag.RowsAffected() == 0 {
+		var exists bool
+		if err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM assessments WHERE assessment_id = $1)`, assessmentID).Scan(&exists); err != nil {
+			return nil, translate(err)
+		}
+		if !exists {
+			return nil, ErrNotFound
+		}
+		return nil, ErrConflict
+	}
+
+	event, err := insertAssessmentEvent(ctx, tx, AssessmentEvent{
+		AssessmentID: assessmentID,
+		EventType:    EventStatusChanged,
+		FromStatus:   &t.From,
+		ToStatus:     &t.To,
+		Details:      t.Details,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return event, translate(tx.Commit(ctx))
+}
+
+// RecordPhase records a finished phase and raises completion_percentage to at least completion.
+// A phase is recorded once, repeating it returns a nil event.
+func (r *AssessmentRepo) RecordPhase(ctx context.Context, assessmentID uint32, phase string, completion float64) (*AssessmentEvent, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return nil, translate(err)
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+		INSERT INTO assessment_events (assessment_id, event_type, phase)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (assessment_id, phase) WHERE event_type = 'phase_completed' DO NOTHING
+		RETURNING ` + assessmentEventColumns
+	event, err := scanAssessmentEvent(tx.QueryRow(ctx, query, assessmentID, EventPhaseCompleted, phase))
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		if errors.Is(err, ErrForeignKeyViolation) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	_, err = tx.Exec(ctx, `UPDATE assessments SET completion_percentage = GREATEST(completion_percentage, $1) WHERE assessment_id = $2`, completion, assessmentID)
+	if err != nil {
+		return nil, translate(err)
+	}
+	return event, translate(tx.Commit(ctx))
+}
+
+// SaveChatHistory stores the BPI chat history of an assessment
+func (r *AssessmentRepo) SaveChatHistory(ctx context.Context, assessmentID uint32, chatHistory json.RawMessage) error {
+	tag, err := r.pool.Exec(ctx, `UPDATE assessments SET chat_history = $1 WHERE assessment_id = $2`, chatHistory, assessmentID)
+	if err != nil {
+		return translate(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Touch records user activity on an assessment
+func (r *AssessmentRepo) Touch(ctx context.Context, assessmentID uint32) error {
+	tag, err := r.pool.Exec(ctx, `UPDATE assessments SET last_activity_at = NOW() WHERE assessment_id = $1`, assessmentID)
+	if err != nil {
+		return translate(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ListIdle returns open assessments without activity for longer than idleFor, oldest activity
+// first. The cutoff is taken from the database clock, the one that wrote last_activity_at.
+func (r *AssessmentRepo) ListIdle(ctx context.Context, idleFor time.Duration, limit int) ([]Assessment, error) {
+	query := `
+		SELECT ` + assessmentColumns + ` FROM assessments
+		WHERE status IN ('started', 'in_progress') AND last_activity_at < NOW() - make_interval(secs => $1)
+		ORDER BY last_activity_at, assessment_id
+		LIMIT $2
+	`
+	rows, err := r.pool.Query(ctx, query, idleFor.Seconds(), limit)
+	if err != nil {
+		return nil, translate(err)
+	}
+	defer rows.Close()
+
+	var list []Assessment
+	for rows.Next() {
+		a, err := scanAssessment(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *a)
+	}
+	return list, translate(rows.Err())
+}
@@ -0,0 +1,89 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// CalendarToken is a row of the calendar_feed_tokens table
+type CalendarToken struct {
+	TokenID    uint32
+	UserID     uint32
+	TokenHash  string // hex SHA-256 of the token
+	Label      *string
+	CreatedAt  time.Time
+	LastUsedAt *time.Time
+	RevokedAt  *time.Time
+}
+
+// CalendarTokenRepo reads and writes the calendar_feed_tokens table
+type CalendarTokenRepo struct {
+	pool *pgxpool.Pool
+}
+
+const calendarTokenColumns = `token_id, user_id, token_hash, label, created_at, last_used_at, revoked_at`
+
+func scanCalendarToken(row pgx.Row) (*CalendarToken, error) {
+	var t CalendarToken
+	err := row.Scan(&t.TokenID, &t.UserID, &t.TokenHash, &t.Label, &t.CreatedAt, &t.LastUsedAt, &t.RevokedAt)
+	if err != nil {
+		return nil, translate(err)
+	}
+	return &t, nil
+}
+
+// Create inserts a token and fills in its id and creation time
+func (r *CalendarTokenRepo) Create(ctx context.Context, token *CalendarToken) error {
+	query := `
+		INSERT INTO calendar_feed_tokens (user_id, token_hash, label)
+		VALUES ($1, $2, $3)
+		RETURNING token_id, created_at
+	`
+	return translate(r.pool.QueryRow(ctx, query, token.UserID, token.TokenHash, token.Label).Scan(&token.TokenID, &token.CreatedAt))
+}
+
+// ListByUser returns the tokens of a user, revoked ones included, newest first
+func (r *CalendarTokenRepo) ListByUser(ctx context.Context, userID uint32) ([]CalendarToken, error) {
+	rows, err := r.pool.Query(ctx, `SELECT `+calendarTokenColumns+` FROM calendar_feed_tokens
+		WHERE user_id = $1
+		ORDER BY created_at DESC, token_id DESC`, userID)
+	if err != nil {
+		return nil, translate(err)
+	}
+	defer rows.Close()
+
+	var tokens []CalendarToken
+	for rows.Next() {
+		t, err := scanCalendarToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, *t)
+	}
+	return tokens, translate(rows.Err())
+}
+
+// Use looks up a live token by hash and records that it was used. Revoked tokens are not found.
+func (r *CalendarTokenRepo) Use(ctx context.Context, tokenHash string) (*CalendarToken, error) {
+	query := `
+		UPDATE calendar_feed_tokens SET last_used_at = NOW()
+		WHERE token_hash = $1 AND revoked_at IS NULL
+		RETURNING ` + calendarTokenColumns
+	return scanCalendarToken(r.pool.QueryRow(ctx, query, tokenHash))
+}
+
+// Revoke stops a token of a user, revoking twice is a no-op
+func (r *CalendarTokenRepo) Revoke(ctx context.Context, userID, tokenID uint32) error {
+	tag, err := r.pool.Exec(ctx, `UPDATE calendar_feed_tokens SET revoked_at = COALESCE(revoked_at, NOW())
+		WHERE token_id = $1 AND user_id = $2`, tokenID, userID)
+	if err != nil {
+		return translate(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Chat message roles
+const (
+	ChatRoleUser      = "user"
+	ChatRoleAssistant = "assistant"
+)
+
+// ChatMessage is a row of the chat_messages table
+type ChatMessage struct {
+	MessageID    uint32
+	AssessmentID uint32
+	Role         string
+	Content      string
+	Action       *string
+	LatencyMs    *int32
+	CreatedAt    time.Time
+}
+
+// ChatMessageRepo reads and writes the chat_messages table
+type ChatMessageRepo struct {
+	pool *pgxpool.Pool
+}
+
+const chatMessageColumns = `message_id, assessment_id, role, content, action, latency_ms, created_at`
+
+func scanChatMessage(row pgx.Row) (*ChatMessage, error) {
+	var m ChatMessage
+	err := row.Scan(&m.MessageID, &m.AssessmentID, &m.Role, &m.Content, &m.Action, &m.LatencyMs, &m.CreatedAt)
+	if err != nil {
+		return nil, translate(err)
+	}
+	return &m, nil
+}
+
+// Append stores the messages of one turn in a single transaction and returns the stored rows
+func (r *ChatMessageRepo) Append(ctx context.Context, assessmentID uint32, messages []ChatMessage) ([]ChatMessage, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return nil, translate(err)
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+		INSERT INTO chat_messages (assessment_id, role, content, action, latency_ms)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING ` + chatMessageColumns
+	stored := make([]ChatMessage, 0, len(messages))
+	for _, m := range messages {
+		row, err := scanChatMessage(tx.QueryRow(ctx, query, assessmentID, m.Role, m.Content, m.Action, m.LatencyMs))
+		if err != nil {
+			return nil, err
+		}
+		stored = append(stored, *row)
+	}
+	return stored, translate(tx.Commit(ctx))
+}
+
+// ListByAssessment returns the chat of an assessment in the order it was written
+func (r *ChatMessageRepo) ListByAssessment(ctx context.Context, assessmentID uint32) ([]ChatMessage, error) {
+	query := `SELECT ` + chatMessageColumns + ` FROM chat_messages WHERE assessment_id = $1 ORDER BY message_id`
+	rows, err := r.pool.Query(ctx, query, assessmentID)
+	if err != nil {
+		return nil, translate(err)
+	}
+	defer rows.Close()
+
+	var messages []ChatMessage
+	for rows.Next() {
+		m, err := scanChatMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, *m)
+	}
+	return messages, translate(rows.Err())
+}
@@ -0,0 +1,82 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PoolConfig holds the pool sizing and startup retry settings
+type PoolConfig struct {
+	MaxConns          int32
+	MinConns          int32
+	MaxConnLifetime   time.Duration
+	MaxConnIdleTime   time.Duration
+	HealthCheckPeriod time.Duration
+	ConnectRetries    int
+	ConnectBackoff    time.Duration
+}
+
+// Connect opens a pool and retries with exponential backoff until the database answers
+func Connect(ctx context.Context, databaseURL string, cfg PoolConfig) (*pgxpool.Pool, error) {
+	if databaseURL == "" {
+		return nil, errors.New("DATABASE_URL is not set")
+	}
+
+	poolConfig, err := pgxpool.ParseConfig(databaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse connection string: %w", err)
+	}
+	poolConfig.MaxConns = cfg.MaxConns
+	poolConfig.MinConns = cfg.MinConns
+	poolConfig.MaxConnLifetime = cfg.MaxConnLifetime
+	poolConfig.MaxConnIdleTime = cfg.MaxConnIdleTime
+	poolConfig.HealthCheckPeriod = cfg.HealthCheckPeriod
+
+	backoff := cfg.ConnectBackoff
+	for attempt := 0; ; attempt++ {
+		pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
+		if err == nil {
+			err = pool.Ping(ctx)
+			if err == nil {
+				return pool, nil
+			}
+			pool.Close()
+		}
+
+		if attempt >= cfg.ConnectRetries {
+			return nil, fmt.Errorf("database not reachable after %d attempts: %w", attempt+1, err)
+		}
+		log.Printf("Database not ready (attempt %d/%d): %v, retrying in %s", attempt+1, cfg.ConnectRetries+1, err, backoff)
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// PostgresVersion logs the version of the connected server
+func PostgresVersion(ctx context.Context, pool *pgxpool.Pool) {
+	var version string
+	if err := pool.QueryRow(ctx, "SELECT version()").Scan(&version); err != nil {
+		log.Printf("Failed to fetch Postgres version: %v", err)
+		return
+	}
+	log.Println("Postgres version:", version)
+}
+
+// seconds passes an optional delay to make_interval, nil stays NULL
+func seconds(d *time.Duration) *float64 {
+	if d == nil {
+		return nil
+	}
+	s := d.Seconds()
+	return &s
+}
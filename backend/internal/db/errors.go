@@ -0,0 +1,70 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Errors shared by every storage backend so callers do not depend on driver error codes
+var (
+	ErrNotFound            = errors.New("record not found")
+	ErrForeignKeyViolation = errors.New("foreign key violation")
+	ErrUniqueViolation     = errors.New("unique violation")
+	ErrCheckViolation      = errors.New("check constraint violation")
+	ErrNotNullViolation    = errors.New("not null violation")
+	ErrInvalidJSON         = errors.New("invalid json")
+	ErrConflict            = errors.New("row changed concurrently")
+	ErrOverlap             = errors.New("time slot overlaps another booking")
+)
+
+// ConstraintError is a violation of a named constraint, or of the NOT NULL of a column. It
+// matches its kind with errors.Is, e.g. errors.Is(err, ErrUniqueViolation).
+type ConstraintError struct {
+	Kind error
+	Name string
+}
+
+func (e *ConstraintError) Error() string { return e.Kind.Error() + ": " + e.Name }
+func (e *ConstraintError) Unwrap() error { return e.Kind }
+
+// Violation returns a violation of kind on the constraint or column name
+func Violation(kind error, name string) error {
+	return &ConstraintError{Kind: kind, Name: name}
+}
+
+// Violates reports whether err is a violation of the named constraint
+func Violates(err error, name string) bool {
+	var c *ConstraintError
+	return errors.As(err, &c) && c.Name == name
+}
+
+// translate maps pgx errors to the shared storage errors
+func translate(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrNotFound
+	}
+
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return err
+	}
+	switch pgErr.Code {
+	case "23503":
+		return Violation(ErrForeignKeyViolation, pgErr.ConstraintName)
+	case "23505":
+		return Violation(ErrUniqueViolation, pgErr.ConstraintName)
+	case "23514":
+		return Violation(ErrCheckViolation, pgErr.ConstraintName)
+	case "23502":
+		return Violation(ErrNotNullViolation, pgErr.ColumnName)
+	case "22P02":
+		return fmt.Errorf("%w: %s", ErrInvalidJSON, pgErr.Message)
+	}
+	return err
+}
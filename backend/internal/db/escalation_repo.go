@@ -0,0 +1,78 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Escalation is a row of the red_flag_escalations table
+type Escalation struct {
+	EscalationID uint32
+	AssessmentID uint32
+	RulesVersion string
+	Matches      json.RawMessage
+	CallID       *uint32 // immediate call booked for the escalation
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+// EscalationRepo reads and writes the red_flag_escalations table
+type EscalationRepo struct {
+	pool *pgxpool.Pool
+}
+
+const escalationColumns = `escalation_id, assessment_id, rules_version, matches, call_id, created_at, updated_at`
+
+func scanEscalation(row pgx.Row) (*Escalation, error) {
+	var e Escalation
+	err := row.Scan(&e.EscalationID, &e.AssessmentID, &e.RulesVersion, &e.Matches, &e.CallID, &e.CreatedAt, &e.UpdatedAt)
+	if err != nil {
+		return nil, translate(err)
+	}
+	return &e, nil
+}
+
+// Record stores the escalation of an assessment, or refreshes the rules version and matches
+// of the existing one, and fills in the row. The booked call of an existing row is kept.
+func (r *EscalationRepo) Record(ctx context.Context, e *Escalation) error {
+	query := `
+		INSERT INTO red_flag_escalations (assessment_id, rules_version, matches)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (assessment_id) DO UPDATE
+		SET rules_version = EXCLUDED.rules_version, matches = EXCLUDED.matches, updated_at = NOW()
+		RETURNING ` + escalationColumns
+	row, err := scanEscalation(r.pool.QueryRow(ctx, query, e.AssessmentID, e.RulesVersion, e.Matches))
+	if err != nil {
+		return err
+	}
+	*e = *row
+	return nil
+}
+
+// GetByAssessment returns the escalation of an assessment
+func (r *EscalationRepo) GetByAssessment(ctx context.Context, assessmentID uint32) (*Escalation, error) {
+	query := `SELECT ` + escalationColumns + ` FROM red_flag_escalations WHERE assessment_id = $1`
+	return scanEscalation(r.pool.QueryRow(ctx, query, assessmentID))
+}
+
+// SetCall links the call booked for an escalation. ErrConflict means another call was
+// linked meanwhile.
+func (r *EscalationRepo) SetCall(ctx context.Context, escalationID, callID uint32) error {
+	query := `
+		UPDATE red_flag_escalations
+		SET call_id = $2, updated_at = NOW()
+		WHERE escalation_id = $1 AND call_id IS NULL
+	`
+	tag, err := r.pool.Exec(ctx, query, escalationID, callID)
+	if err != nil {
+		return translate(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrConflict
+	}
+	return nil
+}
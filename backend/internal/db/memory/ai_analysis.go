@@ -0,0 +1,61 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"ai-bot-deecogs/internal/db"
+)
+
+type aiAnalysisRepo struct{ b *Backend }
+
+func (r *aiAnalysisRepo) Create(ctx context.Context, assessmentID uint32, assessmentData, analysedResults json.RawMessage) (uint32, error) {
+	r.b.mu.Lock()
+	defer r.b.mu.Unlock()
+	if err := r.b.requireAssessment(assessmentID, "ai_analysis_assessment_id_fkey"); err != nil {
+		return 0, err
+	}
+	if err := checkJSON(assessmentData, "assessment_data", false); err != nil {
+		return 0, err
+	}
+	if err := checkJSON(analysedResults, "analysed_results", false); err != nil {
+		return 0, err
+	}
+	row := &db.AIAnalysis{
+		AssessmentID:    assessmentID,
+		AssessmentData:  cloneJSON(assessmentData),
+		AnalysedResults: cloneJSON(analysedResults),
+		CreatedAt:       r.b.now(),
+	}
+	row.AnalysisID = r.b.aiAnalysis.insert(0, row)
+	return row.AnalysisID, nil
+}
+
+func (r *aiAnalysisRepo) Get(ctx context.Context, analysisID uint32) (*db.AIAnalysis, error) {
+	r.b.mu.RLock()
+	defer r.b.mu.RUnlock()
+	row, ok := r.b.aiAnalysis.rows[analysisID]
+	if !ok {
+		return nil, db.ErrNotFound
+	}
+	out := *row
+	out.AssessmentData = cloneJSON(row.AssessmentData)
+	out.AnalysedResults = cloneJSON(row.AnalysedResults)
+	return &out, nil
+}
+
+func (r *aiAnalysisRepo) Latest(ctx context.Context, assessmentID uint32) (*db.AIAnalysis, error) {
+	r.b.mu.RLock()
+	defer r.b.mu.RUnlock()
+	_, row := latest(r.b.aiAnalysis.rows,
+		func(a *db.AIAnalysis) bool { return a.AssessmentID == assessmentID },
+		func(a *db.AIAnalysis) time.Time { return a.CreatedAt })
+	if row == nil {
+		return nil, db.ErrNotFound
+	}
+	out := *row
+	out.AssessmentData = cloneJSON(row.AssessmentData)
+	out.AnalysedResults = cloneJSON(row.AnalysedResults)
+	return &out, nil
+}
@@ -0,0 +1,197 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"ai-bot-deecogs/internal/db"
+)
+
+type analysisJobRepo struct{ b *Backend }
+
+// copyJob returns a row that does not alias the stored one
+func copyJob(j *db.AnalysisJob) *db.AnalysisJob {
+	out := *j
+	out.Input = cloneJSON(j.Input)
+	if j.LockedAt != nil {
+		out.LockedAt = ptr(*j.LockedAt)
+	}
+	if j.LastError != nil {
+		out.LastError = ptr(*j.LastError)
+	}
+	if j.AnalysisID != nil {
+		out.AnalysisID = ptr(*j.AnalysisID)
+	}
+	if j.FinishedAt != nil {
+		out.FinishedAt = ptr(*j.FinishedAt)
+	}
+	return &out
+}
+
+func (r *analysisJobRepo) Enqueue(ctx context.Context, assessmentID uint32, revision string, input json.RawMessage, maxAttempts int) (*db.AnalysisJob, bool, error) {
+	r.b.mu.Lock()
+	defer r.b.mu.Unlock()
+
+	if err := r.b.requireAssessment(assessmentID, "analysis_jobs_assessment_id_fkey"); err != nil {
+		return nil, false, err
+	}
+	if err := checkJSON(input, "input", false); err != nil {
+		return nil, false, err
+	}
+	if maxAttempts < 1 {
+		return nil, false, db.Violation(db.ErrCheckViolation, "analysis_jobs_max_attempts_check")
+	}
+	for _, j := range r.b.analysisJobs.rows {
+		if j.AssessmentID == assessmentID && j.Revision == revision {
+			return copyJob(j), false, nil
+		}
+	}
+
+	now := r.b.now()
+	row := &db.AnalysisJob{
+		AssessmentID: assessmentID,
+		Revision:     revision,
+		Input:        cloneJSON(input),
+		Status:       db.JobQueued,
+		MaxAttempts:  maxAttempts,
+		RunAfter:     now,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	row.JobID = r.b.analysisJobs.insert(0, row)
+	return copyJob(row), true, nil
+}
+
+func (r *analysisJobRepo) Get(ctx context.Context, jobID uint32) (*db.AnalysisJob, error) {
+	r.b.mu.RLock()
+	defer r.b.mu.RUnlock()
+	j, ok := r.b.analysisJobs.rows[jobID]
+	if !ok {
+		return nil, db.ErrNotFound
+	}
+	return copyJob(j), nil
+}
+
+func (r *analysisJobRepo) Latest(ctx context.Context, assessmentID uint32) (*db.AnalysisJob, error) {
+	r.b.mu.RLock()
+	defer r.b.mu.RUnlock()
+	var best *db.AnalysisJob
+	for _, j := range r.b.analysisJobs.rows {
+		if j.AssessmentID == assessmentID && (best == nil || j.JobID > best.JobID) {
+			best = j
+		}
+	}
+	if best == nil {
+		return nil, db.ErrNotFound
+	}
+	return copyJob(best), nil
+}
+
+func (r *analysisJobRepo) Claim(ctx context.Context, lease time.Duration) (*db.AnalysisJob, error) {
+	r.b.mu.Lock()
+	defer r.b.mu.Unlock()
+
+	now := r.b.now()
+	staleBefore := now.Add(-lease)
+	var next *db.AnalysisJob
+	for _, j := range r.b.analysisJobs.rows {
+		due := (j.Status == db.JobQueued && !j.RunAfter.After(now)) ||
+			(j.Status == db.JobRunning && j.LockedAt != nil && j.LockedAt.Before(staleBefore))
+		if !due {
+			continue
+		}
+		if next == nil || j.RunAfter.Before(next.RunAfter) || (j.RunAfter.Equal(next.RunAfter) && j.JobID < next.JobID) {
+			next = j
+		}
+	}
+	if next == nil {
+		return nil, db.ErrNotFound
+	}
+	next.Status = db.JobRunning
+	next.Attempts++
+	next.LockedAt = ptr(now)
+	next.UpdatedAt = now
+	return copyJob(next), nil
+}
+
+// claimed returns a job still held by the claim made at lockedAt, the caller holds the lock
+func (r *analysisJobRepo) claimed(jobID uint32, lockedAt time.Time) (*db.AnalysisJob, error) {
+	j, ok := r.b.analysisJobs.rows[jobID]
+	if !ok || j.Status != db.JobRunning || j.LockedAt == nil || !j.LockedAt.Equal(lockedAt) {
+		return nil, db.ErrConflict
+	}
+	return j, nil
+}
+
+func (r *analysisJobRepo) Succeed(ctx context.Context, jobID uint32, lockedAt time.Time, analysedResults json.RawMessage) (*db.AnalysisJob, error) {
+	r.b.mu.Lock()
+	defer r.b.mu.Unlock()
+
+	j, err := r.claimed(jobID, lockedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkJSON(analysedResults, "analysed_results", false); err != nil {
+		return nil, err
+	}
+	now := r.b.now()
+	analysisID := r.b.aiAnalysis.insert(0, &db.AIAnalysis{
+		AssessmentID:    j.AssessmentID,
+		AssessmentData:  cloneJSON(j.Input),
+		AnalysedResults: cloneJSON(analysedResults),
+		CreatedAt:       now,
+	})
+	r.b.aiAnalysis.rows[analysisID].AnalysisID = analysisID
+
+	j.Status = db.JobSucceeded
+	j.AnalysisID = ptr(analysisID)
+	j.LockedAt = nil
+	j.LastError = nil
+	j.FinishedAt = ptr(now)
+	j.UpdatedAt = now
+	return copyJob(j), nil
+}
+
+func (r *analysisJobRepo) Fail(ctx context.Context, jobID uint32, lockedAt time.Time, message string, retryIn *time.Duration) error {
+	r.b.mu.Lock()
+	defer r.b.mu.Unlock()
+
+	j, err := r.claimed(jobID, lockedAt)
+	if err != nil {
+		return err
+	}
+	now := r.b.now()
+	if retryIn == nil {
+		j.Status = db.JobFailed
+		j.FinishedAt = ptr(now)
+	} else {
+		j.Status = db.JobQueued
+		j.RunAfter = now.Add(*retryIn)
+		j.FinishedAt = nil
+	}
+	j.LastError = ptr(message)
+	j.LockedAt = nil
+	j.UpdatedAt = now
+	return nil
+}
+
+func (r *analysisJobRepo) Requeue(ctx context.Context, jobID uint32) (*db.AnalysisJob, error) {
+	r.b.mu.Lock()
+	defer r.b.mu.Unlock()
+
+	j, ok := r.b.analysisJobs.rows[jobID]
+	if !ok {
+		return nil, db.ErrNotFound
+	}
+	if j.Status != db.JobFailed {
+		return nil, db.ErrConflict
+	}
+	now := r.b.now()
+	j.Status = db.JobQueued
+	j.Attempts = 0
+	j.RunAfter = now
+	j.FinishedAt = nil
+	j.UpdatedAt = now
+	return copyJob(j), nil
+}
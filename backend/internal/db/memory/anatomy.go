@@ -0,0 +1,115 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"ai-bot-deecogs/internal/db"
+)
+
+type anatomyRepo struct{ b *Backend }
+
+func cloneAnatomy(a *db.Anatomy) db.Anatomy {
+	out := *a
+	if a.Description != nil {
+		out.Description = ptr(*a.Description)
+	}
+	if a.Category != nil {
+		out.Category = ptr(*a.Category)
+	}
+	if a.Subcategory != nil {
+		out.Subcategory = ptr(*a.Subcategory)
+	}
+	return out
+}
+
+func (r *anatomyRepo) List(ctx context.Context) ([]db.Anatomy, error) {
+	r.b.mu.RLock()
+	defer r.b.mu.RUnlock()
+	list := make([]db.Anatomy, 0, len(r.b.anatomy.rows))
+	for _, a := range r.b.anatomy.rows {
+		list = append(list, cloneAnatomy(a))
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].AnatomyID < list[j].AnatomyID })
+	return list, nil
+}
+
+func (r *anatomyRepo) Get(ctx context.Context, anatomyID uint32) (*db.Anatomy, error) {
+	r.b.mu.RLock()
+	defer r.b.mu.RUnlock()
+	a, ok := r.b.anatomy.rows[anatomyID]
+	if !ok {
+		return nil, db.ErrNotFound
+	}
+	out := cloneAnatomy(a)
+	return &out, nil
+}
+
+func (r *anatomyRepo) Create(ctx context.Context, anatomy *db.Anatomy) error {
+	row := cloneAnatomy(anatomy)
+	row.AnatomyID = 0
+	row.CreatedAt = time.Time{}
+	id, err := r.b.AddAnatomy(row)
+	if err != nil {
+		return err
+	}
+	created, _ := r.Get(ctx, id)
+	anatomy.AnatomyID = created.AnatomyID
+	anatomy.CreatedAt = created.CreatedAt
+	return nil
+}
+
+func (r *anatomyRepo) Update(ctx context.Context, anatomy *db.Anatomy) error {
+	r.b.mu.Lock()
+	defer r.b.mu.Unlock()
+	row, ok := r.b.anatomy.rows[anatomy.AnatomyID]
+	if !ok {
+		return db.ErrNotFound
+	}
+	for id, a := range r.b.anatomy.rows {
+		if id != anatomy.AnatomyID && a.Name == anatomy.Name {
+			return db.Violation(db.ErrUniqueViolation, "anatomy_name_key")
+		}
+	}
+	updated := cloneAnatomy(anatomy)
+	updated.CreatedAt = row.CreatedAt
+	*row = updated
+	return nil
+}
+
+func (r *anatomyRepo) Delete(ctx context.Context, anatomyID uint32) error {
+	r.b.mu.Lock()
+	defer r.b.mu.Unlock()
+	if _, ok := r.b.anatomy.rows[anatomyID]; !ok {
+		return db.ErrNotFound
+	}
+	delete(r.b.anatomy.rows, anatomyID)
+	for id, m := range r.b.romMeasurements.rows {
+		if m.AnatomyID == anatomyID {
+			delete(r.b.romMeasurements.rows, id)
+		}
+	}
+	for id, n := range r.b.romNorms.rows {
+		if n.AnatomyID == anatomyID {
+			delete(r.b.romNorms.rows, id)
+		}
+	}
+	for id, a := range r.b.assessments.rows {
+		if a.AnatomyID == anatomyID {
+			r.b.deleteAssessment(id)
+		}
+	}
+	return nil
+}
+
+func (r *anatomyRepo) InUse(ctx context.Context, anatomyID uint32) (bool, error) {
+	r.b.mu.RLock()
+	defer r.b.mu.RUnlock()
+	for _, a := range r.b.assessments.rows {
+		if a.AnatomyID == anatomyID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
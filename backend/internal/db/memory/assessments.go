@@ -0,0 +1,226 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"ai-bot-deecogs/internal/db"
+)
+
+type assessmentRepo struct{ b *Backend }
+
+func (r *assessmentRepo) Create(ctx context.Context, userID, anatomyID uint32, assessmentType, status string) (*db.Assessment, error) {
+	r.b.mu.Lock()
+	defer r.b.mu.Unlock()
+
+	if err := checkIn(assessmentType, assessmentTypes, "assessments_assessment_type_check"); err != nil {
+		return nil, err
+	}
+	if err := checkIn(status, assessmentStatus, "assessments_status_check"); err != nil {
+		return nil, err
+	}
+	if _, ok := r.b.users.rows[userID]; !ok {
+		return nil, db.Violation(db.ErrForeignKeyViolation, "assessments_user_id_fkey")
+	}
+	if _, ok := r.b.anatomy.rows[anatomyID]; !ok {
+		return nil, db.Violation(db.ErrForeignKeyViolation, "assessments_anatomy_id_fkey")
+	}
+
+	row := &db.Assessment{
+		UserID:         userID,
+		AnatomyID:      anatomyID,
+		AssessmentType: assessmentType,
+		StartTime:      r.b.now(),
+		Status:         status,
+	}
+	row.LastActivityAt = row.StartTime
+	row.AssessmentID = r.b.assessments.insert(0, row)
+	r.b.addEvent(db.AssessmentEvent{AssessmentID: row.AssessmentID, EventType: db.EventCreated, ToStatus: ptr(status)})
+	out := *row
+	return &out, nil
+}
+
+func (r *assessmentRepo) Get(ctx context.Context, assessmentID uint32) (*db.Assessment, error) {
+	r.b.mu.RLock()
+	defer r.b.mu.RUnlock()
+	row, ok := r.b.assessments.rows[assessmentID]
+	if !ok {
+		return nil, db.ErrNotFound
+	}
+	out := *row
+	out.ChatHistory = cloneJSON(row.ChatHistory)
+	if row.PhysioID != nil {
+		out.PhysioID = ptr(*row.PhysioID)
+	}
+	return &out, nil
+}
+
+func (r *assessmentRepo) Transition(ctx context.Context, assessmentID uint32, t db.StatusTransition) (*db.AssessmentEvent, error) {
+	r.b.mu.Lock()
+	defer r.b.mu.Unlock()
+	row, ok := r.b.assessments.rows[assessmentID]
+	if !ok {
+		return nil, db.ErrNotFound
+	}
+	if row.Status != t.From || (t.IdleFor != nil && !row.LastActivityAt.Before(r.b.now().Add(-*t.IdleFor))) {
+		return nil, db.ErrConflict
+	}
+	if err := checkIn(t.To, assessmentStatus, "assessments_status_check"); err != nil {
+		return nil, err
+	}
+	if err := checkJSON(t.Details, "details", true); err != nil {
+		return nil, err
+	}
+	if t.Completion != nil && (*t.Completion < 0 || *t.Completion > 100) {
+		return nil, db.Violation(db.ErrCheckViolation, "assessments_completion_percentage_check")
+	}
+
+	row.Status = t.To
+	if t.Ended {
+		end := r.b.now()
+		row.EndTime = &end
+	}
+	if t.Completion != nil {
+		row.CompletionPercentage = *t.Completion
+	}
+	event := r.b.addEvent(db.AssessmentEvent{
+		AssessmentID: assessmentID,
+		EventType:    db.EventStatusChanged,
+		FromStatus:   ptr(t.From),
+		ToStatus:     ptr(t.To),
+		Details:      cloneJSON(t.Details),
+	})
+	return event, nil
+}
+
+func (r *assessmentRepo) RecordPhase(ctx context.Context, assessmentID uint32, phase string, completion float64) (*db.AssessmentEvent, error) {
+	r.b.mu.Lock()
+	defer r.b.mu.Unlock()
+	row, ok := r.b.assessments.rows[assessmentID]
+	if !ok {
+		return nil, db.ErrNotFound
+	}
+	if err := checkIn(phase, assessmentPhases, "assessment_events_phase_check"); err != nil {
+		return nil, err
+	}
+	for _, e := range r.b.events.rows {
+		if e.AssessmentID == assessmentID && e.EventType == db.EventPhaseCompleted && *e.Phase == phase {
+			return nil, nil
+		}
+	}
+	if completion > row.CompletionPercentage {
+		row.CompletionPercentage = completion
+	}
+	event := r.b.addEvent(db.AssessmentEvent{
+		AssessmentID: assessmentID,
+		EventType:    db.EventPhaseCompleted,
+		Phase:        ptr(phase),
+	})
+	return event, nil
+}
+
+func (r *assessmentRepo) SaveChatHistory(ctx context.Context, assessmentID uint32, chatHistory json.RawMessage) error {
+	r.b.mu.Lock()
+	defer r.b.mu.Unlock()
+	row, ok := r.b.assessments.rows[assessmentID]
+	if !ok {
+		return db.ErrNotFound
+	}
+	if err := checkJSON(chatHistory, "chat_history", true); err != nil {
+		return err
+	}
+	row.ChatHistory = cloneJSON(chatHistory)
+	return nil
+}
+
+func (r *assessmentRepo) ListByPhysio(ctx context.Context, physioID uint32) ([]db.Assessment, error) {
+	return r.list(func(a *db.Assessment) bool { return a.PhysioID != nil && *a.PhysioID == physioID }), nil
+}
+
+func (r *assessmentRepo) ListByUser(ctx context.Context, userID uint32) ([]db.Assessment, error) {
+	return r.list(func(a *db.Assessment) bool { return a.UserID == userID }), nil
+}
+
+// list returns the matching assessments, newest first
+func (r *assessmentRepo) list(match func(*db.Assessment) bool) []db.Assessment {
+	r.b.mu.RLock()
+	defer r.b.mu.RUnlock()
+	var list []db.Assessment
+	for _, row := range r.b.assessments.rows {
+		if !match(row) {
+			continue
+		}
+		out := *row
+		out.ChatHistory = cloneJSON(row.ChatHistory)
+		if row.PhysioID != nil {
+			out.PhysioID = ptr(*row.PhysioID)
+		}
+		list = append(list, out)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].StartTime.Equal(list[j].StartTime) {
+			return list[i].StartTime.After(list[j].StartTime)
+		}
+		return list[i].AssessmentID > list[j].AssessmentID
+	})
+	return list
+}
+
+func (r *assessmentRepo) AssignPhysio(ctx context.Context, assessmentID uint32, physioID *uint32) error {
+	r.b.mu.Lock()
+	defer r.b.mu.Unlock()
+	row, ok := r.b.assessments.rows[assessmentID]
+	if !ok {
+		return db.ErrNotFound
+	}
+	if physioID == nil {
+		row.PhysioID = nil
+		return nil
+	}
+	if _, ok := r.b.users.rows[*physioID]; !ok {
+		return db.Violation(db.ErrForeignKeyViolation, "assessments_physio_id_fkey")
+	}
+	row.PhysioID = ptr(*physioID)
+	return nil
+}
+
+func (r *assessmentRepo) Touch(ctx context.Context, assessmentID uint32) error {
+	r.b.mu.Lock()
+	defer r.b.mu.Unlock()
+	row, ok := r.b.assessments.rows[assessmentID]
+	if !ok {
+		return db.ErrNotFound
+	}
+	row.LastActivityAt = r.b.now()
+	return nil
+}
+
+func (r *assessmentRepo) ListIdle(ctx context.Context, idleFor time.Duration, limit int) ([]db.Assessment, error) {
+	r.b.mu.RLock()
+	defer r.b.mu.RUnlock()
+	before := r.b.now().Add(-idleFor)
+	var list []db.Assessment
+	for _, row := range r.b.assessments.rows {
+		if (row.Status != "started" && row.Status != "in_progress") || !row.LastActivityAt.Before(before) {
+			continue
+		}
+		out := *row
+		out.ChatHistory = cloneJSON(row.ChatHistory)
+		if row.PhysioID != nil {
+			out.PhysioID = ptr(*row.PhysioID)
+		}
+		list = append(list, out)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].LastActivityAt.Equal(list[j].LastActivityAt) {
+			return list[i].LastActivityAt.Before(list[j].LastActivityAt)
+		}
+		return list[i].AssessmentID < list[j].AssessmentID
+	})
+	if limit > 0 && len(list) > limit {
+		list = list[:limit]
+	}
+	return list, nil
+}
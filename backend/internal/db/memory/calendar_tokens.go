@@ -0,0 +1,90 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"ai-bot-deecogs/internal/db"
+)
+
+type calendarTokenRepo struct{ b *Backend }
+
+func (r *calendarTokenRepo) Create(ctx context.Context, token *db.CalendarToken) error {
+	r.b.mu.Lock()
+	defer r.b.mu.Unlock()
+	if _, ok := r.b.users.rows[token.UserID]; !ok {
+		return db.Violation(db.ErrForeignKeyViolation, "calendar_feed_tokens_user_id_fkey")
+	}
+	for _, t := range r.b.calendarTokens.rows {
+		if t.TokenHash == token.TokenHash {
+			return db.Violation(db.ErrUniqueViolation, "calendar_feed_tokens_token_hash_key")
+		}
+	}
+	row := *token
+	if token.Label != nil {
+		row.Label = ptr(*token.Label)
+	}
+	row.CreatedAt = r.b.now()
+	row.LastUsedAt, row.RevokedAt = nil, nil
+	row.TokenID = r.b.calendarTokens.insert(0, &row)
+	token.TokenID, token.CreatedAt = row.TokenID, row.CreatedAt
+	return nil
+}
+
+func (r *calendarTokenRepo) ListByUser(ctx context.Context, userID uint32) ([]db.CalendarToken, error) {
+	r.b.mu.RLock()
+	defer r.b.mu.RUnlock()
+	var tokens []db.CalendarToken
+	for _, t := range r.b.calendarTokens.rows {
+		if t.UserID == userID {
+			tokens = append(tokens, copyCalendarToken(t))
+		}
+	}
+	sort.Slice(tokens, func(i, j int) bool {
+		if tokens[i].CreatedAt.Equal(tokens[j].CreatedAt) {
+			return tokens[i].TokenID > tokens[j].TokenID
+		}
+		return tokens[i].CreatedAt.After(tokens[j].CreatedAt)
+	})
+	return tokens, nil
+}
+
+func (r *calendarTokenRepo) Use(ctx context.Context, tokenHash string) (*db.CalendarToken, error) {
+	r.b.mu.Lock()
+	defer r.b.mu.Unlock()
+	for _, t := range r.b.calendarTokens.rows {
+		if t.TokenHash == tokenHash && t.RevokedAt == nil {
+			t.LastUsedAt = ptr(r.b.now())
+			out := copyCalendarToken(t)
+			return &out, nil
+		}
+	}
+	return nil, db.ErrNotFound
+}
+
+func (r *calendarTokenRepo) Revoke(ctx context.Context, userID, tokenID uint32) error {
+	r.b.mu.Lock()
+	defer r.b.mu.Unlock()
+	t, ok := r.b.calendarTokens.rows[tokenID]
+	if !ok || t.UserID != userID {
+		return db.ErrNotFound
+	}
+	if t.RevokedAt == nil {
+		t.RevokedAt = ptr(r.b.now())
+	}
+	return nil
+}
+
+func copyCalendarToken(t *db.CalendarToken) db.CalendarToken {
+	out := *t
+	if t.Label != nil {
+		out.Label = ptr(*t.Label)
+	}
+	if t.LastUsedAt != nil {
+		out.LastUsedAt = ptr(*t.LastUsedAt)
+	}
+	if t.RevokedAt != nil {
+		out.RevokedAt = ptr(*t.RevokedAt)
+	}
+	return out
+}
@@ -0,0 +1,66 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"ai-bot-deecogs/internal/db"
+)
+
+var chatRoles = []string{db.ChatRoleUser, db.ChatRoleAssistant}
+
+type chatMessageRepo struct{ b *Backend }
+
+func (r *chatMessageRepo) Append(ctx context.Context, assessmentID uint32, messages []db.ChatMessage) ([]db.ChatMessage, error) {
+	r.b.mu.Lock()
+	defer r.b.mu.Unlock()
+
+	if err := r.b.requireAssessment(assessmentID, "chat_messages_assessment_id_fkey"); err != nil {
+		return nil, err
+	}
+	// Check every row first so a failing turn stores nothing, like the transaction
+	for _, m := range messages {
+		if err := checkIn(m.Role, chatRoles, "chat_messages_role_check"); err != nil {
+			return nil, err
+		}
+		if m.LatencyMs != nil && *m.LatencyMs < 0 {
+			return nil, db.Violation(db.ErrCheckViolation, "chat_messages_latency_ms_check")
+		}
+	}
+
+	stored := make([]db.ChatMessage, 0, len(messages))
+	for _, m := range messages {
+		row := m
+		row.AssessmentID = assessmentID
+		row.CreatedAt = r.b.now()
+		if m.Action != nil {
+			row.Action = ptr(*m.Action)
+		}
+		if m.LatencyMs != nil {
+			row.LatencyMs = ptr(*m.LatencyMs)
+		}
+		row.MessageID = r.b.chatMessages.insert(0, &row)
+		stored = append(stored, row)
+	}
+	return stored, nil
+}
+
+func (r *chatMessageRepo) ListByAssessment(ctx context.Context, assessmentID uint32) ([]db.ChatMessage, error) {
+	r.b.mu.RLock()
+	defer r.b.mu.RUnlock()
+	var messages []db.ChatMessage
+	for _, m := range r.b.chatMessages.rows {
+		if m.AssessmentID == assessmentID {
+			out := *m
+			if m.Action != nil {
+				out.Action = ptr(*m.Action)
+			}
+			if m.LatencyMs != nil {
+				out.LatencyMs = ptr(*m.LatencyMs)
+			}
+			messages = append(messages, out)
+		}
+	}
+	sort.Slice(messages, func(i, j int) bool { return messages[i].MessageID < messages[j].MessageID })
+	return messages, nil
+}
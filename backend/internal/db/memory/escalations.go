@@ -0,0 +1,77 @@
+package memory
+
+import (
+	"context"
+
+	"ai-bot-deecogs/internal/db"
+)
+
+type escalationRepo struct{ b *Backend }
+
+// copyEscalation returns a row that does not alias the stored one
+func copyEscalation(e *db.Escalation) db.Escalation {
+	out := *e
+	out.Matches = cloneJSON(e.Matches)
+	if e.CallID != nil {
+		out.CallID = ptr(*e.CallID)
+	}
+	return out
+}
+
+func (r *escalationRepo) Record(ctx context.Context, e *db.Escalation) error {
+	r.b.mu.Lock()
+	defer r.b.mu.Unlock()
+	if err := r.b.requireAssessment(e.AssessmentID, "red_flag_escalations_assessment_id_fkey"); err != nil {
+		return err
+	}
+	if e.RulesVersion == "" {
+		return db.Violation(db.ErrNotNullViolation, "rules_version")
+	}
+	if err := checkJSON(e.Matches, "matches", false); err != nil {
+		return err
+	}
+
+	now := r.b.now()
+	for _, existing := range r.b.escalations.rows {
+		if existing.AssessmentID == e.AssessmentID {
+			existing.RulesVersion = e.RulesVersion
+			existing.Matches = cloneJSON(e.Matches)
+			existing.UpdatedAt = now
+			*e = copyEscalation(existing)
+			return nil
+		}
+	}
+	row := copyEscalation(e)
+	row.CallID = nil
+	row.CreatedAt, row.UpdatedAt = now, now
+	row.EscalationID = r.b.escalations.insert(0, &row)
+	*e = copyEscalation(&row)
+	return nil
+}
+
+func (r *escalationRepo) GetByAssessment(ctx context.Context, assessmentID uint32) (*db.Escalation, error) {
+	r.b.mu.RLock()
+	defer r.b.mu.RUnlock()
+	for _, e := range r.b.escalations.rows {
+		if e.AssessmentID == assessmentID {
+			out := copyEscalation(e)
+			return &out, nil
+		}
+	}
+	return nil, db.ErrNotFound
+}
+
+func (r *escalationRepo) SetCall(ctx context.Context, escalationID, callID uint32) error {
+	r.b.mu.Lock()
+	defer r.b.mu.Unlock()
+	e, ok := r.b.escalations.rows[escalationID]
+	if !ok || e.CallID != nil {
+		return db.ErrConflict
+	}
+	if _, ok := r.b.physioCalls.rows[callID]; !ok {
+		return db.Violation(db.ErrForeignKeyViolation, "red_flag_escalations_call_id_fkey")
+	}
+	e.CallID = ptr(callID)
+	e.UpdatedAt = r.b.now()
+	return nil
+}
@@ -0,0 +1,41 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"ai-bot-deecogs/internal/db"
+)
+
+var assessmentPhases = []string{"bpi_chat", "questionnaire", "rom", "dashboard"}
+
+type assessmentEventRepo struct{ b *Backend }
+
+// addEvent stores an assessment event and returns a copy, the caller holds the lock
+func (b *Backend) addEvent(e db.AssessmentEvent) *db.AssessmentEvent {
+	e.CreatedAt = b.now()
+	row := e
+	e.EventID = b.events.insert(0, &row)
+	row.EventID = e.EventID
+	return &e
+}
+
+func (r *assessmentEventRepo) ListByAssessment(ctx context.Context, assessmentID uint32) ([]db.AssessmentEvent, error) {
+	r.b.mu.RLock()
+	defer r.b.mu.RUnlock()
+	var events []db.AssessmentEvent
+	for _, e := range r.b.events.rows {
+		if e.AssessmentID == assessmentID {
+			out := *e
+			out.Details = cloneJSON(e.Details)
+			events = append(events, out)
+		}
+	}
+	sort.Slice(events, func(i, j int) bool {
+		if !events[i].CreatedAt.Equal(events[j].CreatedAt) {
+			return events[i].CreatedAt.Before(events[j].CreatedAt)
+		}
+		return events[i].EventID < events[j].EventID
+	})
+	return events, nil
+}
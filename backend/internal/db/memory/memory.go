@@ -0,0 +1,430 @@
+// Package memory is an in-process storage backend with the same constraints as
+// migrations/000001_init_schema.up.sql, used for local runs and tests without Postgres.
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"ai-bot-deecogs/internal/db"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	assessmentTypes   = []string{"PAIN", "INJURY", "FRACTURE", "SWELLING", "STIFFNESS", "WEAKNESS", "DISLOCATION", "RECOVERY", "GENERAL", "OTHER"}
+	assessmentStatus  = []string{"started", "in_progress", "completed", "abandoned"}
+	physioCallStatus  = []string{"scheduled", "in_progress", "completed", "cancelled", "no_show"}
+	physioInitiatedBy = []string{"user", "system"}
+	userRoles         = []string{"patient", "physiotherapist", "admin"}
+	userSexes         = []string{"female", "male"}
+	romMovements      = []string{"flexion", "extension", "abduction", "adduction", "internal_rotation", "external_rotation", "lateral_flexion", "rotation"}
+	romSides          = []string{"left", "right", "bilateral"}
+	romModes          = []string{"active", "passive"}
+	romSources        = []string{db.ROMSourceClient, db.ROMSourceLandmarks}
+	normSexes         = []string{db.NormSexAny, "female", "male"}
+	notifyKinds       = []string{"call_reminder", "critical_flag", "assessment_abandoned"}
+	notifyChannels    = []string{"email", "webhook", "log"}
+)
+
+// table is a SERIAL keyed set of rows
+type table[T any] struct {
+	rows map[uint32]*T
+	next uint32
+}
+
+func newTable[T any]() *table[T] {
+	return &table[T]{rows: map[uint32]*T{}, next: 1}
+}
+
+// insert stores a row under the next id, or under id if it is non-zero. An explicit id moves
+// next past it, as 000017_seeded_sequences does for the rows seeded by 000001.
+func (t *table[T]) insert(id uint32, row *T) uint32 {
+	if id == 0 {
+		id = t.next
+	}
+	t.rows[id] = row
+	if id >= t.next {
+		t.next = id + 1
+	}
+	return id
+}
+
+// Backend holds every table behind a single lock so foreign keys are checked atomically
+type Backend struct {
+	mu  sync.RWMutex
+	now func() time.Time
+
+	users           *table[db.User]
+	anatomy         *table[db.Anatomy]
+	assessments     *table[db.Assessment]
+	events          *table[db.AssessmentEvent]
+	chatMessages    *table[db.ChatMessage]
+	questionnaires  *table[db.Questionnaire]
+	rom             *table[db.ROMAnalysis]
+	romMeasurements *table[db.ROMMeasurement]
+	romNorms        *table[db.ROMNorm]
+	aiAnalysis      *table[db.AIAnalysis]
+	analysisJobs    *table[db.AnalysisJob]
+	physioCalls     *table[db.PhysioCall]
+	availability    *table[db.AvailabilityWindow]
+	availExceptions *table[db.AvailabilityException]
+	selfCarePlans   *table[db.SelfCarePlan]
+	calendarTokens  *table[db.CalendarToken]
+	notifications   *table[db.Notification]
+	escalations     *table[db.Escalation]
+	revokedTokens   map[string]*db.RevokedToken
+}
+
+// New returns an empty backend
+func New() *Backend {
+	return &Backend{
+		now:             func() time.Time { return time.Now().UTC() },
+		users:           newTable[db.User](),
+		anatomy:         newTable[db.Anatomy](),
+		assessments:     newTable[db.Assessment](),
+		events:          newTable[db.AssessmentEvent](),
+		chatMessages:    newTable[db.ChatMessage](),
+		questionnaires:  newTable[db.Questionnaire](),
+		rom:             newTable[db.ROMAnalysis](),
+		romMeasurements: newTable[db.ROMMeasurement](),
+		romNorms:        newTable[db.ROMNorm](),
+		aiAnalysis:      newTable[db.AIAnalysis](),
+		analysisJobs:    newTable[db.AnalysisJob](),
+		physioCalls:     newTable[db.PhysioCall](),
+		availability:    newTable[db.AvailabilityWindow](),
+		availExceptions: newTable[db.AvailabilityException](),
+		selfCarePlans:   newTable[db.SelfCarePlan](),
+		calendarTokens:  newTable[db.CalendarToken](),
+		notifications:   newTable[db.Notification](),
+		escalations:     newTable[db.Escalation](),
+		revokedTokens:   map[string]*db.RevokedToken{},
+	}
+}
+
+// NewStore returns a seeded backend wrapped in a db.Store
+func NewStore() *db.Store {
+	return New().Seed().Store()
+}
+
+// SetClock replaces the clock used for timestamp defaults
+func (b *Backend) SetClock(now func() time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.now = now
+}
+
+// Store exposes the backend through the db.Store interfaces
+func (b *Backend) Store() *db.Store {
+	return &db.Store{
+		Users:          &userRepo{b},
+		Anatomy:        &anatomyRepo{b},
+		Assessments:    &assessmentRepo{b},
+		Events:         &assessmentEventRepo{b},
+		ChatMessages:   &chatMessageRepo{b},
+		Questionnaires: &questionnaireRepo{b},
+		ROM:            &romRepo{b},
+		ROMNorms:       &romNormRepo{b},
+		AIAnalysis:     &aiAnalysisRepo{b},
+		AnalysisJobs:   &analysisJobRepo{b},
+		PhysioCalls:    &physioCallRepo{b},
+		Availability:   &physioAvailabilityRepo{b},
+		SelfCarePlans:  &selfCarePlanRepo{b},
+		RevokedTokens:  &revokedTokenRepo{b},
+		CalendarTokens: &calendarTokenRepo{b},
+		Notifications:  &notificationRepo{b},
+		Escalations:    &escalationRepo{b},
+	}
+}
+
+// Seed loads the rows inserted by the migrations. Like 000017_seeded_sequences on Postgres,
+// the next id of every seeded table follows the highest seeded one.
+func (b *Backend) Seed() *Backend {
+	// 000002_auth rehashes the seeded plaintext password
+	hash, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.DefaultCost)
+	if err != nil {
+		panic(err)
+	}
+	if _, err := b.AddUser(db.User{UserID: 1, Name: "John", Email: "john@example.com", Password: string(hash)}); err != nil {
+		panic(err)
+	}
+	for _, a := range []db.Anatomy{
+		{AnatomyID: 1, Name: "Knee", Description: ptr("Joint between the thigh and lower leg"), Category: ptr("Lower Limb"), Subcategory: ptr("Leg")},
+		{AnatomyID: 2, Name: "Shoulder", Description: ptr("Joint connecting the arm to the torso"), Category: ptr("Upper Limb"), Subcategory: ptr("Arm")},
+		{AnatomyID: 3, Name: "Lower Back", Description: ptr("Region of the spine between the ribs and pelvis"), Category: ptr("Spine")},
+	} {
+		if _, err := b.AddAnatomy(a); err != nil {
+			panic(err)
+		}
+	}
+
+	b.mu.Lock()
+	b.assessments.insert(1, &db.Assessment{
+		AssessmentID:   1,
+		UserID:         1,
+		AnatomyID:      1,
+		AssessmentType: "PAIN",
+		StartTime:      b.now(),
+		Status:         "in_progress",
+		LastActivityAt: b.now(),
+	})
+	// 000005_assessment_events backfills the creation event
+	b.addEvent(db.AssessmentEvent{AssessmentID: 1, EventType: db.EventCreated, ToStatus: ptr("started")})
+	b.mu.Unlock()
+
+	// 000011_rom_norms seeds the normative ranges of the seeded anatomy
+	for _, n := range []struct {
+		anatomyID uint32
+		movement  string
+		ageMin    int
+		normalMax float64
+		source    string
+	}{
+		{2, "flexion", 0, 180, "AAOS"},
+		{2, "extension", 0, 60, "AAOS"},
+		{2, "abduction", 0, 180, "AAOS"},
+		{2, "adduction", 0, 45, "AAOS"},
+		{2, "internal_rotation", 0, 70, "AAOS"},
+		{2, "external_rotation", 0, 90, "AAOS"},
+		{2, "flexion", 60, 165, "age adjusted estimate"},
+		{2, "abduction", 60, 160, "age adjusted estimate"},
+		{2, "external_rotation", 60, 80, "age adjusted estimate"},
+		{1, "flexion", 0, 135, "AAOS"},
+		{1, "flexion", 60, 130, "age adjusted estimate"},
+		{3, "flexion", 0, 80, "AAOS thoracolumbar"},
+		{3, "extension", 0, 25, "AAOS thoracolumbar"},
+		{3, "lateral_flexion", 0, 35, "AAOS thoracolumbar"},
+		{3, "rotation", 0, 45, "AAOS thoracolumbar"},
+		{3, "flexion", 60, 70, "age adjusted estimate"},
+		{3, "extension", 60, 20, "age adjusted estimate"},
+		{3, "lateral_flexion", 60, 30, "age adjusted estimate"},
+	} {
+		norm := db.ROMNorm{AnatomyID: n.anatomyID, Movement: n.movement, Sex: db.NormSexAny, AgeMin: n.ageMin, AgeMax: 150,
+			NormalMax: n.normalMax, Source: ptr(n.source)}
+		if err := (&romNormRepo{b}).Upsert(context.Background(), &norm); err != nil {
+			panic(err)
+		}
+	}
+	return b
+}
+
+// AddUser inserts a user, enforcing the role check and unique active email index
+func (b *Backend) AddUser(user db.User) (uint32, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if user.Role == "" {
+		user.Role = "patient"
+	}
+	if err := checkIn(user.Role, userRoles, "users_role_check"); err != nil {
+		return 0, err
+	}
+	if user.DeletedAt == nil && b.emailTaken(user.Email, 0) {
+		return 0, db.Violation(db.ErrUniqueViolation, db.UserEmailKey)
+	}
+	if user.CreatedAt.IsZero() {
+		user.CreatedAt = b.now()
+	}
+	if user.UpdatedAt.IsZero() {
+		user.UpdatedAt = user.CreatedAt
+	}
+	user.UserID = b.users.insert(user.UserID, &user)
+	return user.UserID, nil
+}
+
+// emailTaken reports whether another active user has the email, the caller holds the lock
+func (b *Backend) emailTaken(email string, exceptID uint32) bool {
+	for id, u := range b.users.rows {
+		if id != exceptID && u.DeletedAt == nil && u.Email == email {
+			return true
+		}
+	}
+	return false
+}
+
+// AddAnatomy inserts an anatomy row, enforcing the unique name constraint
+func (b *Backend) AddAnatomy(anatomy db.Anatomy) (uint32, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, a := range b.anatomy.rows {
+		if a.Name == anatomy.Name {
+			return 0, db.Violation(db.ErrUniqueViolation, "anatomy_name_key")
+		}
+	}
+	if anatomy.CreatedAt.IsZero() {
+		anatomy.CreatedAt = b.now()
+	}
+	anatomy.AnatomyID = b.anatomy.insert(anatomy.AnatomyID, &anatomy)
+	return anatomy.AnatomyID, nil
+}
+
+// DeleteUser removes a user, cascading to its assessments and availability and unassigning it
+// as physiotherapist
+func (b *Backend) DeleteUser(userID uint32) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.users.rows, userID)
+	for _, a := range b.assessments.rows {
+		if a.PhysioID != nil && *a.PhysioID == userID {
+			a.PhysioID = nil
+		}
+	}
+	for _, c := range b.physioCalls.rows {
+		if c.PhysioID != nil && *c.PhysioID == userID {
+			c.PhysioID = nil
+		}
+	}
+	for id, w := range b.availability.rows {
+		if w.PhysioID == userID {
+			delete(b.availability.rows, id)
+		}
+	}
+	for id, e := range b.availExceptions.rows {
+		if e.PhysioID == userID {
+			delete(b.availExceptions.rows, id)
+		}
+	}
+	for id, t := range b.calendarTokens.rows {
+		if t.UserID == userID {
+			delete(b.calendarTokens.rows, id)
+		}
+	}
+	for id, n := range b.notifications.rows {
+		if n.UserID == userID {
+			delete(b.notifications.rows, id)
+		}
+	}
+	for jti, t := range b.revokedTokens {
+		if t.UserID == userID {
+			delete(b.revokedTokens, jti)
+		}
+	}
+	for id, a := range b.assessments.rows {
+		if a.UserID == userID {
+			b.deleteAssessment(id)
+		}
+	}
+}
+
+// deleteAssessment removes an assessment and every row referencing it, the caller holds the lock
+func (b *Backend) deleteAssessment(assessmentID uint32) {
+	delete(b.assessments.rows, assessmentID)
+	for id, e := range b.events.rows {
+		if e.AssessmentID == assessmentID {
+			delete(b.events.rows, id)
+		}
+	}
+	for id, m := range b.chatMessages.rows {
+		if m.AssessmentID == assessmentID {
+			delete(b.chatMessages.rows, id)
+		}
+	}
+	for id, q := range b.questionnaires.rows {
+		if q.AssessmentID == assessmentID {
+			delete(b.questionnaires.rows, id)
+		}
+	}
+	for id, r := range b.rom.rows {
+		if r.AssessmentID == assessmentID {
+			delete(b.rom.rows, id)
+			for mid, m := range b.romMeasurements.rows {
+				if m.RomID == id {
+					delete(b.romMeasurements.rows, mid)
+				}
+			}
+		}
+	}
+	for id, a := range b.aiAnalysis.rows {
+		if a.AssessmentID == assessmentID {
+			delete(b.aiAnalysis.rows, id)
+		}
+	}
+	for id, j := range b.analysisJobs.rows {
+		if j.AssessmentID == assessmentID {
+			delete(b.analysisJobs.rows, id)
+		}
+	}
+	for id, c := range b.physioCalls.rows {
+		if c.AssessmentID == assessmentID {
+			delete(b.physioCalls.rows, id)
+		}
+	}
+	for id, e := range b.escalations.rows {
+		if e.AssessmentID == assessmentID {
+			delete(b.escalations.rows, id)
+		}
+	}
+	for id, n := range b.notifications.rows {
+		if n.AssessmentID != nil && *n.AssessmentID == assessmentID {
+			delete(b.notifications.rows, id)
+		}
+	}
+	for id, p := range b.selfCarePlans.rows {
+		if p.AssessmentID == assessmentID {
+			delete(b.selfCarePlans.rows, id)
+		}
+	}
+}
+
+// requireAssessment enforces a foreign key to assessments, the caller holds the lock
+func (b *Backend) requireAssessment(assessmentID uint32, constraint string) error {
+	if _, ok := b.assessments.rows[assessmentID]; !ok {
+		return db.Violation(db.ErrForeignKeyViolation, constraint)
+	}
+	return nil
+}
+
+// checkIn enforces a CHECK (column IN (...)) constraint
+func checkIn(value string, allowed []string, constraint string) error {
+	for _, a := range allowed {
+		if value == a {
+			return nil
+		}
+	}
+	return db.Violation(db.ErrCheckViolation, constraint)
+}
+
+// checkJSON mirrors the jsonb input rules, a nil value is stored as NULL
+func checkJSON(value json.RawMessage, column string, nullable bool) error {
+	if value == nil {
+		if nullable {
+			return nil
+		}
+		return db.Violation(db.ErrNotNullViolation, column)
+	}
+	if !json.Valid(value) {
+		return fmt.Errorf("%w: invalid input syntax for type json in %s", db.ErrInvalidJSON, column)
+	}
+	return nil
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+// cloneJSON copies a json value so stored rows never alias caller memory
+func cloneJSON(value json.RawMessage) json.RawMessage {
+	if value == nil {
+		return nil
+	}
+	return append(json.RawMessage(nil), value...)
+}
+
+// latest picks the newest row by created_at, breaking ties on the highest id
+func latest[T any](rows map[uint32]*T, match func(*T) bool, createdAt func(*T) time.Time) (uint32, *T) {
+	var bestID uint32
+	var best *T
+	for id, row := range rows {
+		if !match(row) {
+			continue
+		}
+		if best == nil || createdAt(row).After(createdAt(best)) || (createdAt(row).Equal(createdAt(best)) && id > bestID) {
+			bestID, best = id, row
+		}
+	}
+	return bestID, best
+}
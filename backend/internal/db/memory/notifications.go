@@ -0,0 +1,222 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"ai-bot-deecogs/internal/db"
+)
+
+type notificationRepo struct{ b *Backend }
+
+// copyNotification returns a row that does not alias the stored one
+func copyNotification(n *db.Notification) db.Notification {
+	out := *n
+	if n.AssessmentID != nil {
+		out.AssessmentID = ptr(*n.AssessmentID)
+	}
+	if n.CallID != nil {
+		out.CallID = ptr(*n.CallID)
+	}
+	if n.Address != nil {
+		out.Address = ptr(*n.Address)
+	}
+	if n.LockedAt != nil {
+		out.LockedAt = ptr(*n.LockedAt)
+	}
+	if n.LastError != nil {
+		out.LastError = ptr(*n.LastError)
+	}
+	if n.SentAt != nil {
+		out.SentAt = ptr(*n.SentAt)
+	}
+	return out
+}
+
+func (r *notificationRepo) Enqueue(ctx context.Context, n *db.Notification) (bool, error) {
+	r.b.mu.Lock()
+	defer r.b.mu.Unlock()
+
+	if _, ok := r.b.users.rows[n.UserID]; !ok {
+		return false, db.Violation(db.ErrForeignKeyViolation, "notification_outbox_user_id_fkey")
+	}
+	if n.AssessmentID != nil {
+		if err := r.b.requireAssessment(*n.AssessmentID, "notification_outbox_assessment_id_fkey"); err != nil {
+			return false, err
+		}
+	}
+	if n.CallID != nil {
+		if _, ok := r.b.physioCalls.rows[*n.CallID]; !ok {
+			return false, db.Violation(db.ErrForeignKeyViolation, "notification_outbox_call_id_fkey")
+		}
+	}
+	if err := checkIn(n.Kind, notifyKinds, "notification_outbox_kind_check"); err != nil {
+		return false, err
+	}
+	if err := checkIn(n.Channel, notifyChannels, "notification_outbox_channel_check"); err != nil {
+		return false, err
+	}
+	if n.MaxAttempts < 1 {
+		return false, db.Violation(db.ErrCheckViolation, "notification_outbox_max_attempts_check")
+	}
+	for _, existing := range r.b.notifications.rows {
+		if existing.DedupeKey == n.DedupeKey {
+			return false, nil
+		}
+	}
+
+	now := r.b.now()
+	row := copyNotification(n)
+	row.Status = db.NotificationPending
+	row.Attempts = 0
+	row.RunAfter = now
+	row.LockedAt, row.LastError, row.SentAt = nil, nil, nil
+	row.CreatedAt, row.UpdatedAt = now, now
+	row.NotificationID = r.b.notifications.insert(0, &row)
+	*n = copyNotification(&row)
+	return true, nil
+}
+
+func (r *notificationRepo) Get(ctx context.Context, notificationID uint32) (*db.Notification, error) {
+	r.b.mu.RLock()
+	defer r.b.mu.RUnlock()
+	n, ok := r.b.notifications.rows[notificationID]
+	if !ok {
+		return nil, db.ErrNotFound
+	}
+	out := copyNotification(n)
+	return &out, nil
+}
+
+func (r *notificationRepo) List(ctx context.Context, filter db.NotificationFilter) ([]db.Notification, error) {
+	r.b.mu.RLock()
+	defer r.b.mu.RUnlock()
+	var notifications []db.Notification
+	for _, n := range r.b.notifications.rows {
+		if filter.UserID != nil && n.UserID != *filter.UserID {
+			continue
+		}
+		if filter.Status != nil && n.Status != *filter.Status {
+			continue
+		}
+		notifications = append(notifications, copyNotification(n))
+	}
+	sort.Slice(notifications, func(i, j int) bool {
+		return notifications[i].NotificationID > notifications[j].NotificationID
+	})
+	if filter.Limit > 0 && len(notifications) > filter.Limit {
+		notifications = notifications[:filter.Limit]
+	}
+	return notifications, nil
+}
+
+func (r *notificationRepo) Claim(ctx context.Context, lease time.Duration) (*db.Notification, error) {
+	r.b.mu.Lock()
+	defer r.b.mu.Unlock()
+
+	now := r.b.now()
+	staleBefore := now.Add(-lease)
+	var next *db.Notification
+	for _, n := range r.b.notifications.rows {
+		due := (n.Status == db.NotificationPending && !n.RunAfter.After(now)) ||
+			(n.Status == db.NotificationSending && n.LockedAt != nil && n.LockedAt.Before(staleBefore))
+		if !due {
+			continue
+		}
+		if next == nil || n.RunAfter.Before(next.RunAfter) || (n.RunAfter.Equal(next.RunAfter) && n.NotificationID < next.NotificationID) {
+			next = n
+		}
+	}
+	if next == nil {
+		return nil, db.ErrNotFound
+	}
+	next.Status = db.NotificationSending
+	next.Attempts++
+	next.LockedAt = ptr(now)
+	next.UpdatedAt = now
+	out := copyNotification(next)
+	return &out, nil
+}
+
+// claimed returns a message still held by the claim made at lockedAt, the caller holds the lock
+func (r *notificationRepo) claimed(notificationID uint32, lockedAt time.Time) (*db.Notification, error) {
+	n, ok := r.b.notifications.rows[notificationID]
+	if !ok || n.Status != db.NotificationSending || n.LockedAt == nil || !n.LockedAt.Equal(lockedAt) {
+		return nil, db.ErrConflict
+	}
+	return n, nil
+}
+
+func (r *notificationRepo) Sent(ctx context.Context, notificationID uint32, lockedAt time.Time) error {
+	r.b.mu.Lock()
+	defer r.b.mu.Unlock()
+
+	n, err := r.claimed(notificationID, lockedAt)
+	if err != nil {
+		return err
+	}
+	now := r.b.now()
+	n.Status = db.NotificationSent
+	n.SentAt = ptr(now)
+	n.LockedAt = nil
+	n.LastError = nil
+	n.UpdatedAt = now
+	return nil
+}
+
+func (r *notificationRepo) Fail(ctx context.Context, notificationID uint32, lockedAt time.Time, message string, retryIn *time.Duration) error {
+	r.b.mu.Lock()
+	defer r.b.mu.Unlock()
+
+	n, err := r.claimed(notificationID, lockedAt)
+	if err != nil {
+		return err
+	}
+	now := r.b.now()
+	if retryIn == nil {
+		n.Status = db.NotificationFailed
+	} else {
+		n.Status = db.NotificationPending
+		n.RunAfter = now.Add(*retryIn)
+	}
+	n.LastError = ptr(message)
+	n.LockedAt = nil
+	n.UpdatedAt = now
+	return nil
+}
+
+func (r *notificationRepo) Skip(ctx context.Context, notificationID uint32, lockedAt time.Time, reason string) error {
+	r.b.mu.Lock()
+	defer r.b.mu.Unlock()
+
+	n, err := r.claimed(notificationID, lockedAt)
+	if err != nil {
+		return err
+	}
+	n.Status = db.NotificationSkipped
+	n.LastError = ptr(reason)
+	n.LockedAt = nil
+	n.UpdatedAt = r.b.now()
+	return nil
+}
+
+func (r *notificationRepo) Requeue(ctx context.Context, notificationID uint32) (*db.Notification, error) {
+	r.b.mu.Lock()
+	defer r.b.mu.Unlock()
+
+	n, ok := r.b.notifications.rows[notificationID]
+	if !ok {
+		return nil, db.ErrNotFound
+	}
+	if n.Status != db.NotificationFailed {
+		return nil, db.ErrConflict
+	}
+	now := r.b.now()
+	n.Status = db.NotificationPending
+	n.Attempts = 0
+	n.RunAfter = now
+	n.UpdatedAt = now
+	out := copyNotification(n)
+	return &out, nil
+}
@@ -0,0 +1,160 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"ai-bot-deecogs/internal/db"
+)
+
+type physioAvailabilityRepo struct{ b *Backend }
+
+func (r *physioAvailabilityRepo) ListWindows(ctx context.Context, physioID uint32) ([]db.AvailabilityWindow, error) {
+	r.b.mu.RLock()
+	defer r.b.mu.RUnlock()
+	return r.b.windowsOf(physioID), nil
+}
+
+// windowsOf returns the weekly windows of a physiotherapist by weekday and start, the
+// caller holds the lock
+func (b *Backend) windowsOf(physioID uint32) []db.AvailabilityWindow {
+	var windows []db.AvailabilityWindow
+	for _, w := range b.availability.rows {
+		if w.PhysioID == physioID {
+			windows = append(windows, *w)
+		}
+	}
+	sort.Slice(windows, func(i, j int) bool {
+		a, b := windows[i], windows[j]
+		if a.Weekday != b.Weekday {
+			return a.Weekday < b.Weekday
+		}
+		if a.StartMinute != b.StartMinute {
+			return a.StartMinute < b.StartMinute
+		}
+		return a.WindowID < b.WindowID
+	})
+	return windows
+}
+
+func (r *physioAvailabilityRepo) ReplaceWindows(ctx context.Context, physioID uint32, windows []db.AvailabilityWindow) error {
+	r.b.mu.Lock()
+	defer r.b.mu.Unlock()
+	for _, w := range windows {
+		if err := r.b.checkWindow(physioID, w); err != nil {
+			return err
+		}
+	}
+	for id, w := range r.b.availability.rows {
+		if w.PhysioID == physioID {
+			delete(r.b.availability.rows, id)
+		}
+	}
+	now := r.b.now()
+	for i := range windows {
+		row := windows[i]
+		row.PhysioID = physioID
+		row.CreatedAt = now
+		row.WindowID = r.b.availability.insert(0, &row)
+		windows[i] = row
+	}
+	return nil
+}
+
+// checkWindow enforces the constraints of physio_availability, the caller holds the lock
+func (b *Backend) checkWindow(physioID uint32, w db.AvailabilityWindow) error {
+	if _, ok := b.users.rows[physioID]; !ok {
+		return db.Violation(db.ErrForeignKeyViolation, "physio_availability_physio_id_fkey")
+	}
+	switch {
+	case w.Weekday < 0 || w.Weekday > 6:
+		return db.Violation(db.ErrCheckViolation, "physio_availability_weekday_check")
+	case w.StartMinute < 0 || w.StartMinute > 1439:
+		return db.Violation(db.ErrCheckViolation, "physio_availability_start_minute_check")
+	case w.EndMinute < 1 || w.EndMinute > 1440:
+		return db.Violation(db.ErrCheckViolation, "physio_availability_end_minute_check")
+	case w.StartMinute >= w.EndMinute:
+		return db.Violation(db.ErrCheckViolation, "physio_availability_window_check")
+	case w.SlotMinutes < 5 || w.SlotMinutes > 240:
+		return db.Violation(db.ErrCheckViolation, "physio_availability_slot_minutes_check")
+	case w.TimeZone == "":
+		return db.Violation(db.ErrNotNullViolation, "time_zone")
+	}
+	return nil
+}
+
+func (r *physioAvailabilityRepo) ListExceptions(ctx context.Context, physioID uint32, from, to *time.Time) ([]db.AvailabilityException, error) {
+	r.b.mu.RLock()
+	defer r.b.mu.RUnlock()
+	return r.b.exceptionsOf(physioID, from, to), nil
+}
+
+// exceptionsOf returns the exceptions of a physiotherapist overlapping [from, to) in order of
+// start, the caller holds the lock
+func (b *Backend) exceptionsOf(physioID uint32, from, to *time.Time) []db.AvailabilityException {
+	var exceptions []db.AvailabilityException
+	for _, e := range b.availExceptions.rows {
+		switch {
+		case e.PhysioID != physioID:
+		case from != nil && !e.EndsAt.After(*from):
+		case to != nil && !e.StartsAt.Before(*to):
+		default:
+			out := *e
+			if e.Reason != nil {
+				out.Reason = ptr(*e.Reason)
+			}
+			exceptions = append(exceptions, out)
+		}
+	}
+	sort.Slice(exceptions, func(i, j int) bool {
+		if exceptions[i].StartsAt.Equal(exceptions[j].StartsAt) {
+			return exceptions[i].ExceptionID < exceptions[j].ExceptionID
+		}
+		return exceptions[i].StartsAt.Before(exceptions[j].StartsAt)
+	})
+	return exceptions
+}
+
+func (r *physioAvailabilityRepo) CreateException(ctx context.Context, e *db.AvailabilityException) error {
+	r.b.mu.Lock()
+	defer r.b.mu.Unlock()
+	if _, ok := r.b.users.rows[e.PhysioID]; !ok {
+		return db.Violation(db.ErrForeignKeyViolation, "physio_availability_exceptions_physio_id_fkey")
+	}
+	if !e.StartsAt.Before(e.EndsAt) {
+		return db.Violation(db.ErrCheckViolation, "physio_availability_exceptions_range_check")
+	}
+	row := *e
+	if e.Reason != nil {
+		row.Reason = ptr(*e.Reason)
+	}
+	row.CreatedAt = r.b.now()
+	row.ExceptionID = r.b.availExceptions.insert(0, &row)
+	e.ExceptionID, e.CreatedAt = row.ExceptionID, row.CreatedAt
+	return nil
+}
+
+func (r *physioAvailabilityRepo) DeleteException(ctx context.Context, physioID, exceptionID uint32) error {
+	r.b.mu.Lock()
+	defer r.b.mu.Unlock()
+	e, ok := r.b.availExceptions.rows[exceptionID]
+	if !ok || e.PhysioID != physioID {
+		return db.ErrNotFound
+	}
+	delete(r.b.availExceptions.rows, exceptionID)
+	return nil
+}
+
+// checkCalendar runs check against the calendar of the physiotherapist of call like the
+// Postgres repository, the caller holds the lock
+func (b *Backend) checkCalendar(call *db.PhysioCall, check db.CalendarCheck) error {
+	if check == nil || call.PhysioID == nil || call.ScheduledTime == nil {
+		return nil
+	}
+	from, to := call.ScheduledTime.Add(-24*time.Hour), call.End().Add(24*time.Hour)
+	return check(call, db.Calendar{
+		Windows:    b.windowsOf(*call.PhysioID),
+		Exceptions: b.exceptionsOf(*call.PhysioID, &from, &to),
+	})
+}
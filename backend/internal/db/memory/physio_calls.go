@@ -0,0 +1,223 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"ai-bot-deecogs/internal/db"
+)
+
+type physioCallRepo struct{ b *Backend }
+
+func (r *physioCallRepo) Create(ctx context.Context, call *db.PhysioCall, check db.CalendarCheck) error {
+	r.b.mu.Lock()
+	defer r.b.mu.Unlock()
+	if err := r.b.requireAssessment(call.AssessmentID, "physio_calls_assessment_id_fkey"); err != nil {
+		return err
+	}
+	if strings.TrimSpace(call.CallType) == "" {
+		return db.Violation(db.ErrNotNullViolation, "call_type")
+	}
+	if err := checkIn(call.CallStatus, physioCallStatus, "physio_calls_call_status_check"); err != nil {
+		return err
+	}
+	if err := checkIn(call.InitiatedBy, physioInitiatedBy, "physio_calls_initiated_by_check"); err != nil {
+		return err
+	}
+	if err := r.b.checkCalendar(call, check); err != nil {
+		return err
+	}
+	if err := r.b.checkCall(call); err != nil {
+		return err
+	}
+	row := *call
+	row.UserID = 0
+	row.Sequence = 0
+	row.CreatedAt = r.b.now()
+	row.UpdatedAt = row.CreatedAt
+	row.CallID = r.b.physioCalls.insert(0, &row)
+	call.CallID = row.CallID
+	call.UserID = r.b.assessments.rows[call.AssessmentID].UserID
+	call.Sequence = 0
+	call.CreatedAt = row.CreatedAt
+	call.UpdatedAt = row.UpdatedAt
+	return nil
+}
+
+// checkCall enforces the physio_id foreign key, the duration check and the overlap rule of
+// the Postgres repository, the caller holds the lock
+func (b *Backend) checkCall(call *db.PhysioCall) error {
+	if call.PhysioID != nil {
+		if _, ok := b.users.rows[*call.PhysioID]; !ok {
+			return db.Violation(db.ErrForeignKeyViolation, "physio_calls_physio_id_fkey")
+		}
+	}
+	if call.DurationMinutes < 5 || call.DurationMinutes > 240 {
+		return db.Violation(db.ErrCheckViolation, "physio_calls_duration_check")
+	}
+	if call.ScheduledTime == nil {
+		return nil
+	}
+	userID := b.assessments.rows[call.AssessmentID].UserID
+	for _, c := range b.physioCalls.rows {
+		if c.CallID == call.CallID || c.ScheduledTime == nil || (c.CallStatus != "scheduled" && c.CallStatus != "in_progress") {
+			continue
+		}
+		samePatient := b.assessments.rows[c.AssessmentID].UserID == userID
+		samePhysio := call.PhysioID != nil && c.PhysioID != nil && *c.PhysioID == *call.PhysioID
+		if !samePatient && !samePhysio {
+			continue
+		}
+		if c.ScheduledTime.Before(call.End()) && c.End().After(*call.ScheduledTime) {
+			return db.ErrOverlap
+		}
+	}
+	return nil
+}
+
+func (r *physioCallRepo) Get(ctx context.Context, callID uint32) (*db.PhysioCall, error) {
+	r.b.mu.RLock()
+	defer r.b.mu.RUnlock()
+	c, ok := r.b.physioCalls.rows[callID]
+	if !ok {
+		return nil, db.ErrNotFound
+	}
+	out := r.b.copyCall(c)
+	return &out, nil
+}
+
+// copyCall returns a call with its patient filled in, the caller holds the lock
+func (b *Backend) copyCall(c *db.PhysioCall) db.PhysioCall {
+	out := *c
+	out.UserID = b.assessments.rows[c.AssessmentID].UserID
+	if c.ScheduledTime != nil {
+		out.ScheduledTime = ptr(*c.ScheduledTime)
+	}
+	if c.PhysioID != nil {
+		out.PhysioID = ptr(*c.PhysioID)
+	}
+	if c.StartedAt != nil {
+		out.StartedAt = ptr(*c.StartedAt)
+	}
+	if c.EndedAt != nil {
+		out.EndedAt = ptr(*c.EndedAt)
+	}
+	if c.StatusReason != nil {
+		out.StatusReason = ptr(*c.StatusReason)
+	}
+	return out
+}
+
+func (r *physioCallRepo) ListByAssessment(ctx context.Context, assessmentID uint32) ([]db.PhysioCall, error) {
+	r.b.mu.RLock()
+	defer r.b.mu.RUnlock()
+	var calls []db.PhysioCall
+	for _, c := range r.b.physioCalls.rows {
+		if c.AssessmentID == assessmentID {
+			calls = append(calls, r.b.copyCall(c))
+		}
+	}
+	sort.Slice(calls, func(i, j int) bool {
+		if calls[i].CreatedAt.Equal(calls[j].CreatedAt) {
+			return calls[i].CallID > calls[j].CallID
+		}
+		return calls[i].CreatedAt.After(calls[j].CreatedAt)
+	})
+	return calls, nil
+}
+
+func (r *physioCallRepo) List(ctx context.Context, f db.PhysioCallFilter) ([]db.PhysioCall, error) {
+	r.b.mu.RLock()
+	defer r.b.mu.RUnlock()
+	var calls []db.PhysioCall
+	for _, c := range r.b.physioCalls.rows {
+		call := r.b.copyCall(c)
+		at := call.CreatedAt
+		if call.ScheduledTime != nil {
+			at = *call.ScheduledTime
+		}
+		switch {
+		case f.PhysioID != nil && (call.PhysioID == nil || *call.PhysioID != *f.PhysioID):
+		case f.UserID != nil && call.UserID != *f.UserID:
+		case f.Status != nil && call.CallStatus != *f.Status:
+		case f.From != nil && at.Before(*f.From):
+		case f.To != nil && !at.Before(*f.To):
+		default:
+			calls = append(calls, call)
+		}
+	}
+	sort.Slice(calls, func(i, j int) bool {
+		ai, aj := calls[i].CreatedAt, calls[j].CreatedAt
+		if calls[i].ScheduledTime != nil {
+			ai = *calls[i].ScheduledTime
+		}
+		if calls[j].ScheduledTime != nil {
+			aj = *calls[j].ScheduledTime
+		}
+		if ai.Equal(aj) {
+			return calls[i].CallID < calls[j].CallID
+		}
+		return ai.Before(aj)
+	})
+	return calls, nil
+}
+
+func (r *physioCallRepo) Update(ctx context.Context, call *db.PhysioCall, check db.CalendarCheck) error {
+	r.b.mu.Lock()
+	defer r.b.mu.Unlock()
+	row, ok := r.b.physioCalls.rows[call.CallID]
+	if !ok {
+		return db.ErrNotFound
+	}
+	if err := r.b.checkCalendar(call, check); err != nil {
+		return err
+	}
+	if err := r.b.checkCall(call); err != nil {
+		return err
+	}
+	if row.CallStatus != "scheduled" {
+		return db.ErrConflict
+	}
+	row.PhysioID, row.ScheduledTime, row.DurationMinutes = nil, nil, call.DurationMinutes
+	if call.PhysioID != nil {
+		row.PhysioID = ptr(*call.PhysioID)
+	}
+	if call.ScheduledTime != nil {
+		row.ScheduledTime = ptr(*call.ScheduledTime)
+	}
+	row.Sequence++
+	row.UpdatedAt = r.b.now()
+	call.Sequence, call.UpdatedAt = row.Sequence, row.UpdatedAt
+	return nil
+}
+
+func (r *physioCallRepo) Transition(ctx context.Context, callID uint32, t db.CallTransition) (*db.PhysioCall, error) {
+	r.b.mu.Lock()
+	defer r.b.mu.Unlock()
+	row, ok := r.b.physioCalls.rows[callID]
+	if !ok {
+		return nil, db.ErrNotFound
+	}
+	if row.CallStatus != t.From {
+		return nil, db.ErrConflict
+	}
+	if err := checkIn(t.To, physioCallStatus, "physio_calls_call_status_check"); err != nil {
+		return nil, err
+	}
+	now := r.b.now()
+	row.CallStatus = t.To
+	if t.Started {
+		row.StartedAt = ptr(now)
+	}
+	if t.Ended {
+		row.EndedAt = ptr(now)
+	}
+	if t.Reason != nil {
+		row.StatusReason = ptr(*t.Reason)
+	}
+	row.Sequence++
+	row.UpdatedAt = now
+	out := r.b.copyCall(row)
+	return &out, nil
+}
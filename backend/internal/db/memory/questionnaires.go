@@ -0,0 +1,39 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"ai-bot-deecogs/internal/db"
+)
+
+type questionnaireRepo struct{ b *Backend }
+
+func (r *questionnaireRepo) Create(ctx context.Context, assessmentID uint32, chatHistory json.RawMessage) (uint32, error) {
+	r.b.mu.Lock()
+	defer r.b.mu.Unlock()
+	if err := r.b.requireAssessment(assessmentID, "questionnaires_assessment_id_fkey"); err != nil {
+		return 0, err
+	}
+	if err := checkJSON(chatHistory, "chat_history", true); err != nil {
+		return 0, err
+	}
+	row := &db.Questionnaire{AssessmentID: assessmentID, ChatHistory: cloneJSON(chatHistory), CreatedAt: r.b.now()}
+	row.QuestionID = r.b.questionnaires.insert(0, row)
+	return row.QuestionID, nil
+}
+
+func (r *questionnaireRepo) Latest(ctx context.Context, assessmentID uint32) (*db.Questionnaire, error) {
+	r.b.mu.RLock()
+	defer r.b.mu.RUnlock()
+	_, row := latest(r.b.questionnaires.rows,
+		func(q *db.Questionnaire) bool { return q.AssessmentID == assessmentID },
+		func(q *db.Questionnaire) time.Time { return q.CreatedAt })
+	if row == nil {
+		return nil, db.ErrNotFound
+	}
+	out := *row
+	out.ChatHistory = cloneJSON(row.ChatHistory)
+	return &out, nil
+}
@@ -0,0 +1,47 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"ai-bot-deecogs/internal/db"
+)
+
+type revokedTokenRepo struct{ b *Backend }
+
+func (r *revokedTokenRepo) Revoke(ctx context.Context, token db.RevokedToken) error {
+	r.b.mu.Lock()
+	defer r.b.mu.Unlock()
+	if _, ok := r.b.users.rows[token.UserID]; !ok {
+		return db.Violation(db.ErrForeignKeyViolation, "revoked_tokens_user_id_fkey")
+	}
+	if err := checkIn(token.TokenType, []string{"access", "refresh"}, "revoked_tokens_token_type_check"); err != nil {
+		return err
+	}
+	if _, ok := r.b.revokedTokens[token.JTI]; ok {
+		return nil
+	}
+	token.RevokedAt = r.b.now()
+	r.b.revokedTokens[token.JTI] = &token
+	return nil
+}
+
+func (r *revokedTokenRepo) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	r.b.mu.RLock()
+	defer r.b.mu.RUnlock()
+	_, ok := r.b.revokedTokens[jti]
+	return ok, nil
+}
+
+func (r *revokedTokenRepo) PurgeExpired(ctx context.Context, before time.Time) (int64, error) {
+	r.b.mu.Lock()
+	defer r.b.mu.Unlock()
+	var purged int64
+	for jti, t := range r.b.revokedTokens {
+		if t.ExpiresAt.Before(before) {
+			delete(r.b.revokedTokens, jti)
+			purged++
+		}
+	}
+	return purged, nil
+}
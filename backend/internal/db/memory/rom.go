@@ -0,0 +1,133 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"ai-bot-deecogs/internal/db"
+)
+
+type romRepo struct{ b *Backend }
+
+// checkMeasurement enforces the rom_measurements constraints, the caller holds the lock
+func (b *Backend) checkMeasurement(m *db.ROMMeasurement) error {
+	if _, ok := b.anatomy.rows[m.AnatomyID]; !ok {
+		return db.Violation(db.ErrForeignKeyViolation, "rom_measurements_anatomy_id_fkey")
+	}
+	if err := checkIn(m.Movement, romMovements, "rom_measurements_movement_check"); err != nil {
+		return err
+	}
+	if m.Side != nil {
+		if err := checkIn(*m.Side, romSides, "rom_measurements_side_check"); err != nil {
+			return err
+		}
+	}
+	if err := checkIn(m.Mode, romModes, "rom_measurements_mode_check"); err != nil {
+		return err
+	}
+	if m.MinAngle > m.MaxAngle {
+		return db.Violation(db.ErrCheckViolation, "rom_measurements_angles_check")
+	}
+	if m.Repetitions != nil && *m.Repetitions < 1 {
+		return db.Violation(db.ErrCheckViolation, "rom_measurements_repetitions_check")
+	}
+	return nil
+}
+
+// copyMeasurement returns a row that does not alias the stored one
+func copyMeasurement(m *db.ROMMeasurement) db.ROMMeasurement {
+	out := *m
+	if m.Side != nil {
+		out.Side = ptr(*m.Side)
+	}
+	if m.PeakAngle != nil {
+		out.PeakAngle = ptr(*m.PeakAngle)
+	}
+	if m.Repetitions != nil {
+		out.Repetitions = ptr(*m.Repetitions)
+	}
+	if m.PainOnsetAngle != nil {
+		out.PainOnsetAngle = ptr(*m.PainOnsetAngle)
+	}
+	out.Joint = ""
+	return out
+}
+
+func (r *romRepo) Create(ctx context.Context, rom *db.ROMAnalysis) error {
+	r.b.mu.Lock()
+	defer r.b.mu.Unlock()
+	if err := r.b.requireAssessment(rom.AssessmentID, "rom_analysis_assessment_id_fkey"); err != nil {
+		return err
+	}
+	if err := checkJSON(rom.PoseModelData, "pose_model_data", false); err != nil {
+		return err
+	}
+	if err := checkJSON(rom.LandmarkSeries, "landmark_series", true); err != nil {
+		return err
+	}
+	if err := checkJSON(rom.DerivedMetrics, "derived_metrics", true); err != nil {
+		return err
+	}
+	if rom.Source == "" {
+		rom.Source = db.ROMSourceClient
+	}
+	if err := checkIn(rom.Source, romSources, "rom_analysis_source_check"); err != nil {
+		return err
+	}
+	for i := range rom.Measurements {
+		if err := r.b.checkMeasurement(&rom.Measurements[i]); err != nil {
+			return err
+		}
+	}
+
+	row := &db.ROMAnalysis{
+		AssessmentID:   rom.AssessmentID,
+		PoseModelData:  cloneJSON(rom.PoseModelData),
+		Source:         rom.Source,
+		LandmarkSeries: cloneJSON(rom.LandmarkSeries),
+		DerivedMetrics: cloneJSON(rom.DerivedMetrics),
+		CreatedAt:      r.b.now(),
+	}
+	row.RomID = r.b.rom.insert(0, row)
+	rom.RomID, rom.CreatedAt = row.RomID, row.CreatedAt
+	for i := range rom.Measurements {
+		m := copyMeasurement(&rom.Measurements[i])
+		m.RomID = row.RomID
+		m.MeasurementID = r.b.romMeasurements.insert(0, &m)
+		rom.Measurements[i].RomID, rom.Measurements[i].MeasurementID = m.RomID, m.MeasurementID
+	}
+	return nil
+}
+
+func (r *romRepo) Latest(ctx context.Context, assessmentID uint32) (*db.ROMAnalysis, error) {
+	r.b.mu.RLock()
+	defer r.b.mu.RUnlock()
+	_, row := latest(r.b.rom.rows,
+		func(a *db.ROMAnalysis) bool { return a.AssessmentID == assessmentID },
+		func(a *db.ROMAnalysis) time.Time { return a.CreatedAt })
+	if row == nil {
+		return nil, db.ErrNotFound
+	}
+	out := *row
+	out.PoseModelData = cloneJSON(row.PoseModelData)
+	out.LandmarkSeries = nil
+	out.DerivedMetrics = cloneJSON(row.DerivedMetrics)
+	out.Measurements = r.b.measurementsOf(row.RomID)
+	return &out, nil
+}
+
+// measurementsOf returns the measurements of a ROM analysis in submission order, the caller holds the lock
+func (b *Backend) measurementsOf(romID uint32) []db.ROMMeasurement {
+	var list []db.ROMMeasurement
+	for _, m := range b.romMeasurements.rows {
+		if m.RomID != romID {
+			continue
+		}
+		out := copyMeasurement(m)
+		out.Joint = b.anatomy.rows[m.AnatomyID].Name
+		list = append(list, out)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].MeasurementID < list[j].MeasurementID })
+	return list
+}
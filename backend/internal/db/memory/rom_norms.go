@@ -0,0 +1,94 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"ai-bot-deecogs/internal/db"
+)
+
+type romNormRepo struct{ b *Backend }
+
+func (r *romNormRepo) List(ctx context.Context) ([]db.ROMNorm, error) {
+	r.b.mu.RLock()
+	defer r.b.mu.RUnlock()
+	list := make([]db.ROMNorm, 0, len(r.b.romNorms.rows))
+	for _, n := range r.b.romNorms.rows {
+		out := *n
+		out.Joint = r.b.anatomy.rows[n.AnatomyID].Name
+		if n.Source != nil {
+			out.Source = ptr(*n.Source)
+		}
+		list = append(list, out)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		if a.AnatomyID != b.AnatomyID {
+			return a.AnatomyID < b.AnatomyID
+		}
+		if a.Movement != b.Movement {
+			return a.Movement < b.Movement
+		}
+		if a.Sex != b.Sex {
+			return a.Sex < b.Sex
+		}
+		if a.AgeMin != b.AgeMin {
+			return a.AgeMin < b.AgeMin
+		}
+		return a.AgeMax < b.AgeMax
+	})
+	return list, nil
+}
+
+func (r *romNormRepo) Upsert(ctx context.Context, norm *db.ROMNorm) error {
+	r.b.mu.Lock()
+	defer r.b.mu.Unlock()
+	if _, ok := r.b.anatomy.rows[norm.AnatomyID]; !ok {
+		return db.Violation(db.ErrForeignKeyViolation, "rom_norms_anatomy_id_fkey")
+	}
+	if err := checkIn(norm.Movement, romMovements, "rom_norms_movement_check"); err != nil {
+		return err
+	}
+	if err := checkIn(norm.Sex, normSexes, "rom_norms_sex_check"); err != nil {
+		return err
+	}
+	if norm.AgeMin < 0 {
+		return db.Violation(db.ErrCheckViolation, "rom_norms_age_min_check")
+	}
+	if norm.AgeMin > norm.AgeMax {
+		return db.Violation(db.ErrCheckViolation, "rom_norms_ages_check")
+	}
+	if norm.NormalMin >= norm.NormalMax {
+		return db.Violation(db.ErrCheckViolation, "rom_norms_range_check")
+	}
+
+	var source *string
+	if norm.Source != nil {
+		source = ptr(*norm.Source)
+	}
+	for _, n := range r.b.romNorms.rows {
+		if n.AnatomyID == norm.AnatomyID && n.Movement == norm.Movement && n.Sex == norm.Sex &&
+			n.AgeMin == norm.AgeMin && n.AgeMax == norm.AgeMax {
+			n.NormalMin, n.NormalMax, n.Source = norm.NormalMin, norm.NormalMax, source
+			norm.NormID, norm.CreatedAt = n.NormID, n.CreatedAt
+			return nil
+		}
+	}
+	row := *norm
+	row.Joint = ""
+	row.Source = source
+	row.CreatedAt = r.b.now()
+	row.NormID = r.b.romNorms.insert(0, &row)
+	norm.NormID, norm.CreatedAt = row.NormID, row.CreatedAt
+	return nil
+}
+
+func (r *romNormRepo) Delete(ctx context.Context, normID uint32) error {
+	r.b.mu.Lock()
+	defer r.b.mu.Unlock()
+	if _, ok := r.b.romNorms.rows[normID]; !ok {
+		return db.ErrNotFound
+	}
+	delete(r.b.romNorms.rows, normID)
+	return nil
+}
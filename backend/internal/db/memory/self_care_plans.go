@@ -0,0 +1,66 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"ai-bot-deecogs/internal/db"
+)
+
+type selfCarePlanRepo struct{ b *Backend }
+
+func (r *selfCarePlanRepo) Create(ctx context.Context, plan *db.SelfCarePlan) error {
+	r.b.mu.Lock()
+	defer r.b.mu.Unlock()
+	if err := r.b.requireAssessment(plan.AssessmentID, "self_care_plans_assessment_id_fkey"); err != nil {
+		return err
+	}
+	if err := checkJSON(plan.SuggestedExercises, "suggested_exercises", false); err != nil {
+		return err
+	}
+	row := *plan
+	row.SuggestedExercises = cloneJSON(plan.SuggestedExercises)
+	row.CreatedAt = r.b.now()
+	row.PlanID = r.b.selfCarePlans.insert(0, &row)
+	plan.PlanID = row.PlanID
+	plan.CreatedAt = row.CreatedAt
+	return nil
+}
+
+func (r *selfCarePlanRepo) GetByAssessment(ctx context.Context, assessmentID uint32) (*db.SelfCarePlan, error) {
+	r.b.mu.RLock()
+	defer r.b.mu.RUnlock()
+	_, row := latest(r.b.selfCarePlans.rows,
+		func(p *db.SelfCarePlan) bool { return p.AssessmentID == assessmentID },
+		func(p *db.SelfCarePlan) time.Time { return p.CreatedAt })
+	if row == nil {
+		return nil, db.ErrNotFound
+	}
+	out := *row
+	out.SuggestedExercises = cloneJSON(row.SuggestedExercises)
+	return &out, nil
+}
+
+func (r *selfCarePlanRepo) FlagCritical(ctx context.Context, assessmentID uint32) error {
+	r.b.mu.Lock()
+	defer r.b.mu.Unlock()
+	if err := r.b.requireAssessment(assessmentID, "self_care_plans_assessment_id_fkey"); err != nil {
+		return err
+	}
+	_, row := latest(r.b.selfCarePlans.rows,
+		func(p *db.SelfCarePlan) bool { return p.AssessmentID == assessmentID },
+		func(p *db.SelfCarePlan) time.Time { return p.CreatedAt })
+	if row != nil {
+		row.CriticalFlag = true
+		return nil
+	}
+	plan := db.SelfCarePlan{
+		AssessmentID:       assessmentID,
+		SuggestedExercises: json.RawMessage(`[]`),
+		CriticalFlag:       true,
+		CreatedAt:          r.b.now(),
+	}
+	plan.PlanID = r.b.selfCarePlans.insert(0, &plan)
+	return nil
+}
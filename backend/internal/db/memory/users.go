@@ -0,0 +1,150 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"ai-bot-deecogs/internal/db"
+)
+
+type userRepo struct{ b *Backend }
+
+// active returns a live user row, the caller holds the lock
+func (r *userRepo) active(userID uint32) (*db.User, bool) {
+	u, ok := r.b.users.rows[userID]
+	if !ok || u.DeletedAt != nil {
+		return nil, false
+	}
+	return u, true
+}
+
+func (r *userRepo) Exists(ctx context.Context, userID uint32) (bool, error) {
+	r.b.mu.RLock()
+	defer r.b.mu.RUnlock()
+	_, ok := r.active(userID)
+	return ok, nil
+}
+
+func (r *userRepo) Get(ctx context.Context, userID uint32) (*db.User, error) {
+	r.b.mu.RLock()
+	defer r.b.mu.RUnlock()
+	u, ok := r.active(userID)
+	if !ok {
+		return nil, db.ErrNotFound
+	}
+	user := *u
+	return &user, nil
+}
+
+func (r *userRepo) GetByEmail(ctx context.Context, email string) (*db.User, error) {
+	r.b.mu.RLock()
+	defer r.b.mu.RUnlock()
+	for _, u := range r.b.users.rows {
+		if u.DeletedAt == nil && u.Email == email {
+			user := *u
+			return &user, nil
+		}
+	}
+	return nil, db.ErrNotFound
+}
+
+func (r *userRepo) List(ctx context.Context) ([]db.User, error) {
+	r.b.mu.RLock()
+	defer r.b.mu.RUnlock()
+	users := make([]db.User, 0, len(r.b.users.rows))
+	for _, u := range r.b.users.rows {
+		if u.DeletedAt == nil {
+			users = append(users, *u)
+		}
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].UserID < users[j].UserID })
+	return users, nil
+}
+
+func (r *userRepo) Create(ctx context.Context, user *db.User) error {
+	row := *user
+	row.UserID = 0
+	row.CreatedAt = time.Time{}
+	row.UpdatedAt = time.Time{}
+	row.DeletedAt = nil
+	id, err := r.b.AddUser(row)
+	if err != nil {
+		return err
+	}
+	created, _ := r.Get(ctx, id)
+	user.UserID = created.UserID
+	user.CreatedAt = created.CreatedAt
+	user.UpdatedAt = created.UpdatedAt
+	return nil
+}
+
+func (r *userRepo) UpdateProfile(ctx context.Context, userID uint32, name, email string, dateOfBirth *time.Time, sex *string) error {
+	r.b.mu.Lock()
+	defer r.b.mu.Unlock()
+	u, ok := r.active(userID)
+	if !ok {
+		return db.ErrNotFound
+	}
+	if r.b.emailTaken(email, userID) {
+		return db.Violation(db.ErrUniqueViolation, db.UserEmailKey)
+	}
+	if sex != nil {
+		if err := checkIn(*sex, userSexes, "users_sex_check"); err != nil {
+			return err
+		}
+		sex = ptr(*sex)
+	}
+	if dateOfBirth != nil {
+		day := dateOfBirth.UTC().Truncate(24 * time.Hour) // a DATE column drops the time
+		dateOfBirth = &day
+	}
+	u.Name = name
+	u.Email = email
+	u.DateOfBirth = dateOfBirth
+	u.Sex = sex
+	u.UpdatedAt = r.b.now()
+	return nil
+}
+
+func (r *userRepo) UpdatePassword(ctx context.Context, userID uint32, passwordHash string) error {
+	r.b.mu.Lock()
+	defer r.b.mu.Unlock()
+	u, ok := r.active(userID)
+	if !ok {
+		return db.ErrNotFound
+	}
+	u.Password = passwordHash
+	u.TokenVersion++
+	u.UpdatedAt = r.b.now()
+	return nil
+}
+
+func (r *userRepo) UpdateRole(ctx context.Context, userID uint32, role string) error {
+	r.b.mu.Lock()
+	defer r.b.mu.Unlock()
+	u, ok := r.active(userID)
+	if !ok {
+		return db.ErrNotFound
+	}
+	if err := checkIn(role, userRoles, "users_role_check"); err != nil {
+		return err
+	}
+	u.Role = role
+	u.UpdatedAt = r.b.now()
+	return nil
+}
+
+func (r *userRepo) SoftDelete(ctx context.Context, userID uint32) error {
+	r.b.mu.Lock()
+	defer r.b.mu.Unlock()
+	u, ok := r.active(userID)
+	if !ok {
+		return db.ErrNotFound
+	}
+	now := r.b.now()
+	u.DeletedAt = &now
+	u.TokenVersion++
+	u.UpdatedAt = now
+	return nil
+}
@@ -0,0 +1,203 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Notification statuses
+const (
+	NotificationPending = "pending"
+	NotificationSending = "sending"
+	NotificationSent    = "sent"
+	NotificationFailed  = "failed"
+	NotificationSkipped = "skipped" // dropped because its event no longer applies
+)
+
+// Notification is a row of the notification_outbox table
+type Notification struct {
+	NotificationID uint32
+	UserID         uint32
+	Kind           string
+	Channel        string
+	DedupeKey      string
+	AssessmentID   *uint32
+	CallID         *uint32
+	Address        *string
+	Subject        string
+	Body           string
+	Status         string
+	Attempts       int
+	MaxAttempts    int
+	RunAfter       time.Time
+	LockedAt       *time.Time
+	LastError      *string
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	SentAt         *time.Time
+}
+
+// NotificationFilter selects outbox rows, nil fields match everything
+type NotificationFilter struct {
+	UserID *uint32
+	Status *string
+	Limit  int // newest rows first, zero means no limit
+}
+
+// NotificationRepo reads and writes the notification_outbox table
+type NotificationRepo struct {
+	pool *pgxpool.Pool
+}
+
+const notificationColumns = `notification_id, user_id, kind, channel, dedupe_key, assessment_id, call_id, address,
+	subject, body, status, attempts, max_attempts, run_after, locked_at, last_error, created_at, updated_at, sent_at`
+
+func scanNotification(row pgx.Row) (*Notification, error) {
+	var n Notification
+	err := row.Scan(&n.NotificationID, &n.UserID, &n.Kind, &n.Channel, &n.DedupeKey, &n.AssessmentID, &n.CallID, &n.Address,
+		&n.Subject, &n.Body, &n.Status, &n.Attempts, &n.MaxAttempts, &n.RunAfter, &n.LockedAt, &n.LastError,
+		&n.CreatedAt, &n.UpdatedAt, &n.SentAt)
+	if err != nil {
+		return nil, translate(err)
+	}
+	return &n, nil
+}
+
+// Enqueue adds a pending message and fills in its id and timestamps. created is false and
+// nothing is written when a message with the same dedupe key exists.
+func (r *NotificationRepo) Enqueue(ctx context.Context, n *Notification) (bool, error) {
+	query := `
+		INSERT INTO notification_outbox (user_id, kind, channel, dedupe_key, assessment_id, call_id, address,
+			subject, body, max_attempts)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (dedupe_key) DO NOTHING
+		RETURNING ` + notificationColumns
+	row, err := scanNotification(r.pool.QueryRow(ctx, query, n.UserID, n.Kind, n.Channel, n.DedupeKey, n.AssessmentID,
+		n.CallID, n.Address, n.Subject, n.Body, n.MaxAttempts))
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	*n = *row
+	return true, nil
+}
+
+// Get returns a message by id
+func (r *NotificationRepo) Get(ctx context.Context, notificationID uint32) (*Notification, error) {
+	query := `SELECT ` + notificationColumns + ` FROM notification_outbox WHERE notification_id = $1`
+	return scanNotification(r.pool.QueryRow(ctx, query, notificationID))
+}
+
+// List returns the messages matching the filter, newest first
+func (r *NotificationRepo) List(ctx context.Context, filter NotificationFilter) ([]Notification, error) {
+	query := `SELECT ` + notificationColumns + ` FROM notification_outbox
+		WHERE ($1::integer IS NULL OR user_id = $1) AND ($2::text IS NULL OR status = $2)
+		ORDER BY notification_id DESC`
+	args := []any{filter.UserID, filter.Status}
+	if filter.Limit > 0 {
+		query += ` LIMIT $3`
+		args = append(args, filter.Limit)
+	}
+	rows, err := r.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, translate(err)
+	}
+	defer rows.Close()
+
+	var notifications []Notification
+	for rows.Next() {
+		n, err := scanNotification(rows)
+		if err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, *n)
+	}
+	return notifications, translate(rows.Err())
+}
+
+// Claim marks the oldest due message as sending and counts the attempt. A sending message
+// locked longer than lease ago lost its dispatcher and is claimed again. ErrNotFound means
+// nothing is due. Both cutoffs are taken from the database clock, the one that wrote
+// locked_at and run_after.
+func (r *NotificationRepo) Claim(ctx context.Context, lease time.Duration) (*Notification, error) {
+	query := `
+		UPDATE notification_outbox
+		SET status = 'sending', attempts = attempts + 1, locked_at = NOW(), updated_at = NOW()
+		WHERE notification_id = (
+			SELECT notification_id FROM notification_outbox
+			WHERE (status = 'pending' AND run_after <= NOW()) OR (status = 'sending' AND locked_at < NOW() - make_interval(secs => $1))
+			ORDER BY run_after, notification_id
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + notificationColumns
+	return scanNotification(r.pool.QueryRow(ctx, query, lease.Seconds()))
+}
+
+// Sent records a delivered message. lockedAt is the claim being finished, ErrConflict means
+// the message was claimed again meanwhile.
+func (r *NotificationRepo) Sent(ctx context.Context, notificationID uint32, lockedAt time.Time) error {
+	query := `
+		UPDATE notification_outbox
+		SET status = 'sent', sent_at = NOW(), locked_at = NULL, last_error = NULL, updated_at = NOW()
+		WHERE notification_id = $1 AND status = 'sending' AND locked_at = $2
+	`
+	return r.finish(ctx, query, notificationID, lockedAt)
+}
+
+// Fail records a failed attempt. The message is pending again retryIn from now, or failed
+// for good when retryIn is nil. ErrConflict means the message was claimed again meanwhile.
+func (r *NotificationRepo) Fail(ctx context.Context, notificationID uint32, lockedAt time.Time, message string, retryIn *time.Duration) error {
+	query := `
+		UPDATE notification_outbox
+		SET status = CASE WHEN $4::float8 IS NULL THEN 'failed' ELSE 'pending' END,
+			run_after = COALESCE(NOW() + make_interval(secs => $4), run_after),
+			last_error = $3, locked_at = NULL, updated_at = NOW()
+		WHERE notification_id = $1 AND status = 'sending' AND locked_at = $2
+	`
+	return r.finish(ctx, query, notificationID, lockedAt, message, seconds(retryIn))
+}
+
+// Skip drops a claimed message whose event no longer applies, reason is kept in last_error
+func (r *NotificationRepo) Skip(ctx context.Context, notificationID uint32, lockedAt time.Time, reason string) error {
+	query := `
+		UPDATE notification_outbox
+		SET status = 'skipped', last_error = $3, locked_at = NULL, updated_at = NOW()
+		WHERE notification_id = $1 AND status = 'sending' AND locked_at = $2
+	`
+	return r.finish(ctx, query, notificationID, lockedAt, reason)
+}
+
+func (r *NotificationRepo) finish(ctx context.Context, query string, notificationID uint32, lockedAt time.Time, args ...any) error {
+	tag, err := r.pool.Exec(ctx, query, append([]any{notificationID, lockedAt}, args...)...)
+	if err != nil {
+		return translate(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrConflict
+	}
+	return nil
+}
+
+// Requeue gives a failed message a fresh set of attempts, ErrConflict means it has not failed
+func (r *NotificationRepo) Requeue(ctx context.Context, notificationID uint32) (*Notification, error) {
+	query := `
+		UPDATE notification_outbox
+		SET status = 'pending', attempts = 0, run_after = NOW(), updated_at = NOW()
+		WHERE notification_id = $1 AND status = 'failed'
+		RETURNING ` + notificationColumns
+	n, err := scanNotification(r.pool.QueryRow(ctx, query, notificationID))
+	if errors.Is(err, ErrNotFound) {
+		if _, getErr := r.Get(ctx, notificationID); getErr != nil {
+			return nil, getErr
+		}
+		return nil, ErrConflict
+	}
+	return n, err
+}
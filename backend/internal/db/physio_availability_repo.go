@@ -0,0 +1,201 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// AvailabilityWindow is a row of the physio_availability table
+type AvailabilityWindow struct {
+	WindowID    uint32
+	PhysioID    uint32
+	Weekday     int // 0 is Sunday
+	StartMinute int // minutes after local midnight
+	EndMinute   int
+	TimeZone    string // IANA zone the minutes are in
+	SlotMinutes int
+	CreatedAt   time.Time
+}
+
+// AvailabilityException is a row of the physio_availability_exceptions table
+type AvailabilityException struct {
+	ExceptionID uint32
+	PhysioID    uint32
+	StartsAt    time.Time
+	EndsAt      time.Time
+	Available   bool // an extra window, otherwise time off
+	Reason      *string
+	CreatedAt   time.Time
+}
+
+// Calendar is the availability of a physiotherapist around a call
+type Calendar struct {
+	Windows    []AvailabilityWindow
+	Exceptions []AvailabilityException
+}
+
+// CalendarCheck vets the slot of a call against the calendar of its physiotherapist. The
+// repositories run it under the locks of the booking, so a slot checked free stays free.
+type CalendarCheck func(call *PhysioCall, calendar Calendar) error
+
+// PhysioAvailabilityRepo reads and writes the physio_availability and
+// physio_availability_exceptions tables
+type PhysioAvailabilityRepo struct {
+	pool *pgxpool.Pool
+}
+
+// querier is the part of a pool or transaction the availability reads need
+type querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+const availabilityWindowColumns = `window_id, physio_id, weekday, start_minute, end_minute, time_zone, slot_minutes, created_at`
+
+const availabilityExceptionColumns = `exception_id, physio_id, starts_at, ends_at, available, reason, created_at`
+
+// ListWindows returns the weekly windows of a physiotherapist by weekday and start
+func (r *PhysioAvailabilityRepo) ListWindows(ctx context.Context, physioID uint32) ([]AvailabilityWindow, error) {
+	return listWindows(ctx, r.pool, physioID)
+}
+
+func listWindows(ctx context.Context, q querier, physioID uint32) ([]AvailabilityWindow, error) {
+	query := `SELECT ` + availabilityWindowColumns + ` FROM physio_availability
+		WHERE physio_id = $1
+		ORDER BY weekday, start_minute, window_id`
+	rows, err := q.Query(ctx, query, physioID)
+	if err != nil {
+		return nil, translate(err)
+	}
+	defer rows.Close()
+
+	var windows []AvailabilityWindow
+	for rows.Next() {
+		var w AvailabilityWindow
+		err := rows.Scan(&w.WindowID, &w.PhysioID, &w.Weekday, &w.StartMinute, &w.EndMinute, &w.TimeZone, &w.SlotMinutes, &w.CreatedAt)
+		if err != nil {
+			return nil, translate(err)
+		}
+		windows = append(windows, w)
+	}
+	return windows, translate(rows.Err())
+}
+
+// ReplaceWindows swaps the weekly windows of a physiotherapist for windows and fills in
+// their ids. It locks the physiotherapist like a booking, so no call is booked against a
+// half written calendar.
+func (r *PhysioAvailabilityRepo) ReplaceWindows(ctx context.Context, physioID uint32, windows []AvailabilityWindow) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return translate(err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `SELECT user_id FROM users WHERE user_id = $1 FOR UPDATE`, physioID); err != nil {
+		return translate(err)
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM physio_availability WHERE physio_id = $1`, physioID); err != nil {
+		return translate(err)
+	}
+	query := `
+		INSERT INTO physio_availability (physio_id, weekday, start_minute, end_minute, time_zone, slot_minutes)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING window_id, created_at
+	`
+	for i := range windows {
+		w := &windows[i]
+		w.PhysioID = physioID
+		err := tx.QueryRow(ctx, query, physioID, w.Weekday, w.StartMinute, w.EndMinute, w.TimeZone, w.SlotMinutes).
+			Scan(&w.WindowID, &w.CreatedAt)
+		if err != nil {
+			return translate(err)
+		}
+	}
+	return translate(tx.Commit(ctx))
+}
+
+// ListExceptions returns the exceptions of a physiotherapist overlapping [from, to) in order
+// of start, nil bounds are open
+func (r *PhysioAvailabilityRepo) ListExceptions(ctx context.Context, physioID uint32, from, to *time.Time) ([]AvailabilityException, error) {
+	return listExceptions(ctx, r.pool, physioID, from, to)
+}
+
+func listExceptions(ctx context.Context, q querier, physioID uint32, from, to *time.Time) ([]AvailabilityException, error) {
+	query := `SELECT ` + availabilityExceptionColumns + ` FROM physio_availability_exceptions
+		WHERE physio_id = $1
+			AND ($2::timestamp IS NULL OR ends_at > $2)
+			AND ($3::timestamp IS NULL OR starts_at < $3)
+		ORDER BY starts_at, exception_id`
+	rows, err := q.Query(ctx, query, physioID, from, to)
+	if err != nil {
+		return nil, translate(err)
+	}
+	defer rows.Close()
+
+	var exceptions []AvailabilityException
+	for rows.Next() {
+		var e AvailabilityException
+		if err := rows.Scan(&e.ExceptionID, &e.PhysioID, &e.StartsAt, &e.EndsAt, &e.Available, &e.Reason, &e.CreatedAt); err != nil {
+			return nil, translate(err)
+		}
+		exceptions = append(exceptions, e)
+	}
+	return exceptions, translate(rows.Err())
+}
+
+// CreateException inserts an exception and fills in its id and creation time, locking the
+// physiotherapist like ReplaceWindows
+func (r *PhysioAvailabilityRepo) CreateException(ctx context.Context, e *AvailabilityException) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return translate(err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `SELECT user_id FROM users WHERE user_id = $1 FOR UPDATE`, e.PhysioID); err != nil {
+		return translate(err)
+	}
+	query := `
+		INSERT INTO physio_availability_exceptions (physio_id, starts_at, ends_at, available, reason)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING exception_id, created_at
+	`
+	err = tx.QueryRow(ctx, query, e.PhysioID, e.StartsAt, e.EndsAt, e.Available, e.Reason).Scan(&e.ExceptionID, &e.CreatedAt)
+	if err != nil {
+		return translate(err)
+	}
+	return translate(tx.Commit(ctx))
+}
+
+// DeleteException removes an exception of a physiotherapist
+func (r *PhysioAvailabilityRepo) DeleteException(ctx context.Context, physioID, exceptionID uint32) error {
+	tag, err := r.pool.Exec(ctx, `DELETE FROM physio_availability_exceptions WHERE exception_id = $1 AND physio_id = $2`, exceptionID, physioID)
+	if err != nil {
+		return translate(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// checkCalendar runs check against the calendar of the physiotherapist of call, inside the
+// booking transaction. Calls without a physiotherapist or slot are not checked.
+func checkCalendar(ctx context.Context, tx pgx.Tx, call *PhysioCall, check CalendarCheck) error {
+	if check == nil || call.PhysioID == nil || call.ScheduledTime == nil {
+		return nil
+	}
+	windows, err := listWindows(ctx, tx, *call.PhysioID)
+	if err != nil {
+		return err
+	}
+	// a day either side covers windows and extra hours adjoining the slot
+	from, to := call.ScheduledTime.Add(-24*time.Hour), call.End().Add(24*time.Hour)
+	exceptions, err := listExceptions(ctx, tx, *call.PhysioID, &from, &to)
+	if err != nil {
+		return err
+	}
+	return check(call, Calendar{Windows: windows, Exceptions: exceptions})
+}
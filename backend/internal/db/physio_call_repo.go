@@ -0,0 +1,274 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PhysioCall is a row of the physio_calls table
+type PhysioCall struct {
+	CallID          uint32
+	AssessmentID    uint32
+	UserID          uint32 // patient of the assessment, read only
+	CallType        string
+	CallStatus      string
+	ScheduledTime   *time.Time
+	DurationMinutes int
+	PhysioID        *uint32
+	InitiatedBy     string
+	StartedAt       *time.Time
+	EndedAt         *time.Time
+	StatusReason    *string
+	Sequence        int // calendar_sequence, bumped by every change after creation
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+// End returns when the slot of a scheduled call ends
+func (c *PhysioCall) End() time.Time {
+	return c.ScheduledTime.Add(time.Duration(c.DurationMinutes) * time.Minute)
+}
+
+// PhysioCallFilter selects calls, nil fields match everything. From and To bound the
+// scheduled time, or the creation time of immediate calls.
+type PhysioCallFilter struct {
+	PhysioID *uint32
+	UserID   *uint32
+	Status   *string
+	From     *time.Time // inclusive
+	To       *time.Time // exclusive
+}
+
+// CallTransition is a status change of a call, applied only if the call is still in From
+type CallTransition struct {
+	From    string
+	To      string
+	Started bool // sets started_at
+	Ended   bool // sets ended_at
+	Reason  *string
+}
+
+// PhysioCallRepo reads and writes the physio_calls table
+type PhysioCallRepo struct {
+	pool *pgxpool.Pool
+}
+
+const physioCallColumns = `c.call_id, c.assessment_id, a.user_id, c.call_type, c.call_status, c.scheduled_time,
+	c.duration_minutes, c.physio_id, c.initiated_by, c.started_at, c.ended_at, c.status_reason, c.calendar_sequence, c.created_at, c.updated_at`
+
+const physioCallFrom = ` FROM physio_calls c JOIN assessments a ON a.assessment_id = c.assessment_id`
+
+func scanPhysioCall(row pgx.Row) (*PhysioCall, error) {
+	var call PhysioCall
+	err := row.Scan(
+		&call.CallID,
+		&call.AssessmentID,
+		&call.UserID,
+		&call.CallType,
+		&call.CallStatus,
+		&call.ScheduledTime,
+		&call.DurationMinutes,
+		&call.PhysioID,
+		&call.InitiatedBy,
+		&call.StartedAt,
+		&call.EndedAt,
+		&call.StatusReason,
+		&call.Sequence,
+		&call.CreatedAt,
+		&call.UpdatedAt,
+	)
+	if err != nil {
+		return nil, translate(err)
+	}
+	return &call, nil
+}
+
+// Create inserts a call and fills in its id, patient and timestamps. A scheduled call is
+// rejected with ErrOverlap when its slot overlaps an open call of the patient or physiotherapist,
+// and with the error of check when it does not fit the physiotherapist's calendar.
+func (r *PhysioCallRepo) Create(ctx context.Context, call *PhysioCall, check CalendarCheck) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return translate(err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := lockCallParties(ctx, tx, call); err != nil {
+		return err
+	}
+	if err := checkCalendar(ctx, tx, call, check); err != nil {
+		return err
+	}
+	if err := checkCallOverlap(ctx, tx, call); err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO physio_calls (assessment_id, call_type, call_status, scheduled_time, duration_minutes, physio_id, initiated_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING call_id, created_at, updated_at
+	`
+	err = tx.QueryRow(ctx, query,
+		call.AssessmentID, call.CallType, call.CallStatus, call.ScheduledTime, call.DurationMinutes, call.PhysioID, call.InitiatedBy,
+	).Scan(&call.CallID, &call.CreatedAt, &call.UpdatedAt)
+	if err != nil {
+		return translate(err)
+	}
+	return translate(tx.Commit(ctx))
+}
+
+// lockCallParties fills in the patient of the call and locks the patient and physiotherapist
+// rows, so overlap checks of calls sharing either of them run one at a time
+func lockCallParties(ctx context.Context, tx pgx.Tx, call *PhysioCall) error {
+	err := tx.QueryRow(ctx, `SELECT user_id FROM assessments WHERE assessment_id = $1`, call.AssessmentID).Scan(&call.UserID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Violation(ErrForeignKeyViolation, "physio_calls_assessment_id_fkey")
+		}
+		return translate(err)
+	}
+	ids := []uint32{call.UserID}
+	if call.PhysioID != nil {
+		ids = append(ids, *call.PhysioID)
+	}
+	_, err = tx.Exec(ctx, `SELECT user_id FROM users WHERE user_id = ANY($1) ORDER BY user_id FOR UPDATE`, ids)
+	return translate(err)
+}
+
+// checkCallOverlap returns ErrOverlap when another open call of the patient or physiotherapist
+// overlaps the slot of call. Calls without a scheduled time do not hold a slot.
+func checkCallOverlap(ctx context.Context, tx pgx.Tx, call *PhysioCall) error {
+	if call.ScheduledTime == nil {
+		return nil
+	}
+	query := `
+		SELECT EXISTS (
+			SELECT 1` + physioCallFrom + `
+			WHERE c.call_id <> $1
+				AND c.call_status IN ('scheduled', 'in_progress')
+				AND c.scheduled_time < $3
+				AND c.scheduled_time + make_interval(mins => c.duration_minutes) > $2
+				AND (a.user_id = $4 OR c.physio_id = $5::int)
+		)
+	`
+	var overlaps bool
+	err := tx.QueryRow(ctx, query, call.CallID, *call.ScheduledTime, call.End(), call.UserID, call.PhysioID).Scan(&overlaps)
+	if err != nil {
+		return translate(err)
+	}
+	if overlaps {
+		return ErrOverlap
+	}
+	return nil
+}
+
+// Get returns a single call
+func (r *PhysioCallRepo) Get(ctx context.Context, callID uint32) (*PhysioCall, error) {
+	return scanPhysioCall(r.pool.QueryRow(ctx, `SELECT `+physioCallColumns+physioCallFrom+` WHERE c.call_id = $1`, callID))
+}
+
+// ListByAssessment returns the calls of an assessment, newest first
+func (r *PhysioCallRepo) ListByAssessment(ctx context.Context, assessmentID uint32) ([]PhysioCall, error) {
+	return r.list(ctx, `SELECT `+physioCallColumns+physioCallFrom+`
+		WHERE c.assessment_id = $1
+		ORDER BY c.created_at DESC, c.call_id DESC`, assessmentID)
+}
+
+// List returns the calls matching a filter in time order
+func (r *PhysioCallRepo) List(ctx context.Context, f PhysioCallFilter) ([]PhysioCall, error) {
+	query := `SELECT ` + physioCallColumns + physioCallFrom + `
+		WHERE ($1::int IS NULL OR c.physio_id = $1)
+			AND ($2::int IS NULL OR a.user_id = $2)
+			AND ($3::text IS NULL OR c.call_status = $3)
+			AND ($4::timestamp IS NULL OR COALESCE(c.scheduled_time, c.created_at) >= $4)
+			AND ($5::timestamp IS NULL OR COALESCE(c.scheduled_time, c.created_at) < $5)
+		ORDER BY COALESCE(c.scheduled_time, c.created_at), c.call_id`
+	return r.list(ctx, query, f.PhysioID, f.UserID, f.Status, f.From, f.To)
+}
+
+func (r *PhysioCallRepo) list(ctx context.Context, query string, args ...any) ([]PhysioCall, error) {
+	rows, err := r.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, translate(err)
+	}
+	defer rows.Close()
+
+	var calls []PhysioCall
+	for rows.Next() {
+		call, err := scanPhysioCall(rows)
+		if err != nil {
+			return nil, err
+		}
+		calls = append(calls, *call)
+	}
+	return calls, translate(rows.Err())
+}
+
+// Update writes the physiotherapist and slot of a scheduled call. It returns ErrConflict once
+// the call left scheduled, ErrOverlap when the new slot is taken and the error of check when
+// it does not fit the physiotherapist's calendar.
+func (r *PhysioCallRepo) Update(ctx context.Context, call *PhysioCall, check CalendarCheck) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return translate(err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := lockCallParties(ctx, tx, call); err != nil {
+		return err
+	}
+	if err := checkCalendar(ctx, tx, call, check); err != nil {
+		return err
+	}
+	if err := checkCallOverlap(ctx, tx, call); err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE physio_calls
+		SET physio_id = $1, scheduled_time = $2, duration_minutes = $3,
+			calendar_sequence = calendar_sequence + 1, updated_at = NOW()
+		WHERE call_id = $4 AND call_status = 'scheduled'
+		RETURNING calendar_sequence, updated_at
+	`
+	err = tx.QueryRow(ctx, query, call.PhysioID, call.ScheduledTime, call.DurationMinutes, call.CallID).Scan(&call.Sequence, &call.UpdatedAt)
+	if err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return translate(err)
+		}
+		if _, err := r.Get(ctx, call.CallID); err != nil {
+			return err
+		}
+		return ErrConflict
+	}
+	return translate(tx.Commit(ctx))
+}
+
+// Transition moves a call to a new status, ErrConflict means it was not in t.From anymore
+func (r *PhysioCallRepo) Transition(ctx context.Context, callID uint32, t CallTransition) (*PhysioCall, error) {
+	query := `
+		UPDATE physio_calls
+		SET call_status = $1,
+			started_at = CASE WHEN $2 THEN NOW() ELSE started_at END,
+			ended_at = CASE WHEN $3 THEN NOW() ELSE ended_at END,
+			status_reason = COALESCE($4, status_reason),
+			calendar_sequence = calendar_sequence + 1,
+			updated_at = NOW()
+		WHERE call_id = $5 AND call_status = $6
+	`
+	tag, err := r.pool.Exec(ctx, query, t.To, t.Started, t.Ended, t.Reason, callID, t.From)
+	if err != nil {
+		return nil, translate(err)
+	}
+	if tag.RowsAffected() == 0 {
+		if _, err := r.Get(ctx, callID); err != nil {
+			return nil, err
+		}
+		return nil, ErrConflict
+	}
+	return r.Get(ctx, callID)
+}
@@ -0,0 +1,49 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Questionnaire is a row of the questionnaires table
+type Questionnaire struct {
+	QuestionID   uint32
+	AssessmentID uint32
+	ChatHistory  json.RawMessage
+	CreatedAt    time.Time
+}
+
+// QuestionnaireRepo reads and writes the questionnaires table
+type QuestionnaireRepo struct {
+	pool *pgxpool.Pool
+}
+
+// Create stores a questionnaire chat history and returns its id
+func (r *QuestionnaireRepo) Create(ctx context.Context, assessmentID uint32, chatHistory json.RawMessage) (uint32, error) {
+	var questionID uint32
+	err := r.pool.QueryRow(ctx,
+		`INSERT INTO questionnaires (assessment_id, chat_history) VALUES ($1, $2) RETURNING question_id`,
+		assessmentID, chatHistory,
+	).Scan(&questionID)
+	return questionID, translate(err)
+}
+
+// Latest returns the most recent questionnaire of an assessment
+func (r *QuestionnaireRepo) Latest(ctx context.Context, assessmentID uint32) (*Questionnaire, error) {
+	query := `
+		SELECT question_id, assessment_id, chat_history, created_at
+		FROM questionnaires
+		WHERE assessment_id = $1
+		ORDER BY created_at DESC
+		LIMIT 1
+	`
+	var q Questionnaire
+	err := r.pool.QueryRow(ctx, query, assessmentID).Scan(&q.QuestionID, &q.AssessmentID, &q.ChatHistory, &q.CreatedAt)
+	if err != nil {
+		return nil, translate(err)
+	}
+	return &q, nil
+}
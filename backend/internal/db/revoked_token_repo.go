@@ -0,0 +1,49 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// RevokedToken is a row of the revoked_tokens table
+type RevokedToken struct {
+	JTI       string
+	UserID    uint32
+	TokenType string
+	ExpiresAt time.Time
+	RevokedAt time.Time
+}
+
+// RevokedTokenRepo reads and writes the revoked_tokens table
+type RevokedTokenRepo struct {
+	pool *pgxpool.Pool
+}
+
+// Revoke records a token id as revoked, revoking twice is a no-op
+func (r *RevokedTokenRepo) Revoke(ctx context.Context, token RevokedToken) error {
+	query := `
+		INSERT INTO revoked_tokens (jti, user_id, token_type, expires_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (jti) DO NOTHING
+	`
+	_, err := r.pool.Exec(ctx, query, token.JTI, token.UserID, token.TokenType, token.ExpiresAt)
+	return translate(err)
+}
+
+// IsRevoked reports whether a token id has been revoked
+func (r *RevokedTokenRepo) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	var revoked bool
+	err := r.pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = $1)`, jti).Scan(&revoked)
+	return revoked, translate(err)
+}
+
+// PurgeExpired deletes revocations of tokens that have expired anyway
+func (r *RevokedTokenRepo) PurgeExpired(ctx context.Context, before time.Time) (int64, error) {
+	tag, err := r.pool.Exec(ctx, `DELETE FROM revoked_tokens WHERE expires_at < $1`, before)
+	if err != nil {
+		return 0, translate(err)
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,97 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// NormSexAny is the sex of a normative range that applies to everyone
+const NormSexAny = "any"
+
+// ROMNorm is a row of the rom_norms table
+type ROMNorm struct {
+	NormID    uint32
+	AnatomyID uint32
+	Joint     string // anatomy name, read only
+	Movement  string
+	Sex       string
+	AgeMin    int
+	AgeMax    int
+	NormalMin float64
+	NormalMax float64
+	Source    *string
+	CreatedAt time.Time
+}
+
+// ROMNormRepo reads and writes the rom_norms table
+type ROMNormRepo struct {
+	pool *pgxpool.Pool
+}
+
+const romNormColumns = `n.norm_id, n.anatomy_id, a.name, n.movement, n.sex, n.age_min, n.age_max,
+	n.normal_min, n.normal_max, n.source, n.created_at`
+
+func scanROMNorm(row pgx.Row) (*ROMNorm, error) {
+	var n ROMNorm
+	err := row.Scan(&n.NormID, &n.AnatomyID, &n.Joint, &n.Movement, &n.Sex, &n.AgeMin, &n.AgeMax,
+		&n.NormalMin, &n.NormalMax, &n.Source, &n.CreatedAt)
+	if err != nil {
+		return nil, translate(err)
+	}
+	return &n, nil
+}
+
+// List returns every normative range ordered by anatomy, movement, sex and age band
+func (r *ROMNormRepo) List(ctx context.Context) ([]ROMNorm, error) {
+	query := `
+		SELECT ` + romNormColumns + `
+		FROM rom_norms n
+		JOIN anatomy a ON a.anatomy_id = n.anatomy_id
+		ORDER BY n.anatomy_id, n.movement, n.sex, n.age_min, n.age_max
+	`
+	rows, err := r.pool.Query(ctx, query)
+	if err != nil {
+		return nil, translate(err)
+	}
+	defer rows.Close()
+
+	var list []ROMNorm
+	for rows.Next() {
+		n, err := scanROMNorm(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *n)
+	}
+	return list, translate(rows.Err())
+}
+
+// Upsert stores a normative range, replacing the range and source of an existing band,
+// and fills in its id and creation time
+func (r *ROMNormRepo) Upsert(ctx context.Context, n *ROMNorm) error {
+	query := `
+		INSERT INTO rom_norms (anatomy_id, movement, sex, age_min, age_max, normal_min, normal_max, source)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (anatomy_id, movement, sex, age_min, age_max)
+		DO UPDATE SET normal_min = EXCLUDED.normal_min, normal_max = EXCLUDED.normal_max, source = EXCLUDED.source
+		RETURNING norm_id, created_at
+	`
+	err := r.pool.QueryRow(ctx, query, n.AnatomyID, n.Movement, n.Sex, n.AgeMin, n.AgeMax, n.NormalMin, n.NormalMax, n.Source).
+		Scan(&n.NormID, &n.CreatedAt)
+	return translate(err)
+}
+
+// Delete removes a normative range
+func (r *ROMNormRepo) Delete(ctx context.Context, normID uint32) error {
+	tag, err := r.pool.Exec(ctx, `DELETE FROM rom_norms WHERE norm_id = $1`, normID)
+	if err != nil {
+		return translate(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
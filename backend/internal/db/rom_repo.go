@@ -0,0 +1,134 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ROM analysis sources
+const (
+	ROMSourceClient    = "client"    // angles measured by the frontend
+	ROMSourceLandmarks = "landmarks" // angles computed here from pose landmarks
+)
+
+// ROMAnalysis is a row of the rom_analysis table with its measurements
+type ROMAnalysis struct {
+	RomID          uint32
+	AssessmentID   uint32
+	PoseModelData  json.RawMessage
+	Source         string
+	LandmarkSeries json.RawMessage // not read back by Latest, it can be large
+	DerivedMetrics json.RawMessage
+	CreatedAt      time.Time
+	Measurements   []ROMMeasurement
+}
+
+// ROMMeasurement is a row of the rom_measurements table
+type ROMMeasurement struct {
+	MeasurementID  uint32
+	RomID          uint32
+	AnatomyID      uint32
+	Joint          string // anatomy name, read only
+	Movement       string
+	Side           *string
+	Mode           string
+	MinAngle       float64
+	MaxAngle       float64
+	PeakAngle      *float64
+	Repetitions    *int
+	PainOnsetAngle *float64
+}
+
+// ROMRepo reads and writes the rom_analysis and rom_measurements tables
+type ROMRepo struct {
+	pool *pgxpool.Pool
+}
+
+// Create stores a ROM analysis and its measurements in one transaction and fills in its id and creation time
+func (r *ROMRepo) Create(ctx context.Context, rom *ROMAnalysis) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return translate(err)
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+		INSERT INTO rom_analysis (assessment_id, pose_model_data, source, landmark_series, derived_metrics, created_at)
+		VALUES ($1, $2, $3, $4, $5, NOW())
+		RETURNING rom_id, created_at
+	`
+	err = tx.QueryRow(ctx, query, rom.AssessmentID, rom.PoseModelData, rom.Source, rom.LandmarkSeries, rom.DerivedMetrics).
+		Scan(&rom.RomID, &rom.CreatedAt)
+	if err != nil {
+		return translate(err)
+	}
+
+	query = `
+		INSERT INTO rom_measurements (rom_id, anatomy_id, movement, side, mode, min_angle, max_angle,
+			peak_angle, repetitions, pain_onset_angle)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING measurement_id
+	`
+	for i := range rom.Measurements {
+		m := &rom.Measurements[i]
+		m.RomID = rom.RomID
+		err := tx.QueryRow(ctx, query, m.RomID, m.AnatomyID, m.Movement, m.Side, m.Mode, m.MinAngle, m.MaxAngle,
+			m.PeakAngle, m.Repetitions, m.PainOnsetAngle).Scan(&m.MeasurementID)
+		if err != nil {
+			return translate(err)
+		}
+	}
+	return translate(tx.Commit(ctx))
+}
+
+// Latest returns the most recent ROM analysis of an assessment
+func (r *ROMRepo) Latest(ctx context.Context, assessmentID uint32) (*ROMAnalysis, error) {
+	query := `
+		SELECT rom_id, assessment_id, pose_model_data, source, derived_metrics, created_at
+		FROM rom_analysis
+		WHERE assessment_id = $1
+		ORDER BY created_at DESC
+		LIMIT 1
+	`
+	var rom ROMAnalysis
+	err := r.pool.QueryRow(ctx, query, assessmentID).Scan(&rom.RomID, &rom.AssessmentID, &rom.PoseModelData, &rom.Source, &rom.DerivedMetrics, &rom.CreatedAt)
+	if err != nil {
+		return nil, translate(err)
+	}
+	if rom.Measurements, err = r.measurements(ctx, rom.RomID); err != nil {
+		return nil, err
+	}
+	return &rom, nil
+}
+
+// measurements returns the measurements of a ROM analysis in submission order
+func (r *ROMRepo) measurements(ctx context.Context, romID uint32) ([]ROMMeasurement, error) {
+	query := `
+		SELECT m.measurement_id, m.rom_id, m.anatomy_id, a.name, m.movement, m.side, m.mode,
+			m.min_angle, m.max_angle, m.peak_angle, m.repetitions, m.pain_onset_angle
+		FROM rom_measurements m
+		JOIN anatomy a ON a.anatomy_id = m.anatomy_id
+		WHERE m.rom_id = $1
+		ORDER BY m.measurement_id
+	`
+	rows, err := r.pool.Query(ctx, query, romID)
+	if err != nil {
+		return nil, translate(err)
+	}
+	defer rows.Close()
+
+	var list []ROMMeasurement
+	for rows.Next() {
+		var m ROMMeasurement
+		err := rows.Scan(&m.MeasurementID, &m.RomID, &m.AnatomyID, &m.Joint, &m.Movement, &m.Side, &m.Mode,
+			&m.MinAngle, &m.MaxAngle, &m.PeakAngle, &m.Repetitions, &m.PainOnsetAngle)
+		if err != nil {
+			return nil, translate(err)
+		}
+		list = append(list, m)
+	}
+	return list, translate(rows.Err())
+}
@@ -0,0 +1,83 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// SelfCarePlan is a row of the self_care_plans table
+type SelfCarePlan struct {
+	PlanID             uint32
+	AssessmentID       uint32
+	PlanName           *string
+	SuggestedExercises json.RawMessage
+	CriticalFlag       bool
+	CreatedAt          time.Time
+}
+
+// SelfCarePlanRepo reads and writes the self_care_plans table
+type SelfCarePlanRepo struct {
+	pool *pgxpool.Pool
+}
+
+// GetByAssessment returns the most recent self-care plan of an assessment
+func (r *SelfCarePlanRepo) GetByAssessment(ctx context.Context, assessmentID uint32) (*SelfCarePlan, error) {
+	query := `
+		SELECT plan_id, assessment_id, plan_name, suggested_exercises, COALESCE(critical_flag, FALSE), created_at
+		FROM self_care_plans
+		WHERE assessment_id = $1
+		ORDER BY created_at DESC
+		LIMIT 1
+	`
+	var plan SelfCarePlan
+	err := r.pool.QueryRow(ctx, query, assessmentID).Scan(
+		&plan.PlanID,
+		&plan.AssessmentID,
+		&plan.PlanName,
+		&plan.SuggestedExercises,
+		&plan.CriticalFlag,
+		&plan.CreatedAt,
+	)
+	if err != nil {
+		return nil, translate(err)
+	}
+	return &plan, nil
+}
+
+// Create inserts a self-care plan and fills in its id and creation time
+func (r *SelfCarePlanRepo) Create(ctx context.Context, plan *SelfCarePlan) error {
+	query := `
+		INSERT INTO self_care_plans (assessment_id, plan_name, suggested_exercises, critical_flag)
+		VALUES ($1, $2, $3, $4)
+		RETURNING plan_id, created_at
+	`
+	err := r.pool.QueryRow(ctx, query,
+		plan.AssessmentID, plan.PlanName, plan.SuggestedExercises, plan.CriticalFlag,
+	).Scan(&plan.PlanID, &plan.CreatedAt)
+	return translate(err)
+}
+
+// FlagCritical sets the critical flag of the most recent plan of an assessment. An assessment
+// without a plan gets an empty one carrying the flag.
+func (r *SelfCarePlanRepo) FlagCritical(ctx context.Context, assessmentID uint32) error {
+	query := `
+		WITH flagged AS (
+			UPDATE self_care_plans SET critical_flag = TRUE
+			WHERE plan_id = (
+				SELECT plan_id FROM self_care_plans
+				WHERE assessment_id = $1
+				ORDER BY created_at DESC
+				LIMIT 1
+			)
+			RETURNING plan_id
+		)
+		INSERT INTO self_care_plans (assessment_id, suggested_exercises, critical_flag)
+		SELECT $1, '[]', TRUE
+		WHERE NOT EXISTS (SELECT 1 FROM flagged)
+	`
+	_, err := r.pool.Exec(ctx, query, assessmentID)
+	return translate(err)
+}
@@ -0,0 +1,211 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// UserStore is the storage contract for the users table
+type UserStore interface {
+	Exists(ctx context.Context, userID uint32) (bool, error)
+	Get(ctx context.Context, userID uint32) (*User, error)
+	GetByEmail(ctx context.Context, email string) (*User, error)
+	List(ctx context.Context) ([]User, error)
+	Create(ctx context.Context, user *User) error
+	UpdateProfile(ctx context.Context, userID uint32, name, email string, dateOfBirth *time.Time, sex *string) error
+	UpdatePassword(ctx context.Context, userID uint32, passwordHash string) error
+	UpdateRole(ctx context.Context, userID uint32, role string) error
+	SoftDelete(ctx context.Context, userID uint32) error
+}
+
+// AnatomyStore is the storage contract for the anatomy table
+type AnatomyStore interface {
+	List(ctx context.Context) ([]Anatomy, error)
+	Get(ctx context.Context, anatomyID uint32) (*Anatomy, error)
+	Create(ctx context.Context, anatomy *Anatomy) error
+	Update(ctx context.Context, anatomy *Anatomy) error
+	Delete(ctx context.Context, anatomyID uint32) error
+	InUse(ctx context.Context, anatomyID uint32) (bool, error)
+}
+
+// RevokedTokenStore is the storage contract for the revoked_tokens table
+type RevokedTokenStore interface {
+	Revoke(ctx context.Context, token RevokedToken) error
+	IsRevoked(ctx context.Context, jti string) (bool, error)
+	PurgeExpired(ctx context.Context, before time.Time) (int64, error)
+}
+
+// AssessmentStore is the storage contract for the assessments table
+type AssessmentStore interface {
+	Create(ctx context.Context, userID, anatomyID uint32, assessmentType, status string) (*Assessment, error)
+	Get(ctx context.Context, assessmentID uint32) (*Assessment, error)
+	ListByPhysio(ctx context.Context, physioID uint32) ([]Assessment, error)
+	ListByUser(ctx context.Context, userID uint32) ([]Assessment, error)
+	AssignPhysio(ctx context.Context, assessmentID uint32, physioID *uint32) error
+	Transition(ctx context.Context, assessmentID uint32, t StatusTransition) (*AssessmentEvent, error)
+	RecordPhase(ctx context.Context, assessmentID uint32, phase string, completion float64) (*AssessmentEvent, error)
+	SaveChatHistory(ctx context.Context, assessmentID uint32, chatHistory json.RawMessage) error
+	Touch(ctx context.Context, assessmentID uint32) error
+	ListIdle(ctx context.Context, idleFor time.Duration, limit int) ([]Assessment, error)
+}
+
+// AssessmentEventStore is the storage contract for the assessment_events table
+type AssessmentEventStore interface {
+	ListByAssessment(ctx context.Context, assessmentID uint32) ([]AssessmentEvent, error)
+}
+
+// ChatMessageStore is the storage contract for the chat_messages table
+type ChatMessageStore interface {
+	Append(ctx context.Context, assessmentID uint32, messages []ChatMessage) ([]ChatMessage, error)
+	ListByAssessment(ctx context.Context, assessmentID uint32) ([]ChatMessage, error)
+}
+
+// QuestionnaireStore is the storage contract for the questionnaires table
+type QuestionnaireStore interface {
+	Create(ctx context.Context, assessmentID uint32, chatHistory json.RawMessage) (uint32, error)
+	Latest(ctx context.Context, assessmentID uint32) (*Questionnaire, error)
+}
+
+// ROMStore is the storage contract for the rom_analysis and rom_measurements tables
+type ROMStore interface {
+	Create(ctx context.Context, rom *ROMAnalysis) error
+	Latest(ctx context.Context, assessmentID uint32) (*ROMAnalysis, error)
+}
+
+// ROMNormStore is the storage contract for the rom_norms table
+type ROMNormStore interface {
+	List(ctx context.Context) ([]ROMNorm, error)
+	Upsert(ctx context.Context, norm *ROMNorm) error
+	Delete(ctx context.Context, normID uint32) error
+}
+
+// AIAnalysisStore is the storage contract for the ai_analysis table
+type AIAnalysisStore interface {
+	Create(ctx context.Context, assessmentID uint32, assessmentData, analysedResults json.RawMessage) (uint32, error)
+	Get(ctx context.Context, analysisID uint32) (*AIAnalysis, error)
+	Latest(ctx context.Context, assessmentID uint32) (*AIAnalysis, error)
+}
+
+// AnalysisJobStore is the storage contract for the analysis_jobs table
+type AnalysisJobStore interface {
+	Enqueue(ctx context.Context, assessmentID uint32, revision string, input json.RawMessage, maxAttempts int) (*AnalysisJob, bool, error)
+	Get(ctx context.Context, jobID uint32) (*AnalysisJob, error)
+	Latest(ctx context.Context, assessmentID uint32) (*AnalysisJob, error)
+	Claim(ctx context.Context, lease time.Duration) (*AnalysisJob, error)
+	Succeed(ctx context.Context, jobID uint32, lockedAt time.Time, analysedResults json.RawMessage) (*AnalysisJob, error)
+	Fail(ctx context.Context, jobID uint32, lockedAt time.Time, message string, retryIn *time.Duration) error
+	Requeue(ctx context.Context, jobID uint32) (*AnalysisJob, error)
+}
+
+// PhysioCallStore is the storage contract for the physio_calls table
+type PhysioCallStore interface {
+	Create(ctx context.Context, call *PhysioCall, check CalendarCheck) error
+	Get(ctx context.Context, callID uint32) (*PhysioCall, error)
+	ListByAssessment(ctx context.Context, assessmentID uint32) ([]PhysioCall, error)
+	List(ctx context.Context, filter PhysioCallFilter) ([]PhysioCall, error)
+	Update(ctx context.Context, call *PhysioCall, check CalendarCheck) error
+	Transition(ctx context.Context, callID uint32, t CallTransition) (*PhysioCall, error)
+}
+
+// PhysioAvailabilityStore is the storage contract for the physio_availability and
+// physio_availability_exceptions tables
+type PhysioAvailabilityStore interface {
+	ListWindows(ctx context.Context, physioID uint32) ([]AvailabilityWindow, error)
+	ReplaceWindows(ctx context.Context, physioID uint32, windows []AvailabilityWindow) error
+	ListExceptions(ctx context.Context, physioID uint32, from, to *time.Time) ([]AvailabilityException, error)
+	CreateException(ctx context.Context, exception *AvailabilityException) error
+	DeleteException(ctx context.Context, physioID, exceptionID uint32) error
+}
+
+// CalendarTokenStore is the storage contract for the calendar_feed_tokens table
+type CalendarTokenStore interface {
+	Create(ctx context.Context, token *CalendarToken) error
+	ListByUser(ctx context.Context, userID uint32) ([]CalendarToken, error)
+	Use(ctx context.Context, tokenHash string) (*CalendarToken, error)
+	Revoke(ctx context.Context, userID, tokenID uint32) error
+}
+
+// NotificationStore is the storage contract for the notification_outbox table
+type NotificationStore interface {
+	Enqueue(ctx context.Context, n *Notification) (bool, error)
+	Get(ctx context.Context, notificationID uint32) (*Notification, error)
+	List(ctx context.Context, filter NotificationFilter) ([]Notification, error)
+	Claim(ctx context.Context, lease time.Duration) (*Notification, error)
+	Sent(ctx context.Context, notificationID uint32, lockedAt time.Time) error
+	Fail(ctx context.Context, notificationID uint32, lockedAt time.Time, message string, retryIn *time.Duration) error
+	Skip(ctx context.Context, notificationID uint32, lockedAt time.Time, reason string) error
+	Requeue(ctx context.Context, notificationID uint32) (*Notification, error)
+}
+
+// SelfCarePlanStore is the storage contract for the self_care_plans table
+type SelfCarePlanStore interface {
+	Create(ctx context.Context, plan *SelfCarePlan) error
+	GetByAssessment(ctx context.Context, assessmentID uint32) (*SelfCarePlan, error)
+	FlagCritical(ctx context.Context, assessmentID uint32) error
+}
+
+// EscalationStore is the storage contract for the red_flag_escalations table
+type EscalationStore interface {
+	Record(ctx context.Context, escalation *Escalation) error
+	GetByAssessment(ctx context.Context, assessmentID uint32) (*Escalation, error)
+	SetCall(ctx context.Context, escalationID, callID uint32) error
+}
+
+// Store groups the per-table repositories of one storage backend
+type Store struct {
+	Users          UserStore
+	Anatomy        AnatomyStore
+	Assessments    AssessmentStore
+	Events         AssessmentEventStore
+	ChatMessages   ChatMessageStore
+	Questionnaires QuestionnaireStore
+	ROM            ROMStore
+	ROMNorms       ROMNormStore
+	AIAnalysis     AIAnalysisStore
+	AnalysisJobs   AnalysisJobStore
+	PhysioCalls    PhysioCallStore
+	Availability   PhysioAvailabilityStore
+	SelfCarePlans  SelfCarePlanStore
+	RevokedTokens  RevokedTokenStore
+	CalendarTokens CalendarTokenStore
+	Notifications  NotificationStore
+	Escalations    EscalationStore
+
+	// Ping checks the backend is reachable, nil means always ready
+	Ping func(ctx context.Context) error
+}
+
+// NewStore builds the Postgres repositories on top of a pool
+func NewStore(pool *pgxpool.Pool) *Store {
+	return &Store{
+		Users:          &UserRepo{pool: pool},
+		Anatomy:        &AnatomyRepo{pool: pool},
+		Assessments:    &AssessmentRepo{pool: pool},
+		Events:         &AssessmentEventRepo{pool: pool},
+		ChatMessages:   &ChatMessageRepo{pool: pool},
+		Questionnaires: &QuestionnaireRepo{pool: pool},
+		ROM:            &ROMRepo{pool: pool},
+		ROMNorms:       &ROMNormRepo{pool: pool},
+		AIAnalysis:     &AIAnalysisRepo{pool: pool},
+		AnalysisJobs:   &AnalysisJobRepo{pool: pool},
+		PhysioCalls:    &PhysioCallRepo{pool: pool},
+		Availability:   &PhysioAvailabilityRepo{pool: pool},
+		SelfCarePlans:  &SelfCarePlanRepo{pool: pool},
+		RevokedTokens:  &RevokedTokenRepo{pool: pool},
+		CalendarTokens: &CalendarTokenRepo{pool: pool},
+		Notifications:  &NotificationRepo{pool: pool},
+		Escalations:    &EscalationRepo{pool: pool},
+		Ping:           pool.Ping,
+	}
+}
+
+// Ready reports whether the backend can serve queries
+func (s *Store) Ready(ctx context.Context) error {
+	if s.Ping == nil {
+		return nil
+	}
+	return s.Ping(ctx)
+}
@@ -0,0 +1,126 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// User is a row of the users table
+type User struct {
+	UserID       uint32
+	Name         string
+	Email        string
+	Password     string
+	Role         string
+	DateOfBirth  *time.Time
+	Sex          *string
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	DeletedAt    *time.Time
+	TokenVersion int32 // raised on a password change or deletion, tokens signed with an older one are refused
+}
+
+// UserEmailKey is the unique index on the email of active users
+const UserEmailKey = "users_email_active_key"
+
+// UserRepo reads and writes the users table
+type UserRepo struct {
+	pool *pgxpool.Pool
+}
+
+const userColumns = `user_id, name, email, password, role, date_of_birth, sex, created_at, COALESCE(updated_at, created_at), deleted_at, token_version`
+
+func scanUser(row pgx.Row) (*User, error) {
+	var user User
+	err := row.Scan(&user.UserID, &user.Name, &user.Email, &user.Password, &user.Role, &user.DateOfBirth, &user.Sex, &user.CreatedAt, &user.UpdatedAt, &user.DeletedAt, &user.TokenVersion)
+	if err != nil {
+		return nil, translate(err)
+	}
+	return &user, nil
+}
+
+// Exists reports whether an active user with the given id exists
+func (r *UserRepo) Exists(ctx context.Context, userID uint32) (bool, error) {
+	var exists bool
+	err := r.pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM users WHERE user_id = $1 AND deleted_at IS NULL)`, userID).Scan(&exists)
+	return exists, translate(err)
+}
+
+// Get returns a single active user
+func (r *UserRepo) Get(ctx context.Context, userID uint32) (*User, error) {
+	return scanUser(r.pool.QueryRow(ctx, `SELECT `+userColumns+` FROM users WHERE user_id = $1 AND deleted_at IS NULL`, userID))
+}
+
+// GetByEmail looks an active user up by email
+func (r *UserRepo) GetByEmail(ctx context.Context, email string) (*User, error) {
+	return scanUser(r.pool.QueryRow(ctx, `SELECT `+userColumns+` FROM users WHERE email = $1 AND deleted_at IS NULL`, email))
+}
+
+// List returns every active user ordered by id
+func (r *UserRepo) List(ctx context.Context) ([]User, error) {
+	rows, err := r.pool.Query(ctx, `SELECT `+userColumns+` FROM users WHERE deleted_at IS NULL ORDER BY user_id`)
+	if err != nil {
+		return nil, translate(err)
+	}
+	defer rows.Close()
+
+	var users []User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, *user)
+	}
+	return users, translate(rows.Err())
+}
+
+// Create inserts a user and fills in its id and creation time
+func (r *UserRepo) Create(ctx context.Context, user *User) error {
+	query := `
+		INSERT INTO users (name, email, password, role)
+		VALUES ($1, $2, $3, $4)
+		RETURNING user_id, created_at, updated_at
+	`
+	err := r.pool.QueryRow(ctx, query, user.Name, user.Email, user.Password, user.Role).Scan(&user.UserID, &user.CreatedAt, &user.UpdatedAt)
+	return translate(err)
+}
+
+// UpdateProfile replaces the name, email, date of birth and sex of an active user in one statement
+func (r *UserRepo) UpdateProfile(ctx context.Context, userID uint32, name, email string, dateOfBirth *time.Time, sex *string) error {
+	query := `
+		UPDATE users SET name = $1, email = $2, date_of_birth = $3, sex = $4, updated_at = NOW()
+		WHERE user_id = $5 AND deleted_at IS NULL
+	`
+	return r.exec(ctx, query, name, email, dateOfBirth, sex, userID)
+}
+
+// UpdatePassword replaces the password hash of an active user and signs out its sessions
+func (r *UserRepo) UpdatePassword(ctx context.Context, userID uint32, passwordHash string) error {
+	return r.exec(ctx, `UPDATE users SET password = $1, token_version = token_version + 1, updated_at = NOW() WHERE user_id = $2 AND deleted_at IS NULL`, passwordHash, userID)
+}
+
+// UpdateRole changes the role of an active user
+func (r *UserRepo) UpdateRole(ctx context.Context, userID uint32, role string) error {
+	return r.exec(ctx, `UPDATE users SET role = $1, updated_at = NOW() WHERE user_id = $2 AND deleted_at IS NULL`, role, userID)
+}
+
+// SoftDelete marks an active user as deleted and signs out its sessions, its rows are kept for the clinical record
+func (r *UserRepo) SoftDelete(ctx context.Context, userID uint32) error {
+	return r.exec(ctx, `UPDATE users SET deleted_at = NOW(), token_version = token_version + 1, updated_at = NOW() WHERE user_id = $1 AND deleted_at IS NULL`, userID)
+}
+
+// exec runs an update on a single user and reports ErrNotFound when no row matched
+func (r *UserRepo) exec(ctx context.Context, query string, args ...any) error {
+	tag, err := r.pool.Exec(ctx, query, args...)
+	if err != nil {
+		return translate(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
 )
 
 // APIResponse struct for standardized JSON responses
@@ -12,7 +13,7 @@ type APIResponse struct {
 	Success    bool        `json:"success"`
 	StatusCode int         `json:"statusCode"`
 	Data       interface{} `json:"data"`
-	Error 	   *string      `json:"error,omitempty"`
+	Error      *string     `json:"error,omitempty"`
 }
 
 // SendResponse is a utility function to send formatted JSON responses
@@ -35,11 +36,34 @@ func SendResponse(w http.ResponseWriter, success bool, statusCode int, data inte
 	json.NewEncoder(w).Encode(response)
 }
 
-
 func StringToUInt32(str string) (uint32, error) {
 	num, err := strconv.ParseUint(str, 10, 32)
 	if err != nil {
 		return 0, err
 	}
 	return uint32(num), nil
-}
\ No newline at end of file
+}
+
+// BearerToken extracts the token from an "Authorization: Bearer <token>" header
+func BearerToken(r *http.Request) string {
+	header := r.Header.Get("Authorization")
+	if len(header) > 7 && strings.EqualFold(header[:7], "bearer ") {
+		return strings.TrimSpace(header[7:])
+	}
+	return ""
+}
+
+// WebSocketBearerToken extracts the token a browser sends as the WebSocket subprotocols
+// "bearer, <token>", since the WebSocket API cannot set an Authorization header
+func WebSocketBearerToken(r *http.Request) string {
+	var protocols []string
+	for _, header := range r.Header.Values("Sec-WebSocket-Protocol") {
+		for _, p := range strings.Split(header, ",") {
+			protocols = append(protocols, strings.TrimSpace(p))
+		}
+	}
+	if len(protocols) == 2 && protocols[0] == "bearer" {
+		return protocols[1]
+	}
+	return ""
+}
@@ -0,0 +1,137 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// AIAction is the next step a bot asks the triage flow to take
+type AIAction string
+
+const (
+	ActionContinue         AIAction = "continue"
+	ActionCameraOn         AIAction = "camera_on"
+	ActionNextAPI          AIAction = "next_api"
+	ActionROMAPI           AIAction = "rom_api"
+	ActionDashboardAPI     AIAction = "dashboard_api"
+	ActionComplete         AIAction = "complete"
+	ActionEndQuestionnaire AIAction = "end_questionnaire"
+)
+
+// AIBot names the bot a payload came from
+type AIBot string
+
+const (
+	BotBPI           AIBot = "bpi"
+	BotQuestionnaire AIBot = "questionnaire"
+)
+
+// chatActions and questionActions are the actions each bot may answer with
+var (
+	chatActions     = []AIAction{ActionContinue, ActionCameraOn, ActionNextAPI}
+	questionActions = []AIAction{ActionContinue, ActionROMAPI, ActionDashboardAPI, ActionNextAPI, ActionComplete, ActionEndQuestionnaire}
+)
+
+// EndsQuestionnaire reports whether the questionnaire bot is done asking once it sends the action
+func (a AIAction) EndsQuestionnaire() bool {
+	switch a {
+	case ActionROMAPI, ActionDashboardAPI, ActionNextAPI, ActionComplete, ActionEndQuestionnaire:
+		return true
+	}
+	return false
+}
+
+// ChatReply is the BPI bot payload, the reply text and the next action
+type ChatReply struct {
+	Response string   `json:"response"`
+	Action   AIAction `json:"action"`
+}
+
+// QuestionReply is the questionnaire bot payload, the question, its answer options and the next action
+type QuestionReply struct {
+	Question string   `json:"question"`
+	Options  []string `json:"options"`
+	Action   AIAction `json:"action"`
+}
+
+// SchemaViolationError is returned when an AI payload does not match the bot protocol
+type SchemaViolationError struct {
+	Bot    AIBot
+	Field  string
+	Reason string
+}
+
+func (e *SchemaViolationError) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("%s bot payload violates the schema: %s", e.Bot, e.Reason)
+	}
+	return fmt.Sprintf("%s bot payload violates the schema: %s %s", e.Bot, e.Field, e.Reason)
+}
+
+// ParseChatReply decodes and validates a BPI bot payload
+func ParseChatReply(data json.RawMessage) (ChatReply, error) {
+	var reply ChatReply
+	if err := decodeReply(BotBPI, data, &reply); err != nil {
+		return reply, err
+	}
+	return reply, reply.Validate()
+}
+
+// ParseQuestionReply decodes and validates a questionnaire bot payload
+func ParseQuestionReply(data json.RawMessage) (QuestionReply, error) {
+	var reply QuestionReply
+	if err := decodeReply(BotQuestionnaire, data, &reply); err != nil {
+		return reply, err
+	}
+	return reply, reply.Validate()
+}
+
+// Validate checks the reply has text and an action the BPI bot may send
+func (r ChatReply) Validate() error {
+	if r.Response == "" {
+		return &SchemaViolationError{Bot: BotBPI, Field: "response", Reason: "is required"}
+	}
+	return checkAction(BotBPI, r.Action, chatActions)
+}
+
+// Validate checks the reply has an action the questionnaire bot may send, and a question
+// unless the action ends the questionnaire
+func (r QuestionReply) Validate() error {
+	if err := checkAction(BotQuestionnaire, r.Action, questionActions); err != nil {
+		return err
+	}
+	if r.Question == "" && !r.Action.EndsQuestionnaire() {
+		return &SchemaViolationError{Bot: BotQuestionnaire, Field: "question", Reason: "is required"}
+	}
+	for i, option := range r.Options {
+		if option == "" {
+			return &SchemaViolationError{Bot: BotQuestionnaire, Field: fmt.Sprintf("options[%d]", i), Reason: "is empty"}
+		}
+	}
+	return nil
+}
+
+func decodeReply(bot AIBot, data json.RawMessage, reply any) error {
+	if len(data) == 0 || string(data) == "null" {
+		return &SchemaViolationError{Bot: bot, Reason: "data is missing"}
+	}
+	if err := json.Unmarshal(data, reply); err != nil {
+		if typeErr, ok := err.(*json.UnmarshalTypeError); ok && typeErr.Field != "" {
+			return &SchemaViolationError{Bot: bot, Field: typeErr.Field, Reason: "must be " + typeErr.Type.String()}
+		}
+		return &SchemaViolationError{Bot: bot, Reason: "data is not an object"}
+	}
+	return nil
+}
+
+func checkAction(bot AIBot, action AIAction, allowed []AIAction) error {
+	if action == "" {
+		return &SchemaViolationError{Bot: bot, Field: "action", Reason: "is required"}
+	}
+	for _, a := range allowed {
+		if action == a {
+			return nil
+		}
+	}
+	return &SchemaViolationError{Bot: bot, Field: "action", Reason: fmt.Sprintf("%q is not a %s bot action", string(action), bot)}
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseQuestionReply(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr string // the violated field, empty when the reply is valid
+	}{
+		{"next question", `{"question":"Does it hurt?","options":["Yes","No"],"action":"continue"}`, ""},
+		{"continue without a question", `{"options":[],"action":"continue"}`, "question"},
+		{"rom without a question", `{"action":"rom_api"}`, ""},
+		{"dashboard without a question", `{"action":"dashboard_api"}`, ""},
+		{"complete", `{"question":"","action":"complete"}`, ""},
+		{"end questionnaire", `{"action":"end_questionnaire"}`, ""},
+		{"chat action", `{"question":"Show me","action":"camera_on"}`, "action"},
+		{"close chat is not an action", `{"question":"Bye","action":"close_chat"}`, "action"},
+		{"empty option", `{"question":"Does it hurt?","options":["Yes",""],"action":"continue"}`, "options[1]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseQuestionReply([]byte(tt.data))
+			var violation *SchemaViolationError
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Fatalf("ParseQuestionReply(%s) = %v, want no error", tt.data, err)
+			case tt.wantErr != "" && (!errors.As(err, &violation) || violation.Field != tt.wantErr):
+				t.Fatalf("ParseQuestionReply(%s) = %v, want a violation of %s", tt.data, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseChatReplyRejectsCloseChat(t *testing.T) {
+	_, err := ParseChatReply([]byte(`{"response":"Goodbye","action":"close_chat"}`))
+	var violation *SchemaViolationError
+	if !errors.As(err, &violation) || violation.Field != "action" {
+		t.Fatalf("ParseChatReply with close_chat = %v, want an action violation", err)
+	}
+}
@@ -4,9 +4,105 @@ package models
 type AssessmentStatus string
 
 const (
-	StatusStarted AssessmentStatus = "started"
-    StatusInProgress AssessmentStatus = "in_progress"
-    StatusCompleted  AssessmentStatus = "completed"
-    StatusAbandoned  AssessmentStatus = "abandoned"
+	StatusStarted    AssessmentStatus = "started"
+	StatusInProgress AssessmentStatus = "in_progress"
+	StatusCompleted  AssessmentStatus = "completed"
+	StatusAbandoned  AssessmentStatus = "abandoned"
 )
 
+// AssessmentPhase is a step of the triage flow
+type AssessmentPhase string
+
+const (
+	PhaseBPIChat       AssessmentPhase = "bpi_chat"
+	PhaseQuestionnaire AssessmentPhase = "questionnaire"
+	PhaseROM           AssessmentPhase = "rom"
+	PhaseDashboard     AssessmentPhase = "dashboard"
+)
+
+// Role is the kind of account a user has
+type Role string
+
+const (
+	RolePatient         Role = "patient"
+	RolePhysiotherapist Role = "physiotherapist"
+	RoleAdmin           Role = "admin"
+)
+
+// CallStatus is the state of a physio call
+type CallStatus string
+
+const (
+	CallScheduled  CallStatus = "scheduled"
+	CallInProgress CallStatus = "in_progress"
+	CallCompleted  CallStatus = "completed"
+	CallCancelled  CallStatus = "cancelled"
+	CallNoShow     CallStatus = "no_show"
+)
+
+// CallType tells whether a physio call happens now or at its scheduled time
+type CallType string
+
+const (
+	CallImmediate     CallType = "immediate"
+	CallScheduledType CallType = "scheduled"
+)
+
+// Movement is the motion a ROM measurement was taken for
+type Movement string
+
+const (
+	MovementFlexion          Movement = "flexion"
+	MovementExtension        Movement = "extension"
+	MovementAbduction        Movement = "abduction"
+	MovementAdduction        Movement = "adduction"
+	MovementInternalRotation Movement = "internal_rotation"
+	MovementExternalRotation Movement = "external_rotation"
+	MovementLateralFlexion   Movement = "lateral_flexion"
+	MovementRotation         Movement = "rotation"
+)
+
+// Side is the body side a ROM measurement was taken on
+type Side string
+
+const (
+	SideLeft      Side = "left"
+	SideRight     Side = "right"
+	SideBilateral Side = "bilateral"
+)
+
+// ROMMode tells whether the patient moved the joint or it was moved for them
+type ROMMode string
+
+const (
+	ModeActive  ROMMode = "active"
+	ModePassive ROMMode = "passive"
+)
+
+// Sex is the sex a patient's normative ROM ranges are chosen for
+type Sex string
+
+const (
+	SexFemale Sex = "female"
+	SexMale   Sex = "male"
+)
+
+// ROMSeverity grades a ROM by its percent of the normal range
+type ROMSeverity string
+
+const (
+	ROMSeverityNormal   ROMSeverity = "normal"
+	ROMSeverityMild     ROMSeverity = "mild"
+	ROMSeverityModerate ROMSeverity = "moderate"
+	ROMSeveritySevere   ROMSeverity = "severe"
+)
+
+// TrendDirection tells whether a progress series is getting better over time
+type TrendDirection string
+
+const (
+	TrendImproving    TrendDirection = "improving"
+	TrendStable       TrendDirection = "stable"
+	TrendWorsening    TrendDirection = "worsening"
+	TrendInsufficient TrendDirection = "insufficient_data" // fewer than two points in time
+)
@@ -0,0 +1,52 @@
+package models
+
+// Permission is a single action a role may perform
+type Permission string
+
+const (
+	PermAssessmentCreate       Permission = "assessment:create"
+	PermAssessmentReadOwn      Permission = "assessment:read:own"
+	PermAssessmentWriteOwn     Permission = "assessment:write:own"
+	PermAssessmentReadAssigned Permission = "assessment:read:assigned"
+	PermAssessmentReadAll      Permission = "assessment:read:all"
+	PermAssessmentWriteAll     Permission = "assessment:write:all"
+	PermAssessmentAssign       Permission = "assessment:assign"
+	PermPhysioCallManage       Permission = "physio_call:manage:assigned"
+	PermUserManage             Permission = "user:manage"
+	PermAnatomyManage          Permission = "anatomy:manage"
+	PermSystemMonitor          Permission = "system:monitor"
+)
+
+// rolePermissions is the permission matrix, anything not listed is denied
+var rolePermissions = map[Role][]Permission{
+	RolePatient: {
+		PermAssessmentCreate,
+		PermAssessmentReadOwn,
+		PermAssessmentWriteOwn,
+	},
+	RolePhysiotherapist: {
+		PermAssessmentReadAssigned,
+		PermPhysioCallManage,
+	},
+	RoleAdmin: {
+		PermAssessmentCreate,
+		PermAssessmentReadOwn,
+		PermAssessmentWriteOwn,
+		PermAssessmentReadAll,
+		PermAssessmentWriteAll,
+		PermAssessmentAssign,
+		PermUserManage,
+		PermAnatomyManage,
+		PermSystemMonitor,
+	},
+}
+
+// Can reports whether the role grants a permission
+func (r Role) Can(p Permission) bool {
+	for _, granted := range rolePermissions[r] {
+		if granted == p {
+			return true
+		}
+	}
+	return false
+}
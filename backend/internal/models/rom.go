@@ -0,0 +1,207 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ROMMeasurement is the range of one movement of one joint, angles are in degrees
+type ROMMeasurement struct {
+	AnatomyID      uint32    `json:"anatomyId"`
+	Joint          string    `json:"joint,omitempty"` // anatomy name, filled in when read back
+	Movement       Movement  `json:"movement"`
+	Side           Side      `json:"side,omitempty"` // empty when the joint is not lateral, e.g. the lower back
+	Mode           ROMMode   `json:"mode"`
+	MinAngle       *float64  `json:"minAngle"`
+	MaxAngle       *float64  `json:"maxAngle"`
+	PeakAngle      *float64  `json:"peakAngle,omitempty"`
+	Repetitions    *int      `json:"repetitions,omitempty"`
+	PainOnsetAngle *float64  `json:"painOnsetAngle,omitempty"` // angle at which pain started, if it did
+	Score          *ROMScore `json:"score,omitempty"`          // filled in when read back and a normal range is known
+}
+
+// ROMScore compares a measurement with the normal range of its movement for the patient
+type ROMScore struct {
+	NormalMin       float64     `json:"normalMin"`
+	NormalMax       float64     `json:"normalMax"`
+	PercentOfNormal float64     `json:"percentOfNormal"` // measured arc over the normal arc
+	Severity        ROMSeverity `json:"severity"`
+	AgeBand         string      `json:"ageBand"` // e.g. "60-150"
+	Sex             string      `json:"sex"`     // female, male or any
+}
+
+// Lowest percent of normal of each severity band, anything lower is severe
+const (
+	ROMNormalPercent   = 90
+	ROMMildPercent     = 75
+	ROMModeratePercent = 50
+)
+
+// SeverityOf grades a percent of normal
+func SeverityOf(percent float64) ROMSeverity {
+	switch {
+	case percent >= ROMNormalPercent:
+		return ROMSeverityNormal
+	case percent >= ROMMildPercent:
+		return ROMSeverityMild
+	case percent >= ROMModeratePercent:
+		return ROMSeverityModerate
+	}
+	return ROMSeveritySevere
+}
+
+// AngleRange is an inclusive range of angles in degrees
+type AngleRange struct {
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
+}
+
+// Contains reports whether angle lies in the range
+func (r AngleRange) Contains(angle float64) bool {
+	return angle >= r.Min && angle <= r.Max
+}
+
+// ROMMeasurementError is returned when a measurement is incomplete or anatomically implausible
+type ROMMeasurementError struct {
+	Field  string
+	Reason string
+}
+
+func (e *ROMMeasurementError) Error() string {
+	return fmt.Sprintf("invalid ROM measurement: %s %s", e.Field, e.Reason)
+}
+
+// plausibleROM holds the widest angles a joint can reach per movement. They are deliberately
+// wider than normal ranges, a value outside them is a measurement or tracking error.
+var plausibleROM = map[string]map[Movement]AngleRange{
+	"shoulder": {
+		MovementFlexion:          {0, 200},
+		MovementExtension:        {0, 90},
+		MovementAbduction:        {0, 200},
+		MovementAdduction:        {0, 75},
+		MovementInternalRotation: {0, 110},
+		MovementExternalRotation: {0, 120},
+	},
+	"elbow": {
+		MovementFlexion:   {-15, 165},
+		MovementExtension: {-20, 30},
+	},
+	"wrist": {
+		MovementFlexion:   {0, 100},
+		MovementExtension: {0, 100},
+		MovementAbduction: {0, 40}, // radial deviation
+		MovementAdduction: {0, 55}, // ulnar deviation
+	},
+	"hip": {
+		MovementFlexion:          {0, 150},
+		MovementExtension:        {0, 50},
+		MovementAbduction:        {0, 70},
+		MovementAdduction:        {0, 45},
+		MovementInternalRotation: {0, 60},
+		MovementExternalRotation: {0, 70},
+	},
+	"knee": {
+		MovementFlexion:   {-15, 165},
+		MovementExtension: {-20, 30},
+	},
+	"ankle": {
+		MovementFlexion:   {0, 75}, // plantarflexion
+		MovementExtension: {0, 45}, // dorsiflexion
+	},
+	"neck": {
+		MovementFlexion:        {0, 90},
+		MovementExtension:      {0, 100},
+		MovementLateralFlexion: {0, 65},
+		MovementRotation:       {0, 100},
+	},
+	"lower_back": {
+		MovementFlexion:        {0, 120},
+		MovementExtension:      {0, 60},
+		MovementLateralFlexion: {0, 60},
+		MovementRotation:       {0, 70},
+	},
+}
+
+// jointAliases maps other anatomy names to a joint of plausibleROM
+var jointAliases = map[string]string{
+	"cervical_spine": "neck",
+	"lumbar_spine":   "lower_back",
+}
+
+// unknownJointROM bounds every movement of a joint plausibleROM does not know
+var unknownJointROM = AngleRange{-30, 200}
+
+// JointKey normalises an anatomy name, "Lower Back" becomes "lower_back"
+func JointKey(name string) string {
+	key := strings.ToLower(strings.TrimSpace(name))
+	key = strings.NewReplacer(" ", "_", "-", "_").Replace(key)
+	if alias, ok := jointAliases[key]; ok {
+		return alias
+	}
+	return key
+}
+
+// ROMBounds returns the plausible angles of a movement of a joint. ok is false when the
+// joint is known and the movement is not one it makes.
+func ROMBounds(joint string, movement Movement) (bounds AngleRange, ok bool) {
+	movements, known := plausibleROM[JointKey(joint)]
+	if !known {
+		return unknownJointROM, true
+	}
+	bounds, ok = movements[movement]
+	return bounds, ok
+}
+
+// Validate checks the measurement is complete and plausible for joint, the anatomy name it was taken on
+func (m ROMMeasurement) Validate(joint string) error {
+	if m.Movement == "" {
+		return &ROMMeasurementError{Field: "movement", Reason: "is required"}
+	}
+	if !m.Movement.IsValid() {
+		return &ROMMeasurementError{Field: "movement", Reason: fmt.Sprintf("%q is not a movement", string(m.Movement))}
+	}
+	if m.Side != "" && !m.Side.IsValid() {
+		return &ROMMeasurementError{Field: "side", Reason: "must be left, right or bilateral"}
+	}
+	if !m.Mode.IsValid() {
+		return &ROMMeasurementError{Field: "mode", Reason: "must be active or passive"}
+	}
+	bounds, ok := ROMBounds(joint, m.Movement)
+	if !ok {
+		return &ROMMeasurementError{Field: "movement", Reason: fmt.Sprintf("%s is not a movement of the %s", m.Movement, joint)}
+	}
+
+	if m.MinAngle == nil {
+		return &ROMMeasurementError{Field: "minAngle", Reason: "is required"}
+	}
+	if m.MaxAngle == nil {
+		return &ROMMeasurementError{Field: "maxAngle", Reason: "is required"}
+	}
+	angles := []struct {
+		field string
+		value *float64
+	}{
+		{"minAngle", m.MinAngle},
+		{"maxAngle", m.MaxAngle},
+		{"peakAngle", m.PeakAngle},
+		{"painOnsetAngle", m.PainOnsetAngle},
+	}
+	for _, a := range angles {
+		if a.value != nil && !bounds.Contains(*a.value) {
+			return &ROMMeasurementError{
+				Field:  a.field,
+				Reason: fmt.Sprintf("%g is outside the plausible %s %s range of %g to %g degrees", *a.value, joint, m.Movement, bounds.Min, bounds.Max),
+			}
+		}
+	}
+	if *m.MinAngle > *m.MaxAngle {
+		return &ROMMeasurementError{Field: "minAngle", Reason: "is greater than maxAngle"}
+	}
+	if m.PeakAngle != nil && *m.PeakAngle < *m.MinAngle {
+		return &ROMMeasurementError{Field: "peakAngle", Reason: "is less than minAngle"}
+	}
+	if m.Repetitions != nil && *m.Repetitions < 1 {
+		return &ROMMeasurementError{Field: "repetitions", Reason: "must be at least 1"}
+	}
+	return nil
+}
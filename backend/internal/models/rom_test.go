@@ -0,0 +1,80 @@
+package models
+
+import (
+	"errors"
+	"testing"
+)
+
+func angle(v float64) *float64 { return &v }
+
+func TestROMMeasurementValidate(t *testing.T) {
+	knee := func(min, max float64) ROMMeasurement {
+		return ROMMeasurement{Movement: MovementFlexion, Side: SideLeft, Mode: ModeActive, MinAngle: angle(min), MaxAngle: angle(max)}
+	}
+	withPeak := knee(0, 120)
+	withPeak.PeakAngle = angle(-5)
+	painOnset := knee(0, 120)
+	painOnset.PainOnsetAngle = angle(170)
+	noMax := knee(0, 120)
+	noMax.MaxAngle = nil
+	badSide := knee(0, 120)
+	badSide.Side = "middle"
+	noReps := knee(0, 120)
+	noReps.Repetitions = new(int)
+
+	tests := []struct {
+		name    string
+		joint   string
+		m       ROMMeasurement
+		wantErr string // the rejected field, empty when the measurement is valid
+	}{
+		{"normal knee flexion", "Knee", knee(0, 135), ""},
+		{"hyperextended knee", "knee", knee(-10, 140), ""},
+		{"at the plausible bounds", "Knee", knee(-15, 165), ""},
+		{"beyond knee flexion", "Knee", knee(0, 190), "maxAngle"},
+		{"beyond knee hyperextension", "Knee", knee(-40, 120), "minAngle"},
+		{"min above max", "Knee", knee(90, 30), "minAngle"},
+		{"peak below min", "Knee", withPeak, "peakAngle"},
+		{"implausible pain onset", "Knee", painOnset, "painOnsetAngle"},
+		{"missing max", "Knee", noMax, "maxAngle"},
+		{"unknown side", "Knee", badSide, "side"},
+		{"no repetitions", "Knee", noReps, "repetitions"},
+		{"knee does not rotate", "Knee", ROMMeasurement{Movement: MovementRotation, Mode: ModeActive, MinAngle: angle(0), MaxAngle: angle(30)}, "movement"},
+		{"unknown movement", "Knee", ROMMeasurement{Movement: "twist", Mode: ModeActive, MinAngle: angle(0), MaxAngle: angle(30)}, "movement"},
+		{"alias of the lower back", "Lumbar Spine", ROMMeasurement{Movement: MovementExtension, Mode: ModeActive, MinAngle: angle(0), MaxAngle: angle(80)}, "maxAngle"},
+		{"joint without bounds", "Big Toe", ROMMeasurement{Movement: MovementExtension, Mode: ModePassive, MinAngle: angle(0), MaxAngle: angle(70)}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.m.Validate(tt.joint)
+			var measurementErr *ROMMeasurementError
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Fatalf("Validate = %v, want no error", err)
+			case tt.wantErr != "" && (!errors.As(err, &measurementErr) || measurementErr.Field != tt.wantErr):
+				t.Fatalf("Validate = %v, want %s rejected", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSeverityOf(t *testing.T) {
+	tests := []struct {
+		percent float64
+		want    ROMSeverity
+	}{
+		{120, ROMSeverityNormal},
+		{90, ROMSeverityNormal},
+		{89.9, ROMSeverityMild},
+		{75, ROMSeverityMild},
+		{74.9, ROMSeverityModerate},
+		{50, ROMSeverityModerate},
+		{49.9, ROMSeveritySevere},
+		{0, ROMSeveritySevere},
+	}
+	for _, tt := range tests {
+		if got := SeverityOf(tt.percent); got != tt.want {
+			t.Errorf("SeverityOf(%v) = %s, want %s", tt.percent, got, tt.want)
+		}
+	}
+}
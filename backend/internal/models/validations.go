@@ -4,11 +4,11 @@ import "fmt"
 
 // IsValid checks if the assessment status is valid
 func (s AssessmentStatus) IsValid() bool {
-    switch s {
-    case StatusStarted, StatusInProgress, StatusCompleted, StatusAbandoned:
-        return true
-    }
-    return false
+	switch s {
+	case StatusStarted, StatusInProgress, StatusCompleted, StatusAbandoned:
+		return true
+	}
+	return false
 }
 
 // String converts the enum to its string representation
@@ -17,4 +17,156 @@ func (s AssessmentStatus) String() string {
 		return fmt.Sprintf("InvalidAssessmentStatus(%s)", string(s))
 	}
 	return string(s)
-}
\ No newline at end of file
+}
+
+// IsTerminal reports whether no transition leaves the status
+func (s AssessmentStatus) IsTerminal() bool {
+	return s == StatusCompleted || s == StatusAbandoned
+}
+
+// IsValid checks if the phase is known
+func (p AssessmentPhase) IsValid() bool {
+	switch p {
+	case PhaseBPIChat, PhaseQuestionnaire, PhaseROM, PhaseDashboard:
+		return true
+	}
+	return false
+}
+
+// String converts the phase to its string representation
+func (p AssessmentPhase) String() string {
+	if !p.IsValid() {
+		return fmt.Sprintf("InvalidAssessmentPhase(%s)", string(p))
+	}
+	return string(p)
+}
+
+// IsValid checks if the call status is known
+func (s CallStatus) IsValid() bool {
+	switch s {
+	case CallScheduled, CallInProgress, CallCompleted, CallCancelled, CallNoShow:
+		return true
+	}
+	return false
+}
+
+// String converts the call status to its string representation
+func (s CallStatus) String() string {
+	if !s.IsValid() {
+		return fmt.Sprintf("InvalidCallStatus(%s)", string(s))
+	}
+	return string(s)
+}
+
+// IsTerminal reports whether the call is over
+func (s CallStatus) IsTerminal() bool {
+	return s == CallCompleted || s == CallCancelled || s == CallNoShow
+}
+
+// IsValid checks if the call type is known
+func (t CallType) IsValid() bool {
+	return t == CallImmediate || t == CallScheduledType
+}
+
+// String converts the call type to its string representation
+func (t CallType) String() string {
+	if !t.IsValid() {
+		return fmt.Sprintf("InvalidCallType(%s)", string(t))
+	}
+	return string(t)
+}
+
+// IsValid checks if the role is known
+func (r Role) IsValid() bool {
+	switch r {
+	case RolePatient, RolePhysiotherapist, RoleAdmin:
+		return true
+	}
+	return false
+}
+
+// String converts the role to its string representation
+func (r Role) String() string {
+	if !r.IsValid() {
+		return fmt.Sprintf("InvalidRole(%s)", string(r))
+	}
+	return string(r)
+}
+
+// IsValid checks if the action is one any bot may send
+func (a AIAction) IsValid() bool {
+	switch a {
+	case ActionContinue, ActionCameraOn, ActionNextAPI, ActionROMAPI, ActionDashboardAPI, ActionComplete, ActionEndQuestionnaire:
+		return true
+	}
+	return false
+}
+
+// String converts the action to its string representation
+func (a AIAction) String() string {
+	if !a.IsValid() {
+		return fmt.Sprintf("InvalidAIAction(%s)", string(a))
+	}
+	return string(a)
+}
+
+// IsValid checks if the movement is known
+func (m Movement) IsValid() bool {
+	switch m {
+	case MovementFlexion, MovementExtension, MovementAbduction, MovementAdduction,
+		MovementInternalRotation, MovementExternalRotation, MovementLateralFlexion, MovementRotation:
+		return true
+	}
+	return false
+}
+
+// String converts the movement to its string representation
+func (m Movement) String() string {
+	if !m.IsValid() {
+		return fmt.Sprintf("InvalidMovement(%s)", string(m))
+	}
+	return string(m)
+}
+
+// IsValid checks if the side is known
+func (s Side) IsValid() bool {
+	switch s {
+	case SideLeft, SideRight, SideBilateral:
+		return true
+	}
+	return false
+}
+
+// String converts the side to its string representation
+func (s Side) String() string {
+	if !s.IsValid() {
+		return fmt.Sprintf("InvalidSide(%s)", string(s))
+	}
+	return string(s)
+}
+
+// IsValid checks if the ROM mode is known
+func (m ROMMode) IsValid() bool {
+	return m == ModeActive || m == ModePassive
+}
+
+// String converts the ROM mode to its string representation
+func (m ROMMode) String() string {
+	if !m.IsValid() {
+		return fmt.Sprintf("InvalidROMMode(%s)", string(m))
+	}
+	return string(m)
+}
+
+// IsValid checks if the sex is known
+func (s Sex) IsValid() bool {
+	return s == SexFemale || s == SexMale
+}
+
+// String converts the sex to its string representation
+func (s Sex) String() string {
+	if !s.IsValid() {
+		return fmt.Sprintf("InvalidSex(%s)", string(s))
+	}
+	return string(s)
+}
@@ -0,0 +1,186 @@
+package services
+
+import (
+	"ai-bot-deecogs/internal/db"
+	"ai-bot-deecogs/internal/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// AbandonReasonInactivity is recorded on assessments abandoned by the sweeper
+const AbandonReasonInactivity = "inactivity"
+
+// SweeperConfig controls the background abandonment of stale assessments
+type SweeperConfig struct {
+	Interval         time.Duration // time between two runs
+	InactivityWindow time.Duration // open assessments idle for longer are abandoned
+	BatchSize        int           // assessments handled per query
+}
+
+// SweeperStatus describes the sweeper and its last run
+type SweeperStatus struct {
+	Running          bool       `json:"running"`
+	Interval         string     `json:"interval"`
+	InactivityWindow string     `json:"inactivityWindow"`
+	LastRunStartedAt *time.Time `json:"lastRunStartedAt,omitempty"`
+	LastRunDuration  string     `json:"lastRunDuration,omitempty"`
+	LastRunAbandoned int        `json:"lastRunAbandoned"`
+	LastRunSkipped   int        `json:"lastRunSkipped"`
+	LastRunError     string     `json:"lastRunError,omitempty"`
+	TotalRuns        int        `json:"totalRuns"`
+	TotalAbandoned   int        `json:"totalAbandoned"`
+}
+
+// SweepResult counts what a single run did
+type SweepResult struct {
+	Abandoned int // assessments moved to abandoned
+	Skipped   int // assessments that saw activity or changed status meanwhile
+}
+
+var sweeper struct {
+	mu     sync.Mutex
+	status SweeperStatus
+}
+
+// StartAbandonmentSweeper runs the sweeper every cfg.Interval until ctx is cancelled.
+// The returned channel is closed once the current run has finished.
+func StartAbandonmentSweeper(ctx context.Context, cfg SweeperConfig) <-chan struct{} {
+	sweeper.mu.Lock()
+	sweeper.status.Running = true
+	sweeper.status.Interval = cfg.Interval.String()
+	sweeper.status.InactivityWindow = cfg.InactivityWindow.String()
+	sweeper.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer func() {
+			sweeper.mu.Lock()
+			sweeper.status.Running = false
+			sweeper.mu.Unlock()
+		}()
+
+		log.Printf("Abandonment sweeper started, every %s for assessments idle over %s", cfg.Interval, cfg.InactivityWindow)
+		ticker := time.NewTicker(cfg.Interval)
+		defer ticker.Stop()
+		for {
+			runSweep(ctx, cfg)
+			select {
+			case <-ctx.Done():
+				log.Println("Abandonment sweeper stopped")
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return done
+}
+
+// runSweep runs one pass and records its outcome in the status
+func runSweep(ctx context.Context, cfg SweeperConfig) {
+	started := time.Now().UTC()
+	result, err := SweepIdleAssessments(ctx, cfg.InactivityWindow, cfg.BatchSize)
+	if err != nil && !errors.Is(err, context.Canceled) {
+		log.Printf("Abandonment sweep failed: %v", err)
+	}
+	if result.Abandoned > 0 {
+		log.Printf("Abandonment sweep abandoned %d assessments", result.Abandoned)
+	}
+
+	sweeper.mu.Lock()
+	defer sweeper.mu.Unlock()
+	sweeper.status.LastRunStartedAt = &started
+	sweeper.status.LastRunDuration = time.Since(started).String()
+	sweeper.status.LastRunAbandoned = result.Abandoned
+	sweeper.status.LastRunSkipped = result.Skipped
+	sweeper.status.LastRunError = ""
+	if err != nil {
+		sweeper.status.LastRunError = err.Error()
+	}
+	sweeper.status.TotalRuns++
+	sweeper.status.TotalAbandoned += result.Abandoned
+}
+
+// SweepIdleAssessments abandons every started or in_progress assessment without activity
+// for longer than idleFor. An assessment touched while the sweep runs is left open.
+func SweepIdleAssessments(ctx context.Context, idleFor time.Duration, batchSize int) (SweepResult, error) {
+	var result SweepResult
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+
+	for {
+		rows, err := store.Assessments.ListIdle(ctx, idleFor, batchSize)
+		if err != nil {
+			return result, err
+		}
+		abandoned := 0
+		for _, row := range rows {
+			if err := ctx.Err(); err != nil {
+				return result, err
+			}
+			ok, err := abandonIdle(ctx, row, idleFor)
+			if err != nil {
+				return result, err
+			}
+			if ok {
+				abandoned++
+			} else {
+				result.Skipped++
+			}
+		}
+		result.Abandoned += abandoned
+
+		// Skipped rows are no longer listed, stopping on a batch without progress is a safety net
+		if len(rows) < batchSize || abandoned == 0 {
+			return result, nil
+		}
+	}
+}
+
+// abandonIdle abandons one assessment if it is still idle and open
+func abandonIdle(ctx context.Context, row db.Assessment, idleFor time.Duration) (bool, error) {
+	details, err := json.Marshal(map[string]any{
+		"reason":         AbandonReasonInactivity,
+		"lastActivityAt": row.LastActivityAt,
+		"idleFor":        time.Since(row.LastActivityAt).Round(time.Second).String(),
+	})
+	if err != nil {
+		return false, err
+	}
+
+	_, err = store.Assessments.Transition(ctx, row.AssessmentID, db.StatusTransition{
+		From:    row.Status,
+		To:      models.StatusAbandoned.String(),
+		Ended:   true,
+		Details: details,
+		IdleFor: &idleFor,
+	})
+	if errors.Is(err, db.ErrConflict) || errors.Is(err, db.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	log.Printf("Assessment %d abandoned after inactivity since %s", row.AssessmentID, row.LastActivityAt.Format(time.RFC3339))
+	if err := notifyAssessmentAbandoned(ctx, row); err != nil {
+		log.Printf("Warning: Failed to notify the abandonment of assessment %d: %v", row.AssessmentID, err)
+	}
+	return true, nil
+}
+
+// GetSweeperStatus returns the sweeper settings and the outcome of its last run
+func GetSweeperStatus() SweeperStatus {
+	sweeper.mu.Lock()
+	defer sweeper.mu.Unlock()
+	status := sweeper.status
+	if status.LastRunStartedAt != nil {
+		started := *status.LastRunStartedAt
+		status.LastRunStartedAt = &started
+	}
+	return status
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"ai-bot-deecogs/internal/models"
+)
+
+func TestSweepIdleAssessments(t *testing.T) {
+	b := useMemoryStore(t)
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	b.SetClock(func() time.Time { return now })
+
+	idle := newTestAssessment(t, b)
+	idleToo, err := CreateAssessment(1, 1, "PAIN")
+	if err != nil {
+		t.Fatal(err)
+	}
+	finished, err := CreateAssessment(1, 1, "PAIN")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := MarkAssessmentComplete(finished.AssessmentID); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(time.Hour)
+	active, err := CreateAssessment(1, 1, "PAIN")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(90 * time.Minute)
+
+	// A batch of one still reaches both idle assessments
+	result, err := SweepIdleAssessments(context.Background(), 2*time.Hour, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Abandoned != 2 || result.Skipped != 0 {
+		t.Fatalf("sweep = %+v, want 2 abandoned", result)
+	}
+
+	want := map[uint32]models.AssessmentStatus{
+		idle:                  models.StatusAbandoned,
+		idleToo.AssessmentID:  models.StatusAbandoned,
+		finished.AssessmentID: models.StatusCompleted,
+		active.AssessmentID:   models.StatusStarted,
+	}
+	for id, status := range want {
+		got, err := GetAssessment(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Status != status.String() {
+			t.Errorf("assessment %d is %s, want %s", id, got.Status, status)
+		}
+	}
+
+	timeline, err := GetAssessmentTimeline(idle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := timeline[len(timeline)-1]
+	var details struct {
+		Reason string `json:"reason"`
+	}
+	if err := json.Unmarshal(last.Details, &details); err != nil || details.Reason != AbandonReasonInactivity {
+		t.Errorf("last event %s with details %s, want the inactivity reason", last.Type, last.Details)
+	}
+
+	if result, err := SweepIdleAssessments(context.Background(), 2*time.Hour, 1); err != nil || result.Abandoned != 0 {
+		t.Errorf("second sweep = %+v, %v, want nothing left to abandon", result, err)
+	}
+}
+
+func TestSweepLeavesTouchedAssessmentOpen(t *testing.T) {
+	b := useMemoryStore(t)
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	b.SetClock(func() time.Time { return now })
+	id := newTestAssessment(t, b)
+	now = now.Add(3 * time.Hour)
+
+	rows, err := store.Assessments.ListIdle(context.Background(), 2*time.Hour, 10)
+	if err != nil || len(rows) != 1 {
+		t.Fatalf("ListIdle = %d rows, %v, want the idle assessment", len(rows), err)
+	}
+	// The patient comes back between the listing and the transition
+	if err := store.Assessments.Touch(context.Background(), id); err != nil {
+		t.Fatal(err)
+	}
+	ok, err := abandonIdle(context.Background(), rows[0], 2*time.Hour)
+	if err != nil || ok {
+		t.Fatalf("abandonIdle = %v, %v, want the touched assessment skipped", ok, err)
+	}
+	got, err := GetAssessment(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if models.AssessmentStatus(got.Status).IsTerminal() {
+		t.Fatalf("touched assessment is %s, want it left open", got.Status)
+	}
+}
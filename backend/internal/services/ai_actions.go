@@ -0,0 +1,67 @@
+package services
+
+import (
+	"ai-bot-deecogs/internal/models"
+	"context"
+	"encoding/json"
+	"log"
+)
+
+// onChatAction applies the transition a BPI bot action asks for, history holds the finished turn
+func onChatAction(assessmentID uint32, action models.AIAction, history []ChatMessage) error {
+	log.Printf("Assessment %d chat action: %s", assessmentID, action)
+	if action == models.ActionNextAPI {
+		return finishChat(assessmentID, history)
+	}
+	// continue and camera_on keep the chat going, the frontend opens the camera
+	return nil
+}
+
+// onQuestionAction applies the transition a questionnaire bot action asks for
+func onQuestionAction(assessmentID uint32, action models.AIAction, request QuestionRequest) error {
+	log.Printf("Assessment %d questionnaire action: %s", assessmentID, action)
+	switch action {
+	case models.ActionROMAPI, models.ActionNextAPI, models.ActionComplete, models.ActionEndQuestionnaire:
+		return finishQuestionnaire(assessmentID, request)
+	case models.ActionDashboardAPI:
+		// The bot skips the ROM check, the dashboard still needs the answers
+		log.Printf("Assessment %d skips the ROM phase", assessmentID)
+		return finishQuestionnaire(assessmentID, request)
+	}
+	return nil
+}
+
+// finishChat stores the finished chat and records the BPI phase
+func finishChat(assessmentID uint32, history []ChatMessage) error {
+	// The dashboard still reads the finished chat from assessments.chat_history
+	jsonData, err := json.Marshal(ChatRequest{ChatHistory: history})
+	if err != nil {
+		return err
+	}
+	if err := store.Assessments.SaveChatHistory(context.Background(), assessmentID, jsonData); err != nil {
+		return err
+	}
+	if err := CompletePhase(assessmentID, models.PhaseBPIChat); err != nil {
+		log.Printf("Warning: Failed to record chat phase: %v", err)
+	}
+	return nil
+}
+
+// finishQuestionnaire stores the answered questionnaire and records its phase
+func finishQuestionnaire(assessmentID uint32, request QuestionRequest) error {
+	jsonData, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	questionID, err := store.Questionnaires.Create(context.Background(), assessmentID, jsonData)
+	if err != nil {
+		// Don't fail the request, the answer was already given
+		log.Printf("Error saving questionnaire: %v", err)
+		return nil
+	}
+	log.Printf("Questionnaire saved with ID: %d", questionID)
+	if err := CompletePhase(assessmentID, models.PhaseQuestionnaire); err != nil {
+		log.Printf("Warning: Failed to record questionnaire phase: %v", err)
+	}
+	return nil
+}
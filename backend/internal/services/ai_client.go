@@ -0,0 +1,36 @@
+package services
+
+import (
+	"ai-bot-deecogs/internal/clients"
+	"encoding/json"
+)
+
+// aiClient is the AI backend used by the assessment flow, set by Init
+var aiClient clients.AIClient
+
+// toAPIResponse turns the AI envelope into the response passed back to the frontend
+func toAPIResponse(reply *clients.AIResponse) (APIResponse, error) {
+	response := APIResponse{Success: reply.Success, StatusCode: reply.StatusCode, Text: clients.ReplyText(reply.Data)}
+	if len(reply.Data) > 0 {
+		if err := json.Unmarshal(reply.Data, &response.Data); err != nil {
+			return response, err
+		}
+	}
+	return response, nil
+}
+
+func toClientChat(history []ChatMessage) []clients.ChatMessage {
+	out := make([]clients.ChatMessage, len(history))
+	for i, m := range history {
+		out[i] = clients.ChatMessage{User: m.User, Response: m.Response}
+	}
+	return out
+}
+
+func toClientQuestions(history []QuestionMessage) []clients.QuestionMessage {
+	out := make([]clients.QuestionMessage, len(history))
+	for i, m := range history {
+		out[i] = clients.QuestionMessage{User: m.User, Assistant: m.Assistant}
+	}
+	return out
+}
@@ -0,0 +1,303 @@
+package services
+
+import (
+	"ai-bot-deecogs/internal/clients"
+	"ai-bot-deecogs/internal/db"
+	"ai-bot-deecogs/internal/models"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+var (
+	ErrAnalysisNotFound     = errors.New("no analysis has been requested for this assessment")
+	ErrAnalysisInputMissing = errors.New("assessment data is incomplete") // questionnaire or ROM not saved yet
+)
+
+// AnalysisJobConfig controls the dashboard analysis workers
+type AnalysisJobConfig struct {
+	Workers      int           // jobs run at the same time
+	PollInterval time.Duration // time between two looks at the queue when idle
+	MaxAttempts  int           // attempts of a job before it fails
+	RetryBackoff time.Duration // delay before the first retry, doubled for each one after
+	LeaseTimeout time.Duration // a running job older than this lost its worker and is claimed again
+	Wait         time.Duration // time GET /dashboard waits for a job before answering 202
+}
+
+// AnalysisJob is the state of a dashboard analysis and, once it succeeded, its result
+type AnalysisJob struct {
+	JobID         uint32     `json:"jobId"`
+	AssessmentID  uint32     `json:"assessmentId"`
+	Revision      string     `json:"revision"`
+	Status        string     `json:"status"`
+	Attempts      int        `json:"attempts"`
+	MaxAttempts   int        `json:"maxAttempts"`
+	NextAttemptAt *time.Time `json:"nextAttemptAt,omitempty"`
+	LastError     *string    `json:"lastError,omitempty"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	UpdatedAt     time.Time  `json:"updatedAt"`
+	FinishedAt    *time.Time `json:"finishedAt,omitempty"`
+	Result        *AIResult  `json:"result,omitempty"`
+}
+
+// Done reports whether the job will not change anymore
+func (j *AnalysisJob) Done() bool {
+	return j.Status == db.JobSucceeded || j.Status == db.JobFailed
+}
+
+var analysisWorkers struct {
+	cfg  AnalysisJobConfig
+	wake chan struct{}
+}
+
+// StartAnalysisWorkers runs cfg.Workers workers until ctx is cancelled.
+// The returned channel is closed once every worker has finished its job.
+func StartAnalysisWorkers(ctx context.Context, cfg AnalysisJobConfig) <-chan struct{} {
+	analysisWorkers.cfg = cfg
+	analysisWorkers.wake = make(chan struct{}, cfg.Workers)
+
+	var wg sync.WaitGroup
+	for i := 0; i < cfg.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			runAnalysisWorker(ctx, cfg)
+		}()
+	}
+	log.Printf("Analysis workers started: %d, polling every %s", cfg.Workers, cfg.PollInterval)
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		log.Println("Analysis workers stopped")
+		close(done)
+	}()
+	return done
+}
+
+// runAnalysisWorker runs due jobs until the queue is empty, then waits for a wake up or the next poll
+func runAnalysisWorker(ctx context.Context, cfg AnalysisJobConfig) {
+	ticker := time.NewTicker(cfg.PollInterval)
+	defer ticker.Stop()
+	for {
+		for ctx.Err() == nil {
+			job, err := store.AnalysisJobs.Claim(ctx, cfg.LeaseTimeout)
+			if errors.Is(err, db.ErrNotFound) {
+				break
+			}
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Printf("Failed to claim an analysis job: %v", err)
+				}
+				break
+			}
+			runAnalysisJob(ctx, cfg, job)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-analysisWorkers.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// runAnalysisJob sends the job input to the dashboard AI, reviews and stores the result and
+// completes the assessment
+func runAnalysisJob(ctx context.Context, cfg AnalysisJobConfig, job *db.AnalysisJob) {
+	log.Printf("Analysis job %d for assessment %d, attempt %d/%d", job.JobID, job.AssessmentID, job.Attempts, job.MaxAttempts)
+
+	var escalation *db.Escalation
+	result, err := analyse(ctx, job)
+	if err == nil {
+		// The result is reviewed before it is published, so it never shows without its escalation
+		escalation, err = reviewRedFlags(context.Background(), job, result)
+	}
+	if err == nil {
+		_, err = store.AnalysisJobs.Succeed(context.Background(), job.JobID, *job.LockedAt, result)
+		if errors.Is(err, db.ErrConflict) {
+			log.Printf("Analysis job %d was claimed again, dropping this attempt", job.JobID)
+			return
+		}
+	}
+	if err != nil {
+		failAnalysisJob(ctx, cfg, job, err)
+		return
+	}
+
+	log.Printf("Analysis job %d succeeded", job.JobID)
+	if err := MarkAssessmentComplete(job.AssessmentID); err != nil {
+		log.Printf("Warning: Failed to complete assessment %d after its analysis: %v", job.AssessmentID, err)
+	}
+	if escalation != nil {
+		err = notifyEscalation(context.Background(), escalation)
+	} else {
+		err = notifyCriticalPlan(context.Background(), job.AssessmentID)
+	}
+	if err != nil {
+		log.Printf("Warning: Failed to notify the critical flag of assessment %d: %v", job.AssessmentID, err)
+	}
+}
+
+// analyse runs one attempt and returns the analysed results to store
+func analyse(ctx context.Context, job *db.AnalysisJob) (json.RawMessage, error) {
+	var input DashboardDataAIRequest
+	if err := json.Unmarshal(job.Input, &input); err != nil {
+		return nil, err
+	}
+	aiResult, err := RequestAIAnalysisFromAI(ctx, job.AssessmentID, &input)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(aiResult.Response)
+}
+
+// failAnalysisJob queues the job again with backoff, or fails it once no attempt is left
+func failAnalysisJob(ctx context.Context, cfg AnalysisJobConfig, job *db.AnalysisJob, cause error) {
+	var retryIn *time.Duration
+	var status *clients.StatusError
+	switch {
+	case ctx.Err() != nil:
+		// Shutting down, the next start picks the job up again
+		var immediately time.Duration
+		retryIn = &immediately
+	case errors.As(cause, &status):
+		// The AI rejected the input, another attempt gets the same answer
+	case job.Attempts < job.MaxAttempts:
+		next := cfg.RetryBackoff << (job.Attempts - 1)
+		retryIn = &next
+	}
+
+	if err := store.AnalysisJobs.Fail(context.Background(), job.JobID, *job.LockedAt, cause.Error(), retryIn); err != nil {
+		log.Printf("Failed to record the failure of analysis job %d: %v", job.JobID, err)
+		return
+	}
+	if retryIn == nil {
+		log.Printf("Analysis job %d failed: %v", job.JobID, cause)
+	} else {
+		log.Printf("Analysis job %d attempt %d failed, retrying in %s: %v", job.JobID, job.Attempts, *retryIn, cause)
+	}
+}
+
+// wakeAnalysisWorkers lets an idle worker pick up a new job without waiting for the poll
+func wakeAnalysisWorkers() {
+	select {
+	case analysisWorkers.wake <- struct{}{}:
+	default:
+	}
+}
+
+// EnqueueAnalysis queues the dashboard analysis of the current assessment data. The same data
+// is analysed once: created is false when the job of this revision already exists. A failed
+// job of the same revision is queued again.
+func EnqueueAnalysis(assessmentID uint32) (job *AnalysisJob, created bool, err error) {
+	data, err := FetchAssessmentData(assessmentID)
+	if err != nil {
+		return nil, false, fmt.Errorf("%w: %v", ErrAnalysisInputMissing, err)
+	}
+	input, err := json.Marshal(data)
+	if err != nil {
+		return nil, false, err
+	}
+	sum := sha256.Sum256(input)
+	revision := hex.EncodeToString(sum[:])
+
+	row, created, err := store.AnalysisJobs.Enqueue(context.Background(), assessmentID, revision, input, analysisWorkers.cfg.MaxAttempts)
+	if err != nil {
+		return nil, false, err
+	}
+	if !created && row.Status == db.JobFailed {
+		if row, err = store.AnalysisJobs.Requeue(context.Background(), row.JobID); err != nil {
+			return nil, false, err
+		}
+		created = true
+	}
+	if created {
+		log.Printf("Analysis job %d queued for assessment %d", row.JobID, assessmentID)
+		wakeAnalysisWorkers()
+	}
+
+	job, err = toAnalysisJob(row)
+	return job, created, err
+}
+
+// GetAnalysis returns the latest analysis job of an assessment with its result
+func GetAnalysis(assessmentID uint32) (*AnalysisJob, error) {
+	row, err := store.AnalysisJobs.Latest(context.Background(), assessmentID)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, ErrAnalysisNotFound
+		}
+		return nil, err
+	}
+	return toAnalysisJob(row)
+}
+
+// AwaitAnalysis queues the analysis if needed and waits up to the configured time for it
+// to finish, the job is returned unfinished when the wait runs out
+func AwaitAnalysis(ctx context.Context, assessmentID uint32) (*AnalysisJob, error) {
+	job, _, err := EnqueueAnalysis(assessmentID)
+	if err != nil || job.Done() {
+		return job, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, analysisWorkers.cfg.Wait)
+	defer cancel()
+	ticker := time.NewTicker(250 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return job, nil
+		case <-ticker.C:
+		}
+		row, err := store.AnalysisJobs.Get(context.Background(), job.JobID)
+		if err != nil {
+			return nil, err
+		}
+		if job, err = toAnalysisJob(row); err != nil || job.Done() {
+			return job, err
+		}
+	}
+}
+
+func toAnalysisJob(row *db.AnalysisJob) (*AnalysisJob, error) {
+	job := &AnalysisJob{
+		JobID:        row.JobID,
+		AssessmentID: row.AssessmentID,
+		Revision:     row.Revision,
+		Status:       row.Status,
+		Attempts:     row.Attempts,
+		MaxAttempts:  row.MaxAttempts,
+		LastError:    row.LastError,
+		CreatedAt:    row.CreatedAt,
+		UpdatedAt:    row.UpdatedAt,
+		FinishedAt:   row.FinishedAt,
+	}
+	if row.Status == db.JobQueued && row.Attempts > 0 {
+		next := row.RunAfter
+		job.NextAttemptAt = &next
+	}
+	if row.Status != db.JobSucceeded || row.AnalysisID == nil {
+		return job, nil
+	}
+
+	analysis, err := store.AIAnalysis.Get(context.Background(), *row.AnalysisID)
+	if err != nil {
+		return nil, err
+	}
+	job.Result = &AIResult{Action: string(models.ActionDashboardAPI)}
+	if err := json.Unmarshal(analysis.AnalysedResults, &job.Result.Response); err != nil {
+		return nil, err
+	}
+	if job.Result.Escalation, err = GetEscalation(context.Background(), row.AssessmentID); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
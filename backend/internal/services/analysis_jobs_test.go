@@ -0,0 +1,118 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"ai-bot-deecogs/internal/clients"
+	"ai-bot-deecogs/internal/db"
+)
+
+var testJobConfig = AnalysisJobConfig{MaxAttempts: 3, RetryBackoff: time.Minute, LeaseTimeout: 5 * time.Minute}
+
+// queueTestJob enqueues a job on a memory store whose clock is moved by the returned func
+func queueTestJob(t *testing.T) (uint32, func(time.Duration)) {
+	t.Helper()
+	b := useMemoryStore(t)
+	assessmentID := newTestAssessment(t, b)
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	b.SetClock(func() time.Time { return now })
+	job, _, err := store.AnalysisJobs.Enqueue(context.Background(), assessmentID, "rev", []byte(`{}`), testJobConfig.MaxAttempts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return job.JobID, func(d time.Duration) { now = now.Add(d) }
+}
+
+func claimJob(t *testing.T) *db.AnalysisJob {
+	t.Helper()
+	job, err := store.AnalysisJobs.Claim(context.Background(), testJobConfig.LeaseTimeout)
+	if err != nil {
+		t.Fatalf("Claim: %v", err)
+	}
+	return job
+}
+
+func expectNothingDue(t *testing.T) {
+	t.Helper()
+	if job, err := store.AnalysisJobs.Claim(context.Background(), testJobConfig.LeaseTimeout); !errors.Is(err, db.ErrNotFound) {
+		t.Fatalf("Claim = job %v, %v, want nothing due", job, err)
+	}
+}
+
+func TestAnalysisJobRetryBackoff(t *testing.T) {
+	jobID, advance := queueTestJob(t)
+	cause := errors.New("AI timed out")
+
+	// Attempts 1 and 2 are retried after 1 and 2 minutes, attempt 3 fails the job
+	for attempt, backoff := range []time.Duration{time.Minute, 2 * time.Minute} {
+		job := claimJob(t)
+		if job.JobID != jobID || job.Attempts != attempt+1 {
+			t.Fatalf("claimed job %d at attempt %d, want job %d at attempt %d", job.JobID, job.Attempts, jobID, attempt+1)
+		}
+		failAnalysisJob(context.Background(), testJobConfig, job, cause)
+
+		advance(backoff - time.Second)
+		expectNothingDue(t)
+		advance(time.Second)
+	}
+
+	job := claimJob(t)
+	failAnalysisJob(context.Background(), testJobConfig, job, cause)
+	advance(time.Hour)
+	expectNothingDue(t)
+	row, err := store.AnalysisJobs.Get(context.Background(), jobID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if row.Status != db.JobFailed || row.Attempts != 3 || row.LastError == nil || *row.LastError != cause.Error() {
+		t.Fatalf("job after the last attempt = %s at %d attempts (%v), want failed at 3", row.Status, row.Attempts, row.LastError)
+	}
+}
+
+func TestAnalysisJobRejectedInputFailsAtOnce(t *testing.T) {
+	jobID, _ := queueTestJob(t)
+	failAnalysisJob(context.Background(), testJobConfig, claimJob(t), &clients.StatusError{Endpoint: "dashboard", StatusCode: 422})
+
+	row, err := store.AnalysisJobs.Get(context.Background(), jobID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if row.Status != db.JobFailed || row.Attempts != 1 {
+		t.Fatalf("job = %s at %d attempts, want failed after the first", row.Status, row.Attempts)
+	}
+}
+
+func TestAnalysisJobShutdownRequeuesAtOnce(t *testing.T) {
+	queueTestJob(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	failAnalysisJob(ctx, testJobConfig, claimJob(t), context.Canceled)
+
+	if job := claimJob(t); job.Attempts != 2 {
+		t.Fatalf("job claimed after the shutdown at attempt %d, want 2", job.Attempts)
+	}
+}
+
+func TestAnalysisJobStaleLease(t *testing.T) {
+	_, advance := queueTestJob(t)
+	lost := claimJob(t)
+
+	advance(testJobConfig.LeaseTimeout)
+	expectNothingDue(t)
+	advance(time.Second)
+	again := claimJob(t)
+	if again.JobID != lost.JobID || again.Attempts != 2 {
+		t.Fatalf("reclaimed job %d at attempt %d, want job %d at attempt 2", again.JobID, again.Attempts, lost.JobID)
+	}
+
+	// The worker that lost the lease can no longer finish the job
+	if _, err := store.AnalysisJobs.Succeed(context.Background(), lost.JobID, *lost.LockedAt, []byte(`{}`)); !errors.Is(err, db.ErrConflict) {
+		t.Fatalf("Succeed with a lost lease = %v, want ErrConflict", err)
+	}
+	if _, err := store.AnalysisJobs.Succeed(context.Background(), again.JobID, *again.LockedAt, []byte(`{}`)); err != nil {
+		t.Fatalf("Succeed with the current lease: %v", err)
+	}
+}
@@ -0,0 +1,120 @@
+package services
+
+import (
+	"ai-bot-deecogs/internal/db"
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
+
+var (
+	ErrAnatomyNotFound = errors.New("anatomy not found")
+	ErrAnatomyExists   = errors.New("anatomy with this name already exists")
+	ErrAnatomyInUse    = errors.New("anatomy is referenced by existing assessments")
+)
+
+// Anatomy is a body part an assessment can target
+type Anatomy struct {
+	AnatomyID   uint32    `json:"anatomyId"`
+	Name        string    `json:"name"`
+	Description *string   `json:"description,omitempty"`
+	Category    *string   `json:"category,omitempty"`
+	Subcategory *string   `json:"subcategory,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+func toAnatomy(row *db.Anatomy) Anatomy {
+	return Anatomy{
+		AnatomyID:   row.AnatomyID,
+		Name:        row.Name,
+		Description: row.Description,
+		Category:    row.Category,
+		Subcategory: row.Subcategory,
+		CreatedAt:   row.CreatedAt,
+	}
+}
+
+// ListAnatomy returns every anatomy entry
+func ListAnatomy() ([]Anatomy, error) {
+	rows, err := store.Anatomy.List(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	list := make([]Anatomy, 0, len(rows))
+	for i := range rows {
+		list = append(list, toAnatomy(&rows[i]))
+	}
+	return list, nil
+}
+
+// CreateAnatomy stores a new anatomy entry
+func CreateAnatomy(anatomy Anatomy) (*Anatomy, error) {
+	anatomy.Name = strings.TrimSpace(anatomy.Name)
+	if anatomy.Name == "" {
+		return nil, errors.New("name is required")
+	}
+	row := db.Anatomy{
+		Name:        anatomy.Name,
+		Description: anatomy.Description,
+		Category:    anatomy.Category,
+		Subcategory: anatomy.Subcategory,
+	}
+	if err := store.Anatomy.Create(context.Background(), &row); err != nil {
+		if errors.Is(err, db.ErrUniqueViolation) {
+			return nil, ErrAnatomyExists
+		}
+		return nil, err
+	}
+	created := toAnatomy(&row)
+	return &created, nil
+}
+
+// UpdateAnatomy overwrites the name and descriptions of an anatomy entry
+func UpdateAnatomy(anatomy Anatomy) (*Anatomy, error) {
+	anatomy.Name = strings.TrimSpace(anatomy.Name)
+	if anatomy.Name == "" {
+		return nil, errors.New("name is required")
+	}
+	row := db.Anatomy{
+		AnatomyID:   anatomy.AnatomyID,
+		Name:        anatomy.Name,
+		Description: anatomy.Description,
+		Category:    anatomy.Category,
+		Subcategory: anatomy.Subcategory,
+	}
+	if err := store.Anatomy.Update(context.Background(), &row); err != nil {
+		switch {
+		case errors.Is(err, db.ErrNotFound):
+			return nil, ErrAnatomyNotFound
+		case errors.Is(err, db.ErrUniqueViolation):
+			return nil, ErrAnatomyExists
+		}
+		return nil, err
+	}
+	updated, err := store.Anatomy.Get(context.Background(), anatomy.AnatomyID)
+	if err != nil {
+		return nil, err
+	}
+	result := toAnatomy(updated)
+	return &result, nil
+}
+
+// DeleteAnatomy removes an anatomy entry, refusing while assessments still reference it
+// since the foreign key would otherwise cascade and delete patient data
+func DeleteAnatomy(anatomyID uint32) error {
+	inUse, err := store.Anatomy.InUse(context.Background(), anatomyID)
+	if err != nil {
+		return err
+	}
+	if inUse {
+		return ErrAnatomyInUse
+	}
+	if err := store.Anatomy.Delete(context.Background(), anatomyID); err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return ErrAnatomyNotFound
+		}
+		return err
+	}
+	return nil
+}
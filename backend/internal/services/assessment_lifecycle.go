@@ -0,0 +1,193 @@
+package services
+
+import (
+	"ai-bot-deecogs/internal/db"
+	"ai-bot-deecogs/internal/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+var (
+	ErrAssessmentNotFound = errors.New("assessment not found")
+	ErrInvalidTransition  = errors.New("invalid status transition")
+	ErrStatusConflict     = errors.New("assessment status changed concurrently, retry")
+)
+
+// allowedTransitions is the assessment state machine, completed and abandoned are final
+var allowedTransitions = map[models.AssessmentStatus][]models.AssessmentStatus{
+	models.StatusStarted:    {models.StatusInProgress, models.StatusAbandoned},
+	models.StatusInProgress: {models.StatusCompleted, models.StatusAbandoned},
+}
+
+// phaseCompletion is the completion percentage reached once a phase has finished
+var phaseCompletion = map[models.AssessmentPhase]float64{
+	models.PhaseBPIChat:       25,
+	models.PhaseQuestionnaire: 50,
+	models.PhaseROM:           75,
+	models.PhaseDashboard:     100,
+}
+
+// transitionRetries bounds how often a transition is re-read after losing a race
+const transitionRetries = 3
+
+// TimelineEvent is one entry of an assessment's history
+type TimelineEvent struct {
+	EventID    uint32          `json:"eventId"`
+	Type       string          `json:"type"`
+	FromStatus *string         `json:"fromStatus,omitempty"`
+	ToStatus   *string         `json:"toStatus,omitempty"`
+	Phase      *string         `json:"phase,omitempty"`
+	Details    json.RawMessage `json:"details,omitempty" swaggertype:"object"`
+	CreatedAt  time.Time       `json:"createdAt"`
+}
+
+// CanTransition reports whether the state machine allows from -> to
+func CanTransition(from, to models.AssessmentStatus) bool {
+	for _, next := range allowedTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// TransitionAssessment moves an assessment to a new status and records the change.
+// Moving to the current status is a no-op so retried requests are harmless.
+func TransitionAssessment(assessmentID uint32, to models.AssessmentStatus, details json.RawMessage) error {
+	if !to.IsValid() {
+		return errors.New("invalid assessment status")
+	}
+
+	for attempt := 0; attempt < transitionRetries; attempt++ {
+		row, err := store.Assessments.Get(context.Background(), assessmentID)
+		if err != nil {
+			if errors.Is(err, db.ErrNotFound) {
+				return ErrAssessmentNotFound
+			}
+			return err
+		}
+
+		from := models.AssessmentStatus(row.Status)
+		if from == to {
+			return nil
+		}
+		if !CanTransition(from, to) {
+			return fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, from, to)
+		}
+
+		transition := db.StatusTransition{
+			From:    from.String(),
+			To:      to.String(),
+			Ended:   to.IsTerminal(),
+			Details: details,
+		}
+		if to == models.StatusCompleted {
+			full := phaseCompletion[models.PhaseDashboard]
+			transition.Completion = &full
+		}
+
+		_, err = store.Assessments.Transition(context.Background(), assessmentID, transition)
+		if errors.Is(err, db.ErrConflict) {
+			continue
+		}
+		if errors.Is(err, db.ErrNotFound) {
+			return ErrAssessmentNotFound
+		}
+		return err
+	}
+	return ErrStatusConflict
+}
+
+// CompletePhase records that a phase of the flow has finished, moving a started
+// assessment to in_progress and raising its completion percentage
+func CompletePhase(assessmentID uint32, phase models.AssessmentPhase) error {
+	completion, ok := phaseCompletion[phase]
+	if !ok {
+		return fmt.Errorf("unknown assessment phase %q", phase)
+	}
+	if err := requireOpen(assessmentID); err != nil {
+		return err
+	}
+	if err := markInProgress(assessmentID); err != nil {
+		return err
+	}
+
+	event, err := store.Assessments.RecordPhase(context.Background(), assessmentID, phase.String(), completion)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return ErrAssessmentNotFound
+		}
+		return err
+	}
+	if event != nil {
+		log.Printf("Assessment %d finished phase %s (%.0f%%)", assessmentID, phase, completion)
+	}
+	return nil
+}
+
+// requireOpen rejects writes to an assessment that is already completed or abandoned
+func requireOpen(assessmentID uint32) error {
+	row, err := store.Assessments.Get(context.Background(), assessmentID)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return ErrAssessmentNotFound
+		}
+		return err
+	}
+	if status := models.AssessmentStatus(row.Status); status.IsTerminal() {
+		return fmt.Errorf("%w: the assessment is already %s", ErrInvalidTransition, status)
+	}
+	return nil
+}
+
+// markInProgress moves a started assessment to in_progress, any other status is left alone
+func markInProgress(assessmentID uint32) error {
+	row, err := store.Assessments.Get(context.Background(), assessmentID)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return ErrAssessmentNotFound
+		}
+		return err
+	}
+	if models.AssessmentStatus(row.Status) != models.StatusStarted {
+		return nil
+	}
+	err = TransitionAssessment(assessmentID, models.StatusInProgress, nil)
+	if errors.Is(err, ErrInvalidTransition) {
+		// Abandoned in the meantime
+		return nil
+	}
+	return err
+}
+
+// touchAssessment records user activity so the assessment is not swept as abandoned
+func touchAssessment(assessmentID uint32) {
+	if err := store.Assessments.Touch(context.Background(), assessmentID); err != nil {
+		log.Printf("Warning: Failed to record activity on assessment %d: %v", assessmentID, err)
+	}
+}
+
+// GetAssessmentTimeline returns the lifecycle events of an assessment, oldest first
+func GetAssessmentTimeline(assessmentID uint32) ([]TimelineEvent, error) {
+	rows, err := store.Events.ListByAssessment(context.Background(), assessmentID)
+	if err != nil {
+		return nil, err
+	}
+	timeline := make([]TimelineEvent, 0, len(rows))
+	for _, row := range rows {
+		timeline = append(timeline, TimelineEvent{
+			EventID:    row.EventID,
+			Type:       row.EventType,
+			FromStatus: row.FromStatus,
+			ToStatus:   row.ToStatus,
+			Phase:      row.Phase,
+			Details:    row.Details,
+			CreatedAt:  row.CreatedAt,
+		})
+	}
+	return timeline, nil
+}
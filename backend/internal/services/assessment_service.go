@@ -1,16 +1,14 @@
 package services
 
 import (
+	"ai-bot-deecogs/internal/clients"
 	"ai-bot-deecogs/internal/db"
+	"ai-bot-deecogs/internal/helpers"
 	"ai-bot-deecogs/internal/models"
-	"bytes"
 	"context"
-	"database/sql"
 	"encoding/json"
 	"errors"
-	"io"
 	"log"
-	"net/http"
 	"time"
 )
 
@@ -24,6 +22,8 @@ type Assessment struct {
 	Status               string        `json:"status"`
 	CompletionPercentage float64       `json:"completionPercentage"`
 	ChatHistory          []ChatMessage `json:"chatHistory,omitempty"`
+	PhysioID             *uint32       `json:"physioId,omitempty"`
+	LastActivityAt       time.Time     `json:"lastActivityAt"`
 }
 
 // Response format
@@ -31,6 +31,7 @@ type APIResponse struct {
 	Success    bool        `json:"success"`
 	StatusCode int         `json:"statusCode"`
 	Data       interface{} `json:"data"`
+	Text       string      `json:"-"` // the reply text of the bot, see clients.ReplyText
 }
 
 type ChatMessage struct {
@@ -42,6 +43,12 @@ type ChatRequest struct {
 	ChatHistory []ChatMessage `json:"chat_history"`
 }
 
+// ChatMessageRequest is the body of POST /assessments/:id/chat, the history is kept server side
+type ChatMessageRequest struct {
+	Message string `json:"message"`
+	Video   string `json:"video,omitempty"` // Base64 encoded video, replaces the message
+}
+
 // NEW: Video request structure for body part identification
 type VideoRequest struct {
 	ChatHistory []ChatMessage `json:"chat_history"`
@@ -89,20 +96,9 @@ type CustomResponse struct {
 
 // DashboardData represents the data sent to the AI API
 type DashboardDataAIRequest struct {
-	ChatHistory   []QuestionMessage `json:"chat_history"` //QnA chat_history will be used here
-	RangeOfMotion RangeOfMotion     `json:"rangeOfMotion"`
-}
-
-// AIRequest represents the final request payload for the AI API
-type AIRequest struct {
-	Content DashboardDataAIRequest `json:"content"`
-}
-
-// AIResponse represents the response from the AI server
-type AIResponse struct {
-	Success    bool     `json:"success"`
-	StatusCode int      `json:"statusCode"`
-	Data       AIResult `json:"data"`
+	ChatHistory   []QuestionMessage       `json:"chat_history"` //QnA chat_history will be used here
+	RangeOfMotion RangeOfMotion           `json:"rangeOfMotion"`
+	Measurements  []models.ROMMeasurement `json:"measurements,omitempty"` // per joint and movement with percent of normal, absent for older submissions
 }
 
 // StoreAIAnalysis represents the structure for saving AI analysis in DB
@@ -123,14 +119,14 @@ type AIAnalysisResult struct {
 }
 
 type AIResult struct {
-	Response AIAnalysisResult `json:"response"`
-	Action   string           `json:"action"`
+	Response   AIAnalysisResult `json:"response"`
+	Action     string           `json:"action"`
+	Escalation *Escalation      `json:"escalation,omitempty"` // set by the red-flag review, never by the AI
 }
 
 // CreateAssessment creates a new assessment
 func CreateAssessment(userID uint32, anatomyID uint32, assessmentType string) (*Assessment, error) {
-	var exists bool
-	err := db.DB.QueryRow(context.Background(), "SELECT EXISTS (SELECT 1 FROM users WHERE user_id = $1)", userID).Scan(&exists)
+	exists, err := store.Users.Exists(context.Background(), userID)
 	if err != nil {
 		return nil, errors.New("database query error")
 	}
@@ -139,81 +135,41 @@ func CreateAssessment(userID uint32, anatomyID uint32, assessmentType string) (*
 		return nil, errors.New("User not found")
 	}
 
-	query := `
-		INSERT INTO assessments ( user_id, anatomy_id, assessment_type, start_time, status, completion_percentage)
-		VALUES ($1, $2, $3, NOW(), $4, $5) RETURNING assessment_id, start_time
-	`
-
-	var assessmentID uint32
-	var start_time time.Time
-
-	inserterr := db.DB.QueryRow(context.Background(), query, userID, anatomyID, assessmentType, models.StatusStarted.String(), 0).Scan(&assessmentID, &start_time)
+	row, inserterr := store.Assessments.Create(context.Background(), userID, anatomyID, assessmentType, models.StatusStarted.String())
 	if inserterr != nil {
 		log.Println("Error inserting and fetching assessment:", inserterr)
 		return nil, inserterr
 	}
 	return &Assessment{
-		AssessmentID:         assessmentID,
-		UserID:               userID,
-		AnatomyID:            anatomyID,
-		AssessmentType:       assessmentType,
-		StartTime:            start_time,
-		Status:               models.StatusStarted.String(),
-		CompletionPercentage: 0,
+		AssessmentID:         row.AssessmentID,
+		UserID:               row.UserID,
+		AnatomyID:            row.AnatomyID,
+		AssessmentType:       row.AssessmentType,
+		StartTime:            row.StartTime,
+		Status:               row.Status,
+		CompletionPercentage: row.CompletionPercentage,
+		LastActivityAt:       row.LastActivityAt,
 	}, nil
 }
 
 // GetAssessment retrieves an assessment by its ID
 func GetAssessment(assessmentID uint32) (*Assessment, error) {
-	query := `
-		SELECT assessment_id, user_id, anatomy_id, assessment_type, start_time, end_time, status, completion_percentage, chat_history
-		FROM assessments
-		WHERE assessment_id = $1
-	`
-
-	var AssessmentID uint32
-	var UserID uint32
-	var AnatomyID uint32
-	var AssessmentType string
-	var StartTime time.Time
-	var EndTime sql.NullTime
-	var Status string
-	var CompletionPercentage float64
-	var ChatHistory json.RawMessage
-
-	err := db.DB.QueryRow(context.Background(), query, assessmentID).Scan(
-		&AssessmentID,
-		&UserID,
-		&AnatomyID,
-		&AssessmentType,
-		&StartTime,
-		&EndTime,
-		&Status,
-		&CompletionPercentage,
-		&ChatHistory,
-	)
+	row, err := store.Assessments.Get(context.Background(), assessmentID)
 	if err != nil {
 		return nil, err
 	}
 
-	var endTimeValue *time.Time
-	if EndTime.Valid {
-		endTimeValue = &EndTime.Time
-	} else {
-		endTimeValue = nil
-	}
-
 	var chatHistory []ChatMessage
-	if ChatHistory == nil {
+	if row.ChatHistory == nil {
 		log.Println("No chat history found for this assessment")
 		chatHistory = []ChatMessage{} // Initialize empty array
 	} else {
 		// Try to unmarshal directly first (new format)
-		if err := json.Unmarshal(ChatHistory, &chatHistory); err != nil {
+		if err := json.Unmarshal(row.ChatHistory, &chatHistory); err != nil {
 			log.Println("Direct unmarshal failed, trying nested format...")
 			// If that fails, try the nested approach (legacy format)
 			var outerChatHistory map[string]json.RawMessage
-			if err := json.Unmarshal(ChatHistory, &outerChatHistory); err != nil {
+			if err := json.Unmarshal(row.ChatHistory, &outerChatHistory); err != nil {
 				log.Println("Failed to parse outer chat history JSON:", err)
 				return nil, err
 			}
@@ -232,238 +188,203 @@ func GetAssessment(assessmentID uint32) (*Assessment, error) {
 	}
 
 	return &Assessment{
-		AssessmentID:         AssessmentID,
-		UserID:               UserID,
-		AnatomyID:            AnatomyID,
-		AssessmentType:       AssessmentType,
-		StartTime:            StartTime,
-		EndTime:              endTimeValue,
-		Status:               Status,
-		CompletionPercentage: CompletionPercentage,
+		AssessmentID:         row.AssessmentID,
+		UserID:               row.UserID,
+		AnatomyID:            row.AnatomyID,
+		AssessmentType:       row.AssessmentType,
+		StartTime:            row.StartTime,
+		EndTime:              row.EndTime,
+		Status:               row.Status,
+		CompletionPercentage: row.CompletionPercentage,
 		ChatHistory:          chatHistory,
+		PhysioID:             row.PhysioID,
+		LastActivityAt:       row.LastActivityAt,
 	}, nil
 }
 
-// SendChatToAI sends chat history to the AI model and retrieves a response
-func SendChatToAI(assessmentIDUint uint32, chatMessage []ChatMessage) (APIResponse, error) {
-	var aiResponse APIResponse
+// SendChatToAI appends a user message to the stored chat, asks the AI for the next reply and stores both turns
+func SendChatToAI(ctx context.Context, assessmentIDUint uint32, message string) (APIResponse, error) {
+	return sendChat(ctx, assessmentIDUint, message, nil)
+}
 
-	url := "https://deecogs-bpi-bot-844145949029.europe-west1.run.app/chat"
+// StreamChatToAI is SendChatToAI relaying the reply text to onToken while the AI generates it
+func StreamChatToAI(ctx context.Context, assessmentIDUint uint32, message string, onToken clients.TokenFunc) (APIResponse, error) {
+	return sendChat(ctx, assessmentIDUint, message, onToken)
+}
 
-	// Prepare the request payload
-	payload := ChatRequest{ChatHistory: chatMessage}
-	jsonData, err := json.Marshal(payload)
-	if err != nil {
+func sendChat(ctx context.Context, assessmentIDUint uint32, message string, onToken clients.TokenFunc) (APIResponse, error) {
+	var aiResponse APIResponse
+	if err := requireOpen(assessmentIDUint); err != nil {
 		return aiResponse, err
 	}
+	touchAssessment(assessmentIDUint)
 
-	// Send the request to the AI model
-	resp, err := http.Post(url, "application/json", bytes.NewBuffer(jsonData))
+	history, err := loadChatHistory(assessmentIDUint)
 	if err != nil {
 		return aiResponse, err
 	}
-	defer resp.Body.Close()
+	history = append(history, ChatMessage{User: message})
 
-	log.Printf("Response Status: %v\n", resp.StatusCode)
-
-	if resp.StatusCode != http.StatusOK {
-		return aiResponse, errors.New("failed to get a response from AI model")
+	started := time.Now()
+	var reply *clients.AIResponse
+	if onToken != nil {
+		reply, err = aiClient.ChatStream(ctx, toClientChat(history), onToken)
+	} else {
+		reply, err = aiClient.Chat(ctx, toClientChat(history))
+	}
+	if err != nil {
+		return aiResponse, err
+	}
+	latency := time.Since(started)
+	chatReply, err := models.ParseChatReply(reply.Data)
+	if err != nil {
+		log.Printf("Rejected BPI reply for assessment %d: %v", assessmentIDUint, err)
+		return aiResponse, err
+	}
+	aiResponse, err = toAPIResponse(reply)
+	if err != nil {
+		return aiResponse, err
 	}
 
-	if err := json.NewDecoder(resp.Body).Decode(&aiResponse); err != nil {
+	history[len(history)-1].Response = chatReply.Response
+	if err := appendChatTurn(assessmentIDUint, message, chatReply.Response, string(chatReply.Action), latency); err != nil {
 		return aiResponse, err
 	}
 
-	if aiResponse.Data != nil {
-		action := aiResponse.Data.(map[string]interface{})["action"]
-		log.Printf("Action: %v\n", action)
-		if action == "next_api" {
-			log.Println("Next API call")
-			//stringify the ChatRequest and save it in the database
-			query := `UPDATE assessments SET chat_history = $1 WHERE assessment_id = $2 RETURNING assessment_id`
-			var assessmentID string
+	if err := markInProgress(assessmentIDUint); err != nil {
+		log.Printf("Warning: Failed to update assessment status: %v", err)
+	}
 
-			err = db.DB.QueryRow(context.Background(), query, jsonData, assessmentIDUint).Scan(&assessmentID)
-			if err != nil {
-				return aiResponse, err
-			}
-		}
+	if err := onChatAction(assessmentIDUint, chatReply.Action, history); err != nil {
+		return aiResponse, err
 	}
 
 	return aiResponse, nil
 }
 
 // NEW: SendVideoToAI sends video with chat history to AI for body part identification
-func SendVideoToAI(assessmentIDUint uint32, videoRequest VideoRequest) (APIResponse, error) {
-	var aiResponse APIResponse
-
-	url := "https://deecogs-bpi-bot-844145949029.europe-west1.run.app/chat"
-
-	// Prepare the request payload with video
-	payload := struct {
-		ChatHistory []ChatMessage `json:"chat_history"`
-		Video       string        `json:"video"`
-	}{
-		ChatHistory: videoRequest.ChatHistory,
-		Video:       videoRequest.Video,
+func SendVideoToAI(ctx context.Context, assessmentIDUint uint32, videoRequest VideoRequest) (APIResponse, error) {
+	log.Printf("Sending video for body part identification, video size: %d bytes", len(videoRequest.Video))
+	if err := requireOpen(assessmentIDUint); err != nil {
+		return APIResponse{}, err
+	}
+	touchAssessment(assessmentIDUint)
+
+	// Clients that no longer keep the chat send only the video
+	if len(videoRequest.ChatHistory) == 0 {
+		history, err := loadChatHistory(assessmentIDUint)
+		if err != nil {
+			return APIResponse{}, err
+		}
+		videoRequest.ChatHistory = history
 	}
 
-	jsonData, err := json.Marshal(payload)
+	reply, err := aiClient.IdentifyBodyPartFromVideo(ctx, toClientChat(videoRequest.ChatHistory), videoRequest.Video)
 	if err != nil {
-		return aiResponse, err
+		return APIResponse{}, err
 	}
-
-	log.Printf("Sending video for body part identification, payload size: %d bytes", len(jsonData))
-
-	// Send the request to the AI model
-	resp, err := http.Post(url, "application/json", bytes.NewBuffer(jsonData))
+	chatReply, err := models.ParseChatReply(reply.Data)
 	if err != nil {
-		return aiResponse, err
-	}
-	defer resp.Body.Close()
-
-	log.Printf("Video Response Status: %v\n", resp.StatusCode)
-
-	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		log.Printf("Error response body: %s", string(bodyBytes))
-		return aiResponse, errors.New("failed to get a response from AI model for video")
+		log.Printf("Rejected BPI video reply for assessment %d: %v", assessmentIDUint, err)
+		return APIResponse{}, err
 	}
 
-	if err := json.NewDecoder(resp.Body).Decode(&aiResponse); err != nil {
-		return aiResponse, err
+	if err := markInProgress(assessmentIDUint); err != nil {
+		log.Printf("Warning: Failed to update assessment status: %v", err)
 	}
 
 	// Don't save video to chat history, just process the response
 	log.Println("Video processed successfully for body part identification")
 
+	aiResponse, err := toAPIResponse(reply)
+	if err != nil {
+		return aiResponse, err
+	}
+	if err := onChatAction(assessmentIDUint, chatReply.Action, videoRequest.ChatHistory); err != nil {
+		return aiResponse, err
+	}
 	return aiResponse, nil
 }
 
 // SendQuestionsToAI sends chat history to the AI model and retrieves a response
-// Updated SendQuestionsToAI function with better error handling
-func SendQuestionsToAI(assessmentIDUint uint32, questionRequest QuestionRequest) (APIResponse, error) {
-	var aiResponse APIResponse
+func SendQuestionsToAI(ctx context.Context, assessmentIDUint uint32, questionRequest QuestionRequest) (APIResponse, error) {
+	return sendQuestions(ctx, assessmentIDUint, questionRequest, nil)
+}
 
-	url := "https://deecogs-xai-bot-844145949029.europe-west1.run.app/chat"
+// StreamQuestionsToAI is SendQuestionsToAI relaying the reply text to onToken while the AI generates it
+func StreamQuestionsToAI(ctx context.Context, assessmentIDUint uint32, questionRequest QuestionRequest, onToken clients.TokenFunc) (APIResponse, error) {
+	return sendQuestions(ctx, assessmentIDUint, questionRequest, onToken)
+}
 
+func sendQuestions(ctx context.Context, assessmentIDUint uint32, questionRequest QuestionRequest, onToken clients.TokenFunc) (APIResponse, error) {
 	// Validate question history
 	if len(questionRequest.QuestionHistory) == 0 {
 		log.Println("Warning: Empty question history")
 	}
 
-	// Log request details
-	log.Printf("Sending QnA request to AI - Assessment: %d, Messages: %d", assessmentIDUint, len(questionRequest.QuestionHistory))
-
-	// Prepare the request payload
-	payload := questionRequest
-	jsonData, err := json.Marshal(payload)
-	if err != nil {
-		log.Printf("Error marshaling request: %v", err)
-		return aiResponse, err
+	if err := requireOpen(assessmentIDUint); err != nil {
+		return APIResponse{}, err
 	}
+	touchAssessment(assessmentIDUint)
 
-	// Log payload size
-	log.Printf("Request payload size: %d bytes", len(jsonData))
+	// Log request details
+	log.Printf("Sending QnA request to AI - Assessment: %d, Messages: %d", assessmentIDUint, len(questionRequest.QuestionHistory))
 
-	// Create request with timeout
-	client := &http.Client{
-		Timeout: 30 * time.Second,
+	var reply *clients.AIResponse
+	var err error
+	history := toClientQuestions(questionRequest.QuestionHistory)
+	if onToken != nil {
+		reply, err = aiClient.NextQuestionStream(ctx, history, questionRequest.Video, onToken)
+	} else {
+		reply, err = aiClient.NextQuestion(ctx, history, questionRequest.Video)
 	}
-
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
-		log.Printf("Error creating request: %v", err)
-		return aiResponse, err
+		log.Printf("Error sending request to AI: %v", err)
+		return APIResponse{}, err
 	}
-
-	req.Header.Set("Content-Type", "application/json")
-
-	// Send the request to the AI model
-	resp, err := client.Do(req)
+	questionReply, err := models.ParseQuestionReply(reply.Data)
 	if err != nil {
-		log.Printf("Error sending request to AI: %v", err)
-		return aiResponse, err
+		log.Printf("Rejected questionnaire reply for assessment %d: %v", assessmentIDUint, err)
+		return APIResponse{}, err
 	}
-	defer resp.Body.Close()
-
-	// Read response body
-	bodyBytes, err := io.ReadAll(resp.Body)
+	aiResponse, err := toAPIResponse(reply)
 	if err != nil {
-		log.Printf("Error reading response body: %v", err)
 		return aiResponse, err
 	}
 
-	log.Printf("AI Response Status: %v, Body Size: %d bytes", resp.StatusCode, len(bodyBytes))
-
-	if resp.StatusCode != http.StatusOK {
-		log.Printf("AI returned non-200 status. Response: %s", string(bodyBytes))
-		return aiResponse, errors.New("failed to get a response from AI model")
+	if err := markInProgress(assessmentIDUint); err != nil {
+		log.Printf("Warning: Failed to update assessment status: %v", err)
 	}
 
-	// Parse response
-	if err := json.Unmarshal(bodyBytes, &aiResponse); err != nil {
-		log.Printf("Error parsing AI response: %v", err)
-		log.Printf("Raw response: %s", string(bodyBytes))
+	if err := onQuestionAction(assessmentIDUint, questionReply.Action, questionRequest); err != nil {
 		return aiResponse, err
 	}
 
-	// Check if response has data
-	if aiResponse.Data == nil {
-		log.Println("Warning: AI response has no data")
-		return aiResponse, errors.New("AI response contains no data")
-	}
-
-	// Extract action from response for logging
-	if dataMap, ok := aiResponse.Data.(map[string]interface{}); ok {
-		if action, exists := dataMap["action"]; exists {
-			log.Printf("AI Response Action: %v", action)
-
-			// Save to database if moving to next phase
-			if action == "next_api" || action == "rom_api" {
-				log.Printf("Saving questionnaire data for assessment %d", assessmentIDUint)
-
-				// Save the chat history to questionnaires table
-				query := `INSERT INTO questionnaires (assessment_id, chat_history) VALUES ($1, $2) RETURNING question_id`
-				var questionID string
-
-				err = db.DB.QueryRow(context.Background(), query, assessmentIDUint, jsonData).Scan(&questionID)
-				if err != nil {
-					log.Printf("Error saving questionnaire: %v", err)
-					// Don't fail the request, just log the error
-				} else {
-					log.Printf("Questionnaire saved with ID: %s", questionID)
-				}
-			}
-		}
-	}
-
 	return aiResponse, nil
 }
 
-// UpdateAssessmentStatus updates the status of an assessment
-func UpdateAssessmentStatus(assessmentID string, status models.AssessmentStatus) error {
+// UpdateAssessmentStatus moves an assessment to a new status, reason is recorded on the timeline when set
+func UpdateAssessmentStatus(assessmentID string, status models.AssessmentStatus, reason string) error {
 	// Validate the status
 	if !status.IsValid() {
 		return errors.New("invalid assessment status")
 	}
 
-	query := `
-		UPDATE assessments
-		SET status = $1
-		WHERE assessment_id = $2
-	`
-	result, err := db.DB.Exec(context.Background(), query, status.String(), assessmentID)
+	assessmentIDUint, err := helpers.StringToUInt32(assessmentID)
 	if err != nil {
-		return err
+		return ErrAssessmentNotFound
 	}
 
-	rowsAffected := result.RowsAffected()
-	if rowsAffected == 0 {
-		return errors.New("assessment not found")
+	var details json.RawMessage
+	if reason != "" {
+		details, err = json.Marshal(map[string]string{"reason": reason})
+		if err != nil {
+			return err
+		}
 	}
 
-	return nil
+	// Only transitions allowed by the state machine are applied
+	return TransitionAssessment(assessmentIDUint, status, details)
 }
 
 type StartSessionRequest struct {
@@ -478,55 +399,35 @@ type StartSessionResponse struct {
 
 // FetchAssessmentData retrieves chat history & ROM data for assessment
 func FetchAssessmentData(assessmentID uint32) (*DashboardDataAIRequest, error) {
-	var chatHistoryRaw json.RawMessage
-	var poseModelDataRaw json.RawMessage
 	var response *DashboardDataAIRequest
 
 	// Fetch chat history from `questionnaires`
-	queryChat := `SELECT chat_history FROM questionnaires WHERE assessment_id = $1 order by created_at desc limit 1`
-	err := db.DB.QueryRow(context.Background(), queryChat, assessmentID).Scan(&chatHistoryRaw)
+	questionnaire, err := store.Questionnaires.Latest(context.Background(), assessmentID)
 	if err != nil {
-		if err == sql.ErrNoRows {
+		if errors.Is(err, db.ErrNotFound) {
 			log.Println("Chat history not found for assessment:", assessmentID)
 			return nil, errors.New("chat history not found")
 		}
 		log.Println("Failed to fetch chat history:", err)
 		return nil, err
 	}
+	chatHistoryRaw := questionnaire.ChatHistory
 
 	// Fetch pose model data from `rom_analysis`
-	queryROM := `SELECT pose_model_data FROM rom_analysis WHERE assessment_id = $1 order by created_at desc limit 1`
-	err = db.DB.QueryRow(context.Background(), queryROM, assessmentID).Scan(&poseModelDataRaw)
+	rom, err := store.ROM.Latest(context.Background(), assessmentID)
 	if err != nil {
-		if err == sql.ErrNoRows {
+		if errors.Is(err, db.ErrNotFound) {
 			log.Println("Pose model data not found for assessment:", assessmentID)
 			return nil, errors.New("pose model data not found")
 		}
 		log.Println("Failed to fetch pose model data:", err)
 		return nil, err
 	}
+	poseModelDataRaw := rom.PoseModelData
 
-	// Try to unmarshal chat history directly first
-	var chatHistory []QuestionMessage
-	if err := json.Unmarshal(chatHistoryRaw, &chatHistory); err != nil {
-		log.Println("Direct unmarshal failed, trying nested format...")
-		// If that fails, try the nested approach (legacy format)
-		var outerChatHistory map[string]json.RawMessage
-		if err := json.Unmarshal(chatHistoryRaw, &outerChatHistory); err != nil {
-			log.Println("Failed to parse outer chat history JSON:", err)
-			return nil, err
-		}
-
-		// Step 2: Extract Inner `chat_history` JSON
-		if rawInner, exists := outerChatHistory["chat_history"]; exists {
-			if err := json.Unmarshal(rawInner, &chatHistory); err != nil {
-				log.Println("Failed to parse inner chat history JSON:", err)
-				return nil, err
-			}
-		} else {
-			log.Println("Could not parse chat_history in any known format, using empty array")
-			chatHistory = []QuestionMessage{} // Initialize empty array instead of error
-		}
+	chatHistory, err := decodeQuestionHistory(chatHistoryRaw)
+	if err != nil {
+		return nil, err
 	}
 
 	// Step 1: Convert Raw JSON to Map
@@ -551,86 +452,119 @@ func FetchAssessmentData(assessmentID uint32) (*DashboardDataAIRequest, error) {
 	response = &DashboardDataAIRequest{
 		ChatHistory:   chatHistory,
 		RangeOfMotion: rangeOfMotion,
+		Measurements:  scoredROMMeasurements(rom),
 	}
 
 	return response, nil
 }
 
-// RequestAIAnalysisFromAI sends the dashboard data to the AI model and retrieves a response
-func RequestAIAnalysisFromAI(assessmentID uint32, dashboardData *DashboardDataAIRequest) (*AIResult, error) {
-	aiRequest := AIRequest{Content: *dashboardData}
+// decodeQuestionHistory parses the chat history of a questionnaire, stored either as a list
+// of questions or, by older clients, nested under chat_history
+func decodeQuestionHistory(chatHistoryRaw json.RawMessage) ([]QuestionMessage, error) {
+	// Try to unmarshal chat history directly first
+	var chatHistory []QuestionMessage
+	if err := json.Unmarshal(chatHistoryRaw, &chatHistory); err != nil {
+		log.Println("Direct unmarshal failed, trying nested format...")
+		// If that fails, try the nested approach (legacy format)
+		var outerChatHistory map[string]json.RawMessage
+		if err := json.Unmarshal(chatHistoryRaw, &outerChatHistory); err != nil {
+			log.Println("Failed to parse outer chat history JSON:", err)
+			return nil, err
+		}
 
-	// Convert to JSON
-	requestBody, err := json.Marshal(aiRequest)
-	if err != nil {
-		log.Println("Error marshalling AI request:", err)
-		return nil, err
+		// Step 2: Extract Inner `chat_history` JSON
+		if rawInner, exists := outerChatHistory["chat_history"]; exists {
+			if err := json.Unmarshal(rawInner, &chatHistory); err != nil {
+				log.Println("Failed to parse inner chat history JSON:", err)
+				return nil, err
+			}
+		} else {
+			log.Println("Could not parse chat_history in any known format, using empty array")
+			chatHistory = []QuestionMessage{} // Initialize empty array instead of error
+		}
 	}
+	return chatHistory, nil
+}
 
-	url := "https://europe-west2-dochq-staging.cloudfunctions.net/deecogs-dashboard"
-
-	// Send the request to the AI model
-	resp, err := http.Post(url, "application/json", bytes.NewBuffer(requestBody))
+// RequestAIAnalysisFromAI sends the dashboard data to the AI model and retrieves a response
+func RequestAIAnalysisFromAI(ctx context.Context, assessmentID uint32, dashboardData *DashboardDataAIRequest) (*AIResult, error) {
+	content, err := json.Marshal(dashboardData)
 	if err != nil {
+		log.Println("Error marshalling AI request:", err)
 		return nil, err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, errors.New("failed to get a response from AI model")
-	}
-	//Read raw response body before decoding
-	rawResponse, err := io.ReadAll(resp.Body)
+	reply, err := aiClient.AnalyseDashboard(ctx, content)
 	if err != nil {
-		log.Println("Error reading raw response:", err)
 		return nil, err
 	}
 	//Log raw response
-	log.Printf("Raw AI API Response: %s\n", string(rawResponse))
+	log.Printf("Raw AI API Response: %s\n", string(reply.Data))
 
-	var aiResponse AIResponse
-	if err := json.Unmarshal(rawResponse, &aiResponse); err != nil {
+	var result AIResult
+	if err := json.Unmarshal(reply.Data, &result); err != nil {
 		log.Println("Error decoding AI API dashboard response:", err)
 		return nil, err
 	}
 
-	return &aiResponse.Data, nil
+	return &result, nil
 }
 
-// SaveAIAnalysis saves the AI analysis results in the database
-func SaveAIAnalysis(assessmentID uint32, dashboardData *DashboardDataAIRequest, aiResult *AIResult) error {
-	// Convert AI Analysis to JSON
-	response, err := json.Marshal(aiResult.Response)
+// MarkAssessmentComplete records the dashboard phase and completes the assessment,
+// completing it again is a no-op so retried requests are harmless
+func MarkAssessmentComplete(assessmentID uint32) error {
+	row, err := store.Assessments.Get(context.Background(), assessmentID)
 	if err != nil {
-		log.Println("Error marshalling symptoms:", err)
+		if errors.Is(err, db.ErrNotFound) {
+			return ErrAssessmentNotFound
+		}
 		return err
 	}
-
-	query := `
-		INSERT INTO ai_analysis (assessment_id, assessment_data, analysed_results, created_at)
-		VALUES ($1, $2, $3, NOW())
-	`
-	_, err = db.DB.Exec(context.Background(), query, assessmentID, dashboardData, response)
-	if err != nil {
-		log.Println("Error saving AI analysis:", err)
+	if models.AssessmentStatus(row.Status) == models.StatusCompleted {
+		return nil
+	}
+	if err := CompletePhase(assessmentID, models.PhaseDashboard); err != nil {
 		return err
 	}
-
-	return nil
+	return TransitionAssessment(assessmentID, models.StatusCompleted, nil)
 }
 
-// MarkAssessmentComplete marks the assessment as complete
-func MarkAssessmentComplete(assessmentID uint32) error {
-	query := `
-		UPDATE assessments
-		SET status = $1, completion_percentage = $2, end_time = NOW()
-		WHERE assessment_id = $3
-	`
-	_, err := db.DB.Exec(context.Background(), query, models.StatusCompleted.String(), 100, assessmentID)
+// ListAssignedAssessments returns the assessments assigned to a physiotherapist
+func ListAssignedAssessments(physioID uint32) ([]Assessment, error) {
+	rows, err := store.Assessments.ListByPhysio(context.Background(), physioID)
 	if err != nil {
-		log.Println("Error marking assessment as complete:", err)
-		return err
+		return nil, err
 	}
+	assessments := make([]Assessment, 0, len(rows))
+	for _, row := range rows {
+		assessments = append(assessments, Assessment{
+			AssessmentID:         row.AssessmentID,
+			UserID:               row.UserID,
+			AnatomyID:            row.AnatomyID,
+			AssessmentType:       row.AssessmentType,
+			StartTime:            row.StartTime,
+			EndTime:              row.EndTime,
+			Status:               row.Status,
+			CompletionPercentage: row.CompletionPercentage,
+			PhysioID:             row.PhysioID,
+			LastActivityAt:       row.LastActivityAt,
+		})
+	}
+	return assessments, nil
+}
 
+// AssignPhysio makes a physiotherapist responsible for an assessment, nil unassigns it
+func AssignPhysio(assessmentID uint32, physioID *uint32) error {
+	if physioID != nil {
+		if err := requirePhysio(*physioID); err != nil {
+			return err
+		}
+	}
+	if err := store.Assessments.AssignPhysio(context.Background(), assessmentID, physioID); err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return ErrAssessmentNotFound
+		}
+		return err
+	}
 	return nil
 }
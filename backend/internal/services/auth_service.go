@@ -1 +1,336 @@
-package services
\ No newline at end of file
+package services
+
+import (
+	"ai-bot-deecogs/internal/db"
+	"ai-bot-deecogs/internal/models"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Token types carried in the "typ" claim
+const (
+	TokenTypeAccess  = "access"
+	TokenTypeRefresh = "refresh"
+)
+
+var (
+	ErrInvalidCredentials = errors.New("invalid email or password")
+	ErrInvalidToken       = errors.New("invalid or expired token")
+	ErrTokenRevoked       = errors.New("token has been revoked")
+	ErrForbidden          = errors.New("access to this resource is not allowed")
+)
+
+// AuthConfig holds the JWT signing settings
+type AuthConfig struct {
+	Secret     []byte
+	Issuer     string
+	AccessTTL  time.Duration
+	RefreshTTL time.Duration
+}
+
+var authConfig = AuthConfig{
+	Issuer:     "ai-bot-deecogs",
+	AccessTTL:  15 * time.Minute,
+	RefreshTTL: 7 * 24 * time.Hour,
+}
+
+// configureAuth sets the JWT signing settings, unset TTLs and issuer keep their defaults
+func configureAuth(cfg AuthConfig) error {
+	if len(cfg.Secret) == 0 {
+		return errors.New("JWT secret is not set")
+	}
+	if cfg.Issuer == "" {
+		cfg.Issuer = authConfig.Issuer
+	}
+	if cfg.AccessTTL == 0 {
+		cfg.AccessTTL = authConfig.AccessTTL
+	}
+	if cfg.RefreshTTL == 0 {
+		cfg.RefreshTTL = authConfig.RefreshTTL
+	}
+	authConfig = cfg
+	return nil
+}
+
+// TokenClaims are the claims of both access and refresh tokens
+type TokenClaims struct {
+	TokenType string `json:"typ"`
+	Version   int32  `json:"ver"` // token version of the user when the token was signed
+	jwt.RegisteredClaims
+}
+
+// UserID returns the subject of the token as a user id
+func (c *TokenClaims) UserID() (uint32, error) {
+	id, err := strconv.ParseUint(c.Subject, 10, 32)
+	if err != nil {
+		return 0, ErrInvalidToken
+	}
+	return uint32(id), nil
+}
+
+// Principal is the authenticated caller of a request
+type Principal struct {
+	UserID uint32      `json:"userId"`
+	Name   string      `json:"name"`
+	Email  string      `json:"email"`
+	Role   models.Role `json:"role"`
+}
+
+// Can reports whether the caller's role grants a permission
+func (p *Principal) Can(perm models.Permission) bool {
+	return p.Role.Can(perm)
+}
+
+// AccessLevel is the kind of access requested on an assessment
+type AccessLevel int
+
+const (
+	AccessRead AccessLevel = iota
+	AccessWrite
+)
+
+// TokenPair is returned on login and refresh
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+// HashPassword hashes a plain text password with bcrypt
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// CheckPassword compares a plain text password with a bcrypt hash
+func CheckPassword(hash, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+// Authenticate checks credentials and returns the matching user
+func Authenticate(email, password string) (*User, error) {
+	user, err := GetUserByEmail(email)
+	if err != nil {
+		return nil, ErrInvalidCredentials
+	}
+	if !CheckPassword(user.Password, password) {
+		return nil, ErrInvalidCredentials
+	}
+	return user, nil
+}
+
+// IssueTokens signs a new access and refresh token for a user
+func IssueTokens(userID uint32) (*TokenPair, error) {
+	user, err := store.Users.Get(context.Background(), userID)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	return issueTokens(user)
+}
+
+func issueTokens(user *db.User) (*TokenPair, error) {
+	access, err := signToken(user, TokenTypeAccess, authConfig.AccessTTL)
+	if err != nil {
+		return nil, err
+	}
+	refresh, err := signToken(user, TokenTypeRefresh, authConfig.RefreshTTL)
+	if err != nil {
+		return nil, err
+	}
+	return &TokenPair{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(authConfig.AccessTTL.Seconds()),
+	}, nil
+}
+
+// ParseToken verifies a token's signature, expiry, type and revocation status
+func ParseToken(tokenString, tokenType string) (*TokenClaims, error) {
+	claims := &TokenClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
+		return authConfig.Secret, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(authConfig.Issuer),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil || claims.TokenType != tokenType || claims.ID == "" {
+		return nil, ErrInvalidToken
+	}
+
+	revoked, err := store.RevokedTokens.IsRevoked(context.Background(), claims.ID)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, ErrTokenRevoked
+	}
+	return claims, nil
+}
+
+// tokenUser loads the active user of a parsed token, tokens signed before the user's
+// last password change or deletion are revoked
+func tokenUser(claims *TokenClaims) (*db.User, error) {
+	userID, err := claims.UserID()
+	if err != nil {
+		return nil, err
+	}
+	user, err := store.Users.Get(context.Background(), userID)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, ErrInvalidToken
+		}
+		return nil, err
+	}
+	if claims.Version != user.TokenVersion {
+		return nil, ErrTokenRevoked
+	}
+	return user, nil
+}
+
+// AuthenticateToken resolves a bearer access token to the calling user
+func AuthenticateToken(accessToken string) (*Principal, error) {
+	claims, err := ParseToken(accessToken, TokenTypeAccess)
+	if err != nil {
+		return nil, err
+	}
+	user, err := tokenUser(claims)
+	if err != nil {
+		return nil, err
+	}
+	// The role is read on every request so a demotion takes effect immediately
+	return &Principal{UserID: user.UserID, Name: user.Name, Email: user.Email, Role: models.Role(user.Role)}, nil
+}
+
+// AuthorizeAssessment checks the caller's access to an assessment: owners read and write,
+// the assigned physiotherapist only reads and admins do both on any assessment
+func AuthorizeAssessment(principal *Principal, assessmentID uint32, level AccessLevel) error {
+	assessment, err := store.Assessments.Get(context.Background(), assessmentID)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return ErrAssessmentNotFound
+		}
+		return err
+	}
+
+	if level == AccessRead {
+		switch {
+		case principal.Can(models.PermAssessmentReadAll):
+			return nil
+		case assessment.UserID == principal.UserID && principal.Can(models.PermAssessmentReadOwn):
+			return nil
+		case assessment.PhysioID != nil && *assessment.PhysioID == principal.UserID && principal.Can(models.PermAssessmentReadAssigned):
+			return nil
+		}
+		return ErrForbidden
+	}
+
+	switch {
+	case principal.Can(models.PermAssessmentWriteAll):
+		return nil
+	case assessment.UserID == principal.UserID && principal.Can(models.PermAssessmentWriteOwn):
+		return nil
+	}
+	return ErrForbidden
+}
+
+// RefreshTokens rotates a refresh token: the old one is revoked and a new pair issued
+func RefreshTokens(refreshToken string) (*TokenPair, error) {
+	claims, err := ParseToken(refreshToken, TokenTypeRefresh)
+	if err != nil {
+		return nil, err
+	}
+	user, err := tokenUser(claims)
+	if err != nil {
+		return nil, err
+	}
+	if err := RevokeToken(claims); err != nil {
+		return nil, err
+	}
+	return issueTokens(user)
+}
+
+// RevokeToken records a parsed token as revoked until it expires
+func RevokeToken(claims *TokenClaims) error {
+	userID, err := claims.UserID()
+	if err != nil {
+		return err
+	}
+	return store.RevokedTokens.Revoke(context.Background(), db.RevokedToken{
+		JTI:       claims.ID,
+		UserID:    userID,
+		TokenType: claims.TokenType,
+		ExpiresAt: claims.ExpiresAt.Time,
+	})
+}
+
+// Logout revokes the given access token and, when present, the refresh token of the session
+func Logout(accessToken, refreshToken string) error {
+	accessClaims, err := ParseToken(accessToken, TokenTypeAccess)
+	if err != nil {
+		return err
+	}
+	if err := RevokeToken(accessClaims); err != nil {
+		return err
+	}
+	if refreshToken == "" {
+		return nil
+	}
+
+	refreshClaims, err := ParseToken(refreshToken, TokenTypeRefresh)
+	if err != nil {
+		if errors.Is(err, ErrTokenRevoked) {
+			return nil
+		}
+		return err
+	}
+	if refreshClaims.Subject != accessClaims.Subject {
+		return ErrInvalidToken
+	}
+	return RevokeToken(refreshClaims)
+}
+
+func signToken(user *db.User, tokenType string, ttl time.Duration) (string, error) {
+	jti, err := newTokenID()
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	claims := TokenClaims{
+		TokenType: tokenType,
+		Version:   user.TokenVersion,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			Subject:   strconv.FormatUint(uint64(user.UserID), 10),
+			Issuer:    authConfig.Issuer,
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(authConfig.Secret)
+}
+
+func newTokenID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate token id: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
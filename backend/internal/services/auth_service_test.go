@@ -0,0 +1,86 @@
+package services
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+
+	"ai-bot-deecogs/internal/models"
+)
+
+func TestAccountChangesSignOutSessions(t *testing.T) {
+	if err := configureAuth(AuthConfig{Secret: []byte("test-secret")}); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		change func(userID uint32) error
+	}{
+		{"password change", func(userID uint32) error {
+			return ChangePassword(userID, "password123", "password456", true)
+		}},
+		{"soft delete", DeleteUser},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useMemoryStore(t)
+			user, err := CreateUser("Jane", "jane@example.com", "password123")
+			if err != nil {
+				t.Fatal(err)
+			}
+			tokens, err := IssueTokens(user.UserID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := AuthenticateToken(tokens.AccessToken); err != nil {
+				t.Fatalf("AuthenticateToken before the change: %v", err)
+			}
+
+			if err := tt.change(user.UserID); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := AuthenticateToken(tokens.AccessToken); !errors.Is(err, ErrTokenRevoked) && !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("AuthenticateToken after the %s = %v, want the token refused", tt.name, err)
+			}
+			if _, err := RefreshTokens(tokens.RefreshToken); !errors.Is(err, ErrTokenRevoked) && !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("RefreshTokens after the %s = %v, want the token refused", tt.name, err)
+			}
+		})
+	}
+}
+
+func TestPasswordChangeKeepsNewSessions(t *testing.T) {
+	if err := configureAuth(AuthConfig{Secret: []byte("test-secret")}); err != nil {
+		t.Fatal(err)
+	}
+	useMemoryStore(t)
+	user, err := CreateUser("Jane", "jane@example.com", "password123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ChangePassword(user.UserID, "password123", "password456", true); err != nil {
+		t.Fatal(err)
+	}
+	tokens, err := IssueTokens(user.UserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := AuthenticateToken(tokens.AccessToken); err != nil {
+		t.Errorf("AuthenticateToken with a token issued after the change: %v", err)
+	}
+	if _, err := RefreshTokens(tokens.RefreshToken); err != nil {
+		t.Errorf("RefreshTokens with a token issued after the change: %v", err)
+	}
+}
+
+func TestAuthorizeUnknownAssessment(t *testing.T) {
+	b := useMemoryStore(t)
+	id := newTestAssessment(t, b)
+	admin := &Principal{UserID: 1, Role: models.RoleAdmin}
+	if err := AuthorizeAssessment(admin, id+1, AccessRead); !errors.Is(err, ErrAssessmentNotFound) {
+		t.Fatalf("AuthorizeAssessment on a missing assessment = %v, want ErrAssessmentNotFound", err)
+	}
+	if _, err := SchedulePhysioCall(strconv.FormatUint(uint64(id+1), 10), "immediate", nil, 0); !errors.Is(err, ErrAssessmentNotFound) {
+		t.Fatalf("SchedulePhysioCall on a missing assessment = %v, want ErrAssessmentNotFound", err)
+	}
+}
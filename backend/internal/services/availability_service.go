@@ -0,0 +1,500 @@
+package services
+
+import (
+	"ai-bot-deecogs/internal/db"
+	"ai-bot-deecogs/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	_ "time/tzdata" // the runtime image has no zoneinfo database
+)
+
+var (
+	ErrInvalidAvailability = errors.New("invalid availability")
+	ErrSlotUnavailable     = errors.New("the physiotherapist is not available in that slot")
+	ErrExceptionNotFound   = errors.New("availability exception not found")
+)
+
+// maxSlotRange bounds the range of one slot listing
+const maxSlotRange = 31 * 24 * time.Hour
+
+// weekdays names the time.Weekday values, Sunday first
+var weekdays = []string{"sunday", "monday", "tuesday", "wednesday", "thursday", "friday", "saturday"}
+
+// AvailabilityWindow is a weekly window in the time zone of the physiotherapist
+type AvailabilityWindow struct {
+	Weekday string `json:"weekday"` // sunday to saturday
+	Start   string `json:"start"`   // HH:MM
+	End     string `json:"end"`     // HH:MM, 24:00 for midnight
+}
+
+// Availability is the weekly calendar of a physiotherapist
+type Availability struct {
+	PhysioID    uint32               `json:"physio_id"`
+	TimeZone    string               `json:"time_zone"`    // IANA name, e.g. Europe/Berlin
+	SlotMinutes int                  `json:"slot_minutes"` // length of a bookable slot
+	Windows     []AvailabilityWindow `json:"windows"`
+}
+
+// AvailabilityException is time off, or extra hours when Available is set
+type AvailabilityException struct {
+	ExceptionID uint32    `json:"exception_id"`
+	PhysioID    uint32    `json:"physio_id"`
+	StartsAt    time.Time `json:"starts_at"`
+	EndsAt      time.Time `json:"ends_at"`
+	Available   bool      `json:"available"`
+	Reason      *string   `json:"reason,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Slot is a bookable call slot
+type Slot struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// PhysioSlots are the free slots of a physiotherapist, in their time zone
+type PhysioSlots struct {
+	PhysioID    uint32 `json:"physio_id"`
+	TimeZone    string `json:"time_zone"`
+	SlotMinutes int    `json:"slot_minutes"`
+	Slots       []Slot `json:"slots"`
+}
+
+// GetAvailability returns the weekly calendar of a physiotherapist, an empty one if they
+// have not published any windows
+func GetAvailability(physioID uint32) (*Availability, error) {
+	if err := requirePhysio(physioID); err != nil {
+		return nil, err
+	}
+	rows, err := store.Availability.ListWindows(context.Background(), physioID)
+	if err != nil {
+		return nil, err
+	}
+	return toAvailability(physioID, rows), nil
+}
+
+// SetAvailability replaces the weekly calendar of a physiotherapist. Windows of a weekday
+// must not overlap and must fit at least one slot.
+func SetAvailability(physioID uint32, availability Availability) (*Availability, error) {
+	if err := requirePhysio(physioID); err != nil {
+		return nil, err
+	}
+
+	timeZone := availability.TimeZone
+	if timeZone == "" {
+		timeZone = "UTC"
+	}
+	if _, err := time.LoadLocation(timeZone); err != nil || timeZone == "Local" {
+		return nil, fmt.Errorf("%w: unknown time_zone %q", ErrInvalidAvailability, timeZone)
+	}
+	slotMinutes := availability.SlotMinutes
+	if slotMinutes == 0 {
+		slotMinutes = defaultCallMinutes
+	}
+	if slotMinutes < minCallMinutes || slotMinutes > maxCallMinutes {
+		return nil, fmt.Errorf("%w: slot_minutes must be between %d and %d", ErrInvalidAvailability, minCallMinutes, maxCallMinutes)
+	}
+
+	rows := make([]db.AvailabilityWindow, 0, len(availability.Windows))
+	for i, w := range availability.Windows {
+		weekday := weekdayOf(w.Weekday)
+		if weekday < 0 {
+			return nil, fmt.Errorf("%w: window %d: unknown weekday %q", ErrInvalidAvailability, i, w.Weekday)
+		}
+		start, err := parseClock(w.Start)
+		if err != nil {
+			return nil, fmt.Errorf("%w: window %d: start %v", ErrInvalidAvailability, i, err)
+		}
+		end, err := parseClock(w.End)
+		if err != nil {
+			return nil, fmt.Errorf("%w: window %d: end %v", ErrInvalidAvailability, i, err)
+		}
+		if end-start < slotMinutes {
+			return nil, fmt.Errorf("%w: window %d must end at least one slot after it starts", ErrInvalidAvailability, i)
+		}
+		rows = append(rows, db.AvailabilityWindow{
+			Weekday:     weekday,
+			StartMinute: start,
+			EndMinute:   end,
+			TimeZone:    timeZone,
+			SlotMinutes: slotMinutes,
+		})
+	}
+
+	sorted := append([]db.AvailabilityWindow(nil), rows...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Weekday != sorted[j].Weekday {
+			return sorted[i].Weekday < sorted[j].Weekday
+		}
+		return sorted[i].StartMinute < sorted[j].StartMinute
+	})
+	for i := 1; i < len(sorted); i++ {
+		if sorted[i].Weekday == sorted[i-1].Weekday && sorted[i].StartMinute < sorted[i-1].EndMinute {
+			return nil, fmt.Errorf("%w: windows overlap on %s", ErrInvalidAvailability, weekdays[sorted[i].Weekday])
+		}
+	}
+
+	if err := store.Availability.ReplaceWindows(context.Background(), physioID, rows); err != nil {
+		return nil, err
+	}
+	availability = *toAvailability(physioID, rows)
+	availability.TimeZone, availability.SlotMinutes = timeZone, slotMinutes
+	return &availability, nil
+}
+
+// ListAvailabilityExceptions returns the exceptions of a physiotherapist overlapping [from, to)
+func ListAvailabilityExceptions(physioID uint32, from, to *time.Time) ([]AvailabilityException, error) {
+	if err := requirePhysio(physioID); err != nil {
+		return nil, err
+	}
+	if from != nil && to != nil && !from.Before(*to) {
+		return nil, fmt.Errorf("%w: from must be before to", ErrInvalidAvailability)
+	}
+	rows, err := store.Availability.ListExceptions(context.Background(), physioID, utcPtr(from), utcPtr(to))
+	if err != nil {
+		return nil, err
+	}
+	exceptions := make([]AvailabilityException, 0, len(rows))
+	for _, row := range rows {
+		exceptions = append(exceptions, toAvailabilityException(row))
+	}
+	return exceptions, nil
+}
+
+// AddAvailabilityException records time off, or extra hours when available is set. Calls
+// already booked in the time off are left to the physiotherapist to cancel or move.
+func AddAvailabilityException(physioID uint32, startsAt, endsAt time.Time, available bool, reason *string) (*AvailabilityException, error) {
+	if err := requirePhysio(physioID); err != nil {
+		return nil, err
+	}
+	if !startsAt.Before(endsAt) {
+		return nil, fmt.Errorf("%w: starts_at must be before ends_at", ErrInvalidAvailability)
+	}
+	row := db.AvailabilityException{
+		PhysioID:  physioID,
+		StartsAt:  startsAt.UTC(),
+		EndsAt:    endsAt.UTC(),
+		Available: available,
+		Reason:    reason,
+	}
+	if err := store.Availability.CreateException(context.Background(), &row); err != nil {
+		return nil, err
+	}
+	exception := toAvailabilityException(row)
+	return &exception, nil
+}
+
+// DeleteAvailabilityException removes an exception of a physiotherapist
+func DeleteAvailabilityException(physioID, exceptionID uint32) error {
+	err := store.Availability.DeleteException(context.Background(), physioID, exceptionID)
+	if errors.Is(err, db.ErrNotFound) {
+		return ErrExceptionNotFound
+	}
+	return err
+}
+
+// ListPhysioSlots returns the slots of a physiotherapist starting from the later of from and
+// now that end by to and do not overlap an open call of theirs
+func ListPhysioSlots(physioID uint32, from, to time.Time) (*PhysioSlots, error) {
+	if !from.Before(to) {
+		return nil, fmt.Errorf("%w: from must be before to", ErrInvalidAvailability)
+	}
+	if to.Sub(from) > maxSlotRange {
+		return nil, fmt.Errorf("%w: the range can span at most %d days", ErrInvalidAvailability, int(maxSlotRange.Hours()/24))
+	}
+	if err := requirePhysio(physioID); err != nil {
+		return nil, err
+	}
+	if now := time.Now(); from.Before(now) {
+		from = now
+	}
+
+	ctx := context.Background()
+	windows, err := store.Availability.ListWindows(ctx, physioID)
+	if err != nil {
+		return nil, err
+	}
+	around, until := from.Add(-24*time.Hour).UTC(), to.Add(24*time.Hour).UTC()
+	exceptions, err := store.Availability.ListExceptions(ctx, physioID, &around, &until)
+	if err != nil {
+		return nil, err
+	}
+	// a call that starts before from can still run into it
+	since := from.Add(-maxCallMinutes * time.Minute).UTC()
+	end := to.UTC()
+	calls, err := store.PhysioCalls.List(ctx, db.PhysioCallFilter{PhysioID: &physioID, From: &since, To: &end})
+	if err != nil {
+		return nil, err
+	}
+
+	cal := newCalendar(db.Calendar{Windows: windows, Exceptions: exceptions})
+	slots := []Slot{}
+	for _, slot := range cal.slots(from, to) {
+		if !overlapsOpenCall(calls, slot.Start, slot.End) {
+			slots = append(slots, Slot{Start: slot.Start.In(cal.loc), End: slot.End.In(cal.loc)})
+		}
+	}
+	return &PhysioSlots{
+		PhysioID:    physioID,
+		TimeZone:    cal.loc.String(),
+		SlotMinutes: int(cal.slot / time.Minute),
+		Slots:       slots,
+	}, nil
+}
+
+func overlapsOpenCall(calls []db.PhysioCall, start, end time.Time) bool {
+	for _, call := range calls {
+		status := models.CallStatus(call.CallStatus)
+		if call.ScheduledTime == nil || (status != models.CallScheduled && status != models.CallInProgress) {
+			continue
+		}
+		if call.ScheduledTime.Before(end) && call.End().After(start) {
+			return true
+		}
+	}
+	return false
+}
+
+// BookPhysioSlot books a slot returned by ListPhysioSlots as a scheduled call of the
+// assessment. physioID defaults to the physiotherapist of the assessment. The slot is checked
+// again inside the booking transaction, so of two patients racing for it one gets
+// ErrCallOverlap.
+func BookPhysioSlot(assessmentID uint32, physioID *uint32, start time.Time) (*PhysioCall, error) {
+	if start.Before(time.Now()) {
+		return nil, fmt.Errorf("%w: start is in the past", ErrInvalidCall)
+	}
+	ctx := context.Background()
+	assessment, err := store.Assessments.Get(ctx, assessmentID)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, ErrAssessmentNotFound
+		}
+		return nil, err
+	}
+	if physioID == nil {
+		physioID = assessment.PhysioID
+	}
+	if physioID == nil {
+		return nil, fmt.Errorf("%w: physio_id is required, the assessment has no physiotherapist", ErrInvalidCall)
+	}
+	if err := requirePhysio(*physioID); err != nil {
+		return nil, err
+	}
+	windows, err := store.Availability.ListWindows(ctx, *physioID)
+	if err != nil {
+		return nil, err
+	}
+
+	at := start.UTC()
+	return createCall(&db.PhysioCall{
+		AssessmentID:    assessmentID,
+		CallType:        models.CallScheduledType.String(),
+		CallStatus:      models.CallScheduled.String(),
+		ScheduledTime:   &at,
+		DurationMinutes: int(newCalendar(db.Calendar{Windows: windows}).slot / time.Minute),
+		PhysioID:        physioID,
+		InitiatedBy:     callByUser,
+	}, isBookableSlot)
+}
+
+// isBookableSlot is the db.CalendarCheck of bookings: the call must take exactly one slot
+func isBookableSlot(call *db.PhysioCall, c db.Calendar) error {
+	for _, slot := range newCalendar(c).slots(*call.ScheduledTime, call.End()) {
+		if slot.Start.Equal(*call.ScheduledTime) && slot.End.Equal(call.End()) {
+			return nil
+		}
+	}
+	return ErrSlotUnavailable
+}
+
+// fitsAvailability is the db.CalendarCheck of calls scheduled at a chosen time: the call must
+// lie within the hours of the physiotherapist, when they have published any
+func fitsAvailability(call *db.PhysioCall, c db.Calendar) error {
+	if !newCalendar(c).fits(*call.ScheduledTime, call.End()) {
+		return ErrSlotUnavailable
+	}
+	return nil
+}
+
+// calendar expands the weekly windows and exceptions of a physiotherapist into time spans
+type calendar struct {
+	loc        *time.Location
+	slot       time.Duration
+	windows    []db.AvailabilityWindow
+	exceptions []db.AvailabilityException
+}
+
+type span struct {
+	start, end time.Time
+}
+
+func newCalendar(c db.Calendar) *calendar {
+	cal := &calendar{loc: time.UTC, slot: defaultCallMinutes * time.Minute, windows: c.Windows, exceptions: c.Exceptions}
+	if len(c.Windows) > 0 {
+		if loc, err := time.LoadLocation(c.Windows[0].TimeZone); err == nil {
+			cal.loc = loc
+		}
+		cal.slot = time.Duration(c.Windows[0].SlotMinutes) * time.Minute
+	}
+	return cal
+}
+
+// open returns the disjoint spans around [from, to) in which the physiotherapist takes calls:
+// the weekly windows of each local day plus the extra hours, less the time off
+func (c *calendar) open(from, to time.Time) []span {
+	var spans []span
+	local := from.In(c.loc)
+	for day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, c.loc); day.Before(to); day = day.AddDate(0, 0, 1) {
+		y, m, d := day.Date()
+		for _, w := range c.windows {
+			if w.Weekday == int(day.Weekday()) {
+				// time.Date resolves the minutes on the local clock, so windows follow DST
+				spans = append(spans, span{time.Date(y, m, d, 0, w.StartMinute, 0, 0, c.loc), time.Date(y, m, d, 0, w.EndMinute, 0, 0, c.loc)})
+			}
+		}
+	}
+	for _, e := range c.exceptions {
+		if e.Available {
+			spans = append(spans, span{e.StartsAt, e.EndsAt})
+		}
+	}
+
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start.Before(spans[j].start) })
+	var merged []span
+	for _, s := range spans {
+		if n := len(merged); n > 0 && !s.start.After(merged[n-1].end) {
+			if s.end.After(merged[n-1].end) {
+				merged[n-1].end = s.end
+			}
+			continue
+		}
+		merged = append(merged, s)
+	}
+
+	for _, e := range c.exceptions {
+		if e.Available {
+			continue
+		}
+		var kept []span
+		for _, s := range merged {
+			if !e.StartsAt.Before(s.end) || !e.EndsAt.After(s.start) {
+				kept = append(kept, s)
+				continue
+			}
+			if s.start.Before(e.StartsAt) {
+				kept = append(kept, span{s.start, e.StartsAt})
+			}
+			if e.EndsAt.Before(s.end) {
+				kept = append(kept, span{e.EndsAt, s.end})
+			}
+		}
+		merged = kept
+	}
+	return merged
+}
+
+// slots cuts the open spans into slots from their start and keeps those within [from, to)
+func (c *calendar) slots(from, to time.Time) []Slot {
+	var slots []Slot
+	for _, s := range c.open(from, to) {
+		for start := s.start; !start.Add(c.slot).After(s.end); start = start.Add(c.slot) {
+			end := start.Add(c.slot)
+			if end.After(to) {
+				break
+			}
+			if !start.Before(from) {
+				slots = append(slots, Slot{Start: start, End: end})
+			}
+		}
+	}
+	return slots
+}
+
+// fits reports whether [start, end) lies within one open span. Without weekly windows the
+// physiotherapist has not published hours, then only their time off is enforced.
+func (c *calendar) fits(start, end time.Time) bool {
+	if len(c.windows) == 0 {
+		for _, e := range c.exceptions {
+			if !e.Available && e.StartsAt.Before(end) && e.EndsAt.After(start) {
+				return false
+			}
+		}
+		return true
+	}
+	for _, s := range c.open(start, end) {
+		if !s.start.After(start) && !s.end.Before(end) {
+			return true
+		}
+	}
+	return false
+}
+
+// weekdayOf returns the time.Weekday number of a weekday name, -1 if it is unknown
+func weekdayOf(name string) int {
+	for i, w := range weekdays {
+		if strings.EqualFold(name, w) {
+			return i
+		}
+	}
+	return -1
+}
+
+// parseClock reads an HH:MM time of day as minutes after midnight, 24:00 is the end of the day
+func parseClock(value string) (int, error) {
+	if value == "24:00" {
+		return 24 * 60, nil
+	}
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("must be HH:MM, got %q", value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func formatClock(minutes int) string {
+	return fmt.Sprintf("%02d:%02d", minutes/60, minutes%60)
+}
+
+func utcPtr(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	utc := t.UTC()
+	return &utc
+}
+
+func toAvailability(physioID uint32, rows []db.AvailabilityWindow) *Availability {
+	availability := &Availability{
+		PhysioID:    physioID,
+		TimeZone:    "UTC",
+		SlotMinutes: defaultCallMinutes,
+		Windows:     make([]AvailabilityWindow, 0, len(rows)),
+	}
+	if len(rows) > 0 {
+		availability.TimeZone, availability.SlotMinutes = rows[0].TimeZone, rows[0].SlotMinutes
+	}
+	for _, row := range rows {
+		availability.Windows = append(availability.Windows, AvailabilityWindow{
+			Weekday: weekdays[row.Weekday],
+			Start:   formatClock(row.StartMinute),
+			End:     formatClock(row.EndMinute),
+		})
+	}
+	return availability
+}
+
+func toAvailabilityException(row db.AvailabilityException) AvailabilityException {
+	return AvailabilityException{
+		ExceptionID: row.ExceptionID,
+		PhysioID:    row.PhysioID,
+		StartsAt:    row.StartsAt,
+		EndsAt:      row.EndsAt,
+		Available:   row.Available,
+		Reason:      row.Reason,
+		CreatedAt:   row.CreatedAt,
+	}
+}
@@ -0,0 +1,139 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"ai-bot-deecogs/internal/db"
+)
+
+// Berlin moves from CET (UTC+1) to CEST (UTC+2) at 02:00 on Sunday 31 March 2024
+var berlin = mustLoadLocation("Europe/Berlin")
+
+func mustLoadLocation(name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		panic(err)
+	}
+	return loc
+}
+
+// sundayWindow opens every Sunday from start to end, minutes after local midnight
+func sundayWindow(start, end int) db.AvailabilityWindow {
+	return db.AvailabilityWindow{Weekday: int(time.Sunday), StartMinute: start, EndMinute: end, TimeZone: "Europe/Berlin", SlotMinutes: 60}
+}
+
+func berlinTime(day, hour int) time.Time {
+	return time.Date(2024, time.March, day, hour, 0, 0, 0, berlin)
+}
+
+func utcTime(day, hour int) time.Time {
+	return time.Date(2024, time.March, day, hour, 0, 0, 0, time.UTC)
+}
+
+func slotStarts(slots []Slot) []time.Time {
+	starts := make([]time.Time, len(slots))
+	for i, s := range slots {
+		starts[i] = s.Start
+	}
+	return starts
+}
+
+func expectStarts(t *testing.T, got []Slot, want ...time.Time) {
+	t.Helper()
+	starts := slotStarts(got)
+	if len(starts) != len(want) {
+		t.Fatalf("slots start at %v, want %v", starts, want)
+	}
+	for i := range want {
+		if !starts[i].Equal(want[i]) {
+			t.Fatalf("slots start at %v, want %v", starts, want)
+		}
+	}
+}
+
+func TestCalendarSlotsFollowDST(t *testing.T) {
+	cal := newCalendar(db.Calendar{Windows: []db.AvailabilityWindow{sundayWindow(9*60, 11*60)}})
+
+	// 09:00 local is 08:00 UTC before the change and 07:00 UTC after it
+	expectStarts(t, cal.slots(utcTime(24, 0), utcTime(25, 0)), utcTime(24, 8), utcTime(24, 9))
+	expectStarts(t, cal.slots(utcTime(31, 0), utcTime(32, 0)), utcTime(31, 7), utcTime(31, 8))
+	for _, s := range cal.slots(utcTime(24, 0), utcTime(32, 0)) {
+		if local := s.Start.In(berlin); local.Hour() != 9 && local.Hour() != 10 {
+			t.Errorf("slot starts at %s local, want the window hours", local)
+		}
+	}
+}
+
+func TestCalendarWindowAcrossTheDSTGap(t *testing.T) {
+	// 01:00 to 04:00 local on the day of the change is two real hours, 02:00 to 03:00 never happens
+	cal := newCalendar(db.Calendar{Windows: []db.AvailabilityWindow{sundayWindow(60, 4*60)}})
+	expectStarts(t, cal.slots(utcTime(30, 0), utcTime(32, 0)), utcTime(31, 0), utcTime(31, 1))
+	if local := utcTime(31, 1).In(berlin); local.Hour() != 3 {
+		t.Fatalf("second slot starts at %s local, want 03:00", local)
+	}
+}
+
+func TestCalendarExceptions(t *testing.T) {
+	window := sundayWindow(9*60, 13*60)
+	timeOff := db.AvailabilityException{StartsAt: berlinTime(24, 10), EndsAt: berlinTime(24, 11)}
+	extra := db.AvailabilityException{StartsAt: berlinTime(24, 13), EndsAt: berlinTime(24, 15), Available: true}
+	saturday := db.AvailabilityException{StartsAt: berlinTime(23, 9), EndsAt: berlinTime(23, 10), Available: true}
+	cal := newCalendar(db.Calendar{Windows: []db.AvailabilityWindow{window}, Exceptions: []db.AvailabilityException{timeOff, extra, saturday}})
+
+	expectStarts(t, cal.slots(berlinTime(23, 0), berlinTime(25, 0)),
+		berlinTime(23, 9),
+		berlinTime(24, 9), berlinTime(24, 11), berlinTime(24, 12), berlinTime(24, 13), berlinTime(24, 14))
+
+	tests := []struct {
+		name       string
+		start, end time.Time
+		want       bool
+	}{
+		{"within the window", berlinTime(24, 9), berlinTime(24, 10), true},
+		{"into the time off", berlinTime(24, 9), berlinTime(24, 11), false},
+		{"across the window and the extra hours", berlinTime(24, 12), berlinTime(24, 14), true},
+		{"past the extra hours", berlinTime(24, 14), berlinTime(24, 16), false},
+		{"extra hours on a day without a window", berlinTime(23, 9), berlinTime(23, 10), true},
+		{"a day without hours", berlinTime(22, 9), berlinTime(22, 10), false},
+	}
+	for _, tt := range tests {
+		if got := cal.fits(tt.start, tt.end); got != tt.want {
+			t.Errorf("%s: fits = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCalendarWithoutWindowsOnlyEnforcesTimeOff(t *testing.T) {
+	timeOff := db.AvailabilityException{StartsAt: berlinTime(24, 10), EndsAt: berlinTime(24, 11)}
+	cal := newCalendar(db.Calendar{Exceptions: []db.AvailabilityException{timeOff}})
+	if !cal.fits(berlinTime(24, 3), berlinTime(24, 4)) {
+		t.Error("call at night refused, without published hours any time outside time off fits")
+	}
+	if cal.fits(berlinTime(24, 10), berlinTime(24, 11)) {
+		t.Error("call during the time off accepted")
+	}
+	if slots := cal.slots(berlinTime(24, 0), berlinTime(25, 0)); len(slots) != 0 {
+		t.Errorf("slots = %v, want none without published hours", slotStarts(slots))
+	}
+}
+
+func TestIsBookableSlot(t *testing.T) {
+	c := db.Calendar{Windows: []db.AvailabilityWindow{sundayWindow(9*60, 11*60)}}
+	call := func(start time.Time, minutes int) *db.PhysioCall {
+		return &db.PhysioCall{ScheduledTime: &start, DurationMinutes: minutes}
+	}
+	if err := isBookableSlot(call(berlinTime(31, 10), 60), c); err != nil {
+		t.Errorf("booking a whole slot: %v", err)
+	}
+	for _, bad := range []*db.PhysioCall{
+		call(berlinTime(31, 10), 30),
+		call(berlinTime(31, 9).Add(30*time.Minute), 60),
+		call(berlinTime(31, 11), 60),
+	} {
+		if err := isBookableSlot(bad, c); !errors.Is(err, ErrSlotUnavailable) {
+			t.Errorf("booking %s for %d minutes = %v, want ErrSlotUnavailable", bad.ScheduledTime, bad.DurationMinutes, err)
+		}
+	}
+}
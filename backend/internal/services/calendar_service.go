@@ -0,0 +1,275 @@
+package services
+
+import (
+	"ai-bot-deecogs/internal/db"
+	"ai-bot-deecogs/internal/models"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrCalendarTokenNotFound = errors.New("calendar token not found")
+	ErrNoCallSlot            = errors.New("the call has no scheduled time")
+)
+
+const (
+	// icsProductID identifies this service in PRODID
+	icsProductID = "-//DeeCogs//Physio Calls//EN"
+	// icsUIDDomain makes event UIDs globally unique
+	icsUIDDomain = "deecogs"
+	// feedHistory is how far back a feed lists calls
+	feedHistory = 90 * 24 * time.Hour
+	// feedRefresh is how often feed subscribers are asked to poll
+	feedRefresh = "PT1H"
+)
+
+// callReminders are the VALARM offsets before the start of a call
+var callReminders = []time.Duration{24 * time.Hour, 15 * time.Minute}
+
+// CalendarToken is a feed token without its secret
+type CalendarToken struct {
+	TokenID    uint32     `json:"token_id"`
+	Label      *string    `json:"label,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// NewCalendarToken is a freshly created feed token, Token is never shown again
+type NewCalendarToken struct {
+	CalendarToken
+	Token    string `json:"token"`
+	FeedPath string `json:"feed_path"` // path of the feed, relative to the API
+}
+
+// CalendarFeedPath is the feed path of a token
+func CalendarFeedPath(token string) string {
+	return "/calendar-feeds/" + token + ".ics"
+}
+
+// CreateCalendarToken creates a feed token of a user. The feed lists the calls of the user
+// as patient and as physiotherapist.
+func CreateCalendarToken(userID uint32, label *string) (*NewCalendarToken, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("failed to generate calendar token: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(secret)
+
+	row := db.CalendarToken{UserID: userID, TokenHash: hashCalendarToken(token), Label: label}
+	if err := store.CalendarTokens.Create(context.Background(), &row); err != nil {
+		if errors.Is(err, db.ErrForeignKeyViolation) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	return &NewCalendarToken{CalendarToken: toCalendarToken(row), Token: token, FeedPath: CalendarFeedPath(token)}, nil
+}
+
+// ListCalendarTokens returns the feed tokens of a user, newest first
+func ListCalendarTokens(userID uint32) ([]CalendarToken, error) {
+	rows, err := store.CalendarTokens.ListByUser(context.Background(), userID)
+	if err != nil {
+		return nil, err
+	}
+	tokens := make([]CalendarToken, 0, len(rows))
+	for _, row := range rows {
+		tokens = append(tokens, toCalendarToken(row))
+	}
+	return tokens, nil
+}
+
+// RevokeCalendarToken stops a feed token of a user
+func RevokeCalendarToken(userID, tokenID uint32) error {
+	err := store.CalendarTokens.Revoke(context.Background(), userID, tokenID)
+	if errors.Is(err, db.ErrNotFound) {
+		return ErrCalendarTokenNotFound
+	}
+	return err
+}
+
+func hashCalendarToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CalendarFeed renders the iCalendar feed of a token: the scheduled calls of its user as
+// patient and as physiotherapist from feedHistory ago on. Unknown, revoked and orphaned
+// tokens all return ErrCalendarTokenNotFound.
+func CalendarFeed(token string) ([]byte, error) {
+	ctx := context.Background()
+	row, err := store.CalendarTokens.Use(ctx, hashCalendarToken(token))
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, ErrCalendarTokenNotFound
+		}
+		return nil, err
+	}
+	user, err := store.Users.Get(ctx, row.UserID)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, ErrCalendarTokenNotFound
+		}
+		return nil, err
+	}
+
+	since := time.Now().Add(-feedHistory).UTC()
+	asPatient, err := store.PhysioCalls.List(ctx, db.PhysioCallFilter{UserID: &user.UserID, From: &since})
+	if err != nil {
+		return nil, err
+	}
+	asPhysio, err := store.PhysioCalls.List(ctx, db.PhysioCallFilter{PhysioID: &user.UserID, From: &since})
+	if err != nil {
+		return nil, err
+	}
+
+	w := &icsWriter{}
+	writeCalendarStart(w)
+	w.text("NAME", "Physio calls")
+	w.text("X-WR-CALNAME", "Physio calls")
+	w.line("REFRESH-INTERVAL;VALUE=DURATION", feedRefresh)
+	w.line("X-PUBLISHED-TTL", feedRefresh)
+	names := map[uint32]string{}
+	seen := map[uint32]bool{}
+	now := time.Now()
+	for _, call := range append(asPatient, asPhysio...) {
+		if seen[call.CallID] || call.ScheduledTime == nil {
+			continue
+		}
+		seen[call.CallID] = true
+		if err := writeCallEvent(ctx, w, call, names, now); err != nil {
+			return nil, err
+		}
+	}
+	w.line("END", "VCALENDAR")
+	return w.bytes(), nil
+}
+
+// PhysioCallICS renders a single scheduled call as an iCalendar file
+func PhysioCallICS(callID uint32) ([]byte, error) {
+	ctx := context.Background()
+	call, err := getCall(callID)
+	if err != nil {
+		return nil, err
+	}
+	if call.ScheduledTime == nil {
+		return nil, ErrNoCallSlot
+	}
+
+	w := &icsWriter{}
+	writeCalendarStart(w)
+	if err := writeCallEvent(ctx, w, *call, map[uint32]string{}, time.Now()); err != nil {
+		return nil, err
+	}
+	w.line("END", "VCALENDAR")
+	return w.bytes(), nil
+}
+
+func writeCalendarStart(w *icsWriter) {
+	w.line("BEGIN", "VCALENDAR")
+	w.line("VERSION", "2.0")
+	w.line("PRODID", icsProductID)
+	w.line("CALSCALE", "GREGORIAN")
+	w.line("METHOD", "PUBLISH")
+}
+
+// writeCallEvent writes the VEVENT of a scheduled call. The UID stays the same for the life
+// of the call and SEQUENCE grows with every change, so clients replace their copy when a
+// call is moved or cancelled. names caches user names across the events of a feed.
+func writeCallEvent(ctx context.Context, w *icsWriter, call db.PhysioCall, names map[uint32]string, now time.Time) error {
+	assessment, err := store.Assessments.Get(ctx, call.AssessmentID)
+	if err != nil {
+		return err
+	}
+	anatomy := "Assessment"
+	if a, err := store.Anatomy.Get(ctx, assessment.AnatomyID); err == nil {
+		anatomy = a.Name
+	} else if !errors.Is(err, db.ErrNotFound) {
+		return err
+	}
+
+	description := []string{
+		fmt.Sprintf("Assessment %d: %s, %s (%s)", assessment.AssessmentID, anatomy, assessment.AssessmentType, assessment.Status),
+	}
+	if name, err := userName(ctx, call.UserID, names); err != nil {
+		return err
+	} else if name != "" {
+		description = append(description, "Patient: "+name)
+	}
+	if call.PhysioID != nil {
+		if name, err := userName(ctx, *call.PhysioID, names); err != nil {
+			return err
+		} else if name != "" {
+			description = append(description, "Physiotherapist: "+name)
+		}
+	}
+	description = append(description, "Call status: "+call.CallStatus)
+	if call.StatusReason != nil {
+		description = append(description, "Reason: "+*call.StatusReason)
+	}
+
+	status := models.CallStatus(call.CallStatus)
+	cancelled := status == models.CallCancelled || status == models.CallNoShow
+
+	w.line("BEGIN", "VEVENT")
+	w.line("UID", "physio-call-"+strconv.FormatUint(uint64(call.CallID), 10)+"@"+icsUIDDomain)
+	w.dateTime("DTSTAMP", now)
+	w.dateTime("DTSTART", *call.ScheduledTime)
+	w.dateTime("DTEND", call.End())
+	w.line("SEQUENCE", strconv.Itoa(call.Sequence))
+	if cancelled {
+		w.line("STATUS", "CANCELLED")
+	} else {
+		w.line("STATUS", "CONFIRMED")
+	}
+	w.text("SUMMARY", "Physio call: "+anatomy+" assessment")
+	w.text("DESCRIPTION", strings.Join(description, "\n"))
+	w.dateTime("CREATED", call.CreatedAt)
+	w.dateTime("LAST-MODIFIED", call.UpdatedAt)
+	if !status.IsTerminal() {
+		for _, before := range callReminders {
+			w.line("BEGIN", "VALARM")
+			w.line("ACTION", "DISPLAY")
+			w.text("DESCRIPTION", "Physio call: "+anatomy+" assessment")
+			w.line("TRIGGER", icsDuration(before))
+			w.line("END", "VALARM")
+		}
+	}
+	w.line("END", "VEVENT")
+	return nil
+}
+
+// userName returns the name of a user, empty once the user is deleted
+func userName(ctx context.Context, userID uint32, names map[uint32]string) (string, error) {
+	if name, ok := names[userID]; ok {
+		return name, nil
+	}
+	user, err := store.Users.Get(ctx, userID)
+	if err != nil && !errors.Is(err, db.ErrNotFound) {
+		return "", err
+	}
+	if user != nil {
+		names[userID] = user.Name
+	} else {
+		names[userID] = ""
+	}
+	return names[userID], nil
+}
+
+func toCalendarToken(row db.CalendarToken) CalendarToken {
+	return CalendarToken{
+		TokenID:    row.TokenID,
+		Label:      row.Label,
+		CreatedAt:  row.CreatedAt,
+		LastUsedAt: row.LastUsedAt,
+		RevokedAt:  row.RevokedAt,
+	}
+}
@@ -0,0 +1,92 @@
+package services
+
+import (
+	"ai-bot-deecogs/internal/db"
+	"context"
+	"time"
+)
+
+// StoredChatMessage is one persisted turn of the BPI chat
+type StoredChatMessage struct {
+	MessageID uint32    `json:"messageId"`
+	Role      string    `json:"role"`
+	Text      string    `json:"text"`
+	Action    *string   `json:"action,omitempty"`
+	LatencyMs *int32    `json:"latencyMs,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// ChatTranscript is the stored chat of an assessment, used to resume it
+type ChatTranscript struct {
+	AssessmentID uint32              `json:"assessmentId"`
+	Messages     []StoredChatMessage `json:"messages"`
+	ChatHistory  []ChatMessage       `json:"chat_history"` // the same chat as user/response pairs
+	LastAction   string              `json:"lastAction,omitempty"`
+}
+
+// GetChatTranscript returns the stored chat of an assessment
+func GetChatTranscript(assessmentID uint32) (*ChatTranscript, error) {
+	rows, err := store.ChatMessages.ListByAssessment(context.Background(), assessmentID)
+	if err != nil {
+		return nil, err
+	}
+
+	transcript := &ChatTranscript{
+		AssessmentID: assessmentID,
+		Messages:     make([]StoredChatMessage, 0, len(rows)),
+		ChatHistory:  toChatHistory(rows),
+	}
+	for _, row := range rows {
+		transcript.Messages = append(transcript.Messages, StoredChatMessage{
+			MessageID: row.MessageID,
+			Role:      row.Role,
+			Text:      row.Content,
+			Action:    row.Action,
+			LatencyMs: row.LatencyMs,
+			CreatedAt: row.CreatedAt,
+		})
+		if row.Role == db.ChatRoleAssistant && row.Action != nil {
+			transcript.LastAction = *row.Action
+		}
+	}
+	return transcript, nil
+}
+
+// loadChatHistory rebuilds the history sent to the BPI bot from the stored messages
+func loadChatHistory(assessmentID uint32) ([]ChatMessage, error) {
+	rows, err := store.ChatMessages.ListByAssessment(context.Background(), assessmentID)
+	if err != nil {
+		return nil, err
+	}
+	return toChatHistory(rows), nil
+}
+
+// toChatHistory pairs every user message with the assistant reply that follows it
+func toChatHistory(rows []db.ChatMessage) []ChatMessage {
+	history := make([]ChatMessage, 0, len(rows)/2+1)
+	for _, row := range rows {
+		switch {
+		case row.Role == db.ChatRoleUser:
+			history = append(history, ChatMessage{User: row.Content})
+		case len(history) > 0 && history[len(history)-1].Response == "":
+			history[len(history)-1].Response = row.Content
+		default:
+			history = append(history, ChatMessage{Response: row.Content})
+		}
+	}
+	return history
+}
+
+// appendChatTurn stores a user message and the AI reply to it
+func appendChatTurn(assessmentID uint32, message, reply, action string, latency time.Duration) error {
+	ms := int32(latency.Milliseconds())
+	assistant := db.ChatMessage{Role: db.ChatRoleAssistant, Content: reply, LatencyMs: &ms}
+	if action != "" {
+		assistant.Action = &action
+	}
+	_, err := store.ChatMessages.Append(context.Background(), assessmentID, []db.ChatMessage{
+		{Role: db.ChatRoleUser, Content: message},
+		assistant,
+	})
+	return err
+}
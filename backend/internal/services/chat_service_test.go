@@ -0,0 +1,102 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"ai-bot-deecogs/internal/db"
+	"ai-bot-deecogs/internal/models"
+)
+
+func TestChatIsStoredServerSide(t *testing.T) {
+	b := useMemoryStore(t)
+	id := newTestAssessment(t, b)
+
+	// Each request carries only the new message, the reply follows the stored turns
+	for _, message := range []string{"Hi", "My knee hurts"} {
+		if _, err := SendChatToAI(context.Background(), id, message); err != nil {
+			t.Fatal(err)
+		}
+	}
+	transcript, err := GetChatTranscript(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roles := make([]string, len(transcript.Messages))
+	for i, m := range transcript.Messages {
+		roles[i] = m.Role
+	}
+	if want := []string{db.ChatRoleUser, db.ChatRoleAssistant, db.ChatRoleUser, db.ChatRoleAssistant}; !reflect.DeepEqual(roles, want) {
+		t.Fatalf("roles = %v, want %v", roles, want)
+	}
+	if transcript.Messages[1].LatencyMs == nil || transcript.Messages[0].Action != nil {
+		t.Errorf("messages = %+v, want the latency and action on the replies only", transcript.Messages)
+	}
+	// The second reply of the script asks for the camera
+	if transcript.LastAction != string(models.ActionCameraOn) || len(transcript.ChatHistory) != 2 || transcript.ChatHistory[1].User != "My knee hurts" {
+		t.Fatalf("transcript = %+v, want two turns ending with camera_on", transcript)
+	}
+
+	// A reloaded client resumes where the chat stopped
+	for _, message := range []string{"Here", "Two weeks"} {
+		if _, err := SendChatToAI(context.Background(), id, message); err != nil {
+			t.Fatal(err)
+		}
+	}
+	row, err := store.Assessments.Get(context.Background(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var finished ChatRequest
+	if err := json.Unmarshal(row.ChatHistory, &finished); err != nil || len(finished.ChatHistory) != 4 || finished.ChatHistory[3].Response == "" {
+		t.Fatalf("finished chat = %s, %v, want all four turns once the bot moves on", row.ChatHistory, err)
+	}
+}
+
+func TestChatRejectsFinishedAssessment(t *testing.T) {
+	b := useMemoryStore(t)
+	id := newTestAssessment(t, b)
+	if err := MarkAssessmentComplete(id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SendChatToAI(context.Background(), id, "Hi"); !errors.Is(err, ErrInvalidTransition) {
+		t.Fatalf("SendChatToAI = %v, want ErrInvalidTransition", err)
+	}
+	if transcript, err := GetChatTranscript(id); err != nil || len(transcript.Messages) != 0 {
+		t.Fatalf("transcript = %+v, %v, want nothing stored", transcript, err)
+	}
+}
+
+func TestToChatHistory(t *testing.T) {
+	row := func(role, content string) db.ChatMessage { return db.ChatMessage{Role: role, Content: content} }
+	tests := []struct {
+		name string
+		rows []db.ChatMessage
+		want []ChatMessage
+	}{
+		{"empty", nil, []ChatMessage{}},
+		{
+			"turns",
+			[]db.ChatMessage{row(db.ChatRoleUser, "Hi"), row(db.ChatRoleAssistant, "Hello"), row(db.ChatRoleUser, "Knee"), row(db.ChatRoleAssistant, "Show me")},
+			[]ChatMessage{{User: "Hi", Response: "Hello"}, {User: "Knee", Response: "Show me"}},
+		},
+		{
+			"unanswered message",
+			[]db.ChatMessage{row(db.ChatRoleUser, "Hi"), row(db.ChatRoleAssistant, "Hello"), row(db.ChatRoleUser, "Knee")},
+			[]ChatMessage{{User: "Hi", Response: "Hello"}, {User: "Knee"}},
+		},
+		{
+			"greeting before any message",
+			[]db.ChatMessage{row(db.ChatRoleAssistant, "Welcome"), row(db.ChatRoleUser, "Hi"), row(db.ChatRoleAssistant, "Hello")},
+			[]ChatMessage{{Response: "Welcome"}, {User: "Hi", Response: "Hello"}},
+		},
+	}
+	for _, tt := range tests {
+		if got := toChatHistory(tt.rows); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: toChatHistory = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
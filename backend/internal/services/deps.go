@@ -0,0 +1,41 @@
+package services
+
+import (
+	"ai-bot-deecogs/internal/clients"
+	"ai-bot-deecogs/internal/db"
+	"errors"
+)
+
+// Deps are the backends the services run on, built by main from the configuration
+type Deps struct {
+	Store     *db.Store
+	AI        clients.AIClient           // the hosted services or the offline fake
+	Speech    *clients.GoogleSpeechClient // nil fails every speech call with the missing API key error
+	Auth      AuthConfig
+	Notifiers map[string]clients.Notifier // keyed by channel name, none disables notifications
+}
+
+// store holds the repositories used by the services
+var store *db.Store
+
+// Init hands the services their dependencies. The services are package functions
+// sharing them, so it is called once at startup before any request is served.
+func Init(deps Deps) error {
+	if deps.Store == nil {
+		return errors.New("services: a store is required")
+	}
+	if deps.AI == nil {
+		return errors.New("services: an AI client is required")
+	}
+	if err := configureAuth(deps.Auth); err != nil {
+		return err
+	}
+	store = deps.Store
+	aiClient = deps.AI
+	speechClient = deps.Speech
+	if speechClient == nil {
+		speechClient = clients.NewGoogleSpeechClient(clients.GoogleSpeechConfig{})
+	}
+	useNotifiers(deps.Notifiers)
+	return nil
+}
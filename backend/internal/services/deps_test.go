@@ -0,0 +1,29 @@
+package services
+
+import (
+	"testing"
+
+	"ai-bot-deecogs/internal/clients"
+	"ai-bot-deecogs/internal/db/memory"
+)
+
+func TestInitRequiresDeps(t *testing.T) {
+	fake, err := clients.NewFakeAIClient("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := AuthConfig{Secret: []byte("test-secret")}
+	tests := []struct {
+		name string
+		deps Deps
+	}{
+		{"no store", Deps{AI: fake, Auth: auth}},
+		{"no AI client", Deps{Store: memory.NewStore(), Auth: auth}},
+		{"no JWT secret", Deps{Store: memory.NewStore(), AI: fake}},
+	}
+	for _, tt := range tests {
+		if err := Init(tt.deps); err == nil {
+			t.Errorf("%s: Init succeeded, want an error", tt.name)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package services
+
+import (
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// icsWriter builds an RFC 5545 stream: content lines end in CRLF and are folded so no
+// line is longer than 75 octets, without splitting a UTF-8 sequence
+type icsWriter struct {
+	b strings.Builder
+}
+
+// maxICSLine is the longest content line RFC 5545 allows, in octets without the CRLF
+const maxICSLine = 75
+
+func (w *icsWriter) line(name, value string) {
+	content := name + ":" + value
+	width := 0
+	for len(content) > 0 {
+		r, size := utf8.DecodeRuneInString(content)
+		if r == utf8.RuneError && size <= 1 {
+			// drop invalid bytes rather than emit a stream clients reject
+			content = content[1:]
+			continue
+		}
+		if width+size > maxICSLine {
+			w.b.WriteString("\r\n ")
+			width = 1
+		}
+		w.b.WriteString(content[:size])
+		width += size
+		content = content[size:]
+	}
+	w.b.WriteString("\r\n")
+}
+
+// text writes a TEXT property, escaped
+func (w *icsWriter) text(name, value string) {
+	w.line(name, icsText(value))
+}
+
+// dateTime writes a DATE-TIME property in UTC
+func (w *icsWriter) dateTime(name string, t time.Time) {
+	w.line(name, t.UTC().Format("20060102T150405Z"))
+}
+
+func (w *icsWriter) bytes() []byte {
+	return []byte(w.b.String())
+}
+
+var icsEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`)
+
+// icsText escapes a TEXT value
+func icsText(value string) string {
+	return icsEscaper.Replace(value)
+}
+
+// icsDuration formats a negative alarm offset as a DURATION, e.g. -PT15M or -P1D
+func icsDuration(before time.Duration) string {
+	minutes := int(before / time.Minute)
+	switch {
+	case minutes%(24*60) == 0:
+		return "-P" + strconv.Itoa(minutes/(24*60)) + "D"
+	case minutes%60 == 0:
+		return "-PT" + strconv.Itoa(minutes/60) + "H"
+	}
+	return "-PT" + strconv.Itoa(minutes) + "M"
+}
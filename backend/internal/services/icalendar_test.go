@@ -0,0 +1,235 @@
+package services
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"ai-bot-deecogs/internal/models"
+)
+
+// icsLines checks the physical lines of a stream and returns its unfolded content lines
+func icsLines(t *testing.T, ics []byte) []string {
+	t.Helper()
+	if !bytes.HasSuffix(ics, []byte("\r\n")) {
+		t.Fatalf("stream does not end in CRLF: %q", ics)
+	}
+	physical := strings.Split(strings.TrimSuffix(string(ics), "\r\n"), "\r\n")
+	var lines []string
+	for i, line := range physical {
+		if strings.ContainsAny(line, "\r\n") {
+			t.Fatalf("line %d has a bare CR or LF: %q", i, line)
+		}
+		if len(line) > maxICSLine {
+			t.Fatalf("line %d is %d octets long: %q", i, len(line), line)
+		}
+		if !utf8.ValidString(line) {
+			t.Fatalf("line %d splits a UTF-8 sequence: %q", i, line)
+		}
+		if strings.HasPrefix(line, " ") {
+			if len(lines) == 0 {
+				t.Fatal("stream starts with a continuation line")
+			}
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// icsProp returns the value of the first name property of lines, ok is false without one
+func icsProp(lines []string, name string) (string, bool) {
+	for _, line := range lines {
+		if value, ok := strings.CutPrefix(line, name+":"); ok {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+func TestICSWriterFolding(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{"short", "Physio call"},
+		{"exactly one line", strings.Repeat("a", maxICSLine-len("SUMMARY:"))},
+		{"one octet over", strings.Repeat("a", maxICSLine-len("SUMMARY:")+1)},
+		{"long ascii", strings.Repeat("0123456789", 30)},
+		{"multibyte", strings.Repeat("Genou é ü 膝 🦵 ", 20)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &icsWriter{}
+			w.line("SUMMARY", tt.value)
+			lines := icsLines(t, w.bytes())
+			if len(lines) != 1 || lines[0] != "SUMMARY:"+tt.value {
+				t.Fatalf("unfolded to %q, want the original line back", lines)
+			}
+			folds := strings.Count(string(w.bytes()), "\r\n ")
+			if len("SUMMARY:"+tt.value) <= maxICSLine && folds != 0 {
+				t.Errorf("a %d octet line was folded", len("SUMMARY:"+tt.value))
+			}
+			if len("SUMMARY:"+tt.value) > maxICSLine && folds == 0 {
+				t.Errorf("a %d octet line was not folded", len("SUMMARY:"+tt.value))
+			}
+		})
+	}
+}
+
+func TestICSWriterDropsInvalidUTF8(t *testing.T) {
+	w := &icsWriter{}
+	w.line("SUMMARY", "Knee\xff call")
+	if got := string(w.bytes()); got != "SUMMARY:Knee call\r\n" {
+		t.Fatalf("line = %q, want the invalid byte dropped", got)
+	}
+}
+
+func TestICSTextEscaping(t *testing.T) {
+	tests := []struct {
+		value, want string
+	}{
+		{"Knee", "Knee"},
+		{"Knee, left; acute", `Knee\, left\; acute`},
+		{`C:\notes`, `C:\\notes`},
+		{"one\ntwo\r\nthree\rfour", `one\ntwo\nthree\nfour`},
+		{`already \n escaped`, `already \\n escaped`},
+	}
+	for _, tt := range tests {
+		if got := icsText(tt.value); got != tt.want {
+			t.Errorf("icsText(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestICSDuration(t *testing.T) {
+	tests := []struct {
+		before time.Duration
+		want   string
+	}{
+		{15 * time.Minute, "-PT15M"},
+		{90 * time.Minute, "-PT90M"},
+		{time.Hour, "-PT1H"},
+		{24 * time.Hour, "-P1D"},
+		{48 * time.Hour, "-P2D"},
+	}
+	for _, tt := range tests {
+		if got := icsDuration(tt.before); got != tt.want {
+			t.Errorf("icsDuration(%s) = %q, want %q", tt.before, got, tt.want)
+		}
+	}
+}
+
+func TestPhysioCallICSLifecycle(t *testing.T) {
+	b := useMemoryStore(t)
+	id := newTestAssessment(t, b)
+	start := time.Now().Add(48 * time.Hour).Truncate(time.Minute)
+	call, err := SchedulePhysioCall(strconv.FormatUint(uint64(id), 10), "scheduled", &start, 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	callID64, err := strconv.ParseUint(call.CallID, 10, 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	callID := uint32(callID64)
+
+	render := func(step string) []string {
+		t.Helper()
+		ics, err := PhysioCallICS(callID)
+		if err != nil {
+			t.Fatalf("%s: %v", step, err)
+		}
+		lines := icsLines(t, ics)
+		if lines[0] != "BEGIN:VCALENDAR" || lines[len(lines)-1] != "END:VCALENDAR" {
+			t.Fatalf("%s: not a VCALENDAR: %q", step, lines)
+		}
+		stamp, ok := icsProp(lines, "DTSTAMP")
+		if _, err := time.Parse("20060102T150405Z", stamp); !ok || err != nil {
+			t.Fatalf("%s: DTSTAMP %q is not a UTC date-time", step, stamp)
+		}
+		return lines
+	}
+	expect := func(step string, lines []string, props map[string]string) {
+		t.Helper()
+		for name, want := range props {
+			if got, _ := icsProp(lines, name); got != want {
+				t.Errorf("%s: %s = %q, want %q", step, name, got, want)
+			}
+		}
+	}
+
+	wantUID := "physio-call-" + call.CallID + "@" + icsUIDDomain
+	scheduled := render("scheduled")
+	expect("scheduled", scheduled, map[string]string{
+		"UID":      wantUID,
+		"SEQUENCE": "0",
+		"STATUS":   "CONFIRMED",
+		"DTSTART":  start.UTC().Format("20060102T150405Z"),
+		"DTEND":    start.Add(30 * time.Minute).UTC().Format("20060102T150405Z"),
+	})
+
+	// Every reminder is a VALARM nested in the VEVENT
+	var alarms [][]string
+	inEvent := false
+	for i, line := range scheduled {
+		switch line {
+		case "BEGIN:VEVENT":
+			inEvent = true
+		case "END:VEVENT":
+			inEvent = false
+		case "BEGIN:VALARM":
+			if !inEvent {
+				t.Fatal("VALARM outside the VEVENT")
+			}
+			end := i
+			for end < len(scheduled) && scheduled[end] != "END:VALARM" {
+				end++
+			}
+			alarms = append(alarms, scheduled[i+1:end])
+		}
+	}
+	if len(alarms) != len(callReminders) {
+		t.Fatalf("%d alarms, want one per reminder (%d)", len(alarms), len(callReminders))
+	}
+	for i, alarm := range alarms {
+		expect("alarm", alarm, map[string]string{
+			"ACTION":  "DISPLAY",
+			"TRIGGER": icsDuration(callReminders[i]),
+		})
+		if _, ok := icsProp(alarm, "DESCRIPTION"); !ok {
+			t.Errorf("alarm %d has no DESCRIPTION, DISPLAY alarms require one", i)
+		}
+	}
+
+	moved := start.Add(24 * time.Hour)
+	if _, err := ReschedulePhysioCall(callID, moved, 0); err != nil {
+		t.Fatal(err)
+	}
+	expect("rescheduled", render("rescheduled"), map[string]string{
+		"UID":      wantUID,
+		"SEQUENCE": "1",
+		"STATUS":   "CONFIRMED",
+		"DTSTART":  moved.UTC().Format("20060102T150405Z"),
+	})
+
+	patient := &Principal{UserID: call.UserID, Role: models.RolePatient}
+	if _, err := UpdatePhysioCallStatus(patient, callID, models.CallCancelled, nil); err != nil {
+		t.Fatal(err)
+	}
+	cancelled := render("cancelled")
+	expect("cancelled", cancelled, map[string]string{
+		"UID":      wantUID,
+		"SEQUENCE": "2",
+		"STATUS":   "CANCELLED",
+	})
+	for _, line := range cancelled {
+		if line == "BEGIN:VALARM" {
+			t.Error("a cancelled call still has reminders")
+		}
+	}
+}
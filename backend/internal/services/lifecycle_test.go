@@ -0,0 +1,248 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+
+	"ai-bot-deecogs/internal/clients"
+	"ai-bot-deecogs/internal/db"
+	"ai-bot-deecogs/internal/db/memory"
+	"ai-bot-deecogs/internal/models"
+)
+
+// useMemoryStore points the services at an empty in-memory backend and the scripted fake AI
+func useMemoryStore(t *testing.T) *memory.Backend {
+	t.Helper()
+	b := memory.New()
+	store = b.Store()
+	fake, err := clients.NewFakeAIClient("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	aiClient = fake
+	return b
+}
+
+// newTestAssessment signs up a patient and starts a knee assessment for them
+func newTestAssessment(t *testing.T, b *memory.Backend) uint32 {
+	t.Helper()
+	anatomyID, err := b.AddAnatomy(db.Anatomy{Name: "Knee"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, err := CreateUser("Jane", "jane@example.com", "password123")
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	assessment, err := CreateAssessment(user.UserID, anatomyID, "PAIN")
+	if err != nil {
+		t.Fatalf("CreateAssessment: %v", err)
+	}
+	return assessment.AssessmentID
+}
+
+// The fake AI ends the chat on the 4th message and the questionnaire on the 4th answer
+func chatUntilDone(id uint32) error {
+	for _, message := range []string{"Hi", "My knee hurts", "Here", "Two weeks"} {
+		if _, err := SendChatToAI(context.Background(), id, message); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func answerQuestionnaire(id uint32) error {
+	_, err := SendQuestionsToAI(context.Background(), id, QuestionRequest{QuestionHistory: []QuestionMessage{
+		{User: "Knee", Assistant: "On a scale of 0 to 10, how would you rate your pain right now?"},
+		{User: "4-6", Assistant: "Is the pain worse when you climb stairs?"},
+		{User: "Yes", Assistant: "Is it harder to walk than usual?"},
+		{User: "No"},
+	}})
+	return err
+}
+
+func submitROM(id uint32) error {
+	_, err := SubmitROMAnalysis(id, ROMRequest{RangeOfMotion: &RangeOfMotion{Minimum: "10", Maximum: "120"}})
+	return err
+}
+
+func TestAssessmentLifecycle(t *testing.T) {
+	type step struct {
+		name       string
+		run        func(id uint32) error
+		status     models.AssessmentStatus
+		completion float64
+	}
+	abandon := func(id uint32) error {
+		return UpdateAssessmentStatus(strconv.FormatUint(uint64(id), 10), models.StatusAbandoned, "patient left")
+	}
+
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "completed",
+			steps: []step{
+				{"chat", chatUntilDone, models.StatusInProgress, 25},
+				{"questionnaire", answerQuestionnaire, models.StatusInProgress, 50},
+				{"rom", submitROM, models.StatusInProgress, 75},
+				{"complete", MarkAssessmentComplete, models.StatusCompleted, 100},
+			},
+		},
+		{
+			name: "abandoned after the chat",
+			steps: []step{
+				{"chat", chatUntilDone, models.StatusInProgress, 25},
+				{"abandon", abandon, models.StatusAbandoned, 25},
+			},
+		},
+		{
+			name: "abandoned before any phase",
+			steps: []step{
+				{"abandon", abandon, models.StatusAbandoned, 0},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := useMemoryStore(t)
+			id := newTestAssessment(t, b)
+
+			for _, s := range tt.steps {
+				if err := s.run(id); err != nil {
+					t.Fatalf("%s: %v", s.name, err)
+				}
+				got, err := GetAssessment(id)
+				if err != nil {
+					t.Fatalf("%s: GetAssessment: %v", s.name, err)
+				}
+				if got.Status != s.status.String() || got.CompletionPercentage != s.completion {
+					t.Fatalf("after %s: status %s at %.0f%%, want %s at %.0f%%",
+						s.name, got.Status, got.CompletionPercentage, s.status, s.completion)
+				}
+				if terminal := s.status.IsTerminal(); terminal != (got.EndTime != nil) {
+					t.Fatalf("after %s: end time %v, want it set only once the assessment is final", s.name, got.EndTime)
+				}
+			}
+
+			timeline, err := GetAssessmentTimeline(id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(timeline) == 0 || timeline[0].Type != db.EventCreated {
+				t.Fatalf("timeline must start with the creation event, got %+v", timeline)
+			}
+		})
+	}
+}
+
+func TestAssessmentLifecycleStoresEachPhase(t *testing.T) {
+	b := useMemoryStore(t)
+	id := newTestAssessment(t, b)
+	for _, run := range []func(uint32) error{chatUntilDone, answerQuestionnaire, submitROM} {
+		if err := run(id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	transcript, err := GetChatTranscript(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(transcript.Messages) != 8 {
+		t.Errorf("chat has %d messages, want 4 turns of 2", len(transcript.Messages))
+	}
+	data, err := FetchAssessmentData(id)
+	if err != nil {
+		t.Fatalf("FetchAssessmentData: %v", err)
+	}
+	if len(data.ChatHistory) != 4 || data.RangeOfMotion.Maximum != "120" {
+		t.Errorf("dashboard input = %+v, want the 4 answers and the submitted ROM", data)
+	}
+}
+
+func TestCreateUserRejectsTakenEmail(t *testing.T) {
+	b := useMemoryStore(t)
+	newTestAssessment(t, b)
+	if _, err := CreateUser("Jane again", "jane@example.com", "password123"); !errors.Is(err, ErrEmailTaken) {
+		t.Fatalf("CreateUser with a taken email = %v, want ErrEmailTaken", err)
+	}
+}
+
+func TestFinishedAssessmentRejectsWrites(t *testing.T) {
+	writes := []struct {
+		name string
+		run  func(id uint32) error
+	}{
+		{"chat", func(id uint32) error {
+			_, err := SendChatToAI(context.Background(), id, "Hi again")
+			return err
+		}},
+		{"questionnaire", answerQuestionnaire},
+		{"rom", submitROM},
+		{"phase", func(id uint32) error { return CompletePhase(id, models.PhaseROM) }},
+	}
+	finishes := map[models.AssessmentStatus]func(id uint32) error{
+		models.StatusCompleted: MarkAssessmentComplete,
+		models.StatusAbandoned: func(id uint32) error {
+			return UpdateAssessmentStatus(strconv.FormatUint(uint64(id), 10), models.StatusAbandoned, "")
+		},
+	}
+
+	for status, finish := range finishes {
+		for _, w := range writes {
+			t.Run(status.String()+"/"+w.name, func(t *testing.T) {
+				b := useMemoryStore(t)
+				id := newTestAssessment(t, b)
+				if err := chatUntilDone(id); err != nil {
+					t.Fatal(err)
+				}
+				if err := finish(id); err != nil {
+					t.Fatal(err)
+				}
+				before, err := GetAssessment(id)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if err := w.run(id); !errors.Is(err, ErrInvalidTransition) {
+					t.Fatalf("%s on a %s assessment = %v, want ErrInvalidTransition", w.name, status, err)
+				}
+				after, err := GetAssessment(id)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if after.Status != before.Status || after.CompletionPercentage != before.CompletionPercentage {
+					t.Fatalf("%s changed the assessment from %s at %.0f%% to %s at %.0f%%", w.name,
+						before.Status, before.CompletionPercentage, after.Status, after.CompletionPercentage)
+				}
+			})
+		}
+	}
+}
+
+func TestMarkAssessmentComplete(t *testing.T) {
+	b := useMemoryStore(t)
+	completed := newTestAssessment(t, b)
+	if err := MarkAssessmentComplete(completed); err != nil {
+		t.Fatal(err)
+	}
+	if err := MarkAssessmentComplete(completed); err != nil {
+		t.Errorf("completing twice = %v, want a no-op", err)
+	}
+
+	abandoned, err := CreateAssessment(1, 1, "PAIN")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := UpdateAssessmentStatus(strconv.FormatUint(uint64(abandoned.AssessmentID), 10), models.StatusAbandoned, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := MarkAssessmentComplete(abandoned.AssessmentID); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("completing an abandoned assessment = %v, want ErrInvalidTransition", err)
+	}
+}
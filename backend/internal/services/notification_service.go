@@ -0,0 +1,654 @@
+package services
+
+import (
+	"ai-bot-deecogs/internal/clients"
+	"ai-bot-deecogs/internal/db"
+	"ai-bot-deecogs/internal/models"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+var (
+	ErrNotificationNotFound  = errors.New("notification not found")
+	ErrNotificationNotFailed = errors.New("only a failed notification can be retried")
+)
+
+// Notification kinds, each rendered with its own template
+const (
+	NotificationCallReminder = "call_reminder"
+	NotificationCriticalFlag = "critical_flag"
+	NotificationAbandoned    = "assessment_abandoned"
+)
+
+// notifyTimeLayout formats times in messages, recipients have no time zone on file
+const notifyTimeLayout = "Mon 2 Jan 2006 15:04 MST"
+
+// NotificationConfig controls the notification dispatcher
+type NotificationConfig struct {
+	PollInterval  time.Duration   // time between two reminder scans and looks at the outbox
+	MaxAttempts   int             // delivery attempts of a message before it fails
+	RetryBackoff  time.Duration   // delay before the first retry, doubled for each one after
+	LeaseTimeout  time.Duration   // a message sending for longer lost its dispatcher and is claimed again
+	ReminderLeads []time.Duration // reminders go out this long before a scheduled call
+}
+
+// Notification is an outbox message and its delivery state
+type Notification struct {
+	NotificationID uint32     `json:"notificationId"`
+	UserID         uint32     `json:"userId"`
+	Kind           string     `json:"kind"`
+	Channel        string     `json:"channel"`
+	AssessmentID   *uint32    `json:"assessmentId,omitempty"`
+	CallID         *uint32    `json:"callId,omitempty"`
+	Subject        string     `json:"subject"`
+	Body           string     `json:"body"`
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	MaxAttempts    int        `json:"maxAttempts"`
+	NextAttemptAt  *time.Time `json:"nextAttemptAt,omitempty"`
+	LastError      *string    `json:"lastError,omitempty"`
+	CreatedAt      time.Time  `json:"createdAt"`
+	SentAt         *time.Time `json:"sentAt,omitempty"`
+}
+
+var notifications struct {
+	cfg       NotificationConfig
+	notifiers map[string]clients.Notifier
+	channels  []string // channels messages are queued on, sorted
+	templates map[string]*template.Template
+	wake      chan struct{}
+}
+
+// defaultNotificationTemplates are used for kinds without a file in the templates directory.
+// Each defines a "subject" and a "body" template.
+var defaultNotificationTemplates = map[string]string{
+	NotificationCallReminder: `{{define "subject"}}Reminder: physio call {{.StartsIn}}{{end}}
+{{define "body"}}Hello {{.Name}},
+
+{{if .AsPhysio}}Your call with {{.With}}{{else}}Your physio call{{if .With}} with {{.With}}{{end}}{{end}} about the {{.Anatomy}} assessment starts {{.StartsIn}}, on {{.Start}}, and lasts {{.DurationMinutes}} minutes.
+
+If you cannot make it, please reschedule or cancel the call in the app.{{end}}`,
+
+	NotificationCriticalFlag: `{{define "subject"}}{{if .ForPatient}}Please seek care for your {{.Anatomy}} assessment{{else}}Critical flag on assessment {{.AssessmentID}}{{end}}{{end}}
+{{define "body"}}Hello {{.Name}},
+
+{{if .ForPatient}}Your {{.Anatomy}} assessment shows signs that need prompt attention from a clinician. A physiotherapist will contact you, and if your symptoms get worse please contact emergency services.{{else}}The {{.Anatomy}} assessment {{.AssessmentID}} of {{.Patient}} was flagged as critical and needs review.{{end}}
+{{- if .Reasons}}
+
+Reasons:
+{{range .Reasons}}- {{.}}
+{{end}}{{end}}{{end}}`,
+
+	NotificationAbandoned: `{{define "subject"}}Your {{.Anatomy}} assessment is waiting for you{{end}}
+{{define "body"}}Hello {{.Name}},
+
+You started a {{.Anatomy}} assessment but did not finish it, it was closed after no activity since {{.LastActivity}}.
+
+Start a new assessment in the app whenever you are ready.{{end}}`,
+}
+
+func init() {
+	templates, err := parseNotificationTemplates("")
+	if err != nil {
+		panic(err)
+	}
+	notifications.templates = templates
+}
+
+// useNotifiers sets the channels messages are delivered on, keyed by channel name.
+// Without notifiers nothing is queued.
+func useNotifiers(notifiers map[string]clients.Notifier) {
+	notifications.notifiers = notifiers
+	notifications.channels = notifications.channels[:0]
+	for channel := range notifiers {
+		notifications.channels = append(notifications.channels, channel)
+	}
+	sort.Strings(notifications.channels)
+}
+
+// LoadNotificationTemplates replaces the built-in template of each kind that has a
+// <kind>.tmpl file in dir
+func LoadNotificationTemplates(dir string) error {
+	templates, err := parseNotificationTemplates(dir)
+	if err != nil {
+		return err
+	}
+	notifications.templates = templates
+	return nil
+}
+
+func parseNotificationTemplates(dir string) (map[string]*template.Template, error) {
+	templates := map[string]*template.Template{}
+	for kind, text := range defaultNotificationTemplates {
+		source := "built-in"
+		if dir != "" {
+			path := filepath.Join(dir, kind+".tmpl")
+			data, err := os.ReadFile(path)
+			if err == nil {
+				text, source = string(data), path
+			} else if !errors.Is(err, os.ErrNotExist) {
+				return nil, fmt.Errorf("notification template %s: %w", kind, err)
+			}
+		}
+		t, err := template.New(kind).Option("missingkey=zero").Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("notification template %s (%s): %w", kind, source, err)
+		}
+		for _, name := range []string{"subject", "body"} {
+			if t.Lookup(name) == nil {
+				return nil, fmt.Errorf("notification template %s (%s) does not define %q", kind, source, name)
+			}
+		}
+		templates[kind] = t
+	}
+	return templates, nil
+}
+
+// renderNotification executes the subject and body templates of a kind
+func renderNotification(kind string, data map[string]any) (subject, body string, err error) {
+	t := notifications.templates[kind]
+	var b bytes.Buffer
+	if err := t.ExecuteTemplate(&b, "subject", data); err != nil {
+		return "", "", fmt.Errorf("notification template %s: %w", kind, err)
+	}
+	subject = strings.Join(strings.Fields(b.String()), " ")
+	b.Reset()
+	if err := t.ExecuteTemplate(&b, "body", data); err != nil {
+		return "", "", fmt.Errorf("notification template %s: %w", kind, err)
+	}
+	return subject, strings.TrimSpace(b.String()), nil
+}
+
+// notificationRef ties a message to the assessment and call it is about
+type notificationRef struct {
+	assessmentID *uint32
+	callID       *uint32
+}
+
+// notify renders a message for a user and queues it on every channel. key names the event,
+// a message already queued for the event, recipient and channel is not queued again.
+func notify(ctx context.Context, kind, key string, user *db.User, ref notificationRef, data map[string]any) error {
+	if len(notifications.channels) == 0 {
+		return nil
+	}
+	data["Name"] = user.Name
+	subject, body, err := renderNotification(kind, data)
+	if err != nil {
+		return err
+	}
+
+	queued := false
+	for _, channel := range notifications.channels {
+		n := db.Notification{
+			UserID:       user.UserID,
+			Kind:         kind,
+			Channel:      channel,
+			DedupeKey:    fmt.Sprintf("%s:%d:%s", key, user.UserID, channel),
+			AssessmentID: ref.assessmentID,
+			CallID:       ref.callID,
+			Subject:      subject,
+			Body:         body,
+			MaxAttempts:  notifications.cfg.MaxAttempts,
+		}
+		if user.Email != "" {
+			n.Address = &user.Email
+		}
+		created, err := store.Notifications.Enqueue(ctx, &n)
+		if err != nil {
+			return err
+		}
+		if created {
+			log.Printf("Notification %d (%s) queued for user %d on %s", n.NotificationID, kind, user.UserID, channel)
+			queued = true
+		}
+	}
+	if queued {
+		wakeNotificationDispatcher()
+	}
+	return nil
+}
+
+// StartNotificationDispatcher queues call reminders and delivers the outbox every
+// cfg.PollInterval until ctx is cancelled. The returned channel is closed once the
+// message being delivered is done.
+func StartNotificationDispatcher(ctx context.Context, cfg NotificationConfig) <-chan struct{} {
+	cfg.ReminderLeads = append([]time.Duration(nil), cfg.ReminderLeads...)
+	sort.Slice(cfg.ReminderLeads, func(i, j int) bool { return cfg.ReminderLeads[i] < cfg.ReminderLeads[j] })
+	notifications.cfg = cfg
+	notifications.wake = make(chan struct{}, 1)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		log.Printf("Notification dispatcher started on %s, polling every %s", strings.Join(notifications.channels, ", "), cfg.PollInterval)
+		ticker := time.NewTicker(cfg.PollInterval)
+		defer ticker.Stop()
+		for {
+			if _, err := QueueCallReminders(ctx, time.Now().UTC()); err != nil && ctx.Err() == nil {
+				log.Printf("Failed to queue call reminders: %v", err)
+			}
+			deliverNotifications(ctx)
+			select {
+			case <-ctx.Done():
+				log.Println("Notification dispatcher stopped")
+				return
+			case <-notifications.wake:
+			case <-ticker.C:
+			}
+		}
+	}()
+	return done
+}
+
+// wakeNotificationDispatcher delivers a new message without waiting for the poll
+func wakeNotificationDispatcher() {
+	select {
+	case notifications.wake <- struct{}{}:
+	default:
+	}
+}
+
+// deliverNotifications sends due messages until the outbox has none left
+func deliverNotifications(ctx context.Context) {
+	for ctx.Err() == nil {
+		n, err := store.Notifications.Claim(ctx, notifications.cfg.LeaseTimeout)
+		if errors.Is(err, db.ErrNotFound) {
+			return
+		}
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("Failed to claim a notification: %v", err)
+			}
+			return
+		}
+		deliverNotification(ctx, n)
+	}
+}
+
+// deliverNotification sends one claimed message and records the outcome
+func deliverNotification(ctx context.Context, n *db.Notification) {
+	if reason, err := staleNotification(ctx, n); err != nil {
+		failNotification(ctx, n, err)
+		return
+	} else if reason != "" {
+		if err := store.Notifications.Skip(context.Background(), n.NotificationID, *n.LockedAt, reason); err != nil {
+			log.Printf("Failed to skip notification %d: %v", n.NotificationID, err)
+			return
+		}
+		log.Printf("Notification %d skipped: %s", n.NotificationID, reason)
+		return
+	}
+
+	notifier, ok := notifications.notifiers[n.Channel]
+	if !ok {
+		failNotification(ctx, n, &clients.PermanentError{Cause: fmt.Errorf("channel %s is not configured", n.Channel)})
+		return
+	}
+	msg := clients.Message{
+		ID:           n.NotificationID,
+		Kind:         n.Kind,
+		DedupeKey:    n.DedupeKey,
+		UserID:       n.UserID,
+		AssessmentID: n.AssessmentID,
+		CallID:       n.CallID,
+		Subject:      n.Subject,
+		Body:         n.Body,
+		CreatedAt:    n.CreatedAt,
+	}
+	if n.Address != nil {
+		msg.Address = *n.Address
+	}
+	if err := notifier.Send(ctx, msg); err != nil {
+		failNotification(ctx, n, err)
+		return
+	}
+
+	err := store.Notifications.Sent(context.Background(), n.NotificationID, *n.LockedAt)
+	if errors.Is(err, db.ErrConflict) {
+		log.Printf("Notification %d was claimed again while it was sent", n.NotificationID)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to record the delivery of notification %d: %v", n.NotificationID, err)
+		return
+	}
+	log.Printf("Notification %d sent on %s", n.NotificationID, n.Channel)
+}
+
+// staleNotification returns why a message no longer applies, empty when it should be sent.
+// A call reminder is dropped once its call is moved, reassigned, started or cancelled, the
+// next scan queues a reminder for the new slot.
+func staleNotification(ctx context.Context, n *db.Notification) (string, error) {
+	if n.Kind != NotificationCallReminder || n.CallID == nil {
+		return "", nil
+	}
+	call, err := store.PhysioCalls.Get(ctx, *n.CallID)
+	if errors.Is(err, db.ErrNotFound) {
+		return "the call was deleted", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case call.CallStatus != models.CallScheduled.String():
+		return "the call is " + call.CallStatus, nil
+	case call.UpdatedAt.After(n.CreatedAt):
+		return "the call changed after the reminder was queued", nil
+	case call.ScheduledTime == nil || !call.ScheduledTime.After(time.Now()):
+		return "the call has started", nil
+	}
+	return "", nil
+}
+
+// failNotification queues the message again with backoff, or fails it once no attempt is left
+func failNotification(ctx context.Context, n *db.Notification, cause error) {
+	var retryIn *time.Duration
+	var permanent *clients.PermanentError
+	switch {
+	case ctx.Err() != nil:
+		// Shutting down, the next start sends the message
+		var immediately time.Duration
+		retryIn = &immediately
+	case errors.As(cause, &permanent):
+	case n.Attempts < n.MaxAttempts:
+		next := notifications.cfg.RetryBackoff << (n.Attempts - 1)
+		retryIn = &next
+	}
+
+	if err := store.Notifications.Fail(context.Background(), n.NotificationID, *n.LockedAt, cause.Error(), retryIn); err != nil {
+		log.Printf("Failed to record the failure of notification %d: %v", n.NotificationID, err)
+		return
+	}
+	if retryIn == nil {
+		log.Printf("Notification %d failed: %v", n.NotificationID, cause)
+	} else {
+		log.Printf("Notification %d attempt %d failed, retrying in %s: %v", n.NotificationID, n.Attempts, *retryIn, cause)
+	}
+}
+
+// QueueCallReminders queues a reminder to the patient and the physiotherapist of every
+// scheduled call starting within the longest reminder lead. Each lead reminds once per
+// version of the call, and a call booked at short notice gets only the reminder of the
+// shortest lead it is within. It returns the number of calls reminded.
+func QueueCallReminders(ctx context.Context, now time.Time) (int, error) {
+	leads := notifications.cfg.ReminderLeads
+	if len(notifications.channels) == 0 || len(leads) == 0 {
+		return 0, nil
+	}
+	until := now.Add(leads[len(leads)-1])
+	status := models.CallScheduled.String()
+	calls, err := store.PhysioCalls.List(ctx, db.PhysioCallFilter{Status: &status, From: &now, To: &until})
+	if err != nil {
+		return 0, err
+	}
+
+	reminded := 0
+	for _, call := range calls {
+		if call.ScheduledTime == nil {
+			continue
+		}
+		left := call.ScheduledTime.Sub(now)
+		for _, lead := range leads {
+			if left > lead {
+				continue
+			}
+			if err := queueCallReminder(ctx, call, lead, left); err != nil {
+				return reminded, err
+			}
+			reminded++
+			break
+		}
+	}
+	return reminded, nil
+}
+
+// queueCallReminder queues the reminder of one lead to both parties of a call
+func queueCallReminder(ctx context.Context, call db.PhysioCall, lead, left time.Duration) error {
+	assessment, err := store.Assessments.Get(ctx, call.AssessmentID)
+	if err != nil {
+		return err
+	}
+	anatomy, err := anatomyName(ctx, assessment.AnatomyID)
+	if err != nil {
+		return err
+	}
+	names := map[uint32]string{}
+	patient, err := userName(ctx, call.UserID, names)
+	if err != nil {
+		return err
+	}
+	var physio string
+	if call.PhysioID != nil {
+		if physio, err = userName(ctx, *call.PhysioID, names); err != nil {
+			return err
+		}
+	}
+
+	key := fmt.Sprintf("%s:%d:%d:%s", NotificationCallReminder, call.CallID, call.Sequence, lead)
+	ref := notificationRef{assessmentID: &call.AssessmentID, callID: &call.CallID}
+	recipients := []uint32{call.UserID}
+	if call.PhysioID != nil {
+		recipients = append(recipients, *call.PhysioID)
+	}
+	for _, userID := range recipients {
+		user, err := store.Users.Get(ctx, userID)
+		if errors.Is(err, db.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		asPhysio := call.PhysioID != nil && userID == *call.PhysioID
+		with := physio
+		if asPhysio {
+			with = patient
+		}
+		err = notify(ctx, NotificationCallReminder, key, user, ref, map[string]any{
+			"AsPhysio":        asPhysio,
+			"With":            with,
+			"Anatomy":         anatomy,
+			"AssessmentID":    call.AssessmentID,
+			"CallID":          call.CallID,
+			"Start":           call.ScheduledTime.UTC().Format(notifyTimeLayout),
+			"StartsIn":        startsIn(left),
+			"DurationMinutes": call.DurationMinutes,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// startsIn describes a delay the way a reminder reads it, e.g. "in 2 hours"
+func startsIn(left time.Duration) string {
+	plural := func(n int, unit string) string {
+		if n == 1 {
+			return "in 1 " + unit
+		}
+		return fmt.Sprintf("in %d %ss", n, unit)
+	}
+	switch {
+	case left >= 36*time.Hour:
+		return plural(int((left+12*time.Hour)/(24*time.Hour)), "day")
+	case left >= 90*time.Minute:
+		return plural(int((left+30*time.Minute)/time.Hour), "hour")
+	case left >= time.Minute:
+		return plural(int((left+30*time.Second)/time.Minute), "minute")
+	}
+	return "now"
+}
+
+// NotifyCriticalFlag alerts the patient and the physiotherapist of an assessment that the
+// AI flagged it as critical, or every admin when no physiotherapist is assigned. An
+// assessment is alerted about once.
+func NotifyCriticalFlag(ctx context.Context, assessmentID uint32, reasons []string) error {
+	assessment, err := store.Assessments.Get(ctx, assessmentID)
+	if err != nil {
+		return err
+	}
+	anatomy, err := anatomyName(ctx, assessment.AnatomyID)
+	if err != nil {
+		return err
+	}
+	patient, err := store.Users.Get(ctx, assessment.UserID)
+	if err != nil && !errors.Is(err, db.ErrNotFound) {
+		return err
+	}
+
+	var staff []db.User
+	if assessment.PhysioID != nil {
+		physio, err := store.Users.Get(ctx, *assessment.PhysioID)
+		if err != nil && !errors.Is(err, db.ErrNotFound) {
+			return err
+		}
+		if physio != nil {
+			staff = append(staff, *physio)
+		}
+	}
+	if len(staff) == 0 {
+		users, err := store.Users.List(ctx)
+		if err != nil {
+			return err
+		}
+		for _, u := range users {
+			if models.Role(u.Role) == models.RoleAdmin {
+				staff = append(staff, u)
+			}
+		}
+	}
+
+	key := fmt.Sprintf("%s:%d", NotificationCriticalFlag, assessmentID)
+	ref := notificationRef{assessmentID: &assessmentID}
+	data := func(forPatient bool) map[string]any {
+		d := map[string]any{
+			"ForPatient":   forPatient,
+			"Anatomy":      anatomy,
+			"AssessmentID": assessmentID,
+			"Reasons":      reasons,
+			"Patient":      "a patient",
+		}
+		if patient != nil {
+			d["Patient"] = patient.Name
+		}
+		return d
+	}
+	if patient != nil {
+		if err := notify(ctx, NotificationCriticalFlag, key, patient, ref, data(true)); err != nil {
+			return err
+		}
+	}
+	for i := range staff {
+		if err := notify(ctx, NotificationCriticalFlag, key, &staff[i], ref, data(false)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// notifyCriticalPlan alerts about an assessment whose self-care plan carries the critical flag
+func notifyCriticalPlan(ctx context.Context, assessmentID uint32) error {
+	plan, err := store.SelfCarePlans.GetByAssessment(ctx, assessmentID)
+	if errors.Is(err, db.ErrNotFound) {
+		return nil
+	}
+	if err != nil || !plan.CriticalFlag {
+		return err
+	}
+	return NotifyCriticalFlag(ctx, assessmentID, nil)
+}
+
+// notifyAssessmentAbandoned tells the patient an assessment was closed for inactivity
+func notifyAssessmentAbandoned(ctx context.Context, assessment db.Assessment) error {
+	user, err := store.Users.Get(ctx, assessment.UserID)
+	if errors.Is(err, db.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	anatomy, err := anatomyName(ctx, assessment.AnatomyID)
+	if err != nil {
+		return err
+	}
+	key := fmt.Sprintf("%s:%d", NotificationAbandoned, assessment.AssessmentID)
+	return notify(ctx, NotificationAbandoned, key, user, notificationRef{assessmentID: &assessment.AssessmentID}, map[string]any{
+		"Anatomy":      anatomy,
+		"AssessmentID": assessment.AssessmentID,
+		"LastActivity": assessment.LastActivityAt.UTC().Format(notifyTimeLayout),
+	})
+}
+
+// anatomyName returns the name of a body part, a generic word once it is deleted
+func anatomyName(ctx context.Context, anatomyID uint32) (string, error) {
+	anatomy, err := store.Anatomy.Get(ctx, anatomyID)
+	if errors.Is(err, db.ErrNotFound) {
+		return "body", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return anatomy.Name, nil
+}
+
+// ListNotifications returns outbox messages, newest first
+func ListNotifications(userID *uint32, status *string, limit int) ([]Notification, error) {
+	rows, err := store.Notifications.List(context.Background(), db.NotificationFilter{UserID: userID, Status: status, Limit: limit})
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Notification, 0, len(rows))
+	for i := range rows {
+		out = append(out, toNotification(&rows[i]))
+	}
+	return out, nil
+}
+
+// RetryNotification gives a failed message a fresh set of attempts
+func RetryNotification(notificationID uint32) (*Notification, error) {
+	row, err := store.Notifications.Requeue(context.Background(), notificationID)
+	switch {
+	case errors.Is(err, db.ErrNotFound):
+		return nil, ErrNotificationNotFound
+	case errors.Is(err, db.ErrConflict):
+		return nil, ErrNotificationNotFailed
+	case err != nil:
+		return nil, err
+	}
+	wakeNotificationDispatcher()
+	n := toNotification(row)
+	return &n, nil
+}
+
+func toNotification(row *db.Notification) Notification {
+	n := Notification{
+		NotificationID: row.NotificationID,
+		UserID:         row.UserID,
+		Kind:           row.Kind,
+		Channel:        row.Channel,
+		AssessmentID:   row.AssessmentID,
+		CallID:         row.CallID,
+		Subject:        row.Subject,
+		Body:           row.Body,
+		Status:         row.Status,
+		Attempts:       row.Attempts,
+		MaxAttempts:    row.MaxAttempts,
+		LastError:      row.LastError,
+		CreatedAt:      row.CreatedAt,
+		SentAt:         row.SentAt,
+	}
+	if row.Status == db.NotificationPending && row.Attempts > 0 {
+		next := row.RunAfter
+		n.NextAttemptAt = &next
+	}
+	return n
+}
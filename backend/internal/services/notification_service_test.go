@@ -0,0 +1,128 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"ai-bot-deecogs/internal/clients"
+	"ai-bot-deecogs/internal/db"
+)
+
+// scriptedNotifier answers each Send with the next error of its script, nil once it runs out
+type scriptedNotifier struct {
+	errs []error
+	sent []clients.Message
+}
+
+func (n *scriptedNotifier) Send(ctx context.Context, msg clients.Message) error {
+	if len(n.errs) > 0 {
+		err := n.errs[0]
+		n.errs = n.errs[1:]
+		return err
+	}
+	n.sent = append(n.sent, msg)
+	return nil
+}
+
+// queueTestNotification queues a log message on a memory store whose clock is moved by the
+// returned func, notifier delivers it
+func queueTestNotification(t *testing.T, notifier *scriptedNotifier) (uint32, func(time.Duration)) {
+	t.Helper()
+	b := useMemoryStore(t)
+	newTestAssessment(t, b)
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	b.SetClock(func() time.Time { return now })
+
+	cfg := notifications.cfg
+	notifications.cfg = NotificationConfig{MaxAttempts: 3, RetryBackoff: time.Minute, LeaseTimeout: 5 * time.Minute}
+	useNotifiers(map[string]clients.Notifier{"log": notifier})
+	t.Cleanup(func() {
+		notifications.cfg = cfg
+		useNotifiers(nil)
+	})
+
+	n := &db.Notification{UserID: 1, Kind: NotificationCriticalFlag, Channel: "log", DedupeKey: "test", Subject: "Flag", Body: "Body", MaxAttempts: 3}
+	if _, err := store.Notifications.Enqueue(context.Background(), n); err != nil {
+		t.Fatal(err)
+	}
+	return n.NotificationID, func(d time.Duration) { now = now.Add(d) }
+}
+
+func notificationRow(t *testing.T, id uint32) *db.Notification {
+	t.Helper()
+	n, err := store.Notifications.Get(context.Background(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestNotificationRetryBackoff(t *testing.T) {
+	notifier := &scriptedNotifier{errs: []error{errors.New("smtp down"), errors.New("smtp down")}}
+	id, advance := queueTestNotification(t, notifier)
+
+	// Attempt 1 is retried after 1 minute, attempt 2 after 2 and attempt 3 goes through
+	for _, backoff := range []time.Duration{time.Minute, 2 * time.Minute} {
+		deliverNotifications(context.Background())
+		advance(backoff - time.Second)
+		deliverNotifications(context.Background())
+		if n := notificationRow(t, id); n.Status != db.NotificationPending || len(notifier.sent) != 0 {
+			t.Fatalf("message %s after %d attempts, want pending before its backoff ran out", n.Status, n.Attempts)
+		}
+		advance(time.Second)
+	}
+	deliverNotifications(context.Background())
+
+	n := notificationRow(t, id)
+	if n.Status != db.NotificationSent || n.Attempts != 3 || len(notifier.sent) != 1 {
+		t.Fatalf("message %s after %d attempts and %d deliveries, want sent once at the 3rd", n.Status, n.Attempts, len(notifier.sent))
+	}
+}
+
+func TestNotificationFailsForGood(t *testing.T) {
+	tests := []struct {
+		name         string
+		errs         []error
+		wantAttempts int
+	}{
+		{"permanent error", []error{&clients.PermanentError{Cause: errors.New("no such mailbox")}}, 1},
+		{"out of attempts", []error{errors.New("down"), errors.New("down"), errors.New("down")}, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, advance := queueTestNotification(t, &scriptedNotifier{errs: tt.errs})
+			for i := 0; i < 3; i++ {
+				deliverNotifications(context.Background())
+				advance(time.Hour)
+			}
+			if n := notificationRow(t, id); n.Status != db.NotificationFailed || n.Attempts != tt.wantAttempts {
+				t.Fatalf("message %s after %d attempts, want failed after %d", n.Status, n.Attempts, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestNotificationStaleLease(t *testing.T) {
+	id, advance := queueTestNotification(t, &scriptedNotifier{})
+	lost, err := store.Notifications.Claim(context.Background(), notifications.cfg.LeaseTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	advance(notifications.cfg.LeaseTimeout)
+	if _, err := store.Notifications.Claim(context.Background(), notifications.cfg.LeaseTimeout); !errors.Is(err, db.ErrNotFound) {
+		t.Fatalf("Claim within the lease = %v, want nothing due", err)
+	}
+	advance(time.Second)
+	deliverNotifications(context.Background())
+
+	// The dispatcher that lost the lease can no longer record its delivery
+	if err := store.Notifications.Sent(context.Background(), id, *lost.LockedAt); !errors.Is(err, db.ErrConflict) {
+		t.Fatalf("Sent with a lost lease = %v, want ErrConflict", err)
+	}
+	if n := notificationRow(t, id); n.Status != db.NotificationSent || n.Attempts != 2 {
+		t.Fatalf("message %s after %d attempts, want sent at the 2nd", n.Status, n.Attempts)
+	}
+}
@@ -2,84 +2,382 @@ package services
 
 import (
 	"ai-bot-deecogs/internal/db"
+	"ai-bot-deecogs/internal/helpers"
+	"ai-bot-deecogs/internal/models"
 	"context"
 	"errors"
+	"fmt"
+	"strconv"
 	"time"
 )
 
+var (
+	ErrCallNotFound       = errors.New("physio call not found")
+	ErrInvalidCall        = errors.New("invalid physio call")
+	ErrCallOverlap        = errors.New("the slot overlaps another call of the patient or physiotherapist")
+	ErrCallStatusConflict = errors.New("physio call changed concurrently, retry")
+	ErrNotPhysio          = errors.New("user is not a physiotherapist")
+)
+
+// Call durations in minutes, the bounds match physio_calls_duration_check
+const (
+	defaultCallMinutes = 30
+	minCallMinutes     = 5
+	maxCallMinutes     = 240
+)
+
+// Who booked a call, physio_calls.initiated_by. Only the red-flag review books system calls.
+const (
+	callByUser   = "user"
+	callBySystem = "system"
+)
+
+// callTransitions is the physio call state machine, completed, cancelled and no_show are final
+var callTransitions = map[models.CallStatus][]models.CallStatus{
+	models.CallScheduled:  {models.CallInProgress, models.CallCancelled, models.CallNoShow},
+	models.CallInProgress: {models.CallCompleted, models.CallCancelled, models.CallNoShow},
+}
+
 type PhysioCall struct {
-	CallID        string     `json:"call_id"`
-	AssessmentID  string     `json:"assessment_id"`
-	CallType      string     `json:"call_type"`       // Immediate or Scheduled
-	CallStatus    string     `json:"call_status"`     // Scheduled, Completed, Cancelled
-	ScheduledTime *time.Time `json:"scheduled_time"`  // NULL for immediate calls
-	InitiatedBy   string     `json:"initiated_by"`    // User or System
-	CreatedAt     time.Time  `json:"created_at"`
+	CallID          string     `json:"call_id"`
+	AssessmentID    string     `json:"assessment_id"`
+	UserID          uint32     `json:"user_id"`                 // patient of the assessment
+	CallType        string     `json:"call_type"`               // immediate or scheduled
+	CallStatus      string     `json:"call_status"`             // scheduled, in_progress, completed, cancelled or no_show
+	ScheduledTime   *time.Time `json:"scheduled_time"`          // NULL for immediate calls
+	DurationMinutes int        `json:"duration_minutes"`        // length of the slot
+	PhysioID        *uint32    `json:"physio_id,omitempty"`     // physiotherapist taking the call
+	InitiatedBy     string     `json:"initiated_by"`            // User or System
+	StartedAt       *time.Time `json:"started_at,omitempty"`    // when the call went in_progress
+	EndedAt         *time.Time `json:"ended_at,omitempty"`      // when the call reached a final status
+	StatusReason    *string    `json:"status_reason,omitempty"` // why it was cancelled or missed
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// CallFilter narrows a list of calls, From and To bound the scheduled time
+type CallFilter struct {
+	Status *models.CallStatus
+	From   *time.Time
+	To     *time.Time
 }
 
-// SchedulePhysioCall schedules a new physio call
-func SchedulePhysioCall(assessmentID, callType, initiatedBy string, scheduledTime *time.Time) (*PhysioCall, error) {
+// SchedulePhysioCall schedules a new physio call booked by the patient. A call of an assessment
+// with an assigned physiotherapist goes to them, and a scheduled call must lie within their
+// published hours and not overlap another open call of the patient or the physiotherapist.
+func SchedulePhysioCall(assessmentID, callType string, scheduledTime *time.Time, durationMinutes int) (*PhysioCall, error) {
 	// Validate input
-	if callType != "immediate" && callType != "scheduled" {
-		return nil, errors.New("invalid call type")
+	if !models.CallType(callType).IsValid() {
+		return nil, fmt.Errorf("%w: call_type must be immediate or scheduled", ErrInvalidCall)
 	}
-	if initiatedBy != "user" && initiatedBy != "system" {
-		return nil, errors.New("invalid initiator")
+	if durationMinutes == 0 {
+		durationMinutes = defaultCallMinutes
+	}
+	if durationMinutes < minCallMinutes || durationMinutes > maxCallMinutes {
+		return nil, fmt.Errorf("%w: duration_minutes must be between %d and %d", ErrInvalidCall, minCallMinutes, maxCallMinutes)
+	}
+	if models.CallType(callType) == models.CallScheduledType {
+		if scheduledTime == nil {
+			return nil, fmt.Errorf("%w: scheduled_time is required for a scheduled call", ErrInvalidCall)
+		}
+		if scheduledTime.Before(time.Now()) {
+			return nil, fmt.Errorf("%w: scheduled_time is in the past", ErrInvalidCall)
+		}
+		utc := scheduledTime.UTC()
+		scheduledTime = &utc
+	} else {
+		scheduledTime = nil
 	}
 
-	query := `
-		INSERT INTO physio_calls (assessment_id, call_type, call_status, scheduled_time, initiated_by)
-		VALUES ($1, $2, $3, $4, $5)
-		RETURNING call_id, created_at
-	`
-
-	var callID string
-	var createdAt time.Time
-	err := db.DB.QueryRow(context.Background(), query, assessmentID, callType, "scheduled", scheduledTime, initiatedBy).Scan(&callID, &createdAt)
+	assessmentIDUint, err := helpers.StringToUInt32(assessmentID)
+	if err != nil {
+		return nil, errors.New("invalid assessment ID")
+	}
+	assessment, err := store.Assessments.Get(context.Background(), assessmentIDUint)
 	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, ErrAssessmentNotFound
+		}
 		return nil, err
 	}
 
-	return &PhysioCall{
-		CallID:        callID,
-		AssessmentID:  assessmentID,
-		CallType:      callType,
-		CallStatus:    "scheduled",
-		ScheduledTime: scheduledTime,
-		InitiatedBy:   initiatedBy,
-		CreatedAt:     createdAt,
-	}, nil
+	return createCall(&db.PhysioCall{
+		AssessmentID:    assessmentIDUint,
+		CallType:        callType,
+		CallStatus:      models.CallScheduled.String(),
+		ScheduledTime:   scheduledTime,
+		DurationMinutes: durationMinutes,
+		PhysioID:        assessment.PhysioID,
+		InitiatedBy:     callByUser,
+	}, fitsAvailability)
+}
+
+// createCall stores a new call, check vets its slot against the physiotherapist's calendar
+func createCall(call *db.PhysioCall, check db.CalendarCheck) (*PhysioCall, error) {
+	if err := store.PhysioCalls.Create(context.Background(), call, check); err != nil {
+		if errors.Is(err, db.ErrOverlap) {
+			return nil, ErrCallOverlap
+		}
+		return nil, err
+	}
+	return toPhysioCall(*call), nil
 }
 
 // GetPhysioCalls retrieves all physio calls for a given assessment
 func GetPhysioCalls(assessmentID string) ([]PhysioCall, error) {
-	query := `
-		SELECT call_id, assessment_id, call_type, call_status, scheduled_time, initiated_by, created_at
-		FROM physio_calls
-		WHERE assessment_id = $1
-		ORDER BY created_at DESC
-	`
-
-	rows, err := db.DB.Query(context.Background(), query, assessmentID)
+	assessmentIDUint, err := helpers.StringToUInt32(assessmentID)
+	if err != nil {
+		return nil, errors.New("invalid assessment ID")
+	}
+
+	rows, err := store.PhysioCalls.ListByAssessment(context.Background(), assessmentIDUint)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
+	return toPhysioCalls(rows), nil
+}
+
+// GetPhysioCall returns a single call
+func GetPhysioCall(callID uint32) (*PhysioCall, error) {
+	row, err := getCall(callID)
+	if err != nil {
+		return nil, err
+	}
+	return toPhysioCall(*row), nil
+}
+
+func getCall(callID uint32) (*db.PhysioCall, error) {
+	row, err := store.PhysioCalls.Get(context.Background(), callID)
+	if errors.Is(err, db.ErrNotFound) {
+		return nil, ErrCallNotFound
+	}
+	return row, err
+}
+
+// ListUserPhysioCalls returns the calls of a patient's assessments in time order
+func ListUserPhysioCalls(userID uint32, filter CallFilter) ([]PhysioCall, error) {
+	return listCalls(db.PhysioCallFilter{UserID: &userID}, filter)
+}
+
+// ListPhysioCallsOfPhysio returns the calls assigned to a physiotherapist in time order
+func ListPhysioCallsOfPhysio(physioID uint32, filter CallFilter) ([]PhysioCall, error) {
+	return listCalls(db.PhysioCallFilter{PhysioID: &physioID}, filter)
+}
 
-	var calls []PhysioCall
-	for rows.Next() {
-		var call PhysioCall
-		var scheduledTime *time.Time
-		if err := rows.Scan(&call.CallID, &call.AssessmentID, &call.CallType, &call.CallStatus, &scheduledTime, &call.InitiatedBy, &call.CreatedAt); err != nil {
+func listCalls(f db.PhysioCallFilter, filter CallFilter) ([]PhysioCall, error) {
+	if filter.Status != nil {
+		if !filter.Status.IsValid() {
+			return nil, fmt.Errorf("%w: unknown status %q", ErrInvalidCall, string(*filter.Status))
+		}
+		status := filter.Status.String()
+		f.Status = &status
+	}
+	if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		return nil, fmt.Errorf("%w: from must be before to", ErrInvalidCall)
+	}
+	if filter.From != nil {
+		from := filter.From.UTC()
+		f.From = &from
+	}
+	if filter.To != nil {
+		to := filter.To.UTC()
+		f.To = &to
+	}
+	rows, err := store.PhysioCalls.List(context.Background(), f)
+	if err != nil {
+		return nil, err
+	}
+	return toPhysioCalls(rows), nil
+}
+
+// CanTransitionCall reports whether the call state machine allows from -> to
+func CanTransitionCall(from, to models.CallStatus) bool {
+	for _, next := range callTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// UpdatePhysioCallStatus moves a call along its lifecycle. Patients can only cancel their
+// calls, the physiotherapist of the call and admins run it. Moving to the current status is
+// a no-op so retried requests are harmless.
+func UpdatePhysioCallStatus(principal *Principal, callID uint32, to models.CallStatus, reason *string) (*PhysioCall, error) {
+	if !to.IsValid() {
+		return nil, fmt.Errorf("%w: unknown status %q", ErrInvalidCall, string(to))
+	}
+
+	for attempt := 0; attempt < transitionRetries; attempt++ {
+		row, err := getCall(callID)
+		if err != nil {
+			return nil, err
+		}
+		runsCall := principal.Can(models.PermAssessmentWriteAll) ||
+			(row.PhysioID != nil && *row.PhysioID == principal.UserID && principal.Can(models.PermPhysioCallManage))
+		if to != models.CallCancelled && !runsCall {
+			return nil, ErrForbidden
+		}
+
+		from := models.CallStatus(row.CallStatus)
+		if from == to {
+			return toPhysioCall(*row), nil
+		}
+		if !CanTransitionCall(from, to) {
+			return nil, fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, from, to)
+		}
+
+		updated, err := store.PhysioCalls.Transition(context.Background(), callID, db.CallTransition{
+			From:    from.String(),
+			To:      to.String(),
+			Started: to == models.CallInProgress,
+			Ended:   to.IsTerminal(),
+			Reason:  reason,
+		})
+		if errors.Is(err, db.ErrConflict) {
+			continue
+		}
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, ErrCallNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		return toPhysioCall(*updated), nil
+	}
+	return nil, ErrCallStatusConflict
+}
+
+// ReschedulePhysioCall moves a scheduled call to a new slot, durationMinutes 0 keeps its length
+func ReschedulePhysioCall(callID uint32, scheduledTime time.Time, durationMinutes int) (*PhysioCall, error) {
+	if scheduledTime.Before(time.Now()) {
+		return nil, fmt.Errorf("%w: scheduled_time is in the past", ErrInvalidCall)
+	}
+	if durationMinutes != 0 && (durationMinutes < minCallMinutes || durationMinutes > maxCallMinutes) {
+		return nil, fmt.Errorf("%w: duration_minutes must be between %d and %d", ErrInvalidCall, minCallMinutes, maxCallMinutes)
+	}
+	return updateCall(callID, func(call *db.PhysioCall) {
+		at := scheduledTime.UTC()
+		call.ScheduledTime = &at
+		call.CallType = models.CallScheduledType.String()
+		if durationMinutes != 0 {
+			call.DurationMinutes = durationMinutes
+		}
+	})
+}
+
+// AssignCallPhysio makes a physiotherapist responsible for a scheduled call, nil unassigns it
+func AssignCallPhysio(callID uint32, physioID *uint32) (*PhysioCall, error) {
+	if physioID != nil {
+		if err := requirePhysio(*physioID); err != nil {
 			return nil, err
 		}
-		call.ScheduledTime = scheduledTime
-		calls = append(calls, call)
+	}
+	return updateCall(callID, func(call *db.PhysioCall) { call.PhysioID = physioID })
+}
+
+// updateCall applies change to a scheduled call and stores it with availability and overlap checks
+func updateCall(callID uint32, change func(call *db.PhysioCall)) (*PhysioCall, error) {
+	row, err := getCall(callID)
+	if err != nil {
+		return nil, err
+	}
+	if models.CallStatus(row.CallStatus) != models.CallScheduled {
+		return nil, fmt.Errorf("%w: the call is %s, only scheduled calls can change", ErrInvalidTransition, row.CallStatus)
+	}
+	change(row)
+
+	err = store.PhysioCalls.Update(context.Background(), row, fitsAvailability)
+	switch {
+	case errors.Is(err, db.ErrOverlap):
+		return nil, ErrCallOverlap
+	case errors.Is(err, db.ErrConflict):
+		return nil, ErrCallStatusConflict
+	case errors.Is(err, db.ErrNotFound):
+		return nil, ErrCallNotFound
+	case err != nil:
+		return nil, err
+	}
+	return toPhysioCall(*row), nil
+}
+
+// requirePhysio checks a user exists and is a physiotherapist
+func requirePhysio(userID uint32) error {
+	user, err := store.Users.Get(context.Background(), userID)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return ErrUserNotFound
+		}
+		return err
+	}
+	if models.Role(user.Role) != models.RolePhysiotherapist {
+		return ErrNotPhysio
+	}
+	return nil
+}
+
+// AuthorizePhysioCall checks the caller's access to a call: the patient reads and writes it,
+// the physiotherapist of the call or of its assessment reads it, the physiotherapist of the
+// call also writes it, and admins do both on any call
+func AuthorizePhysioCall(principal *Principal, callID uint32, level AccessLevel) error {
+	call, err := getCall(callID)
+	if err != nil {
+		return err
+	}
+	assessment, err := store.Assessments.Get(context.Background(), call.AssessmentID)
+	if err != nil {
+		return err
+	}
+	ownsCall := call.UserID == principal.UserID
+	takesCall := call.PhysioID != nil && *call.PhysioID == principal.UserID
+
+	if level == AccessRead {
+		switch {
+		case principal.Can(models.PermAssessmentReadAll):
+			return nil
+		case ownsCall && principal.Can(models.PermAssessmentReadOwn):
+			return nil
+		case (takesCall || (assessment.PhysioID != nil && *assessment.PhysioID == principal.UserID)) && principal.Can(models.PermAssessmentReadAssigned):
+			return nil
+		}
+		return ErrForbidden
 	}
 
-	if len(calls) == 0 {
-		return nil, errors.New("no physio calls found for the given assessment ID")
+	switch {
+	case principal.Can(models.PermAssessmentWriteAll):
+		return nil
+	case ownsCall && principal.Can(models.PermAssessmentWriteOwn):
+		return nil
+	case takesCall && principal.Can(models.PermPhysioCallManage):
+		return nil
 	}
+	return ErrForbidden
+}
+
+func toPhysioCalls(rows []db.PhysioCall) []PhysioCall {
+	calls := make([]PhysioCall, 0, len(rows))
+	for _, row := range rows {
+		calls = append(calls, *toPhysioCall(row))
+	}
+	return calls
+}
 
-	return calls, nil
+// toPhysioCall converts a physio_calls row to its API representation
+func toPhysioCall(row db.PhysioCall) *PhysioCall {
+	return &PhysioCall{
+		CallID:          strconv.FormatUint(uint64(row.CallID), 10),
+		AssessmentID:    strconv.FormatUint(uint64(row.AssessmentID), 10),
+		UserID:          row.UserID,
+		CallType:        row.CallType,
+		CallStatus:      row.CallStatus,
+		ScheduledTime:   row.ScheduledTime,
+		DurationMinutes: row.DurationMinutes,
+		PhysioID:        row.PhysioID,
+		InitiatedBy:     row.InitiatedBy,
+		StartedAt:       row.StartedAt,
+		EndedAt:         row.EndedAt,
+		StatusReason:    row.StatusReason,
+		CreatedAt:       row.CreatedAt,
+		UpdatedAt:       row.UpdatedAt,
+	}
 }
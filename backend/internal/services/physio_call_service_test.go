@@ -0,0 +1,155 @@
+package services
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"ai-bot-deecogs/internal/db"
+	"ai-bot-deecogs/internal/models"
+)
+
+func TestScheduledCallsAreBookedByTheUser(t *testing.T) {
+	b := useMemoryStore(t)
+	id := newTestAssessment(t, b)
+	call, err := SchedulePhysioCall(strconv.FormatUint(uint64(id), 10), "immediate", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if call.InitiatedBy != callByUser {
+		t.Fatalf("call initiated by %q, want %q", call.InitiatedBy, callByUser)
+	}
+}
+
+func TestCanTransitionCall(t *testing.T) {
+	statuses := []models.CallStatus{models.CallScheduled, models.CallInProgress, models.CallCompleted, models.CallCancelled, models.CallNoShow}
+	allowed := map[[2]models.CallStatus]bool{
+		{models.CallScheduled, models.CallInProgress}: true,
+		{models.CallScheduled, models.CallCancelled}:  true,
+		{models.CallScheduled, models.CallNoShow}:     true,
+		{models.CallInProgress, models.CallCompleted}: true,
+		{models.CallInProgress, models.CallCancelled}: true,
+		{models.CallInProgress, models.CallNoShow}:    true,
+	}
+	for _, from := range statuses {
+		for _, to := range statuses {
+			if got := CanTransitionCall(from, to); got != allowed[[2]models.CallStatus{from, to}] {
+				t.Errorf("CanTransitionCall(%s, %s) = %v", from, to, got)
+			}
+		}
+	}
+}
+
+func TestUpdatePhysioCallStatus(t *testing.T) {
+	b := useMemoryStore(t)
+	id := newTestAssessment(t, b)
+	patient := &Principal{UserID: 1, Role: models.RolePatient}
+	admin := &Principal{UserID: 99, Role: models.RoleAdmin}
+	newCall := func() uint32 {
+		t.Helper()
+		call, err := SchedulePhysioCall(strconv.FormatUint(uint64(id), 10), "immediate", nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		callID, err := strconv.ParseUint(call.CallID, 10, 32)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return uint32(callID)
+	}
+
+	callID := newCall()
+	if _, err := UpdatePhysioCallStatus(patient, callID, models.CallInProgress, nil); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("patient starting the call = %v, want ErrForbidden", err)
+	}
+	started, err := UpdatePhysioCallStatus(admin, callID, models.CallInProgress, nil)
+	if err != nil || started.StartedAt == nil || started.EndedAt != nil {
+		t.Fatalf("starting the call = %+v, %v, want it started and not ended", started, err)
+	}
+	// Retried requests are a no-op
+	if _, err := UpdatePhysioCallStatus(admin, callID, models.CallInProgress, nil); err != nil {
+		t.Fatalf("starting the call again: %v", err)
+	}
+	done, err := UpdatePhysioCallStatus(admin, callID, models.CallCompleted, nil)
+	if err != nil || done.EndedAt == nil {
+		t.Fatalf("completing the call = %+v, %v, want it ended", done, err)
+	}
+	if _, err := UpdatePhysioCallStatus(admin, callID, models.CallCancelled, nil); !errors.Is(err, ErrInvalidTransition) {
+		t.Fatalf("cancelling a completed call = %v, want ErrInvalidTransition", err)
+	}
+
+	reason := "feeling better"
+	cancelled, err := UpdatePhysioCallStatus(patient, newCall(), models.CallCancelled, &reason)
+	if err != nil || cancelled.CallStatus != models.CallCancelled.String() || cancelled.StatusReason == nil || *cancelled.StatusReason != reason {
+		t.Fatalf("patient cancelling = %+v, %v, want it cancelled with the reason", cancelled, err)
+	}
+	if _, err := UpdatePhysioCallStatus(admin, newCall(), "finished", nil); !errors.Is(err, ErrInvalidCall) {
+		t.Fatalf("unknown status = %v, want ErrInvalidCall", err)
+	}
+}
+
+func TestScheduledCallOverlap(t *testing.T) {
+	b := useMemoryStore(t)
+	id := strconv.FormatUint(uint64(newTestAssessment(t, b)), 10)
+	at := time.Now().Add(48 * time.Hour).Truncate(time.Hour)
+	schedule := func(start time.Time, minutes int) (*PhysioCall, error) {
+		return SchedulePhysioCall(id, "scheduled", &start, minutes)
+	}
+
+	first, err := schedule(at, 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := schedule(at.Add(15*time.Minute), 30); !errors.Is(err, ErrCallOverlap) {
+		t.Fatalf("overlapping call = %v, want ErrCallOverlap", err)
+	}
+	if _, err := schedule(at.Add(-15*time.Minute), 60); !errors.Is(err, ErrCallOverlap) {
+		t.Fatalf("call around the first one = %v, want ErrCallOverlap", err)
+	}
+	if _, err := schedule(at.Add(30*time.Minute), 30); err != nil {
+		t.Fatalf("call right after the first one: %v", err)
+	}
+
+	// A cancelled call frees its slot
+	firstID, err := strconv.ParseUint(first.CallID, 10, 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := UpdatePhysioCallStatus(&Principal{UserID: 1, Role: models.RolePatient}, uint32(firstID), models.CallCancelled, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := schedule(at.Add(15*time.Minute), 15); err != nil {
+		t.Fatalf("call in the cancelled slot: %v", err)
+	}
+}
+
+func TestOverlapsOpenCall(t *testing.T) {
+	at := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	call := func(status models.CallStatus, start time.Time) db.PhysioCall {
+		return db.PhysioCall{CallStatus: status.String(), ScheduledTime: &start, DurationMinutes: 30}
+	}
+	calls := []db.PhysioCall{
+		call(models.CallScheduled, at),
+		call(models.CallCancelled, at.Add(time.Hour)),
+		call(models.CallInProgress, at.Add(2*time.Hour)),
+		{CallStatus: models.CallInProgress.String(), DurationMinutes: 30}, // immediate
+	}
+	tests := []struct {
+		name       string
+		start, end time.Time
+		want       bool
+	}{
+		{"same slot", at, at.Add(30 * time.Minute), true},
+		{"overlapping the end", at.Add(15 * time.Minute), at.Add(45 * time.Minute), true},
+		{"right after", at.Add(30 * time.Minute), at.Add(time.Hour), false},
+		{"right before", at.Add(-30 * time.Minute), at, false},
+		{"cancelled call", at.Add(time.Hour), at.Add(90 * time.Minute), false},
+		{"call in progress", at.Add(2 * time.Hour), at.Add(150 * time.Minute), true},
+	}
+	for _, tt := range tests {
+		if got := overlapsOpenCall(calls, tt.start, tt.end); got != tt.want {
+			t.Errorf("%s: overlapsOpenCall = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,306 @@
+package services
+
+import (
+	"ai-bot-deecogs/internal/models"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// MediaPipe Pose landmark indices. Every left landmark from 11 on is odd and its right
+// counterpart is the next index.
+const (
+	poseLandmarkCount = 33
+
+	lmLeftEar       = 7
+	lmRightEar      = 8
+	lmLeftShoulder  = 11
+	lmRightShoulder = 12
+	lmLeftElbow     = 13
+	lmLeftWrist     = 15
+	lmLeftIndex     = 19
+	lmLeftHip       = 23
+	lmRightHip      = 24
+	lmLeftKnee      = 25
+	lmLeftAnkle     = 27
+	lmLeftFootIndex = 31
+)
+
+// PoseLandmark is one keypoint of a frame, x and y are normalised to the image size
+type PoseLandmark struct {
+	X          float64 `json:"x"`
+	Y          float64 `json:"y"`
+	Z          float64 `json:"z"`
+	Visibility float64 `json:"visibility"`
+}
+
+// PoseFrame is the 33 landmarks detected at T milliseconds into the recording
+type PoseFrame struct {
+	T         float64        `json:"t"`
+	Landmarks []PoseLandmark `json:"landmarks"`
+}
+
+// AngleSample is a joint angle in degrees at T milliseconds
+type AngleSample struct {
+	T     float64 `json:"t"`
+	Angle float64 `json:"angle"`
+}
+
+// PoseOptions tunes the angle pipeline
+type PoseOptions struct {
+	MinVisibility    float64 // frames where a landmark the angle needs is less visible are rejected
+	OutlierWindow    int     // half width of the Hampel filter window, in samples
+	OutlierThreshold float64 // deviations from the window median, in scaled MADs, that make an outlier
+	MinOutlierDelta  float64 // degrees, smaller deviations are never outliers
+	SmoothingWindow  int     // width of the centred moving average, in samples
+	MinFrames        int     // usable frames below which no ROM is derived
+	MinRepRange      float64 // degrees, a smaller range counts no repetitions
+}
+
+// defaultPoseOptions suit a 15 to 30 fps MediaPipe stream
+var defaultPoseOptions = PoseOptions{
+	MinVisibility:    0.5,
+	OutlierWindow:    3,
+	OutlierThreshold: 3,
+	MinOutlierDelta:  5,
+	SmoothingWindow:  5,
+	MinFrames:        10,
+	MinRepRange:      10,
+}
+
+// poseAngleResult is the angle series of one recording and the ROM derived from it
+type poseAngleResult struct {
+	FramesTotal   int           `json:"framesTotal"`
+	FramesUsed    int           `json:"framesUsed"`
+	LowVisibility int           `json:"lowVisibility"` // frames rejected for visibility
+	Outliers      int           `json:"outliers"`      // samples dropped by the outlier filter
+	MinAngle      float64       `json:"minAngle"`
+	MaxAngle      float64       `json:"maxAngle"`
+	PeakAngle     float64       `json:"peakAngle"`
+	Repetitions   int           `json:"repetitions"`
+	Series        []AngleSample `json:"series"` // smoothed
+}
+
+type point struct{ x, y float64 }
+
+// angleDef measures one movement of one joint from the landmarks it lists
+type angleDef struct {
+	lateral   bool  // the landmarks are those of the measured side
+	landmarks []int // left side indices for lateral joints
+	angle     func(p []point) float64
+}
+
+// poseAngles are the joint movements that can be measured from 2D landmarks. The plane of
+// the movement comes from the camera position, so flexion and abduction share a definition.
+var poseAngles = func() map[string]map[models.Movement]angleDef {
+	// shoulder: angle between the trunk and the upper arm, 0 with the arm at the side
+	shoulder := angleDef{lateral: true, landmarks: []int{lmLeftElbow, lmLeftShoulder, lmLeftHip}, angle: vertexAngle}
+	elbow := angleDef{lateral: true, landmarks: []int{lmLeftShoulder, lmLeftElbow, lmLeftWrist}, angle: bendAngle}
+	wrist := angleDef{lateral: true, landmarks: []int{lmLeftElbow, lmLeftWrist, lmLeftIndex}, angle: bendAngle}
+	hip := angleDef{lateral: true, landmarks: []int{lmLeftShoulder, lmLeftHip, lmLeftKnee}, angle: bendAngle}
+	knee := angleDef{lateral: true, landmarks: []int{lmLeftHip, lmLeftKnee, lmLeftAnkle}, angle: bendAngle}
+	// ankle: deviation of the foot from a right angle with the shank
+	ankle := angleDef{lateral: true, landmarks: []int{lmLeftKnee, lmLeftAnkle, lmLeftFootIndex},
+		angle: func(p []point) float64 { return math.Abs(vertexAngle(p) - 90) }}
+	// lower back: inclination of the trunk from vertical
+	trunk := angleDef{landmarks: []int{lmLeftShoulder, lmRightShoulder, lmLeftHip, lmRightHip},
+		angle: func(p []point) float64 {
+			shoulders, hips := midpoint(p[0], p[1]), midpoint(p[2], p[3])
+			return between(point{shoulders.x - hips.x, shoulders.y - hips.y}, point{0, -1})
+		}}
+	// neck: angle between the head and the trunk
+	neck := angleDef{landmarks: []int{lmLeftEar, lmRightEar, lmLeftShoulder, lmRightShoulder, lmLeftHip, lmRightHip},
+		angle: func(p []point) float64 {
+			ears, shoulders, hips := midpoint(p[0], p[1]), midpoint(p[2], p[3]), midpoint(p[4], p[5])
+			return between(point{ears.x - shoulders.x, ears.y - shoulders.y}, point{shoulders.x - hips.x, shoulders.y - hips.y})
+		}}
+
+	return map[string]map[models.Movement]angleDef{
+		"shoulder": {
+			models.MovementFlexion: shoulder, models.MovementExtension: shoulder,
+			models.MovementAbduction: shoulder, models.MovementAdduction: shoulder,
+		},
+		"elbow": {models.MovementFlexion: elbow},
+		"wrist": {models.MovementFlexion: wrist, models.MovementExtension: wrist},
+		"hip": {
+			models.MovementFlexion: hip, models.MovementExtension: hip,
+			models.MovementAbduction: hip, models.MovementAdduction: hip,
+		},
+		"knee":  {models.MovementFlexion: knee},
+		"ankle": {models.MovementFlexion: ankle, models.MovementExtension: ankle},
+		"lower_back": {
+			models.MovementFlexion: trunk, models.MovementExtension: trunk, models.MovementLateralFlexion: trunk,
+		},
+		"neck": {
+			models.MovementFlexion: neck, models.MovementExtension: neck, models.MovementLateralFlexion: neck,
+		},
+	}
+}()
+
+// vertexAngle is the angle at p[1] between p[0] and p[2]
+func vertexAngle(p []point) float64 {
+	return between(point{p[0].x - p[1].x, p[0].y - p[1].y}, point{p[2].x - p[1].x, p[2].y - p[1].y})
+}
+
+// bendAngle is how far the segments meeting at p[1] are from a straight line, 0 when straight
+func bendAngle(p []point) float64 {
+	return 180 - vertexAngle(p)
+}
+
+// between returns the angle between two vectors in degrees
+func between(a, b point) float64 {
+	cross := a.x*b.y - a.y*b.x
+	dot := a.x*b.x + a.y*b.y
+	return math.Abs(math.Atan2(cross, dot)) * 180 / math.Pi
+}
+
+func midpoint(a, b point) point {
+	return point{(a.x + b.x) / 2, (a.y + b.y) / 2}
+}
+
+// computePoseAngles turns a landmark stream into the ROM of one movement. Frames missing a
+// landmark visibility are rejected, outliers are dropped with a Hampel filter and the rest
+// is smoothed with a moving average. aspect is the image width over its height.
+func computePoseAngles(joint string, movement models.Movement, side models.Side, frames []PoseFrame, aspect float64, opts PoseOptions) (*poseAngleResult, error) {
+	def, ok := poseAngles[models.JointKey(joint)][movement]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s %s cannot be measured from pose landmarks", ErrInvalidLandmarks, joint, movement)
+	}
+	indices := def.landmarks
+	if def.lateral {
+		switch side {
+		case models.SideLeft:
+		case models.SideRight:
+			indices = make([]int, len(def.landmarks))
+			for i, idx := range def.landmarks {
+				indices[i] = idx + 1
+			}
+		default:
+			return nil, fmt.Errorf("%w: side must be left or right for the %s", ErrInvalidLandmarks, joint)
+		}
+	}
+
+	result := &poseAngleResult{FramesTotal: len(frames)}
+	raw := make([]AngleSample, 0, len(frames))
+	points := make([]point, len(indices))
+	for i, frame := range frames {
+		if len(frame.Landmarks) != poseLandmarkCount {
+			return nil, fmt.Errorf("%w: frame %d has %d landmarks, %d expected", ErrInvalidLandmarks, i, len(frame.Landmarks), poseLandmarkCount)
+		}
+		if i > 0 && frame.T < frames[i-1].T {
+			return nil, fmt.Errorf("%w: frame %d is older than the frame before it", ErrInvalidLandmarks, i)
+		}
+		visible := true
+		for j, idx := range indices {
+			lm := frame.Landmarks[idx]
+			if lm.Visibility < opts.MinVisibility {
+				visible = false
+				break
+			}
+			points[j] = point{lm.X * aspect, lm.Y}
+		}
+		if !visible {
+			result.LowVisibility++
+			continue
+		}
+		raw = append(raw, AngleSample{T: frame.T, Angle: def.angle(points)})
+	}
+
+	kept := hampelFilter(raw, opts)
+	result.Outliers = len(raw) - len(kept)
+	result.FramesUsed = len(kept)
+	if len(kept) < opts.MinFrames {
+		return nil, fmt.Errorf("%w: %d of %d frames are usable, at least %d are needed", ErrInvalidLandmarks, len(kept), len(frames), opts.MinFrames)
+	}
+
+	result.Series = movingAverage(kept, opts.SmoothingWindow)
+	result.MinAngle, result.MaxAngle = result.Series[0].Angle, result.Series[0].Angle
+	for _, s := range result.Series {
+		result.MinAngle = math.Min(result.MinAngle, s.Angle)
+		result.MaxAngle = math.Max(result.MaxAngle, s.Angle)
+	}
+	result.PeakAngle = result.MaxAngle
+	result.Repetitions = countRepetitions(result.Series, result.MinAngle, result.MaxAngle, opts.MinRepRange)
+	return result, nil
+}
+
+// hampelFilter drops the samples further from their window median than the threshold
+func hampelFilter(samples []AngleSample, opts PoseOptions) []AngleSample {
+	if opts.OutlierWindow <= 0 {
+		return samples
+	}
+	kept := make([]AngleSample, 0, len(samples))
+	window := make([]float64, 0, 2*opts.OutlierWindow+1)
+	for i, s := range samples {
+		window = window[:0]
+		for j := max(0, i-opts.OutlierWindow); j <= min(len(samples)-1, i+opts.OutlierWindow); j++ {
+			window = append(window, samples[j].Angle)
+		}
+		median := medianOf(window)
+		for j := range window {
+			window[j] = math.Abs(window[j] - median)
+		}
+		limit := math.Max(opts.OutlierThreshold*1.4826*medianOf(window), opts.MinOutlierDelta)
+		if math.Abs(s.Angle-median) <= limit {
+			kept = append(kept, s)
+		}
+	}
+	return kept
+}
+
+// movingAverage smooths the angles with a centred window that narrows at the edges
+func movingAverage(samples []AngleSample, width int) []AngleSample {
+	half := width / 2
+	out := make([]AngleSample, len(samples))
+	for i := range samples {
+		lo, hi := max(0, i-half), min(len(samples)-1, i+half)
+		var sum float64
+		for j := lo; j <= hi; j++ {
+			sum += samples[j].Angle
+		}
+		out[i] = AngleSample{T: samples[i].T, Angle: round1(sum / float64(hi-lo+1))}
+	}
+	return out
+}
+
+// countRepetitions counts the rises from the lower to the upper third of the range
+func countRepetitions(series []AngleSample, lo, hi, minRange float64) int {
+	if hi-lo < minRange {
+		return 0
+	}
+	low, high := lo+(hi-lo)/3, hi-(hi-lo)/3
+	reps, armed := 0, false
+	for _, s := range series {
+		switch {
+		case s.Angle <= low:
+			armed = true
+		case s.Angle >= high && armed:
+			reps++
+			armed = false
+		}
+	}
+	return reps
+}
+
+// angleAt returns the smoothed angle of the sample closest to t
+func angleAt(series []AngleSample, t float64) float64 {
+	i := sort.Search(len(series), func(i int) bool { return series[i].T >= t })
+	if i == len(series) || (i > 0 && t-series[i-1].T < series[i].T-t) {
+		i--
+	}
+	return series[i].Angle
+}
+
+// medianOf sorts values in place and returns their median
+func medianOf(values []float64) float64 {
+	sort.Float64s(values)
+	n := len(values)
+	if n%2 == 1 {
+		return values[n/2]
+	}
+	return (values[n/2-1] + values[n/2]) / 2
+}
+
+func round1(v float64) float64 {
+	return math.Round(v*10) / 10
+}
@@ -0,0 +1,456 @@
+package services
+
+import (
+	"ai-bot-deecogs/internal/db"
+	"ai-bot-deecogs/internal/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrProgressNotFound = errors.New("the user has no assessment of this anatomy")
+
+// Rates of change, per week, below which a series is stable
+const (
+	romStableRate     = 1    // degrees or percent of normal
+	painStableRate    = 0.25 // points on the 0 to 10 scale
+	symptomStableRate = 0.25 // symptoms
+)
+
+// Symptom statuses, against the latest analysis
+const (
+	SymptomNew      = "new"      // only reported in the latest analysis
+	SymptomOngoing  = "ongoing"  // reported in the latest analysis and before
+	SymptomResolved = "resolved" // not reported in the latest analysis anymore
+)
+
+// UserProgress follows the recovery of one anatomy across all the assessments of a user
+type UserProgress struct {
+	UserID      uint32               `json:"userId"`
+	AnatomyID   uint32               `json:"anatomyId"`
+	Joint       string               `json:"joint"`
+	Assessments []ProgressAssessment `json:"assessments"` // oldest first
+	ROM         []ROMProgress        `json:"rom"`
+	Pain        PainProgress         `json:"pain"`
+	Symptoms    SymptomProgress      `json:"symptoms"`
+}
+
+// ProgressAssessment is an assessment a progress report is built from
+type ProgressAssessment struct {
+	AssessmentID   uint32    `json:"assessmentId"`
+	AssessmentType string    `json:"assessmentType"`
+	Status         string    `json:"status"`
+	StartTime      time.Time `json:"startTime"`
+}
+
+// Trend is the direction and rate of change of a series, from a least squares fit over time
+type Trend struct {
+	Direction   models.TrendDirection `json:"direction"`
+	RatePerWeek *float64              `json:"ratePerWeek,omitempty"`
+	Change      *float64              `json:"change,omitempty"` // last value minus the first
+	Points      int                   `json:"points"`
+}
+
+// ROMProgress is the series of one movement. Movement is empty for submissions that only
+// carried the legacy rangeOfMotion.
+type ROMProgress struct {
+	AnatomyID            uint32             `json:"anatomyId"`
+	Joint                string             `json:"joint"`
+	Movement             models.Movement    `json:"movement,omitempty"`
+	Side                 models.Side        `json:"side,omitempty"`
+	Mode                 models.ROMMode     `json:"mode,omitempty"`
+	Points               []ROMProgressPoint `json:"points"`
+	ArcTrend             Trend              `json:"arcTrend"`
+	PercentOfNormalTrend *Trend             `json:"percentOfNormalTrend,omitempty"` // when a normal range is known
+}
+
+// ROMProgressPoint is the latest ROM of a movement in one assessment
+type ROMProgressPoint struct {
+	AssessmentID    uint32             `json:"assessmentId"`
+	At              time.Time          `json:"at"`
+	MinAngle        float64            `json:"minAngle"`
+	MaxAngle        float64            `json:"maxAngle"`
+	Arc             float64            `json:"arc"` // maxAngle minus minAngle
+	PercentOfNormal *float64           `json:"percentOfNormal,omitempty"`
+	Severity        models.ROMSeverity `json:"severity,omitempty"`
+}
+
+// PainProgress is the pain rating the patient gave in each questionnaire, 0 to 10
+type PainProgress struct {
+	Points []ProgressPoint `json:"points"`
+	Trend  Trend           `json:"trend"`
+}
+
+// ProgressPoint is one value of a series
+type ProgressPoint struct {
+	AssessmentID uint32    `json:"assessmentId"`
+	At           time.Time `json:"at"`
+	Value        float64   `json:"value"`
+}
+
+// SymptomProgress is the symptoms the dashboard analysis found in each assessment
+type SymptomProgress struct {
+	Points     []SymptomPoint   `json:"points"`
+	History    []SymptomHistory `json:"history"`
+	CountTrend Trend            `json:"countTrend"`
+}
+
+// SymptomPoint is the symptoms of one analysis
+type SymptomPoint struct {
+	AssessmentID uint32    `json:"assessmentId"`
+	At           time.Time `json:"at"`
+	Symptoms     []string  `json:"symptoms"`
+}
+
+// SymptomHistory is when a symptom was reported
+type SymptomHistory struct {
+	Symptom     string    `json:"symptom"`
+	FirstSeen   time.Time `json:"firstSeen"`
+	LastSeen    time.Time `json:"lastSeen"`
+	Occurrences int       `json:"occurrences"`
+	Status      string    `json:"status"`
+}
+
+// GetUserProgress returns the progress of every anatomy the user was assessed on
+func GetUserProgress(userID uint32) ([]UserProgress, error) {
+	groups, err := assessmentsByAnatomy(userID)
+	if err != nil {
+		return nil, err
+	}
+	anatomyIDs := make([]uint32, 0, len(groups))
+	for anatomyID := range groups {
+		anatomyIDs = append(anatomyIDs, anatomyID)
+	}
+	sort.Slice(anatomyIDs, func(i, j int) bool { return anatomyIDs[i] < anatomyIDs[j] })
+
+	list := make([]UserProgress, 0, len(groups))
+	for _, anatomyID := range anatomyIDs {
+		progress, err := buildProgress(userID, anatomyID, groups[anatomyID])
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *progress)
+	}
+	return list, nil
+}
+
+// GetUserAnatomyProgress returns the progress of one anatomy of a user
+func GetUserAnatomyProgress(userID, anatomyID uint32) (*UserProgress, error) {
+	groups, err := assessmentsByAnatomy(userID)
+	if err != nil {
+		return nil, err
+	}
+	assessments, ok := groups[anatomyID]
+	if !ok {
+		return nil, ErrProgressNotFound
+	}
+	return buildProgress(userID, anatomyID, assessments)
+}
+
+// assessmentsByAnatomy groups the assessments of a user by anatomy, oldest first
+func assessmentsByAnatomy(userID uint32) (map[uint32][]db.Assessment, error) {
+	exists, err := store.Users.Exists(context.Background(), userID)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrUserNotFound
+	}
+	rows, err := store.Assessments.ListByUser(context.Background(), userID)
+	if err != nil {
+		return nil, err
+	}
+	groups := make(map[uint32][]db.Assessment)
+	for i := len(rows) - 1; i >= 0; i-- {
+		groups[rows[i].AnatomyID] = append(groups[rows[i].AnatomyID], rows[i])
+	}
+	return groups, nil
+}
+
+// romSeriesKey identifies the series a measurement belongs to
+type romSeriesKey struct {
+	anatomyID uint32
+	movement  models.Movement
+	side      models.Side
+	mode      models.ROMMode
+}
+
+// buildProgress reads the latest ROM, questionnaire and analysis of each assessment
+func buildProgress(userID, anatomyID uint32, assessments []db.Assessment) (*UserProgress, error) {
+	ctx := context.Background()
+	progress := &UserProgress{
+		UserID:      userID,
+		AnatomyID:   anatomyID,
+		Assessments: make([]ProgressAssessment, 0, len(assessments)),
+		ROM:         []ROMProgress{},
+		Pain:        PainProgress{Points: []ProgressPoint{}},
+		Symptoms:    SymptomProgress{Points: []SymptomPoint{}, History: []SymptomHistory{}},
+	}
+	if anatomy, err := store.Anatomy.Get(ctx, anatomyID); err == nil {
+		progress.Joint = anatomy.Name
+	} else if !errors.Is(err, db.ErrNotFound) {
+		return nil, err
+	}
+
+	var romKeys []romSeriesKey
+	romSeries := make(map[romSeriesKey]*ROMProgress)
+	for _, a := range assessments {
+		progress.Assessments = append(progress.Assessments, ProgressAssessment{
+			AssessmentID:   a.AssessmentID,
+			AssessmentType: a.AssessmentType,
+			Status:         a.Status,
+			StartTime:      a.StartTime,
+		})
+
+		rom, err := store.ROM.Latest(ctx, a.AssessmentID)
+		if err != nil && !errors.Is(err, db.ErrNotFound) {
+			return nil, err
+		}
+		if rom != nil {
+			for _, m := range progressMeasurements(rom, a, progress.Joint) {
+				key := romSeriesKey{m.AnatomyID, m.Movement, m.Side, m.Mode}
+				series, ok := romSeries[key]
+				if !ok {
+					series = &ROMProgress{AnatomyID: m.AnatomyID, Joint: m.Joint, Movement: m.Movement, Side: m.Side, Mode: m.Mode}
+					romSeries[key] = series
+					romKeys = append(romKeys, key)
+				}
+				point := ROMProgressPoint{
+					AssessmentID: a.AssessmentID,
+					At:           rom.CreatedAt,
+					MinAngle:     *m.MinAngle,
+					MaxAngle:     *m.MaxAngle,
+					Arc:          round1(*m.MaxAngle - *m.MinAngle),
+				}
+				if m.Score != nil {
+					point.PercentOfNormal = &m.Score.PercentOfNormal
+					point.Severity = m.Score.Severity
+				}
+				series.Points = append(series.Points, point)
+			}
+		}
+
+		questionnaire, err := store.Questionnaires.Latest(ctx, a.AssessmentID)
+		if err != nil && !errors.Is(err, db.ErrNotFound) {
+			return nil, err
+		}
+		if questionnaire != nil {
+			if score, ok := painScoreOf(questionnaire.ChatHistory); ok {
+				progress.Pain.Points = append(progress.Pain.Points, ProgressPoint{AssessmentID: a.AssessmentID, At: questionnaire.CreatedAt, Value: score})
+			}
+		}
+
+		analysis, err := store.AIAnalysis.Latest(ctx, a.AssessmentID)
+		if err != nil && !errors.Is(err, db.ErrNotFound) {
+			return nil, err
+		}
+		if analysis != nil {
+			var result AIAnalysisResult
+			if err := json.Unmarshal(analysis.AnalysedResults, &result); err != nil {
+				log.Printf("Warning: Failed to parse the analysis of assessment %d: %v", a.AssessmentID, err)
+			} else {
+				symptoms := result.Symptoms
+				if symptoms == nil {
+					symptoms = []string{}
+				}
+				progress.Symptoms.Points = append(progress.Symptoms.Points, SymptomPoint{AssessmentID: a.AssessmentID, At: analysis.CreatedAt, Symptoms: symptoms})
+			}
+		}
+	}
+
+	for _, key := range romKeys {
+		series := romSeries[key]
+		sortROMPoints(series.Points)
+		arcs := make([]ProgressPoint, 0, len(series.Points))
+		var percents []ProgressPoint
+		for _, p := range series.Points {
+			arcs = append(arcs, ProgressPoint{At: p.At, Value: p.Arc})
+			if p.PercentOfNormal != nil {
+				percents = append(percents, ProgressPoint{At: p.At, Value: *p.PercentOfNormal})
+			}
+		}
+		series.ArcTrend = trendOf(arcs, true, romStableRate)
+		if len(percents) > 0 {
+			trend := trendOf(percents, true, romStableRate)
+			series.PercentOfNormalTrend = &trend
+		}
+		progress.ROM = append(progress.ROM, *series)
+	}
+
+	sortProgressPoints(progress.Pain.Points)
+	progress.Pain.Trend = trendOf(progress.Pain.Points, false, painStableRate)
+
+	sort.SliceStable(progress.Symptoms.Points, func(i, j int) bool {
+		return progress.Symptoms.Points[i].At.Before(progress.Symptoms.Points[j].At)
+	})
+	counts := make([]ProgressPoint, 0, len(progress.Symptoms.Points))
+	for _, p := range progress.Symptoms.Points {
+		counts = append(counts, ProgressPoint{At: p.At, Value: float64(len(p.Symptoms))})
+	}
+	progress.Symptoms.CountTrend = trendOf(counts, false, symptomStableRate)
+	progress.Symptoms.History = symptomHistory(progress.Symptoms.Points)
+	return progress, nil
+}
+
+// progressMeasurements returns the scored measurements of a ROM analysis. A legacy submission
+// becomes one measurement of the assessment anatomy without a movement.
+func progressMeasurements(rom *db.ROMAnalysis, assessment db.Assessment, joint string) []models.ROMMeasurement {
+	if len(rom.Measurements) > 0 {
+		measurements := toROMMeasurements(rom.Measurements)
+		scoreROMMeasurements(assessment.UserID, rom.CreatedAt, measurements)
+		return measurements
+	}
+
+	var payload ROMRequest
+	if err := json.Unmarshal(rom.PoseModelData, &payload); err != nil || payload.RangeOfMotion == nil {
+		return nil
+	}
+	minAngle, err := payload.RangeOfMotion.Minimum.Float64()
+	if err != nil {
+		return nil
+	}
+	maxAngle, err := payload.RangeOfMotion.Maximum.Float64()
+	if err != nil || minAngle > maxAngle {
+		return nil
+	}
+	return []models.ROMMeasurement{{AnatomyID: assessment.AnatomyID, Joint: joint, MinAngle: &minAngle, MaxAngle: &maxAngle}}
+}
+
+var (
+	painQuestion = regexp.MustCompile(`(?i)pain.*(0 ?(to|-) ?10|scale)|(0 ?(to|-) ?10|scale).*pain`)
+	painScale    = regexp.MustCompile(`(?i)\s*(/|out of)\s*10\b|\b0\s*(-|to)\s*10\b`)
+	painRange    = regexp.MustCompile(`(?i)(\d+(?:\.\d+)?)\s*(?:-|to)\s*(\d+(?:\.\d+)?)`)
+	painNumber   = regexp.MustCompile(`\d+(\.\d+)?`)
+)
+
+// painScoreOf finds the first pain rating of a questionnaire. A question is stored with the
+// answer to the one before it, so the answer to a question is the user text of the next entry.
+func painScoreOf(chatHistoryRaw json.RawMessage) (float64, bool) {
+	history, err := decodeQuestionHistory(chatHistoryRaw)
+	if err != nil {
+		return 0, false
+	}
+	for i := 0; i+1 < len(history); i++ {
+		if !painQuestion.MatchString(history[i].Assistant) {
+			continue
+		}
+		if score, ok := painRating(history[i+1].User); ok && score >= 0 && score <= 10 {
+			return score, true
+		}
+	}
+	return 0, false
+}
+
+// painRating reads a rating from an answer. An explicit range such as "4-6" or "4 to 6" counts
+// as its middle, any other answer as its first number. The scale, "/10", "out of 10" or "0-10",
+// is not part of the rating.
+func painRating(answer string) (float64, bool) {
+	answer = painScale.ReplaceAllString(answer, " ")
+	if m := painRange.FindStringSubmatch(answer); m != nil {
+		low, _ := strconv.ParseFloat(m[1], 64)
+		high, _ := strconv.ParseFloat(m[2], 64)
+		return (low + high) / 2, true
+	}
+	number := painNumber.FindString(answer)
+	if number == "" {
+		return 0, false
+	}
+	score, _ := strconv.ParseFloat(number, 64)
+	return score, true
+}
+
+// symptomHistory follows each symptom across the analyses, symptoms are matched ignoring case
+func symptomHistory(points []SymptomPoint) []SymptomHistory {
+	index := make(map[string]int)
+	var history []SymptomHistory
+	for _, p := range points {
+		seen := make(map[string]bool)
+		for _, symptom := range p.Symptoms {
+			key := strings.ToLower(strings.TrimSpace(symptom))
+			if key == "" || seen[key] {
+				continue
+			}
+			seen[key] = true
+			i, ok := index[key]
+			if !ok {
+				i = len(history)
+				index[key] = i
+				history = append(history, SymptomHistory{Symptom: symptom, FirstSeen: p.At})
+			}
+			history[i].LastSeen = p.At
+			history[i].Occurrences++
+		}
+	}
+	if len(points) == 0 {
+		return []SymptomHistory{}
+	}
+
+	latest := points[len(points)-1].At
+	for i := range history {
+		switch {
+		case !history[i].LastSeen.Equal(latest):
+			history[i].Status = SymptomResolved
+		case history[i].FirstSeen.Equal(latest):
+			history[i].Status = SymptomNew
+		default:
+			history[i].Status = SymptomOngoing
+		}
+	}
+	return history
+}
+
+// trendOf fits a line to the series and grades its slope. A series needs two points at
+// different times to have a trend.
+func trendOf(points []ProgressPoint, higherIsBetter bool, stableRate float64) Trend {
+	trend := Trend{Direction: models.TrendInsufficient, Points: len(points)}
+	if len(points) < 2 {
+		return trend
+	}
+
+	// Least squares slope with time in weeks since the first point
+	origin := points[0].At
+	var meanX, meanY float64
+	for _, p := range points {
+		meanX += p.At.Sub(origin).Hours() / (24 * 7)
+		meanY += p.Value
+	}
+	n := float64(len(points))
+	meanX, meanY = meanX/n, meanY/n
+	var num, den float64
+	for _, p := range points {
+		dx := p.At.Sub(origin).Hours()/(24*7) - meanX
+		num += dx * (p.Value - meanY)
+		den += dx * dx
+	}
+	if den == 0 {
+		return trend
+	}
+
+	rate := math.Round(num/den*100) / 100
+	change := math.Round((points[len(points)-1].Value-points[0].Value)*100) / 100
+	trend.RatePerWeek, trend.Change = &rate, &change
+	switch {
+	case math.Abs(rate) < stableRate:
+		trend.Direction = models.TrendStable
+	case (rate > 0) == higherIsBetter:
+		trend.Direction = models.TrendImproving
+	default:
+		trend.Direction = models.TrendWorsening
+	}
+	return trend
+}
+
+func sortProgressPoints(points []ProgressPoint) {
+	sort.SliceStable(points, func(i, j int) bool { return points[i].At.Before(points[j].At) })
+}
+
+func sortROMPoints(points []ROMProgressPoint) {
+	sort.SliceStable(points, func(i, j int) bool { return points[i].At.Before(points[j].At) })
+}
@@ -0,0 +1,164 @@
+package services
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"ai-bot-deecogs/internal/models"
+)
+
+func TestPainRating(t *testing.T) {
+	tests := []struct {
+		answer string
+		want   float64
+		ok     bool
+	}{
+		{"7", 7, true},
+		{"7 out of 10", 7, true},
+		{"7/10", 7, true},
+		{"7 / 10", 7, true},
+		{"about a 6 on a 0-10 scale", 6, true},
+		{"3 now, 8 yesterday", 3, true},
+		{"4-6", 5, true},
+		{"4 - 6", 5, true},
+		{"4 to 6", 5, true},
+		{"4-6/10", 5, true},
+		{"2.5", 2.5, true},
+		{"7.5 out of 10", 7.5, true},
+		{"It hurts a lot", 0, false},
+		{"", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := painRating(tt.answer)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("painRating(%q) = %v, %v, want %v, %v", tt.answer, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestPainScoreOf(t *testing.T) {
+	tests := []struct {
+		name    string
+		history []QuestionMessage
+		want    float64
+		ok      bool
+	}{
+		{
+			name: "answer is the user text of the next entry",
+			history: []QuestionMessage{
+				{User: "Knee", Assistant: "On a scale of 0 to 10, how would you rate your pain?"},
+				{User: "7 out of 10", Assistant: "Does it swell?"},
+				{User: "3"},
+			},
+			want: 7, ok: true,
+		},
+		{
+			name: "first answer that is a rating",
+			history: []QuestionMessage{
+				{User: "Knee", Assistant: "Rate your pain from 0-10"},
+				{User: "I am not sure", Assistant: "On a pain scale of 0 to 10, where would you put it?"},
+				{User: "4 to 6"},
+			},
+			want: 5, ok: true,
+		},
+		{
+			name: "out of range",
+			history: []QuestionMessage{
+				{User: "Knee", Assistant: "Rate your pain from 0-10"},
+				{User: "15"},
+			},
+		},
+		{
+			name: "no pain question",
+			history: []QuestionMessage{
+				{User: "Knee", Assistant: "How many days a week do you exercise?"},
+				{User: "3"},
+			},
+		},
+		{
+			name: "unanswered",
+			history: []QuestionMessage{
+				{User: "Knee", Assistant: "Rate your pain from 0-10"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw, err := json.Marshal(tt.history)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, ok := painScoreOf(raw)
+			if ok != tt.ok || got != tt.want {
+				t.Errorf("painScoreOf = %v, %v, want %v, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestTrendOf(t *testing.T) {
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	week := 7 * 24 * time.Hour
+	series := func(values ...float64) []ProgressPoint {
+		points := make([]ProgressPoint, len(values))
+		for i, v := range values {
+			points[i] = ProgressPoint{At: start.Add(time.Duration(i) * week), Value: v}
+		}
+		return points
+	}
+
+	tests := []struct {
+		name           string
+		points         []ProgressPoint
+		higherIsBetter bool
+		want           models.TrendDirection
+		wantRate       float64
+	}{
+		{"single point", series(5), false, models.TrendInsufficient, 0},
+		{"same time", []ProgressPoint{{At: start, Value: 5}, {At: start, Value: 3}}, false, models.TrendInsufficient, 0},
+		{"pain going down", series(8, 6, 4), false, models.TrendImproving, -2},
+		{"pain going up", series(2, 3, 4), false, models.TrendWorsening, 1},
+		{"arc going up", series(90, 100, 110), true, models.TrendImproving, 10},
+		{"flat", series(5, 5.2, 5), false, models.TrendStable, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trend := trendOf(tt.points, tt.higherIsBetter, 0.5)
+			if trend.Direction != tt.want || trend.Points != len(tt.points) {
+				t.Fatalf("trend = %s over %d points, want %s", trend.Direction, trend.Points, tt.want)
+			}
+			if tt.want == models.TrendInsufficient {
+				if trend.RatePerWeek != nil {
+					t.Errorf("rate = %v, want none without a trend", *trend.RatePerWeek)
+				}
+				return
+			}
+			if *trend.RatePerWeek != tt.wantRate {
+				t.Errorf("rate = %v per week, want %v", *trend.RatePerWeek, tt.wantRate)
+			}
+		})
+	}
+}
+
+func TestSymptomHistory(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 3, d, 0, 0, 0, 0, time.UTC) }
+	history := symptomHistory([]SymptomPoint{
+		{At: day(1), Symptoms: []string{"Swelling", "Stiffness"}},
+		{At: day(8), Symptoms: []string{"stiffness ", "Clicking", "Clicking"}},
+	})
+
+	want := []SymptomHistory{
+		{Symptom: "Swelling", FirstSeen: day(1), LastSeen: day(1), Occurrences: 1, Status: SymptomResolved},
+		{Symptom: "Stiffness", FirstSeen: day(1), LastSeen: day(8), Occurrences: 2, Status: SymptomOngoing},
+		{Symptom: "Clicking", FirstSeen: day(8), LastSeen: day(8), Occurrences: 1, Status: SymptomNew},
+	}
+	if len(history) != len(want) {
+		t.Fatalf("history = %+v, want %+v", history, want)
+	}
+	for i := range want {
+		if history[i] != want[i] {
+			t.Errorf("history[%d] = %+v, want %+v", i, history[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,466 @@
+package services
+
+import (
+	"ai-bot-deecogs/internal/db"
+	"ai-bot-deecogs/internal/models"
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+	"unicode"
+)
+
+//go:embed rules/red_flags.json
+var defaultRedFlagRules []byte
+
+// Where a red-flag rule found its evidence
+const (
+	RedFlagSourceQuestionnaire = "questionnaire"
+	RedFlagSourceChat          = "chat"
+	RedFlagSourceDiagnosis     = "diagnosis"
+)
+
+// A negation cancels a phrase starting at most this many words after it in the same clause
+const negationWindow = 3
+
+// Evidence longer than this is cut
+const maxEvidenceRunes = 300
+
+// RedFlagRules is a versioned rules file. Phrases match whole words regardless of case and
+// punctuation: a word ending in * matches the words it starts and a lone * any one word.
+type RedFlagRules struct {
+	Version     string        `json:"version"`
+	Negations   []string      `json:"negations"`   // words cancelling a phrase that follows them closely
+	Affirmative []string      `json:"affirmative"` // answers starting with one of these agree with the question
+	Rules       []RedFlagRule `json:"rules"`
+}
+
+// RedFlagRule describes one urgent condition and how to spot it
+type RedFlagRule struct {
+	ID        string   `json:"id"`
+	Title     string   `json:"title"`
+	Advice    string   `json:"advice"`
+	Phrases   []string `json:"phrases"`   // said by the patient in the chat or a questionnaire answer
+	Questions []string `json:"questions"` // in a questionnaire question the patient answered yes to
+	Diagnoses []string `json:"diagnoses"` // in a possible diagnosis of the AI
+}
+
+// RedFlagMatch is a rule that matched an assessment, with the first text it matched
+type RedFlagMatch struct {
+	RuleID   string `json:"ruleId"`
+	Title    string `json:"title"`
+	Advice   string `json:"advice"`
+	Source   string `json:"source"` // questionnaire, chat or diagnosis
+	Evidence string `json:"evidence"`
+}
+
+// Escalation is the red-flag block of a dashboard result. The assessment carries the
+// critical flag and an immediate physio call was booked for it.
+type Escalation struct {
+	Critical     bool           `json:"critical"`
+	RulesVersion string         `json:"rulesVersion"`
+	Matches      []RedFlagMatch `json:"matches"`
+	CallID       *uint32        `json:"callId,omitempty"` // absent once the call is deleted
+	EscalatedAt  time.Time      `json:"escalatedAt"`
+}
+
+// phrase is a rule phrase split into words
+type phrase []string
+
+type compiledRule struct {
+	RedFlagRule
+	phrases, questions, diagnoses []phrase
+}
+
+// redFlagEngine is a loaded rules file, nil when the review is disabled
+type redFlagEngine struct {
+	version     string
+	negations   map[string]bool
+	affirmative []phrase
+	rules       []compiledRule
+}
+
+var redFlags *redFlagEngine
+
+// LoadRedFlagRules enables the red-flag review with the rules file at path, or the bundled
+// rules when path is empty
+func LoadRedFlagRules(path string) error {
+	data := defaultRedFlagRules
+	if path != "" {
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			return fmt.Errorf("failed to read red-flag rules: %w", err)
+		}
+	}
+
+	var rules RedFlagRules
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return fmt.Errorf("failed to parse red-flag rules: %w", err)
+	}
+	engine, err := compileRedFlagRules(rules)
+	if err != nil {
+		return fmt.Errorf("invalid red-flag rules: %w", err)
+	}
+	redFlags = engine
+	log.Printf("Red-flag rules %s loaded: %d rules", engine.version, len(engine.rules))
+	return nil
+}
+
+func compileRedFlagRules(rules RedFlagRules) (*redFlagEngine, error) {
+	if strings.TrimSpace(rules.Version) == "" {
+		return nil, errors.New("version is required")
+	}
+	if len(rules.Rules) == 0 {
+		return nil, errors.New("no rules")
+	}
+	engine := &redFlagEngine{version: rules.Version, negations: map[string]bool{}}
+	for _, n := range rules.Negations {
+		for _, w := range words(n) {
+			engine.negations[w] = true
+		}
+	}
+	var err error
+	if engine.affirmative, err = phrases(rules.Affirmative); err != nil {
+		return nil, fmt.Errorf("affirmative: %w", err)
+	}
+
+	seen := map[string]bool{}
+	for _, rule := range rules.Rules {
+		switch {
+		case rule.ID == "":
+			return nil, errors.New("a rule has no id")
+		case seen[rule.ID]:
+			return nil, fmt.Errorf("rule %s is defined twice", rule.ID)
+		case rule.Title == "":
+			return nil, fmt.Errorf("rule %s has no title", rule.ID)
+		case len(rule.Phrases)+len(rule.Questions)+len(rule.Diagnoses) == 0:
+			return nil, fmt.Errorf("rule %s has no phrases, questions or diagnoses", rule.ID)
+		}
+		seen[rule.ID] = true
+
+		c := compiledRule{RedFlagRule: rule}
+		if c.phrases, err = phrases(rule.Phrases); err == nil {
+			if c.questions, err = phrases(rule.Questions); err == nil {
+				c.diagnoses, err = phrases(rule.Diagnoses)
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("rule %s: %w", rule.ID, err)
+		}
+		engine.rules = append(engine.rules, c)
+	}
+	return engine, nil
+}
+
+func phrases(list []string) ([]phrase, error) {
+	out := make([]phrase, 0, len(list))
+	for _, s := range list {
+		p := phrase(words(s))
+		if len(p) == 0 || p[0] == "*" || p[len(p)-1] == "*" {
+			return nil, fmt.Errorf("%q must start and end with a word", s)
+		}
+		out = append(out, p)
+	}
+	return out, nil
+}
+
+// words splits text into lowercase words, dropping apostrophes so "can't" reads "cant"
+func words(text string) []string {
+	text = strings.NewReplacer("'", "", "’", "").Replace(strings.ToLower(text))
+	return strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '*'
+	})
+}
+
+// clauses splits text into word lists at sentence and clause punctuation, so a negation
+// does not reach past a comma
+func clauses(text string) [][]string {
+	var out [][]string
+	for _, part := range strings.FieldsFunc(text, func(r rune) bool { return strings.ContainsRune(".,;:!?\n", r) }) {
+		if w := words(part); len(w) > 0 {
+			out = append(out, w)
+		}
+	}
+	return out
+}
+
+// at reports whether p matches the words starting at i
+func (p phrase) at(ws []string, i int) bool {
+	if i+len(p) > len(ws) {
+		return false
+	}
+	for j, pw := range p {
+		w := ws[i+j]
+		switch {
+		case pw == "*":
+		case strings.HasSuffix(pw, "*"):
+			if !strings.HasPrefix(w, strings.TrimSuffix(pw, "*")) {
+				return false
+			}
+		case w != pw:
+			return false
+		}
+	}
+	return true
+}
+
+// mentions reports whether text holds one of ps, phrases right after a negation do not count
+func (e *redFlagEngine) mentions(text string, ps []phrase, negatable bool) bool {
+	for _, ws := range clauses(text) {
+		for i := range ws {
+			for _, p := range ps {
+				if p.at(ws, i) && !(negatable && e.negated(ws, i)) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func (e *redFlagEngine) negated(ws []string, i int) bool {
+	for k := max(0, i-negationWindow); k < i; k++ {
+		if e.negations[ws[k]] {
+			return true
+		}
+	}
+	return false
+}
+
+// agrees reports whether an answer says yes: it starts with an affirmative phrase and its
+// first clause holds no negation
+func (e *redFlagEngine) agrees(answer string) bool {
+	cs := clauses(answer)
+	if len(cs) == 0 {
+		return false
+	}
+	for _, w := range cs[0] {
+		if e.negations[w] {
+			return false
+		}
+	}
+	for _, p := range e.affirmative {
+		if p.at(cs[0], 0) {
+			return true
+		}
+	}
+	return false
+}
+
+// redFlagInput is the text an analysis is reviewed on
+type redFlagInput struct {
+	answers   []QuestionMessage // User answers Assistant
+	chat      []string          // what the patient said in the chat
+	diagnoses []string          // possible diagnoses of the AI
+}
+
+// evaluate returns the rules matching the input in file order, each with its first evidence
+func (e *redFlagEngine) evaluate(in redFlagInput) []RedFlagMatch {
+	var matches []RedFlagMatch
+	for _, rule := range e.rules {
+		if source, evidence, ok := e.find(rule, in); ok {
+			matches = append(matches, RedFlagMatch{
+				RuleID:   rule.ID,
+				Title:    rule.Title,
+				Advice:   rule.Advice,
+				Source:   source,
+				Evidence: truncateRunes(evidence, maxEvidenceRunes),
+			})
+		}
+	}
+	return matches
+}
+
+func (e *redFlagEngine) find(rule compiledRule, in redFlagInput) (source, evidence string, ok bool) {
+	for _, qa := range in.answers {
+		if qa.Assistant != "" && e.mentions(qa.Assistant, rule.questions, false) && e.agrees(qa.User) {
+			return RedFlagSourceQuestionnaire, qa.Assistant + " - " + qa.User, true
+		}
+		if e.mentions(qa.User, rule.phrases, true) {
+			return RedFlagSourceQuestionnaire, qa.User, true
+		}
+	}
+	for _, message := range in.chat {
+		if e.mentions(message, rule.phrases, true) {
+			return RedFlagSourceChat, message, true
+		}
+	}
+	for _, diagnosis := range in.diagnoses {
+		if e.mentions(diagnosis, rule.diagnoses, true) {
+			return RedFlagSourceDiagnosis, diagnosis, true
+		}
+	}
+	return "", "", false
+}
+
+func truncateRunes(s string, n int) string {
+	r := []rune(strings.TrimSpace(s))
+	if len(r) <= n {
+		return string(r)
+	}
+	return string(r[:n-1]) + "…"
+}
+
+// questionAnswers pairs each questionnaire answer with the question it replies to. The
+// assistant side of an entry is the question asked after its answer, so an answer replies
+// to the question of the entry before it.
+func questionAnswers(history []QuestionMessage) []QuestionMessage {
+	pairs := make([]QuestionMessage, 0, len(history))
+	for i, entry := range history {
+		qa := QuestionMessage{User: entry.User}
+		if i > 0 {
+			qa.Assistant = history[i-1].Assistant
+		}
+		pairs = append(pairs, qa)
+	}
+	return pairs
+}
+
+// reviewRedFlags runs the red-flag rules on an analysed assessment: its questionnaire, what
+// the patient said in the chat and the possible diagnoses in result. On a match the
+// assessment is escalated, its self-care plan gets the critical flag and an immediate call
+// initiated by the system is booked once. nil means nothing matched or the review is off.
+func reviewRedFlags(ctx context.Context, job *db.AnalysisJob, result json.RawMessage) (*db.Escalation, error) {
+	engine := redFlags
+	if engine == nil {
+		return nil, nil
+	}
+
+	var input DashboardDataAIRequest
+	if err := json.Unmarshal(job.Input, &input); err != nil {
+		return nil, err
+	}
+	var analysis AIAnalysisResult
+	if err := json.Unmarshal(result, &analysis); err != nil {
+		return nil, err
+	}
+	messages, err := store.ChatMessages.ListByAssessment(ctx, job.AssessmentID)
+	if err != nil {
+		return nil, err
+	}
+	in := redFlagInput{answers: questionAnswers(input.ChatHistory), diagnoses: analysis.PossibleDiagnosis}
+	for _, m := range messages {
+		if m.Role == db.ChatRoleUser {
+			in.chat = append(in.chat, m.Content)
+		}
+	}
+
+	matches := engine.evaluate(in)
+	if len(matches) == 0 {
+		return nil, nil
+	}
+	raw, err := json.Marshal(matches)
+	if err != nil {
+		return nil, err
+	}
+	escalation := &db.Escalation{AssessmentID: job.AssessmentID, RulesVersion: engine.version, Matches: raw}
+	if err := store.Escalations.Record(ctx, escalation); err != nil {
+		return nil, err
+	}
+	log.Printf("Assessment %d escalated by red-flag rules %s: %d matches", job.AssessmentID, engine.version, len(matches))
+
+	if err := store.SelfCarePlans.FlagCritical(ctx, job.AssessmentID); err != nil {
+		return nil, err
+	}
+	if escalation.CallID == nil {
+		if err := bookEscalationCall(ctx, escalation); err != nil {
+			return nil, err
+		}
+	}
+	return escalation, nil
+}
+
+// bookEscalationCall books the immediate call of an escalation, with the assigned
+// physiotherapist when there is one
+func bookEscalationCall(ctx context.Context, escalation *db.Escalation) error {
+	assessment, err := store.Assessments.Get(ctx, escalation.AssessmentID)
+	if err != nil {
+		return err
+	}
+	call := &db.PhysioCall{
+		AssessmentID:    escalation.AssessmentID,
+		CallType:        models.CallImmediate.String(),
+		CallStatus:      models.CallScheduled.String(),
+		DurationMinutes: defaultCallMinutes,
+		PhysioID:        assessment.PhysioID,
+		InitiatedBy:     callBySystem,
+	}
+	if err := store.PhysioCalls.Create(ctx, call, nil); err != nil {
+		return err
+	}
+
+	err = store.Escalations.SetCall(ctx, escalation.EscalationID, call.CallID)
+	if errors.Is(err, db.ErrConflict) {
+		// Another attempt of the analysis booked its call first
+		reason := "duplicate of the escalation call"
+		_, err = store.PhysioCalls.Transition(ctx, call.CallID, db.CallTransition{
+			From:   models.CallScheduled.String(),
+			To:     models.CallCancelled.String(),
+			Ended:  true,
+			Reason: &reason,
+		})
+		if err != nil {
+			return err
+		}
+		row, err := store.Escalations.GetByAssessment(ctx, escalation.AssessmentID)
+		if err != nil {
+			return err
+		}
+		*escalation = *row
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	escalation.CallID = &call.CallID
+	log.Printf("Immediate physio call %d booked for escalated assessment %d", call.CallID, escalation.AssessmentID)
+	return nil
+}
+
+// GetEscalation returns the red-flag escalation of an assessment, nil when it has none
+func GetEscalation(ctx context.Context, assessmentID uint32) (*Escalation, error) {
+	row, err := store.Escalations.GetByAssessment(ctx, assessmentID)
+	if errors.Is(err, db.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return toEscalation(row)
+}
+
+func toEscalation(row *db.Escalation) (*Escalation, error) {
+	escalation := &Escalation{
+		Critical:     true,
+		RulesVersion: row.RulesVersion,
+		CallID:       row.CallID,
+		EscalatedAt:  row.CreatedAt,
+	}
+	if err := json.Unmarshal(row.Matches, &escalation.Matches); err != nil {
+		return nil, err
+	}
+	return escalation, nil
+}
+
+// escalationReasons lists the titles of the matched rules for the critical flag alert
+func escalationReasons(escalation *Escalation) []string {
+	reasons := make([]string, 0, len(escalation.Matches))
+	for _, m := range escalation.Matches {
+		reasons = append(reasons, m.Title)
+	}
+	return reasons
+}
+
+// notifyEscalation sends the critical flag alert of an escalation with the matched rules
+func notifyEscalation(ctx context.Context, row *db.Escalation) error {
+	escalation, err := toEscalation(row)
+	if err != nil {
+		return err
+	}
+	return NotifyCriticalFlag(ctx, row.AssessmentID, escalationReasons(escalation))
+}
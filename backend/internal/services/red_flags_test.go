@@ -0,0 +1,200 @@
+package services
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// loadTestRedFlags compiles the small rules fixture in testdata/red_flags
+func loadTestRedFlags(t *testing.T) *redFlagEngine {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "red_flags", "rules.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rules RedFlagRules
+	if err := json.Unmarshal(data, &rules); err != nil {
+		t.Fatal(err)
+	}
+	engine, err := compileRedFlagRules(rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return engine
+}
+
+func TestBundledRedFlagRules(t *testing.T) {
+	saved := redFlags
+	defer func() { redFlags = saved }()
+	if err := LoadRedFlagRules(""); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRedFlagMentions(t *testing.T) {
+	engine := loadTestRedFlags(t)
+	cardiac := engine.rules[0].phrases
+	caudaEquina := engine.rules[1].phrases
+
+	tests := []struct {
+		text    string
+		phrases []phrase
+		want    bool
+	}{
+		{"I have chest pain", cardiac, true},
+		{"CHEST PAIN when I climb stairs!", cardiac, true},
+		{"My chest tightens when I walk", cardiac, true},
+		{"my chest feels tight", cardiac, false},
+		{"no chest pain", cardiac, false},
+		{"I don't have any chest pain", cardiac, false},
+		{"never had chest pain before", cardiac, false},
+		{"No, but chest pain started yesterday", cardiac, true},
+		{"Not really. Chest pain when running", cardiac, true},
+		{"no problems at all, I have had chest pain for a week", cardiac, true},
+		{"my chest is fine, the pain is in my knee", cardiac, false},
+		{"numb in the groin", caudaEquina, false},
+		{"numb near groin", caudaEquina, true},
+		{"saddle numbness", caudaEquina, true},
+	}
+	for _, tt := range tests {
+		if got := engine.mentions(tt.text, tt.phrases, true); got != tt.want {
+			t.Errorf("mentions(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestRedFlagAgrees(t *testing.T) {
+	engine := loadTestRedFlags(t)
+	tests := []struct {
+		answer string
+		want   bool
+	}{
+		{"Yes", true},
+		{"yes.", true},
+		{"Yeah, since Monday", true},
+		{"I have", true},
+		{"Sometimes", true},
+		{"No", false},
+		{"Yes I have not", false},
+		{"I haven't", false},
+		{"No. Yes it was fine before", false},
+		{"Maybe", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := engine.agrees(tt.answer); got != tt.want {
+			t.Errorf("agrees(%q) = %v, want %v", tt.answer, got, tt.want)
+		}
+	}
+}
+
+func TestRedFlagEvaluate(t *testing.T) {
+	engine := loadTestRedFlags(t)
+	bladder := "Have you noticed any change in your bladder control?"
+
+	tests := []struct {
+		name string
+		in   redFlagInput
+		want []RedFlagMatch
+	}{
+		{
+			name: "yes to a question",
+			in:   redFlagInput{answers: []QuestionMessage{{Assistant: bladder, User: "Yes"}}},
+			want: []RedFlagMatch{{RuleID: "cauda_equina", Source: RedFlagSourceQuestionnaire, Evidence: bladder + " - Yes"}},
+		},
+		{
+			name: "no to a question",
+			in:   redFlagInput{answers: []QuestionMessage{{Assistant: bladder, User: "No, not at all"}}},
+		},
+		{
+			name: "a question phrase in the answer is not enough",
+			in:   redFlagInput{answers: []QuestionMessage{{Assistant: "Anything else?", User: "bladder control is fine"}}},
+		},
+		{
+			name: "phrase in an answer",
+			in:   redFlagInput{answers: []QuestionMessage{{Assistant: "Anything else?", User: "I have chest pain"}}},
+			want: []RedFlagMatch{{RuleID: "cardiac", Source: RedFlagSourceQuestionnaire, Evidence: "I have chest pain"}},
+		},
+		{
+			name: "negated phrase in the chat",
+			in:   redFlagInput{chat: []string{"no chest pain", "it hurts when I kneel"}},
+		},
+		{
+			name: "phrase in the chat",
+			in:   redFlagInput{chat: []string{"it hurts when I kneel", "I get chest pain on the stairs"}},
+			want: []RedFlagMatch{{RuleID: "cardiac", Source: RedFlagSourceChat, Evidence: "I get chest pain on the stairs"}},
+		},
+		{
+			name: "diagnosis",
+			in:   redFlagInput{diagnoses: []string{"Patellofemoral pain", "Stable angina"}},
+			want: []RedFlagMatch{{RuleID: "cardiac", Source: RedFlagSourceDiagnosis, Evidence: "Stable angina"}},
+		},
+		{
+			name: "every rule in file order, first evidence each",
+			in: redFlagInput{
+				answers: []QuestionMessage{{Assistant: bladder, User: "yes"}},
+				chat:    []string{"chest tightness", "chest pain"},
+			},
+			want: []RedFlagMatch{
+				{RuleID: "cardiac", Source: RedFlagSourceChat, Evidence: "chest tightness"},
+				{RuleID: "cauda_equina", Source: RedFlagSourceQuestionnaire, Evidence: bladder + " - yes"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []RedFlagMatch
+			for _, m := range engine.evaluate(tt.in) {
+				got = append(got, RedFlagMatch{RuleID: m.RuleID, Source: m.Source, Evidence: m.Evidence})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("evaluate = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuestionAnswers(t *testing.T) {
+	// Each entry holds an answer and the question asked after it
+	history := []QuestionMessage{
+		{User: "Knee", Assistant: "Have you lost bladder control?"},
+		{User: "Yes", Assistant: "Do you have chest pain?"},
+		{User: "No"},
+	}
+	want := []QuestionMessage{
+		{User: "Knee"},
+		{User: "Yes", Assistant: "Have you lost bladder control?"},
+		{User: "No", Assistant: "Do you have chest pain?"},
+	}
+	if got := questionAnswers(history); !reflect.DeepEqual(got, want) {
+		t.Fatalf("questionAnswers = %+v, want %+v", got, want)
+	}
+
+	engine := loadTestRedFlags(t)
+	matches := engine.evaluate(redFlagInput{answers: questionAnswers(history)})
+	if len(matches) != 1 || matches[0].RuleID != "cauda_equina" {
+		t.Fatalf("evaluate = %+v, want the yes paired with the bladder question only", matches)
+	}
+}
+
+func TestCompileRedFlagRulesRejects(t *testing.T) {
+	rule := RedFlagRule{ID: "r", Title: "R", Phrases: []string{"chest pain"}}
+	tests := []struct {
+		name  string
+		rules RedFlagRules
+	}{
+		{"no version", RedFlagRules{Rules: []RedFlagRule{rule}}},
+		{"no rules", RedFlagRules{Version: "1"}},
+		{"duplicate id", RedFlagRules{Version: "1", Rules: []RedFlagRule{rule, rule}}},
+		{"nothing to match", RedFlagRules{Version: "1", Rules: []RedFlagRule{{ID: "r", Title: "R"}}}},
+		{"leading wildcard", RedFlagRules{Version: "1", Rules: []RedFlagRule{{ID: "r", Title: "R", Phrases: []string{"* pain"}}}}},
+	}
+	for _, tt := range tests {
+		if _, err := compileRedFlagRules(tt.rules); err == nil {
+			t.Errorf("%s: compiled, want an error", tt.name)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package services
+
+import (
+	"ai-bot-deecogs/internal/db"
+	"ai-bot-deecogs/internal/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// maxLandmarkFrames bounds a recording, five minutes at 30 fps
+const maxLandmarkFrames = 9000
+
+var ErrInvalidLandmarks = errors.New("invalid pose landmarks")
+
+// LandmarkROMRequest is a set of pose landmark recordings, one per movement
+type LandmarkROMRequest struct {
+	ImageWidth  float64             `json:"imageWidth,omitempty"` // scales x against y, both default to 1
+	ImageHeight float64             `json:"imageHeight,omitempty"`
+	Recordings  []LandmarkRecording `json:"recordings"`
+}
+
+// LandmarkRecording is the landmark stream of one movement of one joint
+type LandmarkRecording struct {
+	AnatomyID   uint32          `json:"anatomyId,omitempty"` // defaults to the assessment anatomy
+	Movement    models.Movement `json:"movement"`
+	Side        models.Side     `json:"side,omitempty"`
+	Mode        models.ROMMode  `json:"mode,omitempty"`
+	PainOnsetAt *float64        `json:"painOnsetAt,omitempty"` // time the patient reported pain, in ms
+	Frames      []PoseFrame     `json:"frames"`
+}
+
+// LandmarkRecordingResult is what was derived from one recording
+type LandmarkRecordingResult struct {
+	AnatomyID      uint32          `json:"anatomyId"`
+	Movement       models.Movement `json:"movement"`
+	Side           models.Side     `json:"side,omitempty"`
+	Mode           models.ROMMode  `json:"mode"`
+	PainOnsetAngle *float64        `json:"painOnsetAngle,omitempty"`
+	poseAngleResult
+}
+
+// LandmarkROMResponse is the ROM computed from a landmark submission
+type LandmarkROMResponse struct {
+	RomID        uint32                    `json:"romId"`
+	Measurements []models.ROMMeasurement   `json:"measurements"`
+	Recordings   []LandmarkRecordingResult `json:"recordings"`
+}
+
+// SubmitLandmarkROM computes the ROM of each recording from its pose landmarks and stores the
+// frames, the derived angle series and the measurements for an assessment
+func SubmitLandmarkROM(assessmentID uint32, request LandmarkROMRequest) (*LandmarkROMResponse, error) {
+	if len(request.Recordings) == 0 {
+		return nil, fmt.Errorf("%w: recordings is required", ErrInvalidLandmarks)
+	}
+	if len(request.Recordings) > maxROMMeasurements {
+		return nil, fmt.Errorf("%w: more than %d recordings", ErrInvalidLandmarks, maxROMMeasurements)
+	}
+	aspect := 1.0
+	if request.ImageWidth > 0 && request.ImageHeight > 0 {
+		aspect = request.ImageWidth / request.ImageHeight
+	}
+	assessment, err := store.Assessments.Get(context.Background(), assessmentID)
+	if err != nil {
+		return nil, err
+	}
+
+	measurements := make([]models.ROMMeasurement, 0, len(request.Recordings))
+	results := make([]LandmarkRecordingResult, 0, len(request.Recordings))
+	for i, rec := range request.Recordings {
+		if len(rec.Frames) > maxLandmarkFrames {
+			return nil, fmt.Errorf("%w: recordings[%d] has more than %d frames", ErrInvalidLandmarks, i, maxLandmarkFrames)
+		}
+		if rec.AnatomyID == 0 {
+			rec.AnatomyID = assessment.AnatomyID
+		}
+		if rec.Mode == "" {
+			rec.Mode = models.ModeActive
+		}
+		anatomy, err := store.Anatomy.Get(context.Background(), rec.AnatomyID)
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, fmt.Errorf("%w: recordings[%d].anatomyId does not exist", ErrInvalidLandmarks, i)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		angles, err := computePoseAngles(anatomy.Name, rec.Movement, rec.Side, rec.Frames, aspect, defaultPoseOptions)
+		if err != nil {
+			return nil, fmt.Errorf("recordings[%d]: %w", i, err)
+		}
+		result := LandmarkRecordingResult{
+			AnatomyID:       rec.AnatomyID,
+			Movement:        rec.Movement,
+			Side:            rec.Side,
+			Mode:            rec.Mode,
+			poseAngleResult: *angles,
+		}
+		if rec.PainOnsetAt != nil {
+			angle := angleAt(angles.Series, *rec.PainOnsetAt)
+			result.PainOnsetAngle = &angle
+		}
+		results = append(results, result)
+
+		m := models.ROMMeasurement{
+			AnatomyID:      rec.AnatomyID,
+			Movement:       rec.Movement,
+			Side:           rec.Side,
+			Mode:           rec.Mode,
+			MinAngle:       &result.MinAngle,
+			MaxAngle:       &result.MaxAngle,
+			PeakAngle:      &result.PeakAngle,
+			PainOnsetAngle: result.PainOnsetAngle,
+		}
+		if result.Repetitions > 0 {
+			m.Repetitions = &result.Repetitions
+		}
+		measurements = append(measurements, m)
+	}
+
+	// The derived ROM goes through the same plausibility checks as submitted angles
+	rows, err := checkROMMeasurements(assessmentID, measurements)
+	if err != nil {
+		return nil, err
+	}
+	landmarks, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	derived, err := json.Marshal(results)
+	if err != nil {
+		return nil, err
+	}
+	rom := &db.ROMAnalysis{
+		AssessmentID:   assessmentID,
+		Source:         db.ROMSourceLandmarks,
+		LandmarkSeries: landmarks,
+		DerivedMetrics: derived,
+		Measurements:   rows,
+	}
+	if err := saveROM(rom, ROMRequest{Measurements: measurements}); err != nil {
+		return nil, err
+	}
+
+	return &LandmarkROMResponse{RomID: rom.RomID, Measurements: measurements, Recordings: results}, nil
+}
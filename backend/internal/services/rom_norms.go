@@ -0,0 +1,212 @@
+package services
+
+import (
+	"ai-bot-deecogs/internal/db"
+	"ai-bot-deecogs/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"time"
+)
+
+var (
+	ErrROMNormNotFound = errors.New("normative range not found")
+	ErrInvalidROMNorm  = errors.New("invalid normative range")
+)
+
+// ROMNorm is the normal range of a movement for an age band and sex
+type ROMNorm struct {
+	NormID    uint32          `json:"normId"`
+	AnatomyID uint32          `json:"anatomyId"`
+	Joint     string          `json:"joint,omitempty"`
+	Movement  models.Movement `json:"movement"`
+	Sex       string          `json:"sex"`    // female, male or any
+	AgeMin    int             `json:"ageMin"` // inclusive
+	AgeMax    int             `json:"ageMax"` // inclusive
+	NormalMin float64         `json:"normalMin"`
+	NormalMax float64         `json:"normalMax"`
+	Source    *string         `json:"source,omitempty"`
+	CreatedAt time.Time       `json:"createdAt"`
+}
+
+func toROMNorm(row *db.ROMNorm) ROMNorm {
+	return ROMNorm{
+		NormID:    row.NormID,
+		AnatomyID: row.AnatomyID,
+		Joint:     row.Joint,
+		Movement:  models.Movement(row.Movement),
+		Sex:       row.Sex,
+		AgeMin:    row.AgeMin,
+		AgeMax:    row.AgeMax,
+		NormalMin: row.NormalMin,
+		NormalMax: row.NormalMax,
+		Source:    row.Source,
+		CreatedAt: row.CreatedAt,
+	}
+}
+
+// ListROMNorms returns every normative range
+func ListROMNorms() ([]ROMNorm, error) {
+	rows, err := store.ROMNorms.List(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	list := make([]ROMNorm, 0, len(rows))
+	for i := range rows {
+		list = append(list, toROMNorm(&rows[i]))
+	}
+	return list, nil
+}
+
+// UpsertROMNorms seeds normative ranges. A band that already exists for the anatomy,
+// movement, sex and ages gets the new range, so loading the same set twice changes nothing.
+func UpsertROMNorms(norms []ROMNorm) ([]ROMNorm, error) {
+	for i, n := range norms {
+		if err := validateROMNorm(n); err != nil {
+			return nil, fmt.Errorf("%w: norms[%d]: %v", ErrInvalidROMNorm, i, err)
+		}
+	}
+	out := make([]ROMNorm, 0, len(norms))
+	for i, n := range norms {
+		if n.Sex == "" {
+			n.Sex = db.NormSexAny
+		}
+		row := db.ROMNorm{
+			AnatomyID: n.AnatomyID,
+			Movement:  string(n.Movement),
+			Sex:       n.Sex,
+			AgeMin:    n.AgeMin,
+			AgeMax:    n.AgeMax,
+			NormalMin: n.NormalMin,
+			NormalMax: n.NormalMax,
+			Source:    n.Source,
+		}
+		if err := store.ROMNorms.Upsert(context.Background(), &row); err != nil {
+			if errors.Is(err, db.ErrForeignKeyViolation) {
+				return nil, fmt.Errorf("%w: norms[%d]: anatomyId does not exist", ErrInvalidROMNorm, i)
+			}
+			return nil, err
+		}
+		out = append(out, toROMNorm(&row))
+	}
+	return out, nil
+}
+
+func validateROMNorm(n ROMNorm) error {
+	if !n.Movement.IsValid() {
+		return fmt.Errorf("%q is not a movement", string(n.Movement))
+	}
+	if n.Sex != "" && n.Sex != db.NormSexAny && !models.Sex(n.Sex).IsValid() {
+		return errors.New("sex must be female, male or any")
+	}
+	if n.AgeMin < 0 || n.AgeMin > n.AgeMax {
+		return errors.New("ageMin must be between 0 and ageMax")
+	}
+	if n.NormalMin >= n.NormalMax {
+		return errors.New("normalMin must be less than normalMax")
+	}
+	return nil
+}
+
+// DeleteROMNorm removes a normative range
+func DeleteROMNorm(normID uint32) error {
+	err := store.ROMNorms.Delete(context.Background(), normID)
+	if errors.Is(err, db.ErrNotFound) {
+		return ErrROMNormNotFound
+	}
+	return err
+}
+
+// scoreROMMeasurements fills in the percent of normal of each measurement, using the age
+// and sex the user had at the time of the measurement. Measurements without a normal range
+// for the user are left unscored.
+func scoreROMMeasurements(userID uint32, at time.Time, measurements []models.ROMMeasurement) {
+	if len(measurements) == 0 {
+		return
+	}
+	norms, err := store.ROMNorms.List(context.Background())
+	if err != nil {
+		log.Printf("Warning: Failed to load normative ROM ranges: %v", err)
+		return
+	}
+
+	var age *int
+	sex := db.NormSexAny
+	user, err := store.Users.Get(context.Background(), userID)
+	if err != nil && !errors.Is(err, db.ErrNotFound) {
+		log.Printf("Warning: Failed to load user %d for ROM scoring: %v", userID, err)
+	}
+	if user != nil {
+		if user.DateOfBirth != nil {
+			years := yearsBetween(*user.DateOfBirth, at)
+			age = &years
+		}
+		if user.Sex != nil {
+			sex = *user.Sex
+		}
+	}
+
+	for i := range measurements {
+		m := &measurements[i]
+		norm := matchROMNorm(norms, m.AnatomyID, string(m.Movement), age, sex)
+		if norm == nil || m.MinAngle == nil || m.MaxAngle == nil {
+			continue
+		}
+		percent := math.Round((*m.MaxAngle-*m.MinAngle)/(norm.NormalMax-norm.NormalMin)*1000) / 10
+		m.Score = &models.ROMScore{
+			NormalMin:       norm.NormalMin,
+			NormalMax:       norm.NormalMax,
+			PercentOfNormal: percent,
+			Severity:        models.SeverityOf(percent),
+			AgeBand:         fmt.Sprintf("%d-%d", norm.AgeMin, norm.AgeMax),
+			Sex:             norm.Sex,
+		}
+	}
+}
+
+// matchROMNorm picks the range of a movement for a patient: a band of the patient's sex before
+// one for any sex, then the narrowest band covering the age. Without an age only the widest
+// band is used, it is the one meant for everyone.
+func matchROMNorm(norms []db.ROMNorm, anatomyID uint32, movement string, age *int, sex string) *db.ROMNorm {
+	var best *db.ROMNorm
+	better := func(n *db.ROMNorm) bool {
+		if best == nil {
+			return true
+		}
+		if (n.Sex == sex) != (best.Sex == sex) {
+			return n.Sex == sex
+		}
+		width, bestWidth := n.AgeMax-n.AgeMin, best.AgeMax-best.AgeMin
+		if age == nil {
+			return width > bestWidth
+		}
+		return width < bestWidth
+	}
+	for i := range norms {
+		n := &norms[i]
+		if n.AnatomyID != anatomyID || n.Movement != movement {
+			continue
+		}
+		if n.Sex != sex && n.Sex != db.NormSexAny {
+			continue
+		}
+		if age != nil && (*age < n.AgeMin || *age > n.AgeMax) {
+			continue
+		}
+		if better(n) {
+			best = n
+		}
+	}
+	return best
+}
+
+// yearsBetween returns the age in whole years at the given time
+func yearsBetween(birth, at time.Time) int {
+	years := at.Year() - birth.Year()
+	if at.Month() < birth.Month() || (at.Month() == birth.Month() && at.Day() < birth.Day()) {
+		years--
+	}
+	return years
+}
@@ -0,0 +1,114 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"ai-bot-deecogs/internal/db"
+	"ai-bot-deecogs/internal/models"
+)
+
+func TestMatchROMNorm(t *testing.T) {
+	norms := []db.ROMNorm{
+		{NormID: 1, AnatomyID: 1, Movement: "flexion", Sex: db.NormSexAny, AgeMin: 0, AgeMax: 150},
+		{NormID: 2, AnatomyID: 1, Movement: "flexion", Sex: db.NormSexAny, AgeMin: 60, AgeMax: 150},
+		{NormID: 3, AnatomyID: 1, Movement: "flexion", Sex: "female", AgeMin: 0, AgeMax: 150},
+		{NormID: 4, AnatomyID: 1, Movement: "extension", Sex: db.NormSexAny, AgeMin: 0, AgeMax: 150},
+		{NormID: 5, AnatomyID: 2, Movement: "flexion", Sex: db.NormSexAny, AgeMin: 20, AgeMax: 40},
+	}
+	age := func(years int) *int { return &years }
+	tests := []struct {
+		name      string
+		anatomyID uint32
+		movement  string
+		age       *int
+		sex       string
+		want      uint32 // 0 when no range applies
+	}{
+		{"narrowest band of the age", 1, "flexion", age(70), db.NormSexAny, 2},
+		{"band of the sex before a narrower one for anyone", 1, "flexion", age(70), "female", 3},
+		{"band for anyone when none is for the sex", 1, "flexion", age(30), "male", 1},
+		{"widest band without an age", 1, "flexion", nil, db.NormSexAny, 1},
+		{"other movement", 1, "extension", age(30), "male", 4},
+		{"age outside every band", 2, "flexion", age(50), db.NormSexAny, 0},
+		{"no range for the movement", 2, "extension", age(30), db.NormSexAny, 0},
+	}
+	for _, tt := range tests {
+		got := matchROMNorm(norms, tt.anatomyID, tt.movement, tt.age, tt.sex)
+		var id uint32
+		if got != nil {
+			id = got.NormID
+		}
+		if id != tt.want {
+			t.Errorf("%s: matched norm %d, want %d", tt.name, id, tt.want)
+		}
+	}
+}
+
+func TestROMPercentOfNormal(t *testing.T) {
+	b := useMemoryStore(t)
+	b.SetClock(func() time.Time { return time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC) })
+	id := newTestAssessment(t, b)
+	str := func(s string) *string { return &s }
+	// Jane turns 64 on the day after the measurement
+	if _, err := UpdateUserProfile(1, nil, nil, str("1960-03-02"), str("female")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := UpsertROMNorms([]ROMNorm{
+		{AnatomyID: 1, Movement: models.MovementFlexion, Sex: "female", AgeMin: 18, AgeMax: 63, NormalMin: 0, NormalMax: 140},
+		{AnatomyID: 1, Movement: models.MovementFlexion, Sex: "female", AgeMin: 64, AgeMax: 150, NormalMin: 0, NormalMax: 120},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	flexion := func(side models.Side, min, max float64) models.ROMMeasurement {
+		return models.ROMMeasurement{Movement: models.MovementFlexion, Side: side, MinAngle: angle(min), MaxAngle: angle(max)}
+	}
+	if _, err := SubmitROMAnalysis(id, ROMRequest{Measurements: []models.ROMMeasurement{
+		flexion(models.SideLeft, 0, 126),
+		flexion(models.SideRight, 10, 80),
+		flexion(models.SideBilateral, 0, 50),
+		{Movement: models.MovementExtension, Side: models.SideLeft, MinAngle: angle(0), MaxAngle: angle(5)},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	rom, err := GetROMAnalysisByAssessmentId(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		percent  float64
+		severity models.ROMSeverity
+	}{
+		{90, models.ROMSeverityNormal},
+		{50, models.ROMSeverityModerate},
+		{35.7, models.ROMSeveritySevere},
+	}
+	for i, w := range want {
+		score := rom.Measurements[i].Score
+		if score == nil || score.PercentOfNormal != w.percent || score.Severity != w.severity || score.AgeBand != "18-63" {
+			t.Errorf("measurement %d scored %+v, want %v%% %s in the 18-63 band", i, score, w.percent, w.severity)
+		}
+	}
+	if score := rom.Measurements[3].Score; score != nil {
+		t.Errorf("extension scored %+v, want it unscored without a normal range", score)
+	}
+}
+
+func TestYearsBetween(t *testing.T) {
+	birth := time.Date(1960, 3, 2, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		at   time.Time
+		want int
+	}{
+		{time.Date(2024, 3, 1, 23, 0, 0, 0, time.UTC), 63},
+		{time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC), 64},
+		{time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC), 63},
+	}
+	for _, tt := range tests {
+		if got := yearsBetween(birth, tt.at); got != tt.want {
+			t.Errorf("yearsBetween(%s) = %d, want %d", tt.at.Format("2006-01-02"), got, tt.want)
+		}
+	}
+}
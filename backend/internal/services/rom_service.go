@@ -2,85 +2,102 @@ package services
 
 import (
 	"ai-bot-deecogs/internal/db"
+	"ai-bot-deecogs/internal/models"
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
+	"strconv"
 	"time"
 )
 
+// maxROMMeasurements bounds the measurements of one submission
+const maxROMMeasurements = 32
+
+var ErrROMDataMissing = errors.New("rangeOfMotion or measurements is required")
+
 type RangeOfMotion struct {
 	Minimum json.Number `json:"minimum"`
 	Maximum json.Number `json:"maximum"`
 }
 
+// ROMRequest is a ROM submission. Older clients send only rangeOfMotion, newer ones send
+// measurements and rangeOfMotion is then derived from the first one.
 type ROMRequest struct {
-	RangeOfMotion RangeOfMotion `json:"rangeOfMotion"` // Store JSONB data
+	RangeOfMotion *RangeOfMotion          `json:"rangeOfMotion,omitempty"` // Store JSONB data
+	Measurements  []models.ROMMeasurement `json:"measurements,omitempty"`
 }
 
 type ROMDataResponse struct {
-	RomID        uint32          `json:"romId"`
-	AssessmentID uint32          `json:"assessmentId"`
-	RangeOfMotion RangeOfMotion `json:"rangeOfMotion"`
-	CreatedAt    time.Time          `json:"createdAt"`
+	RomID         uint32                  `json:"romId"`
+	AssessmentID  uint32                  `json:"assessmentId"`
+	RangeOfMotion RangeOfMotion           `json:"rangeOfMotion"`
+	Measurements  []models.ROMMeasurement `json:"measurements"`
+	Source        string                  `json:"source"`            // client or landmarks
+	Derived       json.RawMessage         `json:"derived,omitempty"` // angle series of landmark submissions
+	CreatedAt     time.Time               `json:"createdAt"`
 }
 
 type ROMDataResponseCleaned struct {
-	RomID        uint32          `json:"romId"`
-	AssessmentID uint32          `json:"assessmentId"`
+	RomID         uint32        `json:"romId"`
+	AssessmentID  uint32        `json:"assessmentId"`
 	RangeOfMotion RangeOfMotion `json:"rangeOfMotion"`
-	CreatedAt    time.Time          `json:"createdAt"`
+	CreatedAt     time.Time     `json:"createdAt"`
 }
 
-// SubmitROMAnalysis stores pose model data and analysis results for an assessment
-func SubmitROMAnalysis(assessmentId uint32, rangeOfMotion RangeOfMotion) (APIResponse, error) {
+// SubmitROMAnalysis validates the measurements of a ROM submission and stores them for an assessment
+func SubmitROMAnalysis(assessmentId uint32, payload ROMRequest) (APIResponse, error) {
 	var aiResponse APIResponse
-
-	payload:= ROMRequest{RangeOfMotion: rangeOfMotion}
-	jsonData, marshalErr := json.Marshal(payload)
-	if marshalErr != nil {
-		return aiResponse, marshalErr
+	if payload.RangeOfMotion == nil && len(payload.Measurements) == 0 {
+		return aiResponse, ErrROMDataMissing
 	}
-
-	query := `
-		INSERT INTO rom_analysis (assessment_id, pose_model_data, created_at)
-		VALUES ($1, $2, NOW())
-	`
-	_, err := db.DB.Exec(context.Background(), query, assessmentId, jsonData )
+	rows, err := checkROMMeasurements(assessmentId, payload.Measurements)
 	if err != nil {
 		return aiResponse, err
 	}
 
-	return aiResponse, nil
+	rom := &db.ROMAnalysis{AssessmentID: assessmentId, Source: db.ROMSourceClient, Measurements: rows}
+	return aiResponse, saveROM(rom, payload)
 }
 
-// GetROMAnalysis retrieves ROM analysis data for a given assessment
-func GetROMAnalysisByAssessmentId(assessmentID uint32) (*ROMDataResponse, error) {
-	// var apiResponse ROMDataResponseCleaned
-	query := `
-		SELECT rom_id, assessment_id, pose_model_data, created_at
-		FROM rom_analysis
-		WHERE assessment_id = $1 order by created_at desc limit 1
-	`
-
-	var romData ROMDataResponse
-	// var PoseModelData RangeOfMotion
-
-	// var unmarshalRomData = json.Unmarshal([]byte(romData.RangeOfMotion), &romData.RangeOfMotion)
-	// log.Printf("Unmarshalled ROM Data: %v", unmarshalRomData)
+// saveROM stores a checked ROM submission and records the ROM phase. rangeOfMotion is
+// filled in from the first measurement for the readers of the legacy payload.
+func saveROM(rom *db.ROMAnalysis, payload ROMRequest) error {
+	if err := requireOpen(rom.AssessmentID); err != nil {
+		return err
+	}
+	touchAssessment(rom.AssessmentID)
 
-	var poseModelDataRaw json.RawMessage
+	if payload.RangeOfMotion == nil {
+		first := payload.Measurements[0]
+		payload.RangeOfMotion = &RangeOfMotion{
+			Minimum: json.Number(strconv.FormatFloat(*first.MinAngle, 'f', -1, 64)),
+			Maximum: json.Number(strconv.FormatFloat(*first.MaxAngle, 'f', -1, 64)),
+		}
+	}
+	jsonData, marshalErr := json.Marshal(payload)
+	if marshalErr != nil {
+		return marshalErr
+	}
+	rom.PoseModelData = jsonData
 
-	err := db.DB.QueryRow(context.Background(), query, assessmentID).Scan(
-		&romData.RomID,
-		&romData.AssessmentID,
-		&poseModelDataRaw,
-		&romData.CreatedAt,
-	)
+	if err := store.ROM.Create(context.Background(), rom); err != nil {
+		return err
+	}
+	if err := CompletePhase(rom.AssessmentID, models.PhaseROM); err != nil {
+		log.Printf("Warning: Failed to record ROM phase: %v", err)
+	}
+	return nil
+}
 
+// GetROMAnalysis retrieves ROM analysis data for a given assessment
+func GetROMAnalysisByAssessmentId(assessmentID uint32) (*ROMDataResponse, error) {
+	romData, err := store.ROM.Latest(context.Background(), assessmentID)
 	if err != nil {
 		return nil, err
 	}
+	poseModelDataRaw := romData.PoseModelData
 
 	// romData.RangeOfMotion.UnmarshalJSON(romData.RangeOfMotion)
 
@@ -111,9 +128,122 @@ func GetROMAnalysisByAssessmentId(assessmentID uint32) (*ROMDataResponse, error)
 	}
 
 	return &ROMDataResponse{
-		RomID: romData.RomID,
-		AssessmentID: romData.AssessmentID,
+		RomID:         romData.RomID,
+		AssessmentID:  romData.AssessmentID,
 		RangeOfMotion: rangeOfMotion,
-		CreatedAt: romData.CreatedAt,
+		Measurements:  scoredROMMeasurements(romData),
+		Source:        romData.Source,
+		Derived:       romData.DerivedMetrics,
+		CreatedAt:     romData.CreatedAt,
 	}, nil
 }
+
+// checkROMMeasurements fills in the defaults of each measurement and checks it against the
+// plausible range of its joint. A measurement without anatomyId is of the assessment anatomy.
+func checkROMMeasurements(assessmentID uint32, measurements []models.ROMMeasurement) ([]db.ROMMeasurement, error) {
+	if len(measurements) == 0 {
+		return nil, nil
+	}
+	if len(measurements) > maxROMMeasurements {
+		return nil, &models.ROMMeasurementError{Field: "measurements", Reason: fmt.Sprintf("has more than %d entries", maxROMMeasurements)}
+	}
+	assessment, err := store.Assessments.Get(context.Background(), assessmentID)
+	if err != nil {
+		return nil, err
+	}
+
+	joints := map[uint32]string{}
+	seen := map[string]bool{}
+	rows := make([]db.ROMMeasurement, 0, len(measurements))
+	for i := range measurements {
+		m := &measurements[i]
+		if m.AnatomyID == 0 {
+			m.AnatomyID = assessment.AnatomyID
+		}
+		if m.Mode == "" {
+			m.Mode = models.ModeActive
+		}
+		joint, ok := joints[m.AnatomyID]
+		if !ok {
+			anatomy, err := store.Anatomy.Get(context.Background(), m.AnatomyID)
+			if errors.Is(err, db.ErrNotFound) {
+				return nil, &models.ROMMeasurementError{Field: fmt.Sprintf("measurements[%d].anatomyId", i), Reason: "does not exist"}
+			}
+			if err != nil {
+				return nil, err
+			}
+			joint = anatomy.Name
+			joints[m.AnatomyID] = joint
+		}
+		m.Joint = joint
+
+		if err := m.Validate(joint); err != nil {
+			var measurementErr *models.ROMMeasurementError
+			if errors.As(err, &measurementErr) {
+				measurementErr.Field = fmt.Sprintf("measurements[%d].%s", i, measurementErr.Field)
+			}
+			return nil, err
+		}
+		key := fmt.Sprintf("%d/%s/%s/%s", m.AnatomyID, m.Movement, m.Side, m.Mode)
+		if seen[key] {
+			return nil, &models.ROMMeasurementError{Field: fmt.Sprintf("measurements[%d]", i), Reason: "repeats the joint, movement, side and mode of an earlier measurement"}
+		}
+		seen[key] = true
+
+		row := db.ROMMeasurement{
+			AnatomyID:      m.AnatomyID,
+			Movement:       string(m.Movement),
+			Mode:           string(m.Mode),
+			MinAngle:       *m.MinAngle,
+			MaxAngle:       *m.MaxAngle,
+			PeakAngle:      m.PeakAngle,
+			Repetitions:    m.Repetitions,
+			PainOnsetAngle: m.PainOnsetAngle,
+		}
+		if m.Side != "" {
+			side := string(m.Side)
+			row.Side = &side
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// scoredROMMeasurements returns the measurements of a ROM analysis scored against the normal
+// ranges of the assessment's patient
+func scoredROMMeasurements(rom *db.ROMAnalysis) []models.ROMMeasurement {
+	measurements := toROMMeasurements(rom.Measurements)
+	if len(measurements) == 0 {
+		return measurements
+	}
+	assessment, err := store.Assessments.Get(context.Background(), rom.AssessmentID)
+	if err != nil {
+		log.Printf("Warning: Failed to load assessment %d for ROM scoring: %v", rom.AssessmentID, err)
+		return measurements
+	}
+	scoreROMMeasurements(assessment.UserID, rom.CreatedAt, measurements)
+	return measurements
+}
+
+func toROMMeasurements(rows []db.ROMMeasurement) []models.ROMMeasurement {
+	list := make([]models.ROMMeasurement, 0, len(rows))
+	for _, row := range rows {
+		minAngle, maxAngle := row.MinAngle, row.MaxAngle
+		m := models.ROMMeasurement{
+			AnatomyID:      row.AnatomyID,
+			Joint:          row.Joint,
+			Movement:       models.Movement(row.Movement),
+			Mode:           models.ROMMode(row.Mode),
+			MinAngle:       &minAngle,
+			MaxAngle:       &maxAngle,
+			PeakAngle:      row.PeakAngle,
+			Repetitions:    row.Repetitions,
+			PainOnsetAngle: row.PainOnsetAngle,
+		}
+		if row.Side != nil {
+			m.Side = models.Side(*row.Side)
+		}
+		list = append(list, m)
+	}
+	return list
+}
@@ -0,0 +1,94 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"ai-bot-deecogs/internal/models"
+)
+
+func angle(v float64) *float64 { return &v }
+
+func TestSubmitLegacyROM(t *testing.T) {
+	b := useMemoryStore(t)
+	id := newTestAssessment(t, b)
+	if _, err := SubmitROMAnalysis(id, ROMRequest{RangeOfMotion: &RangeOfMotion{Minimum: "10", Maximum: "120.5"}}); err != nil {
+		t.Fatal(err)
+	}
+	rom, err := GetROMAnalysisByAssessmentId(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rom.RangeOfMotion.Minimum != "10" || rom.RangeOfMotion.Maximum != "120.5" || len(rom.Measurements) != 0 {
+		t.Fatalf("rom = %+v, want the legacy range and no measurements", rom)
+	}
+}
+
+func TestSubmitROMMeasurements(t *testing.T) {
+	b := useMemoryStore(t)
+	id := newTestAssessment(t, b)
+	payload := ROMRequest{Measurements: []models.ROMMeasurement{
+		{Movement: models.MovementFlexion, Side: models.SideLeft, MinAngle: angle(5), MaxAngle: angle(110)},
+		{Movement: models.MovementFlexion, Side: models.SideRight, MinAngle: angle(0), MaxAngle: angle(135)},
+	}}
+	if _, err := SubmitROMAnalysis(id, payload); err != nil {
+		t.Fatal(err)
+	}
+	rom, err := GetROMAnalysisByAssessmentId(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Readers of the legacy payload see the first measurement
+	if rom.RangeOfMotion.Minimum != "5" || rom.RangeOfMotion.Maximum != "110" {
+		t.Errorf("rangeOfMotion = %+v, want it derived from the first measurement", rom.RangeOfMotion)
+	}
+	if len(rom.Measurements) != 2 {
+		t.Fatalf("measurements = %+v, want both", rom.Measurements)
+	}
+	first := rom.Measurements[0]
+	if first.Joint != "Knee" || first.Mode != models.ModeActive || first.AnatomyID == 0 {
+		t.Errorf("first measurement = %+v, want the assessment knee, active by default", first)
+	}
+	var stored map[string]json.RawMessage
+	if raw, err := store.ROM.Latest(context.Background(), id); err != nil || json.Unmarshal(raw.PoseModelData, &stored) != nil || stored["rangeOfMotion"] == nil {
+		t.Errorf("pose model data without rangeOfMotion: %v", err)
+	}
+}
+
+func TestSubmitROMRejects(t *testing.T) {
+	b := useMemoryStore(t)
+	id := newTestAssessment(t, b)
+	flexion := func(side models.Side, max float64) models.ROMMeasurement {
+		return models.ROMMeasurement{Movement: models.MovementFlexion, Side: side, MinAngle: angle(0), MaxAngle: angle(max)}
+	}
+	tests := []struct {
+		name      string
+		payload   ROMRequest
+		wantField string // empty for ErrROMDataMissing
+	}{
+		{"nothing", ROMRequest{}, ""},
+		{"implausible angle", ROMRequest{Measurements: []models.ROMMeasurement{flexion(models.SideLeft, 120), flexion(models.SideRight, 200)}}, "measurements[1].maxAngle"},
+		{"repeated measurement", ROMRequest{Measurements: []models.ROMMeasurement{flexion(models.SideLeft, 120), flexion(models.SideLeft, 125)}}, "measurements[1]"},
+		{"unknown anatomy", ROMRequest{Measurements: []models.ROMMeasurement{{AnatomyID: 42, Movement: models.MovementFlexion, MinAngle: angle(0), MaxAngle: angle(90)}}}, "measurements[0].anatomyId"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := SubmitROMAnalysis(id, tt.payload)
+			if tt.wantField == "" {
+				if !errors.Is(err, ErrROMDataMissing) {
+					t.Fatalf("SubmitROMAnalysis = %v, want ErrROMDataMissing", err)
+				}
+				return
+			}
+			var measurementErr *models.ROMMeasurementError
+			if !errors.As(err, &measurementErr) || measurementErr.Field != tt.wantField {
+				t.Fatalf("SubmitROMAnalysis = %v, want %s rejected", err, tt.wantField)
+			}
+		})
+	}
+	if _, err := GetROMAnalysisByAssessmentId(id); err == nil {
+		t.Fatal("a rejected submission was stored")
+	}
+}
@@ -2,8 +2,10 @@ package services
 
 import (
 	"ai-bot-deecogs/internal/db"
+	"ai-bot-deecogs/internal/helpers"
 	"context"
 	"errors"
+	"time"
 )
 
 type SelfCarePlan struct {
@@ -16,26 +18,28 @@ type SelfCarePlan struct {
 
 // GetSelfCarePlans retrieves self-care plans for a specific assessment
 func GetSelfCarePlans(assessmentID string) (*SelfCarePlan, error) {
-	query := `
-		SELECT assessment_id, plan_name, suggested_exercises, critical_flag, created_at
-		FROM self_care_plans
-		WHERE assessment_id = $1
-	`
+	assessmentIDUint, err := helpers.StringToUInt32(assessmentID)
+	if err != nil {
+		return nil, errors.New("no self-care plan found for the given assessment ID")
+	}
 
-	var plan SelfCarePlan
-	err := db.DB.QueryRow(context.Background(), query, assessmentID).Scan(
-		&plan.AssessmentID,
-		&plan.PlanName,
-		&plan.SuggestedExercises,
-		&plan.CriticalFlag,
-		&plan.CreatedAt,
-	)
+	row, err := store.SelfCarePlans.GetByAssessment(context.Background(), assessmentIDUint)
 	if err != nil {
-		if err.Error() == "no rows in result set" {
+		if errors.Is(err, db.ErrNotFound) {
 			return nil, errors.New("no self-care plan found for the given assessment ID")
 		}
 		return nil, err
 	}
 
+	plan := SelfCarePlan{
+		AssessmentID:       assessmentID,
+		SuggestedExercises: row.SuggestedExercises,
+		CriticalFlag:       row.CriticalFlag,
+		CreatedAt:          row.CreatedAt.Format(time.RFC3339),
+	}
+	if row.PlanName != nil {
+		plan.PlanName = *row.PlanName
+	}
+
 	return &plan, nil
 }
@@ -0,0 +1,83 @@
+package services
+
+import (
+	"ai-bot-deecogs/internal/clients"
+	"context"
+)
+
+// speechClient calls the Google speech APIs, set by Init
+var speechClient *clients.GoogleSpeechClient
+
+// VoiceSettings selects the recognition language and the synthesized voice
+type VoiceSettings struct {
+	LanguageCode string  `json:"languageCode"`
+	VoiceName    string  `json:"voiceName,omitempty"`
+	SpeakingRate float64 `json:"speakingRate,omitempty"`
+}
+
+// DefaultVoiceSettings is used by voice sessions until the client configures its own
+func DefaultVoiceSettings() VoiceSettings {
+	return VoiceSettings{LanguageCode: "en-US", SpeakingRate: 1}
+}
+
+// TranscribeAudio recognises base64 WEBM_OPUS audio and returns the best transcript,
+// an empty transcript means no speech was detected
+func TranscribeAudio(ctx context.Context, audioContent, languageCode string) (string, error) {
+	googleRequest := map[string]interface{}{
+		"config": map[string]interface{}{
+			"encoding":                   "WEBM_OPUS",
+			"sampleRateHertz":            48000,
+			"languageCode":               languageCode,
+			"enableAutomaticPunctuation": true,
+			"model":                      "latest_long",
+			"useEnhanced":                true,
+		},
+		"audio": map[string]interface{}{
+			"content": audioContent,
+		},
+	}
+
+	result, err := speechClient.SpeechToText(ctx, googleRequest)
+	if err != nil {
+		return "", err
+	}
+
+	// Extract transcript from Google response
+	if results, ok := result["results"].([]interface{}); ok && len(results) > 0 {
+		if firstResult, ok := results[0].(map[string]interface{}); ok {
+			if alternatives, ok := firstResult["alternatives"].([]interface{}); ok && len(alternatives) > 0 {
+				if alt, ok := alternatives[0].(map[string]interface{}); ok {
+					if t, ok := alt["transcript"].(string); ok {
+						return t, nil
+					}
+				}
+			}
+		}
+	}
+	return "", nil
+}
+
+// SynthesizeSpeech turns text into base64 LINEAR16 audio, empty when the API returned none
+func SynthesizeSpeech(ctx context.Context, text string, voice VoiceSettings) (string, error) {
+	googleRequest := map[string]interface{}{
+		"input": map[string]interface{}{
+			"text": text,
+		},
+		"voice": map[string]interface{}{
+			"languageCode": voice.LanguageCode,
+			"name":         voice.VoiceName,
+		},
+		"audioConfig": map[string]interface{}{
+			"audioEncoding": "LINEAR16",
+			"speakingRate":  voice.SpeakingRate,
+		},
+	}
+
+	result, err := speechClient.TextToSpeech(ctx, googleRequest)
+	if err != nil {
+		return "", err
+	}
+
+	audioContent, _ := result["audioContent"].(string)
+	return audioContent, nil
+}
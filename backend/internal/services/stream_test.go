@@ -0,0 +1,65 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestStreamChatStoresTheTurn(t *testing.T) {
+	b := useMemoryStore(t)
+	id := newTestAssessment(t, b)
+
+	var streamed strings.Builder
+	resp, err := StreamChatToAI(context.Background(), id, "Hi", func(token string) { streamed.WriteString(token) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if streamed.Len() == 0 || streamed.String() != resp.Text {
+		t.Fatalf("streamed %q, want the whole reply %q", streamed.String(), resp.Text)
+	}
+	transcript, err := GetChatTranscript(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(transcript.ChatHistory) != 1 || transcript.ChatHistory[0].Response != resp.Text {
+		t.Fatalf("stored chat = %+v, want the streamed turn as SendChatToAI stores it", transcript.ChatHistory)
+	}
+}
+
+func TestCancelledStreamStoresNothing(t *testing.T) {
+	b := useMemoryStore(t)
+	id := newTestAssessment(t, b)
+
+	// The client goes away after the first token
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var tokens int
+	_, err := StreamChatToAI(ctx, id, "Hi", func(string) {
+		tokens++
+		cancel()
+	})
+	if !errors.Is(err, context.Canceled) || tokens != 1 {
+		t.Fatalf("StreamChatToAI = %v after %d tokens, want context.Canceled after the first", err, tokens)
+	}
+	if transcript, err := GetChatTranscript(id); err != nil || len(transcript.Messages) != 0 {
+		t.Fatalf("transcript = %+v, %v, want nothing stored", transcript, err)
+	}
+}
+
+func TestStreamQuestions(t *testing.T) {
+	b := useMemoryStore(t)
+	id := newTestAssessment(t, b)
+
+	var tokens []string
+	resp, err := StreamQuestionsToAI(context.Background(), id, QuestionRequest{QuestionHistory: []QuestionMessage{{User: "Knee"}}}, func(token string) {
+		tokens = append(tokens, token)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tokens) < 2 || strings.Join(tokens, "") != resp.Text || !strings.HasSuffix(resp.Text, "?") {
+		t.Fatalf("tokens = %q, want the question %q word by word", tokens, resp.Text)
+	}
+}
@@ -2,8 +2,29 @@ package services
 
 import (
 	"ai-bot-deecogs/internal/db"
+	"ai-bot-deecogs/internal/models"
 	"context"
 	"errors"
+	"fmt"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrUserNotFound     = errors.New("user not found")
+	ErrEmailTaken       = errors.New("email already registered")
+	ErrInvalidUserData  = errors.New("invalid user data")
+	ErrPasswordMismatch = errors.New("current password is incorrect")
+)
+
+// Password length limits, bcrypt ignores everything past 72 bytes
+const (
+	minPasswordLength = 8
+	maxPasswordLength = 72
+
+	dateLayout = "2006-01-02"
 )
 
 type User struct {
@@ -13,19 +34,253 @@ type User struct {
 	Password string
 }
 
+// UserProfile is the public view of a user, it never carries the password
+type UserProfile struct {
+	UserID      uint32    `json:"userId"`
+	Name        string    `json:"name"`
+	Email       string    `json:"email"`
+	Role        string    `json:"role"`
+	DateOfBirth *string   `json:"dateOfBirth,omitempty"` // YYYY-MM-DD
+	Sex         *string   `json:"sex,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+func toUserProfile(row *db.User) UserProfile {
+	profile := UserProfile{
+		UserID:    row.UserID,
+		Name:      row.Name,
+		Email:     row.Email,
+		Role:      row.Role,
+		Sex:       row.Sex,
+		CreatedAt: row.CreatedAt,
+		UpdatedAt: row.UpdatedAt,
+	}
+	if row.DateOfBirth != nil {
+		dob := row.DateOfBirth.Format(dateLayout)
+		profile.DateOfBirth = &dob
+	}
+	return profile
+}
+
 // GetUserByEmail retrieves a user by email
 func GetUserByEmail(email string) (*User, error) {
-	var user User
+	row, err := store.Users.GetByEmail(context.Background(), email)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	return &User{
+		UserID:   strconv.FormatUint(uint64(row.UserID), 10),
+		Name:     row.Name,
+		Email:    row.Email,
+		Password: row.Password,
+	}, nil
+}
 
-	query := `
-		SELECT user_id, name, email, password
-		FROM users
-		WHERE email = $1
-	`
-	err := db.DB.QueryRow(context.Background(), query, email).Scan(&user.UserID, &user.Name, &user.Email, &user.Password)
+// CreateUser validates the input, hashes the password and stores a new patient
+func CreateUser(name, email, password string) (*UserProfile, error) {
+	name, email = strings.TrimSpace(name), strings.TrimSpace(email)
+	if err := validateName(name); err != nil {
+		return nil, err
+	}
+	if err := validateEmail(email); err != nil {
+		return nil, err
+	}
+	if err := validatePassword(password); err != nil {
+		return nil, err
+	}
+
+	hash, err := HashPassword(password)
 	if err != nil {
-		return nil, errors.New("user not found")
+		return nil, err
 	}
 
-	return &user, nil
+	row := db.User{Name: name, Email: email, Password: hash, Role: models.RolePatient.String()}
+	if err := store.Users.Create(context.Background(), &row); err != nil {
+		if db.Violates(err, db.UserEmailKey) {
+			return nil, ErrEmailTaken
+		}
+		return nil, err
+	}
+
+	profile := toUserProfile(&row)
+	return &profile, nil
+}
+
+// GetUserProfile returns the profile of an active user
+func GetUserProfile(userID uint32) (*UserProfile, error) {
+	row, err := store.Users.Get(context.Background(), userID)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	profile := toUserProfile(row)
+	return &profile, nil
+}
+
+// UpdateUserProfile changes the name, email, date of birth and sex of a user, nil fields are
+// left as they are and an empty date of birth or sex clears it
+func UpdateUserProfile(userID uint32, name, email, dateOfBirth, sex *string) (*UserProfile, error) {
+	current, err := store.Users.Get(context.Background(), userID)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	newName, newEmail := current.Name, current.Email
+	if name != nil {
+		newName = strings.TrimSpace(*name)
+		if err := validateName(newName); err != nil {
+			return nil, err
+		}
+	}
+	if email != nil {
+		newEmail = strings.TrimSpace(*email)
+		if err := validateEmail(newEmail); err != nil {
+			return nil, err
+		}
+	}
+
+	newDateOfBirth, newSex := current.DateOfBirth, current.Sex
+	if dateOfBirth != nil {
+		if newDateOfBirth, err = parseDateOfBirth(*dateOfBirth); err != nil {
+			return nil, err
+		}
+	}
+	if sex != nil {
+		newSex = nil
+		if *sex != "" {
+			if !models.Sex(*sex).IsValid() {
+				return nil, fmt.Errorf("%w: sex must be female or male", ErrInvalidUserData)
+			}
+			newSex = sex
+		}
+	}
+
+	err = store.Users.UpdateProfile(context.Background(), userID, newName, newEmail, newDateOfBirth, newSex)
+	if err != nil {
+		switch {
+		case errors.Is(err, db.ErrNotFound):
+			return nil, ErrUserNotFound
+		case db.Violates(err, db.UserEmailKey):
+			return nil, ErrEmailTaken
+		}
+		return nil, err
+	}
+	return GetUserProfile(userID)
+}
+
+// ChangePassword replaces a user's password, checking the current one first when verifyCurrent is set
+func ChangePassword(userID uint32, currentPassword, newPassword string, verifyCurrent bool) error {
+	row, err := store.Users.Get(context.Background(), userID)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return ErrUserNotFound
+		}
+		return err
+	}
+	if verifyCurrent && !CheckPassword(row.Password, currentPassword) {
+		return ErrPasswordMismatch
+	}
+	if err := validatePassword(newPassword); err != nil {
+		return err
+	}
+
+	hash, err := HashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+	if err := store.Users.UpdatePassword(context.Background(), userID, hash); err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return ErrUserNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// DeleteUser soft deletes a user: the account can no longer sign in but its assessments are kept
+func DeleteUser(userID uint32) error {
+	if err := store.Users.SoftDelete(context.Background(), userID); err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return ErrUserNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// ListUsers returns the profile of every active user
+func ListUsers() ([]UserProfile, error) {
+	rows, err := store.Users.List(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	profiles := make([]UserProfile, 0, len(rows))
+	for i := range rows {
+		profiles = append(profiles, toUserProfile(&rows[i]))
+	}
+	return profiles, nil
+}
+
+// SetUserRole changes the role of a user
+func SetUserRole(userID uint32, role models.Role) (*UserProfile, error) {
+	if !role.IsValid() {
+		return nil, fmt.Errorf("%w: invalid role", ErrInvalidUserData)
+	}
+	if err := store.Users.UpdateRole(context.Background(), userID, role.String()); err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	return GetUserProfile(userID)
+}
+
+func validateName(name string) error {
+	if name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidUserData)
+	}
+	if len(name) > 255 {
+		return fmt.Errorf("%w: name must be at most 255 characters", ErrInvalidUserData)
+	}
+	return nil
+}
+
+func validateEmail(email string) error {
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email || len(email) > 255 {
+		return fmt.Errorf("%w: email is not a valid address", ErrInvalidUserData)
+	}
+	return nil
+}
+
+// parseDateOfBirth reads a YYYY-MM-DD date of birth, an empty one is nil
+func parseDateOfBirth(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	dob, err := time.Parse(dateLayout, value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: dateOfBirth must be a YYYY-MM-DD date", ErrInvalidUserData)
+	}
+	if now := time.Now().UTC(); dob.After(now) || dob.Before(now.AddDate(-150, 0, 0)) {
+		return nil, fmt.Errorf("%w: dateOfBirth is out of range", ErrInvalidUserData)
+	}
+	return &dob, nil
+}
+
+func validatePassword(password string) error {
+	if len(password) < minPasswordLength {
+		return fmt.Errorf("%w: password must be at least %d characters", ErrInvalidUserData, minPasswordLength)
+	}
+	if len(password) > maxPasswordLength {
+		return fmt.Errorf("%w: password must be at most %d bytes", ErrInvalidUserData, maxPasswordLength)
+	}
+	return nil
 }
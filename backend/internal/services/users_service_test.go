@@ -0,0 +1,40 @@
+package services
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestUpdateUserProfile(t *testing.T) {
+	b := useMemoryStore(t)
+	newTestAssessment(t, b)
+	other, err := CreateUser("John", "john@example.com", "password123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	str := func(s string) *string { return &s }
+
+	profile, err := UpdateUserProfile(other.UserID, str("John Smith"), nil, str("1980-05-17"), str("male"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile.Name != "John Smith" || profile.Email != "john@example.com" || profile.DateOfBirth == nil || *profile.DateOfBirth != "1980-05-17" ||
+		profile.Sex == nil || *profile.Sex != "male" {
+		t.Fatalf("profile = %+v, want the new name, date of birth and sex and the old email", profile)
+	}
+
+	// A rejected change leaves every field as it was
+	if _, err := UpdateUserProfile(other.UserID, str("Johnny"), str("jane@example.com"), str("1990-01-01"), str("")); !errors.Is(err, ErrEmailTaken) {
+		t.Fatalf("UpdateUserProfile with a taken email = %v, want ErrEmailTaken", err)
+	}
+	if _, err := UpdateUserProfile(other.UserID, str("Johnny"), nil, str("tomorrow"), nil); !errors.Is(err, ErrInvalidUserData) {
+		t.Fatalf("UpdateUserProfile with a bad date = %v, want ErrInvalidUserData", err)
+	}
+	after, err := GetUserProfile(other.UserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.Name != "John Smith" || *after.DateOfBirth != "1980-05-17" || after.Sex == nil {
+		t.Fatalf("profile after rejected changes = %+v, want it unchanged", after)
+	}
+}
@@ -0,0 +1,4 @@
+-- migrations/000002_auth.down.sql
+-- Password hashes cannot be reverted to plain text, only the revocation table is dropped
+
+DROP TABLE IF EXISTS revoked_tokens;
@@ -0,0 +1,19 @@
+-- migrations/000002_auth.up.sql
+
+-- pgcrypto's crypt() with a 'bf' salt produces bcrypt hashes compatible with golang.org/x/crypto/bcrypt
+CREATE EXTENSION IF NOT EXISTS pgcrypto;
+
+-- Rehash any password still stored in plain text (the seeded user)
+UPDATE users
+SET password = crypt(password, gen_salt('bf', 10))
+WHERE password NOT LIKE '$2a$%' AND password NOT LIKE '$2b$%' AND password NOT LIKE '$2y$%';
+
+CREATE TABLE IF NOT EXISTS revoked_tokens (
+    jti VARCHAR(64) PRIMARY KEY, -- JWT id of the revoked access or refresh token
+    user_id INTEGER NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    token_type VARCHAR(20) NOT NULL CHECK (token_type IN ('access', 'refresh')),
+    expires_at TIMESTAMP NOT NULL, -- Rows can be purged once the token would have expired anyway
+    revoked_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_revoked_tokens_expires_at ON revoked_tokens(expires_at);
@@ -0,0 +1,5 @@
+-- migrations/000003_roles.down.sql
+
+DROP INDEX IF EXISTS idx_assessments_physio_id;
+ALTER TABLE assessments DROP COLUMN IF EXISTS physio_id;
+ALTER TABLE users DROP COLUMN IF EXISTS role;
@@ -0,0 +1,11 @@
+-- migrations/000003_roles.up.sql
+
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS role VARCHAR(20) NOT NULL DEFAULT 'patient'
+    CHECK (role IN ('patient', 'physiotherapist', 'admin'));
+
+-- Physiotherapist responsible for reviewing the assessment
+ALTER TABLE assessments
+    ADD COLUMN IF NOT EXISTS physio_id INTEGER REFERENCES users(user_id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_assessments_physio_id ON assessments(physio_id);
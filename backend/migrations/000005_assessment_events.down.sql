@@ -0,0 +1,3 @@
+-- migrations/000005_assessment_events.down.sql
+
+DROP TABLE IF EXISTS assessment_events;
@@ -0,0 +1,24 @@
+-- migrations/000005_assessment_events.up.sql
+
+-- Lifecycle history of an assessment: creation, status transitions and finished phases
+CREATE TABLE IF NOT EXISTS assessment_events (
+    event_id SERIAL PRIMARY KEY,
+    assessment_id INTEGER NOT NULL REFERENCES assessments(assessment_id) ON DELETE CASCADE,
+    event_type VARCHAR(30) NOT NULL CHECK (event_type IN ('created', 'status_changed', 'phase_completed')),
+    from_status VARCHAR(50),
+    to_status VARCHAR(50),
+    phase VARCHAR(30) CHECK (phase IN ('bpi_chat', 'questionnaire', 'rom', 'dashboard')),
+    details JSONB,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_assessment_events_assessment_id ON assessment_events(assessment_id, created_at);
+
+-- A phase is only recorded once per assessment
+CREATE UNIQUE INDEX IF NOT EXISTS assessment_events_phase_key ON assessment_events(assessment_id, phase) WHERE event_type = 'phase_completed';
+
+-- Backfill a creation event for existing assessments
+INSERT INTO assessment_events (assessment_id, event_type, to_status, created_at)
+SELECT a.assessment_id, 'created', 'started', COALESCE(a.start_time, CURRENT_TIMESTAMP)
+FROM assessments a
+WHERE NOT EXISTS (SELECT 1 FROM assessment_events e WHERE e.assessment_id = a.assessment_id AND e.event_type = 'created');
@@ -0,0 +1,4 @@
+-- migrations/000006_assessment_activity.down.sql
+
+DROP INDEX IF EXISTS idx_assessments_open_activity;
+ALTER TABLE assessments DROP COLUMN IF EXISTS last_activity_at;
@@ -0,0 +1,17 @@
+-- migrations/000006_assessment_activity.up.sql
+
+-- Last chat, questionnaire or ROM activity, used to abandon stale assessments
+ALTER TABLE assessments ADD COLUMN IF NOT EXISTS last_activity_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP;
+
+-- Backfill from the rows written so far, GREATEST ignores NULLs
+UPDATE assessments a
+SET last_activity_at = COALESCE(GREATEST(
+    a.start_time,
+    (SELECT MAX(q.created_at) FROM questionnaires q WHERE q.assessment_id = a.assessment_id),
+    (SELECT MAX(r.created_at) FROM rom_analysis r WHERE r.assessment_id = a.assessment_id),
+    (SELECT MAX(e.created_at) FROM assessment_events e WHERE e.assessment_id = a.assessment_id)
+), a.last_activity_at);
+
+-- The sweeper only looks at open assessments
+CREATE INDEX IF NOT EXISTS idx_assessments_open_activity ON assessments(last_activity_at)
+WHERE status IN ('started', 'in_progress');
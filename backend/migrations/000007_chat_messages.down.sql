@@ -0,0 +1,3 @@
+-- migrations/000007_chat_messages.down.sql
+
+DROP TABLE IF EXISTS chat_messages;
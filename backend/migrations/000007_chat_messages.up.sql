@@ -0,0 +1,25 @@
+-- migrations/000007_chat_messages.up.sql
+
+-- One row per turn of the BPI chat, so a conversation can be resumed from the server
+CREATE TABLE IF NOT EXISTS chat_messages (
+    message_id SERIAL PRIMARY KEY,
+    assessment_id INTEGER NOT NULL REFERENCES assessments(assessment_id) ON DELETE CASCADE,
+    role VARCHAR(20) NOT NULL CHECK (role IN ('user', 'assistant')),
+    content TEXT NOT NULL,
+    action VARCHAR(30), -- AI action of an assistant turn, e.g. continue or next_api
+    latency_ms INTEGER CHECK (latency_ms >= 0), -- AI response time of an assistant turn
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_chat_messages_assessment_id ON chat_messages(assessment_id, message_id);
+
+-- Backfill the histories saved in assessments.chat_history at the end of a chat
+INSERT INTO chat_messages (assessment_id, role, content, created_at)
+SELECT a.assessment_id, m.role, m.content, COALESCE(a.start_time, CURRENT_TIMESTAMP)
+FROM assessments a
+CROSS JOIN LATERAL jsonb_array_elements(a.chat_history -> 'chat_history') WITH ORDINALITY AS t(turn, n)
+CROSS JOIN LATERAL (VALUES (1, 'user', t.turn ->> 'user'), (2, 'assistant', t.turn ->> 'response')) AS m(pos, role, content)
+WHERE jsonb_typeof(a.chat_history -> 'chat_history') = 'array'
+  AND COALESCE(m.content, '') <> ''
+  AND NOT EXISTS (SELECT 1 FROM chat_messages c WHERE c.assessment_id = a.assessment_id)
+ORDER BY a.assessment_id, t.n, m.pos;
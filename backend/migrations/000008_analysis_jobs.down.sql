@@ -0,0 +1,3 @@
+-- migrations/000008_analysis_jobs.down.sql
+
+DROP TABLE IF EXISTS analysis_jobs;
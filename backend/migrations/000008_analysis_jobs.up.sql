@@ -0,0 +1,27 @@
+-- migrations/000008_analysis_jobs.up.sql
+
+-- Dashboard analyses run by the background workers. The input is snapshotted at
+-- enqueue time and revision is its hash, so each revision is analysed once.
+CREATE TABLE IF NOT EXISTS analysis_jobs (
+    job_id SERIAL PRIMARY KEY,
+    assessment_id INTEGER NOT NULL REFERENCES assessments(assessment_id) ON DELETE CASCADE,
+    revision VARCHAR(64) NOT NULL, -- sha256 of input
+    input JSONB NOT NULL, -- dashboard data sent to the AI
+    status VARCHAR(20) NOT NULL DEFAULT 'queued' CHECK (status IN ('queued', 'running', 'succeeded', 'failed')),
+    attempts INTEGER NOT NULL DEFAULT 0 CHECK (attempts >= 0),
+    max_attempts INTEGER NOT NULL CHECK (max_attempts >= 1),
+    run_after TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP, -- next attempt of a queued job
+    locked_at TIMESTAMP, -- start of the current attempt of a running job
+    last_error TEXT,
+    analysis_id INTEGER REFERENCES ai_analysis(analysis_id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    finished_at TIMESTAMP,
+    CONSTRAINT analysis_jobs_revision_key UNIQUE (assessment_id, revision)
+);
+
+-- Workers claim the oldest due job, or a running one whose worker died
+CREATE INDEX IF NOT EXISTS idx_analysis_jobs_claimable ON analysis_jobs(run_after)
+WHERE status IN ('queued', 'running');
+
+CREATE INDEX IF NOT EXISTS idx_analysis_jobs_assessment_id ON analysis_jobs(assessment_id, job_id);
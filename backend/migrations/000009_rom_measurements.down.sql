@@ -0,0 +1,3 @@
+-- migrations/000009_rom_measurements.down.sql
+
+DROP TABLE IF EXISTS rom_measurements;
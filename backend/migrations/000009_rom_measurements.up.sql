@@ -0,0 +1,22 @@
+-- migrations/000009_rom_measurements.up.sql
+
+-- One row per joint movement of a ROM submission. pose_model_data keeps the submitted
+-- payload, these rows make the measurements queryable per joint and movement.
+CREATE TABLE IF NOT EXISTS rom_measurements (
+    measurement_id SERIAL PRIMARY KEY,
+    rom_id INTEGER NOT NULL REFERENCES rom_analysis(rom_id) ON DELETE CASCADE,
+    anatomy_id INTEGER NOT NULL REFERENCES anatomy(anatomy_id) ON DELETE CASCADE,
+    movement VARCHAR(32) NOT NULL CHECK (movement IN ('flexion', 'extension', 'abduction', 'adduction',
+        'internal_rotation', 'external_rotation', 'lateral_flexion', 'rotation')),
+    side VARCHAR(16) CHECK (side IN ('left', 'right', 'bilateral')), -- NULL when the joint is not lateral
+    mode VARCHAR(16) NOT NULL DEFAULT 'active' CHECK (mode IN ('active', 'passive')),
+    min_angle DOUBLE PRECISION NOT NULL, -- degrees
+    max_angle DOUBLE PRECISION NOT NULL,
+    peak_angle DOUBLE PRECISION,
+    repetitions INTEGER CHECK (repetitions >= 1),
+    pain_onset_angle DOUBLE PRECISION,
+    CONSTRAINT rom_measurements_angles_check CHECK (min_angle <= max_angle)
+);
+
+CREATE INDEX IF NOT EXISTS idx_rom_measurements_rom_id ON rom_measurements(rom_id);
+CREATE INDEX IF NOT EXISTS idx_rom_measurements_anatomy_movement ON rom_measurements(anatomy_id, movement);
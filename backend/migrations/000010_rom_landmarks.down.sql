@@ -0,0 +1,6 @@
+-- migrations/000010_rom_landmarks.down.sql
+
+ALTER TABLE rom_analysis
+    DROP COLUMN IF EXISTS derived_metrics,
+    DROP COLUMN IF EXISTS landmark_series,
+    DROP COLUMN IF EXISTS source;
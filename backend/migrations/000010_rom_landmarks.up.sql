@@ -0,0 +1,8 @@
+-- migrations/000010_rom_landmarks.up.sql
+
+-- ROM computed on the server keeps the landmark stream it came from and the angle
+-- series derived from it, so it can be audited and recomputed.
+ALTER TABLE rom_analysis
+    ADD COLUMN IF NOT EXISTS source VARCHAR(16) NOT NULL DEFAULT 'client' CHECK (source IN ('client', 'landmarks')),
+    ADD COLUMN IF NOT EXISTS landmark_series JSONB, -- submitted frames, NULL when the client sent angles
+    ADD COLUMN IF NOT EXISTS derived_metrics JSONB; -- angle series and frame counts per recording
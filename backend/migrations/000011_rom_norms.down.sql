@@ -0,0 +1,5 @@
+-- migrations/000011_rom_norms.down.sql
+
+DROP TABLE IF EXISTS rom_norms;
+ALTER TABLE users DROP COLUMN IF EXISTS sex;
+ALTER TABLE users DROP COLUMN IF EXISTS date_of_birth;
@@ -0,0 +1,50 @@
+-- migrations/000011_rom_norms.up.sql
+
+-- Age and sex select the normative ROM range a patient is compared with
+ALTER TABLE users ADD COLUMN IF NOT EXISTS date_of_birth DATE;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS sex VARCHAR(16) CHECK (sex IN ('female', 'male'));
+
+-- Normal range of a movement per age band and sex. A band applies to ages age_min to
+-- age_max inclusive, sex 'any' to patients of either sex or of unknown sex.
+CREATE TABLE IF NOT EXISTS rom_norms (
+    norm_id SERIAL PRIMARY KEY,
+    anatomy_id INTEGER NOT NULL REFERENCES anatomy(anatomy_id) ON DELETE CASCADE,
+    movement VARCHAR(32) NOT NULL CHECK (movement IN ('flexion', 'extension', 'abduction', 'adduction',
+        'internal_rotation', 'external_rotation', 'lateral_flexion', 'rotation')),
+    sex VARCHAR(16) NOT NULL DEFAULT 'any' CHECK (sex IN ('any', 'female', 'male')),
+    age_min INTEGER NOT NULL DEFAULT 0 CHECK (age_min >= 0),
+    age_max INTEGER NOT NULL DEFAULT 150,
+    normal_min DOUBLE PRECISION NOT NULL, -- degrees
+    normal_max DOUBLE PRECISION NOT NULL,
+    source TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT rom_norms_ages_check CHECK (age_min <= age_max),
+    CONSTRAINT rom_norms_range_check CHECK (normal_min < normal_max),
+    CONSTRAINT rom_norms_band_key UNIQUE (anatomy_id, movement, sex, age_min, age_max)
+);
+
+-- Adult averages for the seeded anatomy, with lower ranges from 60
+INSERT INTO rom_norms (anatomy_id, movement, sex, age_min, age_max, normal_min, normal_max, source)
+SELECT a.anatomy_id, v.movement, 'any', v.age_min, v.age_max, v.normal_min, v.normal_max, v.source
+FROM (VALUES
+    ('Shoulder', 'flexion', 0, 150, 0, 180, 'AAOS'),
+    ('Shoulder', 'extension', 0, 150, 0, 60, 'AAOS'),
+    ('Shoulder', 'abduction', 0, 150, 0, 180, 'AAOS'),
+    ('Shoulder', 'adduction', 0, 150, 0, 45, 'AAOS'),
+    ('Shoulder', 'internal_rotation', 0, 150, 0, 70, 'AAOS'),
+    ('Shoulder', 'external_rotation', 0, 150, 0, 90, 'AAOS'),
+    ('Shoulder', 'flexion', 60, 150, 0, 165, 'age adjusted estimate'),
+    ('Shoulder', 'abduction', 60, 150, 0, 160, 'age adjusted estimate'),
+    ('Shoulder', 'external_rotation', 60, 150, 0, 80, 'age adjusted estimate'),
+    ('Knee', 'flexion', 0, 150, 0, 135, 'AAOS'),
+    ('Knee', 'flexion', 60, 150, 0, 130, 'age adjusted estimate'),
+    ('Lower Back', 'flexion', 0, 150, 0, 80, 'AAOS thoracolumbar'),
+    ('Lower Back', 'extension', 0, 150, 0, 25, 'AAOS thoracolumbar'),
+    ('Lower Back', 'lateral_flexion', 0, 150, 0, 35, 'AAOS thoracolumbar'),
+    ('Lower Back', 'rotation', 0, 150, 0, 45, 'AAOS thoracolumbar'),
+    ('Lower Back', 'flexion', 60, 150, 0, 70, 'age adjusted estimate'),
+    ('Lower Back', 'extension', 60, 150, 0, 20, 'age adjusted estimate'),
+    ('Lower Back', 'lateral_flexion', 60, 150, 0, 30, 'age adjusted estimate')
+) AS v(name, movement, age_min, age_max, normal_min, normal_max, source)
+JOIN anatomy a ON a.name = v.name
+ON CONFLICT (anatomy_id, movement, sex, age_min, age_max) DO NOTHING;
@@ -0,0 +1,17 @@
+-- migrations/000012_physio_call_lifecycle.down.sql
+
+DROP INDEX IF EXISTS idx_physio_calls_assessment_id;
+DROP INDEX IF EXISTS idx_physio_calls_physio_id;
+ALTER TABLE physio_calls DROP COLUMN IF EXISTS updated_at;
+ALTER TABLE physio_calls DROP COLUMN IF EXISTS status_reason;
+ALTER TABLE physio_calls DROP COLUMN IF EXISTS ended_at;
+ALTER TABLE physio_calls DROP COLUMN IF EXISTS started_at;
+ALTER TABLE physio_calls DROP CONSTRAINT IF EXISTS physio_calls_duration_check;
+ALTER TABLE physio_calls DROP COLUMN IF EXISTS duration_minutes;
+ALTER TABLE physio_calls DROP COLUMN IF EXISTS physio_id;
+
+UPDATE physio_calls SET call_status = 'scheduled' WHERE call_status = 'in_progress';
+UPDATE physio_calls SET call_status = 'cancelled' WHERE call_status = 'no_show';
+ALTER TABLE physio_calls DROP CONSTRAINT IF EXISTS physio_calls_call_status_check;
+ALTER TABLE physio_calls ADD CONSTRAINT physio_calls_call_status_check
+    CHECK (call_status IN ('scheduled', 'completed', 'cancelled'));
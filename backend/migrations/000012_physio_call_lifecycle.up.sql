@@ -0,0 +1,23 @@
+-- migrations/000012_physio_call_lifecycle.up.sql
+
+-- A call runs scheduled -> in_progress -> completed, and can end as cancelled or no_show
+ALTER TABLE physio_calls DROP CONSTRAINT IF EXISTS physio_calls_call_status_check;
+ALTER TABLE physio_calls ADD CONSTRAINT physio_calls_call_status_check
+    CHECK (call_status IN ('scheduled', 'in_progress', 'completed', 'cancelled', 'no_show'));
+
+-- Physiotherapist taking the call and the slot it occupies
+ALTER TABLE physio_calls
+    ADD COLUMN IF NOT EXISTS physio_id INTEGER REFERENCES users(user_id) ON DELETE SET NULL;
+ALTER TABLE physio_calls ADD COLUMN IF NOT EXISTS duration_minutes INTEGER NOT NULL DEFAULT 30;
+ALTER TABLE physio_calls DROP CONSTRAINT IF EXISTS physio_calls_duration_check;
+ALTER TABLE physio_calls ADD CONSTRAINT physio_calls_duration_check
+    CHECK (duration_minutes BETWEEN 5 AND 240);
+
+ALTER TABLE physio_calls ADD COLUMN IF NOT EXISTS started_at TIMESTAMP;
+ALTER TABLE physio_calls ADD COLUMN IF NOT EXISTS ended_at TIMESTAMP;
+ALTER TABLE physio_calls ADD COLUMN IF NOT EXISTS status_reason TEXT; -- why a call was cancelled or missed
+ALTER TABLE physio_calls ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP;
+
+-- Overlap checks look up the open calls of a physiotherapist around a time
+CREATE INDEX IF NOT EXISTS idx_physio_calls_physio_id ON physio_calls(physio_id, scheduled_time);
+CREATE INDEX IF NOT EXISTS idx_physio_calls_assessment_id ON physio_calls(assessment_id);
@@ -0,0 +1,4 @@
+-- migrations/000013_physio_availability.down.sql
+
+DROP TABLE IF EXISTS physio_availability_exceptions;
+DROP TABLE IF EXISTS physio_availability;
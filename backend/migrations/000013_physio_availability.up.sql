@@ -0,0 +1,33 @@
+-- migrations/000013_physio_availability.up.sql
+
+-- Recurring weekly windows in which a physiotherapist takes calls. Times are minutes after
+-- local midnight in time_zone, an IANA zone name, so windows follow daylight saving time.
+-- weekday counts from 0 for Sunday.
+CREATE TABLE IF NOT EXISTS physio_availability (
+    window_id SERIAL PRIMARY KEY,
+    physio_id INTEGER NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    weekday SMALLINT NOT NULL CHECK (weekday BETWEEN 0 AND 6),
+    start_minute INTEGER NOT NULL CHECK (start_minute BETWEEN 0 AND 1439),
+    end_minute INTEGER NOT NULL CHECK (end_minute BETWEEN 1 AND 1440),
+    time_zone VARCHAR(64) NOT NULL DEFAULT 'UTC',
+    slot_minutes INTEGER NOT NULL DEFAULT 30 CHECK (slot_minutes BETWEEN 5 AND 240),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT physio_availability_window_check CHECK (start_minute < end_minute)
+);
+
+CREATE INDEX IF NOT EXISTS idx_physio_availability_physio_id ON physio_availability(physio_id, weekday);
+
+-- One-off changes to the weekly windows: time off when available is false, an extra
+-- window when it is true. starts_at and ends_at are UTC.
+CREATE TABLE IF NOT EXISTS physio_availability_exceptions (
+    exception_id SERIAL PRIMARY KEY,
+    physio_id INTEGER NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    starts_at TIMESTAMP NOT NULL,
+    ends_at TIMESTAMP NOT NULL,
+    available BOOLEAN NOT NULL DEFAULT FALSE,
+    reason TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT physio_availability_exceptions_range_check CHECK (starts_at < ends_at)
+);
+
+CREATE INDEX IF NOT EXISTS idx_physio_availability_exceptions_physio_id ON physio_availability_exceptions(physio_id, starts_at);
@@ -0,0 +1,4 @@
+-- migrations/000014_calendar_feeds.down.sql
+
+DROP TABLE IF EXISTS calendar_feed_tokens;
+ALTER TABLE physio_calls DROP COLUMN IF EXISTS calendar_sequence;
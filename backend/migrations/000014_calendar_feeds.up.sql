@@ -0,0 +1,19 @@
+-- migrations/000014_calendar_feeds.up.sql
+
+-- SEQUENCE of the iCalendar event of a call, raised by every reschedule, reassignment and
+-- status change so calendar clients replace their copy
+ALTER TABLE physio_calls ADD COLUMN IF NOT EXISTS calendar_sequence INTEGER NOT NULL DEFAULT 0;
+
+-- Secrets in the URL of a user's calendar feed. Only a SHA-256 of the token is stored, the
+-- token itself is shown once when it is created. A revoked token stops the feed for good.
+CREATE TABLE IF NOT EXISTS calendar_feed_tokens (
+    token_id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    label VARCHAR(100),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_used_at TIMESTAMP,
+    revoked_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_calendar_feed_tokens_user_id ON calendar_feed_tokens(user_id);
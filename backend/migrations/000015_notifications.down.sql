@@ -0,0 +1,3 @@
+-- migrations/000015_notifications.down.sql
+
+DROP TABLE IF EXISTS notification_outbox;
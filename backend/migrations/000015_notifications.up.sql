@@ -0,0 +1,33 @@
+-- migrations/000015_notifications.up.sql
+
+-- Outbox of the notification dispatcher. A message is rendered when it is queued, one row
+-- per recipient and channel, and delivered with retries by the background dispatcher.
+-- dedupe_key names the event, so a reminder or alert is queued once however often its
+-- trigger fires.
+CREATE TABLE IF NOT EXISTS notification_outbox (
+    notification_id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(user_id) ON DELETE CASCADE, -- recipient
+    kind VARCHAR(32) NOT NULL CHECK (kind IN ('call_reminder', 'critical_flag', 'assessment_abandoned')),
+    channel VARCHAR(16) NOT NULL CHECK (channel IN ('email', 'webhook', 'log')),
+    dedupe_key VARCHAR(255) NOT NULL UNIQUE,
+    assessment_id INTEGER REFERENCES assessments(assessment_id) ON DELETE CASCADE,
+    call_id INTEGER REFERENCES physio_calls(call_id) ON DELETE CASCADE, -- set on call reminders
+    address VARCHAR(255), -- email address of the recipient when queued
+    subject TEXT NOT NULL,
+    body TEXT NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'sending', 'sent', 'failed', 'skipped')),
+    attempts INTEGER NOT NULL DEFAULT 0 CHECK (attempts >= 0),
+    max_attempts INTEGER NOT NULL CHECK (max_attempts >= 1),
+    run_after TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP, -- next attempt of a pending message
+    locked_at TIMESTAMP, -- start of the current attempt of a sending message
+    last_error TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    sent_at TIMESTAMP
+);
+
+-- The dispatcher claims the oldest due message, or a sending one whose attempt died
+CREATE INDEX IF NOT EXISTS idx_notification_outbox_claimable ON notification_outbox(run_after)
+WHERE status IN ('pending', 'sending');
+
+CREATE INDEX IF NOT EXISTS idx_notification_outbox_user_id ON notification_outbox(user_id, notification_id);
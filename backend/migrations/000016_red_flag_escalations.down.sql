@@ -0,0 +1,3 @@
+-- migrations/000016_red_flag_escalations.down.sql
+
+DROP TABLE IF EXISTS red_flag_escalations;
@@ -0,0 +1,14 @@
+-- migrations/000016_red_flag_escalations.up.sql
+
+-- Red flags found by the rule engine in the answers and the AI analysis of an assessment.
+-- An assessment is escalated once: a later analysis that matches again refreshes the matches
+-- and keeps the physio call booked for the first one.
+CREATE TABLE IF NOT EXISTS red_flag_escalations (
+    escalation_id SERIAL PRIMARY KEY,
+    assessment_id INTEGER NOT NULL UNIQUE REFERENCES assessments(assessment_id) ON DELETE CASCADE,
+    rules_version VARCHAR(64) NOT NULL, -- version of the rules file that matched
+    matches JSONB NOT NULL, -- rule, source and matched text of every hit
+    call_id INTEGER REFERENCES physio_calls(call_id) ON DELETE SET NULL, -- immediate call booked by the system
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
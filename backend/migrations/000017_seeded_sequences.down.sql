@@ -0,0 +1,3 @@
+-- migrations/000017_seeded_sequences.down.sql
+
+-- Nothing to undo: rewinding the sequences would hand out ids that are in use
@@ -0,0 +1,8 @@
+-- migrations/000017_seeded_sequences.up.sql
+
+-- 000001 seeds users, anatomy and assessments with explicit ids, which does not advance their
+-- SERIAL sequences, so the first insert without an id collided with a seeded row. Each
+-- sequence is moved past the highest id, a later nextval returns MAX + 1.
+SELECT setval(pg_get_serial_sequence('users', 'user_id'), COALESCE(MAX(user_id), 1), MAX(user_id) IS NOT NULL) FROM users;
+SELECT setval(pg_get_serial_sequence('anatomy', 'anatomy_id'), COALESCE(MAX(anatomy_id), 1), MAX(anatomy_id) IS NOT NULL) FROM anatomy;
+SELECT setval(pg_get_serial_sequence('assessments', 'assessment_id'), COALESCE(MAX(assessment_id), 1), MAX(assessment_id) IS NOT NULL) FROM assessments;
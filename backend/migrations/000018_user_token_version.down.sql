@@ -0,0 +1,3 @@
+-- migrations/000018_user_token_version.down.sql
+
+ALTER TABLE users DROP COLUMN IF EXISTS token_version;
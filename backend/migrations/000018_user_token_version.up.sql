@@ -0,0 +1,5 @@
+-- migrations/000018_user_token_version.up.sql
+
+-- Every token carries the version it was signed with, bumping it on a password change or
+-- deletion signs out every session of the user at once
+ALTER TABLE users ADD COLUMN IF NOT EXISTS token_version INTEGER NOT NULL DEFAULT 0;